@@ -36,6 +36,9 @@ type Routes struct {
 	Orders						*mux.Router // 'api/v1/orders'
 	Order 						*mux.Router // 'api/v1/orders/{order_id:[A-Za-z0-9_-]+}
 
+	Customers					*mux.Router // 'api/v1/customers'
+	Customer					*mux.Router // 'api/v1/customers/{customer_id:[A-Za-z0-9]+}'
+
 	Elasticsearch 				*mux.Router // 'api/v1/elasticsearch'
 
 	FacebookUsers        		*mux.Router // 'api/v1/facebookusers'
@@ -44,6 +47,8 @@ type Routes struct {
 	Fanpages        			*mux.Router // 'api/v1/fanpages'
 	FanpagesForUser 			*mux.Router // 'api/v1/users/{user_id:[A-Za-z0-9]+}/fanpages'
 	Fanpage         			*mux.Router // 'api/v1/fanpages/{fanpage_id:[A-Za-z0-9]+}'
+	FanpageMembers				*mux.Router // 'api/v1/fanpages/{page_id:[A-Za-z0-9]+}/members'
+	FanpageMember				*mux.Router // 'api/v1/fanpages/{page_id:[A-Za-z0-9]+}/members/{user_id:[A-Za-z0-9]+}'
 
 	Posts 						*mux.Router // 'api/v1/posts
 	Post 						*mux.Router // 'api/v1/posts/{post_id:[A-Za-z0-9]+}'
@@ -57,6 +62,8 @@ type Routes struct {
 	PageTags 					*mux.Router // 'api/v1/fanpages/tags'
 	PageTag 					*mux.Router // 'api/v1/fanpages/{page_tag_id:[A-Za-z0-9]+}'
 
+	AutoMessageTask 			*mux.Router // 'api/v1/fanpages/{page_id:[A-Za-z0-9]+}/auto_message_tasks/{task_id:[A-Za-z0-9]+}'
+
 	Roles   					*mux.Router // 'api/v1/roles'
 	Schemes 					*mux.Router // 'api/v1/schemes'
 
@@ -112,11 +119,16 @@ func Init(configservice configservice.ConfigService, globalOptionsFunc app.AppOp
 	api.BaseRoutes.Fanpages = api.BaseRoutes.ApiRoot.PathPrefix("/fanpages").Subrouter()
 	api.BaseRoutes.FanpagesForUser = api.BaseRoutes.User.PathPrefix("/fanpages").Subrouter()
 	api.BaseRoutes.Fanpage = api.BaseRoutes.Fanpages.PathPrefix("/{page_id:[A-Za-z0-9]+}").Subrouter()
+	api.BaseRoutes.FanpageMembers = api.BaseRoutes.Fanpage.PathPrefix("/members").Subrouter()
+	api.BaseRoutes.FanpageMember = api.BaseRoutes.FanpageMembers.PathPrefix("/{user_id:[A-Za-z0-9]+}").Subrouter()
 
 	// orders
 	api.BaseRoutes.Orders = api.BaseRoutes.ApiRoot.PathPrefix("/orders").Subrouter()
 	api.BaseRoutes.Order = api.BaseRoutes.Orders.PathPrefix("/{order_id:[A-Za-z0-9]+}").Subrouter()
 
+	api.BaseRoutes.Customers = api.BaseRoutes.ApiRoot.PathPrefix("/customers").Subrouter()
+	api.BaseRoutes.Customer = api.BaseRoutes.Customers.PathPrefix("/{customer_id:[A-Za-z0-9]+}").Subrouter()
+
 	// posts
 	//api.BaseRoutes.Posts = api.BaseRoutes.ApiRoot.PathPrefix("/posts").Subrouter()
 	//api.BaseRoutes.Post = api.BaseRoutes.Fanpage.PathPrefix("/posts/{post_id:[A-Za-z0-9_-]+}").Subrouter()
@@ -139,6 +151,9 @@ func Init(configservice configservice.ConfigService, globalOptionsFunc app.AppOp
 	api.BaseRoutes.PageTags = api.BaseRoutes.Fanpage.PathPrefix("/tags").Subrouter()
 	api.BaseRoutes.PageTag = api.BaseRoutes.PageTags.PathPrefix("/{tag_id:[A-Za-z0-9]+}").Subrouter()
 
+	// auto message task
+	api.BaseRoutes.AutoMessageTask = api.BaseRoutes.Fanpage.PathPrefix("/auto_message_tasks/{task_id:[A-Za-z0-9]+}").Subrouter()
+
 	api.BaseRoutes.ConversationNote = api.BaseRoutes.Conversation.PathPrefix("/{note_id:[A-Za-z0-9]+}").Subrouter()
 	// test
 	api.BaseRoutes.Test = api.BaseRoutes.ApiRoot.PathPrefix("/test").Subrouter()
@@ -172,6 +187,7 @@ func Init(configservice configservice.ConfigService, globalOptionsFunc app.AppOp
 	api.InitPageTag()
 	api.InitConversationTag()
 	api.InitConversationNote()
+	api.InitModerationRule()
 	api.InitPreference()
 	api.InitWebSocket()
 	api.InitRole()
@@ -180,8 +196,14 @@ func Init(configservice configservice.ConfigService, globalOptionsFunc app.AppOp
 	api.InitStatus()
 	api.InitElasticsearch()
 	api.InitOrders()
+	api.InitProducts()
+	api.InitCustomers()
+	api.InitFanpageMembers()
+	api.InitConversationAssignment()
+	api.InitConversationStatus()
+	api.InitAutoMessageTask()
 	api.InitOpenGraph()
-	//api.InitWebHooks()
+	api.InitWebHooks()
 
 	root.Handle("/api/v1/{anything:.*}", http.HandlerFunc(api.Handle404))
 	return api
@@ -3,7 +3,6 @@ package api1
 import (
 	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"bitbucket.org/enesyteam/papo-server/model"
-	"fmt"
 	"net/http"
 )
 
@@ -20,18 +19,21 @@ func getFacebookCommentAttachments(c *Context, w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// check if client sent a query with page access token
-	var pageAccessToken string
-	query := r.URL.Query()
-	if len(query.Get("page_access_token")) > 0 {
-		pageAccessToken = query.Get("page_access_token")
-		//fmt.Println(pageAccessToken)
-	} else {
-		fmt.Println("must get page access token")
+	// comment id không chứa page id nên client phải gửi kèm page_id, page token do server quản lý
+	pageId := r.URL.Query().Get("page_id")
+	if len(pageId) == 0 {
+		c.SetInvalidUrlParam("page_id")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, pageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
 	}
 
-	if len(pageAccessToken) == 0 {
-		c.Err = model.NewAppError("getFacebookAttachmentTargetByIds", "api.get_facebook_attachment_target_by_ids.app_error", nil, "", http.StatusUnauthorized)
+	pageAccessToken, err := c.App.GetPageAccessToken(pageId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
 		return
 	}
 
@@ -61,18 +63,14 @@ func getFacebookMessageAttachments(c *Context, w http.ResponseWriter, r *http.Re
 		return
 	}
 
-	// check if client sent a query with page access token
-	var pageAccessToken string
-	query := r.URL.Query()
-	if len(query.Get("page_access_token")) > 0 {
-		pageAccessToken = query.Get("page_access_token")
-		//fmt.Println(pageAccessToken)
-	} else {
-		fmt.Println("must get page access token")
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
 	}
 
-	if len(pageAccessToken) == 0 {
-		c.Err = model.NewAppError("getFacebookAttachmentTargetByIds", "api.get_facebook_attachment_target_by_ids.app_error", nil, "", http.StatusUnauthorized)
+	pageAccessToken, err := c.App.GetPageAccessToken(c.Params.PageId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
 		return
 	}
 
@@ -91,8 +89,6 @@ func getFacebookMessageAttachments(c *Context, w http.ResponseWriter, r *http.Re
 }
 
 func getFacebookAttachmentTargetByIds(c *Context, w http.ResponseWriter, r *http.Request) {
-	// No permission check required
-
 	ids := model.ArrayFromJson(r.Body)
 
 	if len(ids) == 0 {
@@ -100,6 +96,17 @@ func getFacebookAttachmentTargetByIds(c *Context, w http.ResponseWriter, r *http
 		return
 	}
 
+	ids, err := c.App.FilterAttachmentIdsForSession(c.App.Session, ids)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	if len(ids) == 0 {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	if result := <-c.App.Srv.Store.User().GetById(c.App.Session.UserId); result.Err != nil {
 		w.WriteHeader(http.StatusBadRequest)
 		w.Write([]byte(result.Err.ToJson()))
@@ -140,7 +147,16 @@ func getFacebookAttachmentByIds(c *Context, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
-	// No permission check required
+	ids, err := c.App.FilterAttachmentIdsForSession(c.App.Session, ids)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	if len(ids) == 0 {
+		w.Write([]byte(model.FacebookAttachmentImageListToJson([]*model.FacebookAttachmentImage{})))
+		return
+	}
 
 	attachments, err := c.App.GetFacebookAttachmentByIds(ids)
 	if err != nil {
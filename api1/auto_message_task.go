@@ -0,0 +1,168 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (api *API) InitAutoMessageTask() {
+	api.BaseRoutes.AutoMessageTask.Handle("", api.ApiSessionRequired(getAutoMessageTask)).Methods("GET")
+	api.BaseRoutes.AutoMessageTask.Handle("", api.ApiSessionRequired(deleteAutoMessageTask)).Methods("DELETE")
+	api.BaseRoutes.AutoMessageTask.Handle("/progress", api.ApiSessionRequired(getAutoMessageTaskProgress)).Methods("GET")
+	api.BaseRoutes.AutoMessageTask.Handle("/recipients", api.ApiSessionRequired(getAutoMessageTaskRecipients)).Methods("GET")
+	api.BaseRoutes.AutoMessageTask.Handle("/start", api.ApiSessionRequired(startAutoMessageTask)).Methods("POST")
+	api.BaseRoutes.AutoMessageTask.Handle("/pause", api.ApiSessionRequired(pauseAutoMessageTask)).Methods("POST")
+	api.BaseRoutes.AutoMessageTask.Handle("/resume", api.ApiSessionRequired(resumeAutoMessageTask)).Methods("POST")
+	api.BaseRoutes.AutoMessageTask.Handle("/cancel", api.ApiSessionRequired(cancelAutoMessageTask)).Methods("POST")
+}
+
+// requireAutoMessageTask kiểm tra quyền trên page và chiến dịch thuộc page trong url
+func requireAutoMessageTask(c *Context, permission *model.Permission) *model.AutoMessageTask {
+	c.RequirePageId().RequireTaskId()
+	if c.Err != nil {
+		return nil
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, permission) {
+		c.SetPermissionError(permission)
+		return nil
+	}
+
+	task, err := c.App.GetAutoMessageTask(c.Params.TaskId)
+	if err != nil {
+		c.Err = err
+		return nil
+	}
+
+	if task.PageId != c.Params.PageId || task.DeleteAt > 0 {
+		c.Err = model.NewAppError("requireAutoMessageTask", "api.auto_message_task.page_mismatch.app_error", nil, "task_id="+task.Id+", page_id="+c.Params.PageId, http.StatusNotFound)
+		return nil
+	}
+
+	return task
+}
+
+func getAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	task := requireAutoMessageTask(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(task.ToJson()))
+}
+
+func deleteAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	if err := c.App.DeleteAutoMessageTask(c.Params.TaskId); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+func getAutoMessageTaskProgress(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	progress, err := c.App.GetAutoMessageTaskProgress(c.Params.TaskId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(progress.ToJson()))
+}
+
+// getAutoMessageTaskRecipients trả về kết quả gửi cho từng hội thoại, lọc theo ?status=sent|failed|skipped|queued
+func getAutoMessageTaskRecipients(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	switch status {
+	case "", model.AUTO_MESSAGE_RECIPIENT_STATUS_SENT, model.AUTO_MESSAGE_RECIPIENT_STATUS_FAILED, model.AUTO_MESSAGE_RECIPIENT_STATUS_SKIPPED, model.AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED:
+	default:
+		c.SetInvalidParam("status")
+		return
+	}
+
+	recipients, err := c.App.GetAutoMessageTaskRecipients(c.Params.TaskId, status, c.Params.Page, c.Params.PerPage)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.AutoMessageTaskRecipientsToJson(recipients)))
+}
+
+func startAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	task, err := c.App.StartAutoMessageTask(c.Params.TaskId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(task.ToJson()))
+}
+
+func pauseAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	task, err := c.App.PauseAutoMessageTask(c.Params.TaskId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(task.ToJson()))
+}
+
+func resumeAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	task, err := c.App.ResumeAutoMessageTask(c.Params.TaskId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(task.ToJson()))
+}
+
+func cancelAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	requireAutoMessageTask(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	task, err := c.App.CancelAutoMessageTask(c.Params.TaskId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(task.ToJson()))
+}
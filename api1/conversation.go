@@ -4,15 +4,12 @@
 package api1
 
 import (
-	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"bitbucket.org/enesyteam/papo-server/model"
-	"bitbucket.org/enesyteam/papo-server/utils"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
 	"net/http"
+	"net/url"
 	"strconv"
-	"time"
+	"strings"
 )
 
 func (api *API) InitFacebookConversation() {
@@ -25,14 +22,18 @@ func (api *API) InitFacebookConversation() {
 	api.BaseRoutes.Conversations.Handle("/search", api.ApiSessionRequired(searchConversations)).Methods("POST")
 	api.BaseRoutes.Conversation.Handle("/messages", api.ApiSessionRequired(addConversationMessage)).Methods("POST")
 
+	api.BaseRoutes.Conversation.Handle("/messaging_window", api.ApiSessionRequired(getConversationMessagingWindow)).Methods("GET")
 	api.BaseRoutes.Conversation.Handle("/reply", api.ApiSessionRequired(replyConversation)).Methods("POST")
-}
-
-type searchInput struct {
-	PageIds []string `json:"pageIds"`
-	Term 	string 		`json:"term"`
-	Limit 	int 		`json:"limit"`
-	Offset  int 		`json:"offset"`
+	api.BaseRoutes.Conversation.Handle("/messages/{message_id:[A-Za-z0-9]+}/retry", api.ApiSessionRequired(retryConversationMessage)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/messages/{message_id:[A-Za-z0-9]+}", api.ApiSessionRequired(cancelConversationMessage)).Methods("DELETE")
+	api.BaseRoutes.Conversation.Handle("/messages/{message_id:[A-Za-z0-9]+}/files", api.ApiSessionRequired(getConversationMessageFiles)).Methods("GET")
+
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}/hide", api.ApiSessionRequired(hideComment)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}/unhide", api.ApiSessionRequired(unhideComment)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}/like", api.ApiSessionRequired(likeComment)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}/unlike", api.ApiSessionRequired(unlikeComment)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}/private_replies", api.ApiSessionRequired(privateReplyComment)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/comments/{comment_id:[A-Za-z0-9_]+}", api.ApiSessionRequired(deleteComment)).Methods("DELETE")
 }
 
 func replyConversation(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -42,197 +43,92 @@ func replyConversation(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	// page, người nhận và thread được lấy từ hội thoại, các giá trị này trong body bị bỏ qua
 	message := model.ConversationReplyFromJson(r.Body)
-	if len(message.PageId) == 0 {
-		c.Err = model.NewAppError("api.reply", "api.reply_conversation.missing_page_token.app_error", nil, "", http.StatusBadRequest)
+	if message == nil {
+		c.SetInvalidParam("reply")
 		return
 	}
 
-	if len(message.PageToken) == 0 {
-		c.Err = model.NewAppError("api.reply", "api.reply_conversation.missing_page_token.app_error", nil, "", http.StatusBadRequest)
+	// tin nhắn được lưu với trạng thái pending và emit về client ngay, outbox sẽ gửi lên facebook sau,
+	// kết quả gửi được báo về qua websocket: conversation_add_message khi thành công, message_failed khi lỗi.
+	// page token do server quản lý, không nhận token từ client
+	conversationMessage, err := c.App.EnqueueConversationReply(c.Params.ConversationId, message, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
 		return
 	}
 
-	if len(message.UserToken) == 0 {
-		c.Err = model.NewAppError("api.reply", "api.reply_conversation.missing_user_token.app_error", nil, "", http.StatusBadRequest)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(conversationMessage.ToJson()))
+}
+
+func retryConversationMessage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	c.RequireMessageId()
+	if c.Err != nil {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
 
-	if message.Type == "comment" {
-		if len(message.CommentId) > 0 {
+	message, err := c.App.RetryOutboxMessage(c.Params.ConversationId, c.Params.MessageId)
+	if err != nil {
+		c.Err = err
+		return
+	}
 
-			// thêm conversation message và emit về user, sau đó mới post lên facebook,
-			// kết quả nhận được từ post facebook có 2 giai đoạn:
-			// 1: Sau khi post xong, thành công sẽ trả về comment_id
-			// 2: Webhook gửi về 1 kết quả sau khi post thành công
-			conversationMessage := &model.FacebookConversationMessage{
-				Type: "comment",
-				From: message.PageId,
-				PageId: message.PageId,
-				Message: message.Message,
-				ConversationId: c.Params.ConversationId,
-				CreatedTime: time.Now().Format("2006-01-02T15:04:05-0700"),
-			}
+	w.Write([]byte(message.ToJson()))
+}
 
-			conversationMessage.PreSave()
-
-			// thêm message vào database
-			var er *model.AppError
-			var rms *model.FacebookConversationMessage
-			if rms, _, er = c.App.AddMessage(conversationMessage, false, false, true); er != nil {
-				// TODO: làm gì với lỗi này?
-
-			} else {
-				// update conversation snippet
-				updateResult := <- c.App.Srv.Store.FacebookConversation().UpdateConversation(c.Params.ConversationId, utils.GetSnippet(message.Message), true, time.Now().Format("2006-01-02T15:04:05-0700"), 0, "")
-				if updateResult.Err != nil {
-					//mlog.Error(fmt.Sprintf("Couldn't update pages status err=%v", updateResult.Err))
-				}
-				// emit message to socket
-				m := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_ADD_MESSAGE, "", message.PageId, "", nil)
-				m.Add("page_id", message.PageId)
-				m.Add("message", rms)
-				c.App.Publish(m)
-			}
+func cancelConversationMessage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	c.RequireMessageId()
+	if c.Err != nil {
+		return
+	}
 
-			response, fErr, aErr := c.App.ReplyComment(message.CommentId, message)
-			if fErr != nil {
-				fmt.Println("fErr", fErr.Error)
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(fErr.Error.ToJson()))
-				return
-			} else if aErr != nil {
-				fmt.Println("aErr: ", aErr)
-				c.Err = aErr
-				return
-			} else {
-				var resp *facebookgraph.FacebookReplyCommentResponse
-				x, _ := ioutil.ReadAll(response)
-				json.Unmarshal([]byte(x), &resp)
-
-				// also add pending message id
-				resp.PendingMessageId = message.PendingMessageId
-				resp.ConversationId = c.Params.ConversationId
-
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(facebookgraph.FacebookReplyCommentResponseToJson(resp)))
-				return
-			}
-		}
-	} else if message.Type == "message" {
-		if len(message.ThreadId) > 0 {
-			// thêm conversation message và emit về user, sau đó mới post lên facebook,
-			// kết quả nhận được từ post facebook có 2 giai đoạn:
-			// 1: Sau khi post xong, thành công sẽ trả về comment_id
-			// 2: Webhook gửi về 1 kết quả sau khi post thành công
-			conversationMessage := &model.FacebookConversationMessage{
-				Type: "message",
-				From: message.PageId,
-				PageId: message.PageId,
-				Message: message.Message,
-				ConversationId: c.Params.ConversationId,
-				CreatedTime:  time.Now().Format("2006-01-02T15:04:05-0700"),
-				UserId: c.App.Session.UserId,
-			}
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
 
-			conversationMessage.PreSave()
-
-			var psId string
-			// Maybe need get page scope id, then update to conversation
-			if len(message.PageScopeId) == 0 {
-				// get match Page Scope Id from App Scope Id
-				psid, getPsIdErr, getAppErr := c.App.MatchPageScopeId(message.PageId, message.UserToken, message.To)
-				if getPsIdErr != nil {
-					w.WriteHeader(http.StatusBadRequest)
-					w.Write([]byte(getPsIdErr.Error.ToJson()))
-					return
-				}
-
-				if getAppErr != nil {
-					c.Err = getAppErr
-					return
-				}
-
-				psId = psid
-
-				// update conversation page_scope_id for later replying
-				updatedErr := c.App.UpdateConversationPageScopeId(c.Params.ConversationId, psid)
-				if updatedErr != nil {
-					// TODO: Log this err to mlog
-				}
-			} else {
-				psId = message.PageScopeId
-			}
+	if _, err := c.App.CancelOutboxMessage(c.Params.ConversationId, c.Params.MessageId, c.App.Session.UserId); err != nil {
+		c.Err = err
+		return
+	}
 
+	ReturnStatusOK(w)
+}
 
-			// thêm message vào database
-			var er *model.AppError
-			//var rms *model.FacebookConversationMessage
-			if _, _, er = c.App.AddMessage(conversationMessage, false, false, true); er != nil {
-				// TODO: làm gì với lỗi này?
+// getConversationMessageFiles trả về bản sao attachment đã lưu trên server, nội dung file lấy qua /files/{file_id}
+func getConversationMessageFiles(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	c.RequireMessageId()
+	if c.Err != nil {
+		return
+	}
 
-			} else {
-				// update conversation snippet
-				updateResult := <- c.App.Srv.Store.FacebookConversation().UpdateConversation(c.Params.ConversationId, utils.GetSnippet(message.Message), true, time.Now().Format("2006-01-02T15:04:05-0700"), 0, "")
-				if updateResult.Err != nil {
-					//mlog.Error(fmt.Sprintf("Couldn't update pages status err=%v", updateResult.Err))
-				}
-			}
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
 
-			response, fErr, aErr := c.App.ReplyMessage(message.ThreadId, psId, message)
-			if fErr != nil {
-				fmt.Println("fErr", fErr.Error)
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(fErr.Error.ToJson()))
-				return
-			} else if aErr != nil {
-				w.WriteHeader(http.StatusBadRequest)
-				w.Write([]byte(aErr.ToJson()))
-				return
-			} else {
-				var resp *facebookgraph.FacebookReplyCommentResponse
-				x, _ := ioutil.ReadAll(response)
-				json.Unmarshal([]byte(x), &resp)
-
-				// sau khi post facebook thanh cong, ket qua tra ve gom co message_id
-				// chung ta se cap nhat message_id
-				conversationMessage.MessageId = resp.MessageId
-				conversationMessage.Sent = true
-				overWriteMessageResult := <- c.App.Srv.Store.FacebookConversation().OverwriteMessage(conversationMessage)
-				if overWriteMessageResult.Err != nil {
-					fmt.Println("da co loi xay ra khi cap nhat message id")
-				} else {
-					var updatedMessage *model.FacebookConversationMessage
-					updatedMessage = overWriteMessageResult.Data.(*model.FacebookConversationMessage)
-					if updatedMessage != nil {
-						fmt.Println("da cap nhat messageId thanh cong: ", updatedMessage)
-						// emit message to socket
-						m := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_ADD_MESSAGE, "", message.PageId, "", nil)
-						m.Add("page_id", message.PageId)
-						m.Add("id", updatedMessage.Id)
-						m.Add("message", updatedMessage)
-						m.Add("pending_message_id", message.PendingMessageId)
-						c.App.Publish(m)
-					}
-				}
-
-
-				// also add pending message id
-				resp.PendingMessageId = message.PendingMessageId
-				resp.ConversationId = c.Params.ConversationId
-
-				w.WriteHeader(http.StatusOK)
-				w.Write([]byte(facebookgraph.FacebookReplyCommentResponseToJson(resp)))
-				return
-			}
-		}
-	} else {
-		//fmt.Println("missing type of reply")
-		err := model.NewAppError("replyConversation", "api.conversation.reply_conversation.token_not_found.app_error", nil, "", http.StatusUnauthorized)
-		w.WriteHeader(http.StatusUnauthorized)
-		w.Write([]byte(err.ToJson()))
+	infos, err := c.App.GetConversationMessageFiles(c.Params.ConversationId, c.Params.MessageId)
+	if err != nil {
+		c.Err = err
+		return
 	}
+
+	w.Write([]byte(model.FileInfosToJson(infos)))
 }
 
 func addConversationMessage(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -241,6 +137,11 @@ func addConversationMessage(c *Context, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
 	message := model.FacebookConversationMessageFromJson(r.Body)
 	if message == nil {
 		c.SetInvalidParam("Nội dung tin nhắn")
@@ -276,47 +177,25 @@ func addConversationMessage(c *Context, w http.ResponseWriter, r *http.Request)
 }
 
 func searchConversations(c *Context, w http.ResponseWriter, r *http.Request) {
-	var si *searchInput
-	x, _ := ioutil.ReadAll(r.Body)
-	json.Unmarshal([]byte(x), &si)
-
-	if si == nil {
-		c.SetInvalidParam("Nội dung tìm kiếm")
+	options := model.ConversationSearchOptionsFromJson(r.Body)
+	if options == nil {
+		c.SetInvalidParam("search_options")
 		return
 	}
 
-	var inPages string
-	if len(si.PageIds) > 0 {
-		inPages += "("
-		i := 1
-		for _, pageId := range si.PageIds {
-			inPages += "'" + pageId + "'"
-			if i < len(si.PageIds) {
-				inPages += ","
-			}
-			i += 1
-		}
-		inPages += ")"
-	}
-
-	if len(si.Term) == 0 {
-		c.SetInvalidParam("Từ khóa tìm kiếm")
+	options.PageIds = restrictPageIds(c, options.PageIds, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
 		return
 	}
 
-	limit := si.Limit
-	offset:= si.Offset
-
-	if limit == 0 {
-		limit = 30
-	}
-	cvs, err := c.App.SearchConversations(si.Term, inPages, limit, offset)
+	result, err := c.App.SearchConversations(options)
 	if err != nil {
 		c.Err = err
 		return
 	}
+
 	w.Header().Set("Cache-Control", "no-cache, no-store, must-revalidate")
-	w.Write([]byte(model.ConversationResponseListToJson(cvs)))
+	w.Write([]byte(result.ToJson()))
 }
 
 func updateSeen(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -326,6 +205,11 @@ func updateSeen(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	_, err := c.App.UpdateSeen(c.Params.ConversationId, c.Params.PageId, c.App.Session.UserId)
 
 	if err != nil {
@@ -343,6 +227,11 @@ func updateUnSeen(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	result, err := c.App.UpdateUnSeen(c.Params.ConversationId, c.Params.PageId, c.App.Session.UserId)
 
 	if err != nil {
@@ -405,19 +294,137 @@ func createConversations(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func getConversations(c *Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	limit, _ := strconv.Atoi(query.Get("limit"))
-	offset, _ := strconv.Atoi(query.Get("offset"))
-	pageIds := query.Get("pageIds")
-	if limit == 0 {
-		limit = 30
+	options, err := conversationSearchOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		c.Err = err
+		return
 	}
-	cvs, err := c.App.GetConversations(pageIds, offset, limit)
+
+	options.PageIds = restrictPageIds(c, options.PageIds, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	result, err := c.App.SearchConversations(options)
 	if err != nil {
 		c.Err = err
 		return
 	}
-	w.Write([]byte(model.FacebookConversationListToJson(cvs)))
+
+	if len(result.NextCursor) > 0 {
+		w.Header().Set(model.HEADER_NEXT_CURSOR, result.NextCursor)
+	}
+	w.Write([]byte(model.FacebookConversationListToJson(result.Conversations)))
+}
+
+func getConversationMessagingWindow(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	window, err := c.App.GetConversationMessagingWindow(c.Params.ConversationId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(window.ToJson()))
+}
+
+// restrictPageIds giới hạn truy vấn trên nhiều page trong các page mà user có quyền permission,
+// nếu không truyền pageIds sẽ trả về mọi page của user có quyền đó
+func restrictPageIds(c *Context, pageIds []string, permission *model.Permission) []string {
+	if c.App.SessionHasPermissionTo(c.App.Session, permission) {
+		return pageIds
+	}
+
+	if len(pageIds) == 0 {
+		pageIds = c.App.GetPageIdsForSession(c.App.Session, permission)
+		if len(pageIds) == 0 {
+			c.SetPermissionError(permission)
+		}
+		return pageIds
+	}
+
+	for _, pageId := range pageIds {
+		if !c.App.SessionHasPermissionToFanpage(c.App.Session, pageId, permission) {
+			c.SetPermissionError(permission)
+			return nil
+		}
+	}
+	return pageIds
+}
+
+// conversationSearchOptionsFromQuery đọc điều kiện lọc từ query string, pageIds được giữ lại cho client cũ
+func conversationSearchOptionsFromQuery(query url.Values) (*model.ConversationSearchOptions, *model.AppError) {
+	options := &model.ConversationSearchOptions{
+		Term:       query.Get("term"),
+		Type:       query.Get("type"),
+		TagMatch:   query.Get("tag_match"),
+		AssignedTo: query.Get("assigned_to"),
+		Cursor:     query.Get("cursor"),
+	}
+
+	for _, key := range []string{"page_ids", "pageIds"} {
+		for _, id := range strings.Split(query.Get(key), ",") {
+			if id = strings.TrimSpace(id); len(id) > 0 {
+				options.PageIds = append(options.PageIds, id)
+			}
+		}
+	}
+
+	for _, id := range strings.Split(query.Get("tag_ids"), ",") {
+		if id = strings.TrimSpace(id); len(id) > 0 {
+			options.TagIds = append(options.TagIds, id)
+		}
+	}
+
+	for _, status := range strings.Split(query.Get("status"), ",") {
+		if status = strings.TrimSpace(status); len(status) > 0 {
+			options.Statuses = append(options.Statuses, status)
+		}
+	}
+
+	boolParams := map[string]**bool{"seen": &options.Seen, "replied": &options.Replied, "has_phone": &options.HasPhone}
+	for key, target := range boolParams {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, model.NewAppError("getConversations", "api.context.invalid_url_param.app_error", map[string]interface{}{"Name": key}, "", http.StatusBadRequest)
+			}
+			*target = &parsed
+		}
+	}
+
+	intParams := map[string]*int{"limit": &options.Limit, "offset": &options.Offset}
+	for key, target := range intParams {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, model.NewAppError("getConversations", "api.context.invalid_url_param.app_error", map[string]interface{}{"Name": key}, "", http.StatusBadRequest)
+			}
+			*target = parsed
+		}
+	}
+
+	int64Params := map[string]*int64{"since": &options.Since, "until": &options.Until}
+	for key, target := range int64Params {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, model.NewAppError("getConversations", "api.context.invalid_url_param.app_error", map[string]interface{}{"Name": key}, "", http.StatusBadRequest)
+			}
+			*target = parsed
+		}
+	}
+
+	return options, nil
 }
 
 func getConversationMessages(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -436,6 +443,11 @@ func getConversationMessages(c *Context, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	messages, err := c.App.GetConversationMessages(conversationId, offset, limit)
 	if err != nil {
 		c.Err = err
@@ -444,3 +456,105 @@ func getConversationMessages(c *Context, w http.ResponseWriter, r *http.Request)
 	//c.App.SanitizeMessages(c.App.Session, messages)
 	w.Write([]byte(model.FacebookConversationMessagesToJson(messages)))
 }
+
+func hideComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	setCommentHidden(c, w, true)
+}
+
+func unhideComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	setCommentHidden(c, w, false)
+}
+
+func setCommentHidden(c *Context, w http.ResponseWriter, isHidden bool) {
+	c.RequireConversationId().RequireCommentId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	comment, err := c.App.HideComment(c.Params.ConversationId, c.Params.CommentId, isHidden, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(comment.ToJson()))
+}
+
+func likeComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	setCommentLiked(c, w, true)
+}
+
+func unlikeComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	setCommentLiked(c, w, false)
+}
+
+func setCommentLiked(c *Context, w http.ResponseWriter, isLiked bool) {
+	c.RequireConversationId().RequireCommentId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	comment, err := c.App.LikeComment(c.Params.ConversationId, c.Params.CommentId, isLiked, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(comment.ToJson()))
+}
+
+func deleteComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId().RequireCommentId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	if _, err := c.App.DeleteComment(c.Params.ConversationId, c.Params.CommentId, c.App.Session.UserId); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+func privateReplyComment(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId().RequireCommentId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+	message := strings.TrimSpace(props["message"])
+	if len(message) == 0 {
+		c.SetInvalidParam("message")
+		return
+	}
+
+	comment, err := c.App.PrivateReplyComment(c.Params.ConversationId, c.Params.CommentId, message, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(comment.ToJson()))
+}
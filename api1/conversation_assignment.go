@@ -0,0 +1,115 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (api *API) InitConversationAssignment() {
+	api.BaseRoutes.Conversation.Handle("/assign", api.ApiSessionRequired(assignConversation)).Methods("POST")
+	api.BaseRoutes.Conversation.Handle("/assign", api.ApiSessionRequired(unassignConversation)).Methods("DELETE")
+
+	api.BaseRoutes.Fanpage.Handle("/routing", api.ApiSessionRequired(getConversationRouting)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/routing", api.ApiSessionRequired(updateConversationRouting)).Methods("PUT")
+}
+
+// assignConversation giao hội thoại cho user_id trong body, user_id rỗng là tự nhận hội thoại
+func assignConversation(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	if c.Err != nil {
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+	assigneeId := props["user_id"]
+	if len(assigneeId) == 0 || assigneeId == model.ME {
+		assigneeId = c.App.Session.UserId
+	}
+	if len(assigneeId) != 26 {
+		c.SetInvalidParam("user_id")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	conversation, err := c.App.AssignConversation(c.Params.ConversationId, assigneeId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(conversation.ToJson()))
+}
+
+func unassignConversation(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	conversation, err := c.App.UnassignConversation(c.Params.ConversationId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(conversation.ToJson()))
+}
+
+func getConversationRouting(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	routing, err := c.App.GetConversationRouting(c.Params.PageId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(routing.ToJson()))
+}
+
+func updateConversationRouting(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	routing := model.ConversationRoutingFromJson(r.Body)
+	if routing == nil {
+		c.SetInvalidParam("routing")
+		return
+	}
+	routing.PageId = c.Params.PageId
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	updated, err := c.App.UpdateConversationRouting(routing)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
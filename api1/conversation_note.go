@@ -19,6 +19,11 @@ func getConversationNotes(c *Context, w http.ResponseWriter, r *http.Request) {
 	if c.Err != nil {
 		return
 	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
 	cId := c.Params.ConversationId
 	if p, err := c.App.GetConversationNotes(cId); err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -36,6 +41,11 @@ func createConversationNote(c *Context, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
 	note := model.ConversationNoteFromJson(r.Body)
 
 	if note == nil {
@@ -80,6 +90,11 @@ func updateNote(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
 	noteId := c.Params.NoteId
 
 	note := model.ConversationNoteFromJson(r.Body)
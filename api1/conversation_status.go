@@ -0,0 +1,40 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (api *API) InitConversationStatus() {
+	api.BaseRoutes.Conversation.Handle("/status", api.ApiSessionRequired(updateConversationStatus)).Methods("PUT")
+}
+
+// updateConversationStatus đổi trạng thái hội thoại, audit được ghi ở tầng app để cả các lần hệ thống tự mở lại hội thoại cũng được lưu
+func updateConversationStatus(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	if c.Err != nil {
+		return
+	}
+
+	update := model.ConversationStatusUpdateFromJson(r.Body)
+	if update == nil || !model.IsValidConversationStatus(update.Status) {
+		c.SetInvalidParam("status")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	conversation, err := c.App.UpdateConversationStatus(c.Params.ConversationId, update, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(conversation.ToJson()))
+}
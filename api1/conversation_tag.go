@@ -19,6 +19,11 @@ func getConversationTags(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	conversationId := c.Params.ConversationId
 	if p, err := c.App.GetConversationTags(conversationId); err != nil {
 		w.WriteHeader(http.StatusNotFound)
@@ -37,6 +42,11 @@ func addOrRemoveConversationTag(c *Context, w http.ResponseWriter, r *http.Reque
 		return
 	}
 
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_MANAGE_TAGS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_TAGS)
+		return
+	}
+
 	tag := model.PageTagFromJson(r.Body)
 
 	if tag == nil {
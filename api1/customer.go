@@ -0,0 +1,150 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"encoding/json"
+	"net/http"
+)
+
+func (api *API) InitCustomers() {
+	api.BaseRoutes.Customer.Handle("", api.ApiSessionRequired(getCustomerProfile)).Methods("GET")
+	api.BaseRoutes.Customer.Handle("", api.ApiSessionRequired(updateCustomer)).Methods("PUT")
+	api.BaseRoutes.Customer.Handle("/merge", api.ApiSessionRequired(mergeCustomers)).Methods("POST")
+	api.BaseRoutes.Customer.Handle("/split", api.ApiSessionRequired(splitCustomer)).Methods("POST")
+
+	api.BaseRoutes.Conversation.Handle("/customer", api.ApiSessionRequired(getConversationCustomer)).Methods("GET")
+}
+
+func getCustomerProfile(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireCustomerId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToCustomer(c.App.Session, c.Params.CustomerId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	profile, err := c.App.GetCustomerProfile(c.Params.CustomerId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(c.App.SanitizeCustomerProfile(c.App.Session, profile).ToJson()))
+}
+
+func updateCustomer(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireCustomerId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionCanManageCustomer(c.App.Session, c.Params.CustomerId) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	customer := model.CustomerRecordFromJson(r.Body)
+	if customer == nil {
+		c.SetInvalidParam("customer")
+		return
+	}
+	customer.Id = c.Params.CustomerId
+
+	updated, err := c.App.UpdateCustomer(customer)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
+
+// mergeCustomers gộp hồ sơ customer_id trong body vào hồ sơ trên url
+func mergeCustomers(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireCustomerId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionCanManageCustomer(c.App.Session, c.Params.CustomerId) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+	sourceId := props["customer_id"]
+	if len(sourceId) != 26 {
+		c.SetInvalidParam("customer_id")
+		return
+	}
+
+	if !c.App.SessionCanManageCustomer(c.App.Session, sourceId) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	profile, err := c.App.MergeCustomers(c.Params.CustomerId, sourceId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(c.App.SanitizeCustomerProfile(c.App.Session, profile).ToJson()))
+}
+
+// splitCustomer tách các facebook_uids trong body ra thành hồ sơ mới, trả về hồ sơ mới
+func splitCustomer(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireCustomerId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionCanManageCustomer(c.App.Session, c.Params.CustomerId) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	var props struct {
+		FacebookUids []string `json:"facebook_uids"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&props); err != nil || len(props.FacebookUids) == 0 {
+		c.SetInvalidParam("facebook_uids")
+		return
+	}
+
+	profile, err := c.App.SplitCustomer(c.Params.CustomerId, props.FacebookUids)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(c.App.SanitizeCustomerProfile(c.App.Session, profile).ToJson()))
+}
+
+// getConversationCustomer trả về hồ sơ khách hàng của người gửi hội thoại
+func getConversationCustomer(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	profile, err := c.App.GetCustomerProfileForConversation(c.Params.ConversationId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(c.App.SanitizeCustomerProfile(c.App.Session, profile).ToJson()))
+}
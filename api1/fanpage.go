@@ -11,7 +11,6 @@ import (
 	"io/ioutil"
 	"net/http"
 	"strconv"
-	"time"
 )
 
 func (api *API) InitFanpage() {
@@ -30,6 +29,8 @@ func (api *API) InitFanpage() {
 	api.BaseRoutes.Fanpage.Handle("/snippets/{snippet_id:[A-Za-z0-9]+}/update", api.ApiSessionRequired(updateSnippet)).Methods("PUT")
 	// initialize pages
 	api.BaseRoutes.Fanpages.Handle("/initialize", api.ApiSessionRequired(initializeFanpages)).Methods("POST")
+	// đồng bộ lại tin nhắn, bình luận bị bỏ sót kể từ mốc thời gian since
+	api.BaseRoutes.Fanpage.Handle("/sync", api.ApiSessionRequired(syncFanpage)).Methods("POST")
 
 	// AUTO MESSAGE TASK
 	api.BaseRoutes.Fanpage.Handle("/auto_message_tasks", api.ApiSessionRequired(createAutoMessageTask)).Methods("POST")
@@ -97,6 +98,13 @@ func updatePagesStatus(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	for _, pageId := range status.PageIds {
+		if !c.App.SessionHasPermissionToFanpage(c.App.Session, pageId, model.PERMISSION_MANAGE_FANPAGE) {
+			c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+			return
+		}
+	}
+
 	lpi := model.LoadPagesInput{
 		PageIds: status.PageIds,
 	}
@@ -115,6 +123,11 @@ func updatePageStatus(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
 	status := model.PageStatusFromJson(r.Body)
 	if len(status.Status) == 0 {
 		c.SetInvalidParam("Status")
@@ -137,8 +150,8 @@ func getFileInfosForPage(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !c.App.SessionHasPermissionToChannelByPost(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CHANNEL) {
-		c.SetPermissionError(model.PERMISSION_READ_CHANNEL)
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
 		return
 	}
 
@@ -171,6 +184,11 @@ func getPageAutoMessageTasks(c *Context, w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	if p, err := c.App.GetPageAutoMessageTasks(pageId); err != nil {
 		w.WriteHeader(http.StatusNotFound)
 		w.Write([]byte(err.ToJson()))
@@ -182,6 +200,16 @@ func getPageAutoMessageTasks(c *Context, w http.ResponseWriter, r *http.Request)
 }
 
 func createAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
 	task := model.AutoMessageTaskFromJson(r.Body)
 
 	pageId := c.Params.PageId
@@ -239,6 +267,16 @@ func createAutoMessageTask(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func getPageSnippets(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	pageId := c.Params.PageId
 	if len(pageId) > 0 {
 		if p, err := c.App.GetPageSnippets(pageId); err != nil {
@@ -253,6 +291,15 @@ func getPageSnippets(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func createSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_SNIPPETS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SNIPPETS)
+		return
+	}
 
 	snippet := model.ReplySnippetFromJson(r.Body)
 
@@ -260,6 +307,7 @@ func createSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
 		c.SetInvalidParam("missing")
 		return
 	}
+	snippet.PageId = c.Params.PageId
 
 	if len(snippet.Trigger) == 0 {
 		c.SetInvalidParam("Trigger")
@@ -287,6 +335,16 @@ func createSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func updateSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_SNIPPETS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SNIPPETS)
+		return
+	}
+
 	snippetId := c.Params.SnippetId
 
 	snippet := model.ReplySnippetFromJson(r.Body)
@@ -295,6 +353,8 @@ func updateSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 	snippet.Id = snippetId
+	// snippet luôn thuộc page trên URL, store sẽ từ chối nếu snippet cũ thuộc page khác
+	snippet.PageId = c.Params.PageId
 
 	var rSnippet *model.ReplySnippet
 	var err *model.AppError
@@ -312,6 +372,16 @@ func updateSnippet(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func getFanpage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	pageId := c.Params.PageId
 	if len(pageId) > 0 {
 		if p, err := c.App.GetFanpageByPageId(pageId); err != nil {
@@ -320,7 +390,7 @@ func getFanpage(c *Context, w http.ResponseWriter, r *http.Request) {
 			return
 		} else {
 			w.WriteHeader(http.StatusOK)
-			w.Write([]byte(p.ToJson()))
+			w.Write([]byte(c.App.SanitizeFanpage(c.App.Session, p).ToJson()))
 		}
 	}
 }
@@ -338,6 +408,8 @@ func initializeFanpages(c *Context, w http.ResponseWriter, r *http.Request) {
 
 		user := result.Data.(*model.User)
 
+		var initJobs []*model.Job
+
 		// Validation trước khi khởi tạo
 		validationResult := c.App.ValidationPagesBeforeInit(lpi, c.App.Session.UserId)
 		successLpi := model.PagesInitValidationToLPi(validationResult)
@@ -373,7 +445,7 @@ func initializeFanpages(c *Context, w http.ResponseWriter, r *http.Request) {
 					}
 				}
 
-				pageToken, disabled, err, aerr := c.App.GraphPageAndInit2(pageId, user, pages)
+				_, disabled, err, aerr := c.App.GraphPageAndInit2(pageId, user, pages)
 
 				if disabled {
 					break
@@ -398,101 +470,22 @@ func initializeFanpages(c *Context, w http.ResponseWriter, r *http.Request) {
 					break
 				}
 
-				// KHỞI TẠO PAGE
-				// 1: Khởi tạo kết quả init page
-				var pageInitResult *model.FanpageInitResult
-				if rs, error := c.App.CreateFanpageInitResult(&model.FanpageInitResult{
-					PageId: pageId,
-					Creator: c.App.Session.UserId,
-				}); error != nil {
-					mlog.Error(error.Message)
-				} else {
-					pageInitResult = rs
-				}
-
-				// TODO: cập nhật trạng thái page => initializing, emit socket thông báo trạng thái page
-				if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_INITIALIZING, c.App.Session.UserId); error != nil {
-					mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_INITIALIZING)
-				}
-
-				start := time.Now()
-
-				// chạy 2 tiến trình song song: Khởi tạo messages và khởi tạo comments
-				doneMessages := make(chan bool)
-				doneComments := make(chan bool)
-
-				go func() {
-					// Graph messages
-					fbErr, AppErr := c.App.GraphMessagesAndInit(pageId, pageToken, pageInitResult)
-					if fbErr != nil {
-						mlog.Error(fbErr.Error.Message)
-						if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_ERROR, c.App.Session.UserId); error != nil {
-							mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_ERROR)
-						}
-					}
-
-					if AppErr != nil {
-						mlog.Error(AppErr.Error())
-						if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_ERROR, c.App.Session.UserId); error != nil {
-							mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_ERROR)
-						}
-					}
-
-					if fbErr == nil && AppErr == nil {
-						doneMessages <- true
-					}
-
-					close(doneMessages)
-				}()
-
-				go func() {
-					// Graph comments
-					nextLink := "next"
-					var fbErr2 *facebookgraph.FacebookError
-					var appErr *model.AppError
-					for len(nextLink) > 0 {
-						nextLink = ""
-						nextLink, fbErr2, appErr = c.App.GraphPagePostsAndInit(pageId, pageToken, nextLink, pageInitResult)
-						if fbErr2 != nil {
-							mlog.Error(fbErr2.Error.Message)
-							if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_ERROR, c.App.Session.UserId); error != nil {
-								mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_ERROR)
-							}
-							break
-						}
-						if appErr != nil {
-							mlog.Error(appErr.Error())
-							if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_ERROR, c.App.Session.UserId); error != nil {
-								mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_ERROR)
-							}
-						}
-					}
-
-					if fbErr2 == nil && appErr == nil {
-						doneComments <- true
-					}
-
-					close(doneComments)
-				}()
-
-				finishedMessages := <- doneMessages
-				finishedComments := <- doneComments
-
-				if finishedMessages && finishedComments {
-					// TODO: cập nhật trạng thái page => initialized, emit socket thông báo trạng thái page
-					if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_INITIALIZED, c.App.Session.UserId); error != nil {
-						mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_INITIALIZED)
+				// KHỞI TẠO PAGE: việc graph dữ liệu chạy trong jobs worker, có thể chạy tiếp sau khi server khởi động lại
+				// và huỷ qua /jobs/{job_id}/cancel
+				job, jobErr := c.App.CreateFanpageInitJob(pageId, c.App.Session.UserId)
+				if jobErr != nil {
+					mlog.Error(pageId+": Không thể tạo job khởi tạo page", mlog.Err(jobErr))
+					if error, _ := c.App.UpdatePageStatus(pageId, model.PAGE_STATUS_ERROR, c.App.Session.UserId); error != nil {
+						mlog.Error(pageId+": Không thể cập nhật trạng thái page "+model.PAGE_STATUS_ERROR)
 					}
-
-					mlog.Info("Khởi tạo Page thành công " + pageId + " sau " + time.Since(start).String() + " giây")
-				} else {
-					// TODO: lỗi khởi tạo page
-					mlog.Error("Khởi tạo không thành công: " + pageId)
+					break
 				}
+
+				initJobs = append(initJobs, job)
 			}
 		}
 
-		ReturnStatusOK(w)
+		w.Write([]byte(model.JobsToJson(initJobs)))
 	}
 }
 
@@ -597,3 +590,33 @@ func createFanpage(c *Context, w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusCreated)
 	w.Write([]byte(rfanpage.ToJson()))
 }
+
+func syncFanpage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	var since int64
+	if value := r.URL.Query().Get("since"); len(value) > 0 {
+		var err error
+		if since, err = strconv.ParseInt(value, 10, 64); err != nil || since < 0 || since > model.GetMillis() {
+			c.SetInvalidParam("since")
+			return
+		}
+	}
+
+	job, err := c.App.CreateFanpageSyncJob(c.Params.PageId, c.App.Session.UserId, since)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(job.ToJson()))
+}
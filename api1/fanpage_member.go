@@ -0,0 +1,178 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (api *API) InitFanpageMembers() {
+	api.BaseRoutes.FanpageMembers.Handle("", api.ApiSessionRequired(getFanpageMembers)).Methods("GET")
+	api.BaseRoutes.FanpageMembers.Handle("", api.ApiSessionRequired(addFanpageMember)).Methods("POST")
+	api.BaseRoutes.FanpageMembers.Handle("/invite", api.ApiSessionRequired(inviteUsersToFanpage)).Methods("POST")
+	api.BaseRoutes.FanpageMember.Handle("", api.ApiSessionRequired(removeFanpageMember)).Methods("DELETE")
+	api.BaseRoutes.FanpageMember.Handle("/roles", api.ApiSessionRequired(updateFanpageMemberRoles)).Methods("PUT")
+
+	api.BaseRoutes.Fanpages.Handle("/members/invite/accept", api.ApiSessionRequired(acceptFanpageInvite)).Methods("POST")
+}
+
+func getFanpageMembers(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	members, err := c.App.GetFanpageMembers(c.Params.PageId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.FanpageMemberToJson(members)))
+}
+
+// addFanpageMember thêm user có sẵn vào page, body gồm user_id và roles
+func addFanpageMember(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+	userId := props["user_id"]
+	if len(userId) != 26 {
+		c.SetInvalidParam("user_id")
+		return
+	}
+
+	roles := props["roles"]
+	if !model.IsValidFanpageMemberRoles(roles) {
+		c.SetInvalidParam("roles")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE_MEMBERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE_MEMBERS)
+		return
+	}
+
+	member, err := c.App.AddFanpageMember(c.Params.PageId, userId, roles)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("page_id=" + c.Params.PageId + " user_id=" + userId + " roles=" + roles)
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(member.ToJson()))
+}
+
+// inviteUsersToFanpage mời theo email, trả về các member được thêm ngay vì email đã có tài khoản
+func inviteUsersToFanpage(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	invite := model.FanpageMemberInviteFromJson(r.Body)
+	if invite == nil || len(invite.Emails) == 0 {
+		c.SetInvalidParam("emails")
+		return
+	}
+
+	if !model.IsValidFanpageMemberRoles(invite.Roles) {
+		c.SetInvalidParam("roles")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE_MEMBERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE_MEMBERS)
+		return
+	}
+
+	members, err := c.App.InviteUsersToFanpage(c.Params.PageId, invite.Emails, invite.Roles, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("page_id=" + c.Params.PageId + " roles=" + invite.Roles)
+	w.Write([]byte(model.FanpageMemberToJson(members)))
+}
+
+// removeFanpageMember cho phép admin của page xoá thành viên, hoặc thành viên tự rời page
+func removeFanpageMember(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId().RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	if c.Params.UserId != c.App.Session.UserId {
+		if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE_MEMBERS) {
+			c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE_MEMBERS)
+			return
+		}
+	}
+
+	if err := c.App.RemoveFanpageMember(c.Params.PageId, c.Params.UserId); err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("page_id=" + c.Params.PageId + " user_id=" + c.Params.UserId)
+	ReturnStatusOK(w)
+}
+
+func updateFanpageMemberRoles(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId().RequireUserId()
+	if c.Err != nil {
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+	roles := props["roles"]
+	if !model.IsValidFanpageMemberRoles(roles) {
+		c.SetInvalidParam("roles")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE_MEMBERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE_MEMBERS)
+		return
+	}
+
+	member, err := c.App.UpdateFanpageMemberRoles(c.Params.PageId, c.Params.UserId, roles)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("page_id=" + c.Params.PageId + " user_id=" + c.Params.UserId + " roles=" + roles)
+	w.Write([]byte(member.ToJson()))
+}
+
+// acceptFanpageInvite thêm user hiện tại vào page từ token trong email mời
+func acceptFanpageInvite(c *Context, w http.ResponseWriter, r *http.Request) {
+	props := model.MapFromJson(r.Body)
+	token := props["token"]
+	if len(token) == 0 {
+		c.SetInvalidParam("token")
+		return
+	}
+
+	member, err := c.App.AddFanpageMemberByToken(token, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(member.ToJson()))
+}
@@ -22,17 +22,17 @@ func getJob(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_JOBS) {
-		c.SetPermissionError(model.PERMISSION_MANAGE_JOBS)
-		return
-	}
-
 	job, err := c.App.GetJob(c.Params.JobId)
 	if err != nil {
 		c.Err = err
 		return
 	}
 
+	if !sessionCanManageJob(c, job) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_JOBS)
+		return
+	}
+
 	w.Write([]byte(job.ToJson()))
 }
 
@@ -103,7 +103,13 @@ func cancelJob(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_JOBS) {
+	job, err := c.App.GetJob(c.Params.JobId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	if !sessionCanManageJob(c, job) {
 		c.SetPermissionError(model.PERMISSION_MANAGE_JOBS)
 		return
 	}
@@ -115,3 +121,21 @@ func cancelJob(c *Context, w http.ResponseWriter, r *http.Request) {
 
 	ReturnStatusOK(w)
 }
+
+// ngoài quản trị hệ thống, người khởi tạo page được xem và huỷ job khởi tạo page của mình
+func sessionCanManageJob(c *Context, job *model.Job) bool {
+	if c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_JOBS) {
+		return true
+	}
+
+	if job.Type != model.JOB_TYPE_FANPAGE_INIT && job.Type != model.JOB_TYPE_FANPAGE_SYNC {
+		return false
+	}
+
+	// job đồng bộ định kỳ không có user_id nên chỉ người có quyền MANAGE_JOBS mới quản lý được
+	userId := job.Data[model.JOB_DATA_FANPAGE_INIT_USER_ID]
+	if job.Type == model.JOB_TYPE_FANPAGE_SYNC {
+		userId = job.Data[model.JOB_DATA_FANPAGE_SYNC_USER_ID]
+	}
+	return len(userId) > 0 && userId == c.App.Session.UserId
+}
@@ -0,0 +1,139 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (api *API) InitModerationRule() {
+	api.BaseRoutes.Fanpage.Handle("/moderation_rules", api.ApiSessionRequired(getModerationRules)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/moderation_rules", api.ApiSessionRequired(createModerationRule)).Methods("POST")
+	api.BaseRoutes.Fanpage.Handle("/moderation_rules/{rule_id:[A-Za-z0-9]+}", api.ApiSessionRequired(getModerationRule)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/moderation_rules/{rule_id:[A-Za-z0-9]+}", api.ApiSessionRequired(updateModerationRule)).Methods("PUT")
+	api.BaseRoutes.Fanpage.Handle("/moderation_rules/{rule_id:[A-Za-z0-9]+}", api.ApiSessionRequired(deleteModerationRule)).Methods("DELETE")
+}
+
+func getModerationRules(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	rules, err := c.App.GetModerationRules(c.Params.PageId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.ModerationRuleListToJson(rules)))
+}
+
+func createModerationRule(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_FANPAGE)
+		return
+	}
+
+	rule := model.ModerationRuleFromJson(r.Body)
+	if rule == nil {
+		c.SetInvalidParam("moderation_rule")
+		return
+	}
+
+	rule.Id = ""
+	rule.PageId = c.Params.PageId
+	rule.CreatorId = c.App.Session.UserId
+
+	created, err := c.App.CreateModerationRule(rule)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(created.ToJson()))
+}
+
+func getModerationRule(c *Context, w http.ResponseWriter, r *http.Request) {
+	rule := getPageModerationRule(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(rule.ToJson()))
+}
+
+func updateModerationRule(c *Context, w http.ResponseWriter, r *http.Request) {
+	getPageModerationRule(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	rule := model.ModerationRuleFromJson(r.Body)
+	if rule == nil {
+		c.SetInvalidParam("moderation_rule")
+		return
+	}
+	rule.Id = c.Params.RuleId
+
+	updated, err := c.App.UpdateModerationRule(rule)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
+
+func deleteModerationRule(c *Context, w http.ResponseWriter, r *http.Request) {
+	rule := getPageModerationRule(c, model.PERMISSION_MANAGE_FANPAGE)
+	if c.Err != nil {
+		return
+	}
+
+	if err := c.App.DeleteModerationRule(rule); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+// getPageModerationRule đọc luật theo rule_id trên url, kiểm tra quyền của user trên page và luật thuộc page_id trên url
+func getPageModerationRule(c *Context, permission *model.Permission) *model.ModerationRule {
+	c.RequirePageId().RequireRuleId()
+	if c.Err != nil {
+		return nil
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, permission) {
+		c.SetPermissionError(permission)
+		return nil
+	}
+
+	rule, err := c.App.GetModerationRule(c.Params.RuleId)
+	if err != nil {
+		c.Err = err
+		return nil
+	}
+
+	if rule.PageId != c.Params.PageId {
+		c.Err = model.NewAppError("getPageModerationRule", "api.moderation_rule.page_mismatch.app_error", nil, "rule_id="+rule.Id+", page_id="+c.Params.PageId, http.StatusNotFound)
+		return nil
+	}
+
+	return rule
+}
@@ -1,34 +1,117 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
 package api1
 
 import (
 	"bitbucket.org/enesyteam/papo-server/model"
 	"net/http"
+	"net/url"
 	"strconv"
+	"strings"
 )
 
 func (api *API) InitOrders() {
 	api.BaseRoutes.Orders.Handle("", api.ApiSessionRequired(getOrders)).Methods("GET")
 	api.BaseRoutes.Orders.Handle("", api.ApiSessionRequired(createOrder)).Methods("POST")
+	api.BaseRoutes.Orders.Handle("/search", api.ApiSessionRequired(searchOrders)).Methods("POST")
 	api.BaseRoutes.Order.Handle("", api.ApiSessionRequired(getOrder)).Methods("GET")
+	api.BaseRoutes.Order.Handle("", api.ApiSessionRequired(updateOrder)).Methods("PUT")
+	api.BaseRoutes.Order.Handle("", api.ApiSessionRequired(deleteOrder)).Methods("DELETE")
+
+	api.BaseRoutes.Fanpage.Handle("/order_statuses", api.ApiSessionRequired(getOrderStatuses)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/order_statuses", api.ApiSessionRequired(createOrderStatus)).Methods("POST")
+	api.BaseRoutes.Fanpage.Handle("/order_statuses/{order_status_id:[A-Za-z0-9]+}", api.ApiSessionRequired(updateOrderStatus)).Methods("PUT")
+	api.BaseRoutes.Fanpage.Handle("/order_statuses/{order_status_id:[A-Za-z0-9]+}", api.ApiSessionRequired(deleteOrderStatus)).Methods("DELETE")
 }
 
 func getOrders(c *Context, w http.ResponseWriter, r *http.Request) {
-	query := r.URL.Query()
-	limit, _ := strconv.Atoi(query.Get("limit"))
-	offset, _ := strconv.Atoi(query.Get("offset"))
+	options, err := orderSearchOptionsFromQuery(r.URL.Query())
+	if err != nil {
+		c.Err = err
+		return
+	}
 
-	err, orders := c.App.GetOrders(limit, offset)
+	options.PageIds = restrictPageIds(c, options.PageIds, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	result, err := c.App.SearchOrders(options)
 	if err != nil {
 		c.Err = err
 		return
 	}
-	w.WriteHeader(http.StatusOK)
-	w.Write([]byte(model.OrderListToJson(orders)))
+
+	w.Write([]byte(result.ToJson()))
 }
 
-func getOrder(c *Context, w http.ResponseWriter, r *http.Request) {
+func searchOrders(c *Context, w http.ResponseWriter, r *http.Request) {
+	options := model.OrderSearchOptionsFromJson(r.Body)
+	if options == nil {
+		c.SetInvalidParam("order_search")
+		return
+	}
 
+	options.PageIds = restrictPageIds(c, options.PageIds, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
 
+	result, err := c.App.SearchOrders(options)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(result.ToJson()))
+}
+
+// orderSearchOptionsFromQuery đọc điều kiện lọc đơn hàng từ query string, các id được phân cách bằng dấu phẩy
+func orderSearchOptionsFromQuery(query url.Values) (*model.OrderSearchOptions, *model.AppError) {
+	options := &model.OrderSearchOptions{
+		PageIds:        splitQueryIds(query.Get("page_ids")),
+		StatusIds:      splitQueryIds(query.Get("status_ids")),
+		AssignedTo:     query.Get("assigned_to"),
+		CreatorId:      query.Get("creator_id"),
+		ConversationId: query.Get("conversation_id"),
+		FacebookUid:    query.Get("facebook_uid"),
+		Term:           query.Get("term"),
+	}
+
+	intParams := map[string]*int{"limit": &options.Limit, "offset": &options.Offset}
+	for key, target := range intParams {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, model.NewAppError("getOrders", "api.context.invalid_url_param.app_error", map[string]interface{}{"Name": key}, "", http.StatusBadRequest)
+			}
+			*target = parsed
+		}
+	}
+
+	int64Params := map[string]*int64{"since": &options.Since, "until": &options.Until}
+	for key, target := range int64Params {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return nil, model.NewAppError("getOrders", "api.context.invalid_url_param.app_error", map[string]interface{}{"Name": key}, "", http.StatusBadRequest)
+			}
+			*target = parsed
+		}
+	}
+
+	return options, nil
+}
+
+func splitQueryIds(value string) []string {
+	var ids []string
+	for _, id := range strings.Split(value, ",") {
+		if id = strings.TrimSpace(id); len(id) > 0 {
+			ids = append(ids, id)
+		}
+	}
+	return ids
 }
 
 func createOrder(c *Context, w http.ResponseWriter, r *http.Request) {
@@ -38,11 +121,204 @@ func createOrder(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if err, addedOrder := c.App.CreateOrder(order); err != nil {
+	order.Id = ""
+	order.CreatorId = c.App.Session.UserId
+
+	if len(order.PageId) == 0 {
+		c.SetInvalidParam("page_id")
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, order.PageId, model.PERMISSION_MANAGE_ORDERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_ORDERS)
+		return
+	}
+
+	created, err := c.App.CreateOrder(order)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(created.ToJson()))
+}
+
+func getOrder(c *Context, w http.ResponseWriter, r *http.Request) {
+	order := getOrderWithPermission(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(order.ToJson()))
+}
+
+func updateOrder(c *Context, w http.ResponseWriter, r *http.Request) {
+	getOrderWithPermission(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	order := model.OrderFromJson(r.Body)
+	if order == nil {
+		c.SetInvalidParam("order")
+		return
+	}
+	order.Id = c.Params.OrderId
+
+	updated, err := c.App.UpdateOrder(order)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
+
+func deleteOrder(c *Context, w http.ResponseWriter, r *http.Request) {
+	order := getOrderWithPermission(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	if err := c.App.DeleteOrder(order); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+// getOrderWithPermission đọc đơn hàng theo order_id trên url và kiểm tra quyền của user trên page của đơn hàng
+func getOrderWithPermission(c *Context, permission *model.Permission) *model.Order {
+	c.RequireOrderId()
+	if c.Err != nil {
+		return nil
+	}
+
+	order, err := c.App.GetOrder(c.Params.OrderId)
+	if err != nil {
+		c.Err = err
+		return nil
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, order.PageId, permission) {
+		c.SetPermissionError(permission)
+		return nil
+	}
+
+	return order
+}
+
+func getOrderStatuses(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	statuses, err := c.App.GetOrderStatuses(c.Params.PageId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.OrderStatusListToJson(statuses)))
+}
+
+func createOrderStatus(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_ORDERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_ORDERS)
+		return
+	}
+
+	status := model.OrderStatusFromJson(r.Body)
+	if status == nil {
+		c.SetInvalidParam("order_status")
+		return
+	}
+
+	status.Id = ""
+	status.PageId = c.Params.PageId
+	status.CreatorId = c.App.Session.UserId
+
+	created, err := c.App.CreateOrderStatus(status)
+	if err != nil {
 		c.Err = err
 		return
-	} else {
-		w.WriteHeader(http.StatusOK)
-		w.Write([]byte(addedOrder.ToJson()))
 	}
-}
\ No newline at end of file
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(created.ToJson()))
+}
+
+func updateOrderStatus(c *Context, w http.ResponseWriter, r *http.Request) {
+	getPageOrderStatus(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	status := model.OrderStatusFromJson(r.Body)
+	if status == nil {
+		c.SetInvalidParam("order_status")
+		return
+	}
+	status.Id = c.Params.OrderStatusId
+
+	updated, err := c.App.UpdateOrderStatus(status)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
+
+func deleteOrderStatus(c *Context, w http.ResponseWriter, r *http.Request) {
+	status := getPageOrderStatus(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	if err := c.App.DeleteOrderStatus(status); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+// getPageOrderStatus đọc trạng thái theo order_status_id trên url, kiểm tra quyền của user trên page và trạng thái thuộc page_id trên url
+func getPageOrderStatus(c *Context, permission *model.Permission) *model.OrderStatus {
+	c.RequirePageId().RequireOrderStatusId()
+	if c.Err != nil {
+		return nil
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, permission) {
+		c.SetPermissionError(permission)
+		return nil
+	}
+
+	status, err := c.App.GetOrderStatus(c.Params.OrderStatusId)
+	if err != nil {
+		c.Err = err
+		return nil
+	}
+
+	if status.PageId != c.Params.PageId {
+		c.Err = model.NewAppError("getPageOrderStatus", "api.order_status.page_mismatch.app_error", nil, "order_status_id="+status.Id+", page_id="+c.Params.PageId, http.StatusNotFound)
+		return nil
+	}
+
+	return status
+}
@@ -15,6 +15,16 @@ func (api *API) InitPageTag() {
 }
 
 func getPageTags(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	pageId := c.Params.PageId
 	if len(pageId) > 0 {
 		if p, err := c.App.GetPageTags(pageId); err != nil {
@@ -29,6 +39,16 @@ func getPageTags(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func createPageTag(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_TAGS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_TAGS)
+		return
+	}
+
 	tag := model.PageTagFromJson(r.Body)
 
 	if tag == nil {
@@ -73,6 +93,16 @@ func createPageTag(c *Context, w http.ResponseWriter, r *http.Request) {
 // cần fix lại, không cho phép update tag của page khác
 // => check page_id
 func updateTag(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_TAGS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_TAGS)
+		return
+	}
+
 	tagId := c.Params.TagId
 
 	tag := model.PageTagFromJson(r.Body)
@@ -3,7 +3,6 @@ package api1
 import (
 	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"bitbucket.org/enesyteam/papo-server/model"
-	"fmt"
 	"net/http"
 )
 
@@ -13,18 +12,25 @@ func (api *API) InitPost() {
 }
 
 func fetchPost(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	c.RequirePostId()
 	if c.Err != nil {
 		return
 	}
 
-	// check if client sent a query with page access token
-	var pageAccessToken string
-	query := r.URL.Query()
-	if len(query.Get("page_access_token")) > 0 {
-		pageAccessToken = query.Get("page_access_token")
-	} else {
-		fmt.Println("must get page access token")
+	pageAccessToken, err := c.App.GetPageAccessToken(c.Params.PageId, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
 	}
 
 	fErr, aErr, post := c.App.FetchPost(c.Params.PostId, pageAccessToken)
@@ -47,6 +53,11 @@ func getPagePosts(c *Context, w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
 	posts, err := c.App.GetPagePosts(c.Params.PageId)
 	if err != nil {
 		c.Err = err
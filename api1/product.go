@@ -0,0 +1,185 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"strconv"
+)
+
+func (api *API) InitProducts() {
+	api.BaseRoutes.Fanpage.Handle("/products", api.ApiSessionRequired(getProducts)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/products", api.ApiSessionRequired(createProduct)).Methods("POST")
+	api.BaseRoutes.Fanpage.Handle("/products/{product_id:[A-Za-z0-9]+}", api.ApiSessionRequired(getProduct)).Methods("GET")
+	api.BaseRoutes.Fanpage.Handle("/products/{product_id:[A-Za-z0-9]+}", api.ApiSessionRequired(updateProduct)).Methods("PUT")
+	api.BaseRoutes.Fanpage.Handle("/products/{product_id:[A-Za-z0-9]+}", api.ApiSessionRequired(deleteProduct)).Methods("DELETE")
+
+	api.BaseRoutes.Conversation.Handle("/products/{product_id:[A-Za-z0-9]+}/send", api.ApiSessionRequired(sendProduct)).Methods("POST")
+}
+
+func getProducts(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_READ_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_READ_CONVERSATIONS)
+		return
+	}
+
+	query := r.URL.Query()
+	options := &model.ProductSearchOptions{
+		PageId: c.Params.PageId,
+		Term:   query.Get("term"),
+	}
+
+	intParams := map[string]*int{"limit": &options.Limit, "offset": &options.Offset}
+	for key, target := range intParams {
+		if value := query.Get(key); len(value) > 0 {
+			parsed, err := strconv.Atoi(value)
+			if err != nil {
+				c.SetInvalidUrlParam(key)
+				return
+			}
+			*target = parsed
+		}
+	}
+
+	products, err := c.App.SearchProducts(options)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.ProductListToJson(products)))
+}
+
+func createProduct(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequirePageId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, model.PERMISSION_MANAGE_ORDERS) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_ORDERS)
+		return
+	}
+
+	product := model.ProductFromJson(r.Body)
+	if product == nil {
+		c.SetInvalidParam("product")
+		return
+	}
+
+	product.Id = ""
+	product.PageId = c.Params.PageId
+	product.CreatorId = c.App.Session.UserId
+
+	created, err := c.App.CreateProduct(product)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(created.ToJson()))
+}
+
+func getProduct(c *Context, w http.ResponseWriter, r *http.Request) {
+	product := getPageProduct(c, model.PERMISSION_READ_CONVERSATIONS)
+	if c.Err != nil {
+		return
+	}
+
+	w.Write([]byte(product.ToJson()))
+}
+
+func updateProduct(c *Context, w http.ResponseWriter, r *http.Request) {
+	getPageProduct(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	product := model.ProductFromJson(r.Body)
+	if product == nil {
+		c.SetInvalidParam("product")
+		return
+	}
+	product.Id = c.Params.ProductId
+
+	updated, err := c.App.UpdateProduct(product, c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(updated.ToJson()))
+}
+
+func deleteProduct(c *Context, w http.ResponseWriter, r *http.Request) {
+	product := getPageProduct(c, model.PERMISSION_MANAGE_ORDERS)
+	if c.Err != nil {
+		return
+	}
+
+	if err := c.App.DeleteProduct(product); err != nil {
+		c.Err = err
+		return
+	}
+
+	ReturnStatusOK(w)
+}
+
+// sendProduct gửi sản phẩm cho khách hàng dưới dạng generic template, body có thể chứa variant_id
+// để gửi một phân loại cụ thể. Tin nhắn đi qua outbox giống replyConversation
+func sendProduct(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireConversationId().RequireProductId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionToConversation(c.App.Session, c.Params.ConversationId, model.PERMISSION_REPLY_CONVERSATIONS) {
+		c.SetPermissionError(model.PERMISSION_REPLY_CONVERSATIONS)
+		return
+	}
+
+	props := model.MapFromJson(r.Body)
+
+	message, err := c.App.SendProductToConversation(c.Params.ConversationId, c.Params.ProductId, props["variant_id"], c.App.Session.UserId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	w.Write([]byte(message.ToJson()))
+}
+
+// getPageProduct đọc sản phẩm theo product_id trên url, kiểm tra quyền của user trên page và sản phẩm thuộc page_id trên url
+func getPageProduct(c *Context, permission *model.Permission) *model.Product {
+	c.RequirePageId().RequireProductId()
+	if c.Err != nil {
+		return nil
+	}
+
+	if !c.App.SessionHasPermissionToFanpage(c.App.Session, c.Params.PageId, permission) {
+		c.SetPermissionError(permission)
+		return nil
+	}
+
+	product, err := c.App.GetProduct(c.Params.ProductId)
+	if err != nil {
+		c.Err = err
+		return nil
+	}
+
+	if product.PageId != c.Params.PageId {
+		c.Err = model.NewAppError("getPageProduct", "api.product.page_mismatch.app_error", nil, "product_id="+product.Id+", page_id="+c.Params.PageId, http.StatusNotFound)
+		return nil
+	}
+
+	return product
+}
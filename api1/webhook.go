@@ -0,0 +1,106 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package api1
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"strconv"
+)
+
+func (api *API) InitWebHooks() {
+	api.BaseRoutes.WebHooks.Handle("/facebook/events", api.ApiSessionRequired(getFacebookWebhookEvents)).Methods("GET")
+	api.BaseRoutes.WebHooks.Handle("/facebook/events/{event_id:[A-Za-z0-9]+}", api.ApiSessionRequired(getFacebookWebhookEvent)).Methods("GET")
+	api.BaseRoutes.WebHooks.Handle("/facebook/events/{event_id:[A-Za-z0-9]+}/replay", api.ApiSessionRequired(replayFacebookWebhookEvent)).Methods("POST")
+	api.BaseRoutes.WebHooks.Handle("/facebook/replay", api.ApiSessionRequired(replayFacebookWebhookEvents)).Methods("POST")
+}
+
+func getFacebookWebhookEvents(c *Context, w http.ResponseWriter, r *http.Request) {
+	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_SYSTEM) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SYSTEM)
+		return
+	}
+
+	var since int64
+	if len(r.URL.Query().Get("since")) > 0 {
+		var err error
+		if since, err = strconv.ParseInt(r.URL.Query().Get("since"), 10, 64); err != nil {
+			c.SetInvalidParam("since")
+			return
+		}
+	}
+
+	events, err := c.App.GetFacebookWebhookEvents(since, r.URL.Query().Get("status"), c.Params.Page, c.Params.PerPage)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(model.FacebookWebhookEventsToJson(events)))
+}
+
+func getFacebookWebhookEvent(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireEventId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_SYSTEM) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SYSTEM)
+		return
+	}
+
+	event, err := c.App.GetFacebookWebhookEvent(c.Params.EventId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	w.Write([]byte(event.ToJson()))
+}
+
+func replayFacebookWebhookEvent(c *Context, w http.ResponseWriter, r *http.Request) {
+	c.RequireEventId()
+	if c.Err != nil {
+		return
+	}
+
+	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_SYSTEM) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SYSTEM)
+		return
+	}
+
+	event, err := c.App.ReplayFacebookWebhookEvent(c.Params.EventId)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("event_id=" + event.Id)
+
+	w.Write([]byte(event.ToJson()))
+}
+
+func replayFacebookWebhookEvents(c *Context, w http.ResponseWriter, r *http.Request) {
+	if !c.App.SessionHasPermissionTo(c.App.Session, model.PERMISSION_MANAGE_SYSTEM) {
+		c.SetPermissionError(model.PERMISSION_MANAGE_SYSTEM)
+		return
+	}
+
+	since, parseErr := strconv.ParseInt(r.URL.Query().Get("since"), 10, 64)
+	if parseErr != nil || since <= 0 {
+		c.SetInvalidParam("since")
+		return
+	}
+
+	count, err := c.App.ReplayFacebookWebhookEventsSince(since)
+	if err != nil {
+		c.Err = err
+		return
+	}
+
+	c.LogAudit("since=" + strconv.FormatInt(since, 10) + " count=" + strconv.FormatInt(count, 10))
+
+	w.Write([]byte(model.MapToJson(map[string]string{"count": strconv.FormatInt(count, 10)})))
+}
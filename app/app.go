@@ -96,9 +96,12 @@ func (a *App) InitServer() {
 			a.Srv().Go(func() {
 				runLicenseExpirationCheckJob(a)
 				runCheckWarnMetricStatusJob(a)
+				runConversationSnoozeJob(a)
 			})
 		}
 		a.srv.RunJobs()
+		a.StartFacebookWebhookQueue()
+		a.StartFacebookOutbox()
 	})
 }
 
@@ -119,6 +122,15 @@ func (a *App) initJobs() {
 	if jobsActiveUsersInterface != nil {
 		a.srv.Jobs.ActiveUsers = jobsActiveUsersInterface(a)
 	}
+	if jobsFanpageInitInterface != nil {
+		a.srv.Jobs.FanpageInit = jobsFanpageInitInterface(a)
+	}
+	if jobsFanpageSyncInterface != nil {
+		a.srv.Jobs.FanpageSync = jobsFanpageSyncInterface(a)
+	}
+	if jobsAutoMessageTaskInterface != nil {
+		a.srv.Jobs.AutoMessageTask = jobsAutoMessageTaskInterface(a)
+	}
 	a.srv.Jobs.Workers = a.srv.Jobs.InitWorkers()
 	a.srv.Jobs.Schedulers = a.srv.Jobs.InitSchedulers()
 }
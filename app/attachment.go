@@ -26,6 +26,28 @@ func (a *App) GetFacebookAttachmentByIds(ids []string) ([]*model.FacebookAttachm
 	return nil, nil
 }
 
+// FilterAttachmentIdsForSession chỉ giữ lại các attachment (theo Id hoặc TargetId) thuộc page mà session được xem hội thoại
+func (a *App) FilterAttachmentIdsForSession(session model.Session, ids []string) ([]string, *model.AppError) {
+	result := <-a.Srv.Store.FacebookConversation().GetAttachmentPageIds(ids)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	attachmentPageIds := result.Data.(map[string]string)
+
+	readable := map[string]bool{}
+	for _, pageId := range a.GetPageIdsForSession(session, model.PERMISSION_READ_CONVERSATIONS) {
+		readable[pageId] = true
+	}
+
+	filtered := []string{}
+	for _, id := range ids {
+		if pageId, ok := attachmentPageIds[id]; ok && readable[pageId] {
+			filtered = append(filtered, id)
+		}
+	}
+	return filtered, nil
+}
+
 func (app *App) GraphAttachmentTarget(targetId, attachmentType, token string) (*facebookgraph.FacebookError, *model.AppError, *model.TargetItem) {
 
 	if cacheItem, ok := commentAttachmentByIdsCache.Get(targetId); ok {
@@ -0,0 +1,210 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bytes"
+	"io"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// attachmentMirrorFilename đặt tên file từ url của attachment, thêm phần mở rộng theo content type nếu url không có
+func attachmentMirrorFilename(sourceUrl string, contentType string, index int) string {
+	name := ""
+	if u, err := url.Parse(sourceUrl); err == nil {
+		name = path.Base(u.Path)
+	}
+
+	if name == "" || name == "." || name == "/" {
+		name = "attachment_" + strconv.Itoa(index)
+	}
+
+	if filepath.Ext(name) == "" {
+		if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+			if extensions, _ := mime.ExtensionsByType(mediaType); len(extensions) > 0 {
+				name += extensions[0]
+			}
+		}
+	}
+
+	return name
+}
+
+// MirrorMessageAttachmentsInBackground sao lưu attachment của tin nhắn hoặc bình luận mà không chặn xử lý webhook
+func (app *App) MirrorMessageAttachmentsInBackground(message *model.FacebookConversationMessage, sourceUrls []string) {
+	if !*app.Config().FacebookAPISettings.EnableAttachmentMirroring || len(sourceUrls) == 0 {
+		return
+	}
+
+	app.Srv.Go(func() {
+		if _, err := app.MirrorMessageAttachments(message, sourceUrls); err != nil {
+			mlog.Warn("Unable to mirror message attachments", mlog.String("message_id", message.Id), mlog.Err(err))
+		}
+	})
+}
+
+// MirrorMessageAttachments tải attachment từ CDN của facebook, lưu qua FileBackend thành các FileInfo gắn với tin nhắn,
+// tạo preview/thumbnail cho ảnh rồi cập nhật FileIds của tin nhắn. Attachment nào lỗi sẽ được bỏ qua.
+func (app *App) MirrorMessageAttachments(message *model.FacebookConversationMessage, sourceUrls []string) ([]*model.FileInfo, *model.AppError) {
+	existing, err := app.GetFileInfosForMessage(message.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	mirrored := map[string]bool{}
+	for _, info := range existing {
+		mirrored[info.SourceUrl] = true
+	}
+
+	var infos []*model.FileInfo
+	var previewPaths, thumbnailPaths []string
+	var images [][]byte
+
+	for i, sourceUrl := range sourceUrls {
+		if len(sourceUrl) == 0 || mirrored[sourceUrl] {
+			continue
+		}
+		mirrored[sourceUrl] = true
+
+		info, data, err := app.mirrorAttachment(message, sourceUrl, i)
+		if err != nil {
+			mlog.Warn("Unable to mirror attachment", mlog.String("message_id", message.Id), mlog.String("url", sourceUrl), mlog.Err(err))
+			continue
+		}
+
+		infos = append(infos, info)
+		if info.IsImage() {
+			previewPaths = append(previewPaths, info.PreviewPath)
+			thumbnailPaths = append(thumbnailPaths, info.ThumbnailPath)
+			images = append(images, data)
+		}
+	}
+
+	if len(infos) == 0 {
+		return existing, nil
+	}
+
+	app.HandleImages(previewPaths, thumbnailPaths, images)
+	app.generateMiniPreviewForInfos(infos)
+
+	// việc tải file có thể mất thời gian, chỉ ghi cột FileIds để không ghi đè trạng thái gửi thay đổi trong lúc đó
+	all := append(existing, infos...)
+	message.FileIds = make(model.StringArray, 0, len(all))
+	for _, info := range all {
+		message.FileIds = append(message.FileIds, info.Id)
+	}
+
+	if result := <-app.Srv.Store.FacebookConversation().UpdateMessageFileIds(message.Id, message.FileIds); result.Err != nil {
+		return nil, result.Err
+	}
+
+	event := model.NewWebSocketEvent(model.MESSAGE_ATTACHMENTS_MIRRORED, "", message.PageId, "", nil)
+	event.Add("conversation_id", message.ConversationId)
+	event.Add("id", message.Id)
+	event.Add("file_ids", message.FileIds)
+	app.Publish(event)
+
+	return all, nil
+}
+
+func (app *App) mirrorAttachment(message *model.FacebookConversationMessage, sourceUrl string, index int) (*model.FileInfo, []byte, *model.AppError) {
+	data, contentType, err := app.downloadAttachment(sourceUrl)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	filename := attachmentMirrorFilename(sourceUrl, contentType, index)
+
+	info, err := model.GetInfoForBytes(filename, bytes.NewReader(data), len(data))
+	if err != nil {
+		return nil, nil, err
+	}
+
+	info.Id = model.NewId()
+	info.PageId = message.PageId
+	info.MessageId = message.Id
+	info.SourceUrl = sourceUrl
+	info.CreateAt = model.GetMillis()
+	info.UpdateAt = info.CreateAt
+
+	pathPrefix := time.Now().Format("20060102") + "/fanpages/" + message.PageId + "/messages/" + message.Id + "/" + info.Id + "/"
+	info.Path = pathPrefix + filename
+
+	if info.IsImage() {
+		if int64(info.Width)*int64(info.Height) > MaxImageSize {
+			return nil, nil, model.NewAppError("mirrorAttachment", "api.file.upload_file.large_image.app_error", map[string]interface{}{"Filename": filename}, "", http.StatusBadRequest)
+		}
+
+		nameWithoutExtension := strings.TrimSuffix(filename, filepath.Ext(filename))
+		info.PreviewPath = pathPrefix + nameWithoutExtension + "_preview.jpg"
+		info.ThumbnailPath = pathPrefix + nameWithoutExtension + "_thumb.jpg"
+	}
+
+	if _, err := app.WriteFile(bytes.NewReader(data), info.Path); err != nil {
+		return nil, nil, err
+	}
+
+	if _, nErr := app.Srv.Store.FileInfo().Save(info); nErr != nil {
+		return nil, nil, model.NewAppError("mirrorAttachment", "app.file_info.save.app_error", nil, nErr.Error(), http.StatusInternalServerError)
+	}
+
+	return info, data, nil
+}
+
+// downloadAttachment tải attachment, giới hạn theo FileSettings.MaxFileSize
+func (app *App) downloadAttachment(sourceUrl string) ([]byte, string, *model.AppError) {
+	resp, err := app.HTTPService.MakeClient(true).Get(sourceUrl)
+	if err != nil {
+		return nil, "", model.NewAppError("downloadAttachment", "app.attachment_mirror.download.app_error", nil, err.Error(), http.StatusBadRequest)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", model.NewAppError("downloadAttachment", "app.attachment_mirror.download.app_error", nil, "status="+resp.Status, http.StatusBadRequest)
+	}
+
+	maxSize := *app.Config().FileSettings.MaxFileSize
+	data, err := ioutil.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, "", model.NewAppError("downloadAttachment", "app.attachment_mirror.download.app_error", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	if int64(len(data)) > maxSize {
+		return nil, "", model.NewAppError("downloadAttachment", "app.attachment_mirror.too_large.app_error", nil, "url="+sourceUrl, http.StatusRequestEntityTooLarge)
+	}
+
+	return data, resp.Header.Get("Content-Type"), nil
+}
+
+// GetConversationMessageFiles trả về các file đã sao lưu của một tin nhắn trong hội thoại
+func (app *App) GetConversationMessageFiles(conversationId, messageId string) ([]*model.FileInfo, *model.AppError) {
+	result := <-app.Srv.Store.FacebookConversation().GetOutboxMessage(messageId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	if result.Data.(*model.FacebookConversationMessage).ConversationId != conversationId {
+		return nil, model.NewAppError("GetConversationMessageFiles", "app.attachment_mirror.message_not_found.app_error", nil, "id="+messageId, http.StatusNotFound)
+	}
+
+	return app.GetFileInfosForMessage(messageId)
+}
+
+func (app *App) GetFileInfosForMessage(messageId string) ([]*model.FileInfo, *model.AppError) {
+	infos, err := app.Srv.Store.FileInfo().GetForMessage(messageId)
+	if err != nil {
+		return nil, model.NewAppError("GetFileInfosForMessage", "app.file_info.get_for_message.app_error", nil, err.Error(), http.StatusInternalServerError)
+	}
+	return infos, nil
+}
@@ -64,6 +64,56 @@ func (app *App) SessionHasPermissionToChannel(session model.Session, channelId s
 	return  true
 }
 
+// SessionHasPermissionToFanpage kiểm tra quyền của session trên page theo role của FanpageMember,
+// các role hệ thống (vd: system_admin) vẫn được áp dụng nếu user không phải member của page
+func (app *App) SessionHasPermissionToFanpage(session model.Session, pageId string, permission *model.Permission) bool {
+	if pageId == "" {
+		return false
+	}
+
+	if result := <-app.Srv.Store.Fanpage().GetAllPageMembersForUser(session.UserId, true, false); result.Err == nil {
+		members := result.Data.(map[string]string)
+		if roles, ok := members[pageId]; ok {
+			if app.RolesGrantPermission(strings.Fields(roles), permission.Id) {
+				return true
+			}
+		}
+	}
+
+	return app.SessionHasPermissionTo(session, permission)
+}
+
+// SessionHasPermissionToConversation kiểm tra quyền của session trên page chứa hội thoại
+func (app *App) SessionHasPermissionToConversation(session model.Session, conversationId string, permission *model.Permission) bool {
+	if conversationId == "" {
+		return false
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().Get(conversationId)
+	if result.Err != nil {
+		return app.SessionHasPermissionTo(session, permission)
+	}
+
+	return app.SessionHasPermissionToFanpage(session, result.Data.(*model.FacebookConversation).PageId, permission)
+}
+
+// GetPageIdsForSession trả về các page mà session có quyền permission, dùng để giới hạn các truy vấn trên nhiều page
+func (app *App) GetPageIdsForSession(session model.Session, permission *model.Permission) []string {
+	pageIds := []string{}
+
+	result := <-app.Srv.Store.Fanpage().GetAllPageMembersForUser(session.UserId, true, false)
+	if result.Err != nil {
+		return pageIds
+	}
+
+	for pageId, roles := range result.Data.(map[string]string) {
+		if app.RolesGrantPermission(strings.Fields(roles), permission.Id) {
+			pageIds = append(pageIds, pageId)
+		}
+	}
+	return pageIds
+}
+
 func (app *App) SessionHasPermissionToChannelByPost(session model.Session, postId string, permission *model.Permission) bool {
 	//var channelMember *model.ChannelMember
 	//if result := <-a.Srv.Store.Channel().GetMemberForPost(postId, session.UserId); result.Err == nil {
@@ -0,0 +1,544 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	// số hội thoại được xử lý trong một bước của job, bước ngắn để pause/cancel có hiệu lực sớm
+	AUTO_MESSAGE_TASK_BATCH_SIZE = 10
+
+	// thời gian chờ tối đa trong một bước khi page đang bị Facebook giới hạn
+	AUTO_MESSAGE_TASK_THROTTLE_MAX_WAIT = 30 * time.Second
+
+	// job gửi tin nhắn đang chạy mà không lưu cursor trong khoảng thời gian này được coi là bị bỏ dở
+	AUTO_MESSAGE_TASK_JOB_STALE_MILLIS = 15 * 60 * 1000
+)
+
+// autoMessagePageLimiter chia đều lượt gửi của các chiến dịch trên cùng một page theo AutoMessagePerMinute
+type autoMessagePageLimiter struct {
+	mutex sync.Mutex
+	next  map[string]time.Time
+}
+
+var autoMessageLimiter = &autoMessagePageLimiter{next: map[string]time.Time{}}
+
+// wait giữ chỗ lượt gửi tiếp theo của page và chờ tới lượt đó
+func (l *autoMessagePageLimiter) wait(pageId string, interval time.Duration) {
+	l.mutex.Lock()
+	now := time.Now()
+	next := l.next[pageId]
+	if next.Before(now) {
+		next = now
+	}
+	l.next[pageId] = next.Add(interval)
+	l.mutex.Unlock()
+
+	time.Sleep(next.Sub(now))
+}
+
+func (app *App) GetAutoMessageTask(taskId string) (*model.AutoMessageTask, *model.AppError) {
+	result := <-app.Srv.Store.AutoMessageTask().Get(taskId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.AutoMessageTask), nil
+}
+
+// GetAutoMessageTaskProgress trả về bộ đếm của chiến dịch kèm trạng thái job gửi tin nhắn gần nhất
+func (app *App) GetAutoMessageTaskProgress(taskId string) (*model.AutoMessageTaskProgress, *model.AppError) {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	progress := task.Progress()
+	if len(task.JobId) > 0 {
+		if job, err := app.Srv.Jobs.GetJob(task.JobId); err == nil {
+			progress.JobStatus = job.Status
+		}
+	}
+	return progress, nil
+}
+
+func (app *App) GetAutoMessageTaskRecipients(taskId string, status string, page, perPage int) ([]*model.AutoMessageTaskRecipient, *model.AppError) {
+	result := <-app.Srv.Store.AutoMessageTask().GetRecipients(taskId, status, page*perPage, perPage)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.AutoMessageTaskRecipient), nil
+}
+
+// StartAutoMessageTask bắt đầu gửi tin nhắn của chiến dịch mới tạo, việc gửi do jobs worker thực hiện
+func (app *App) StartAutoMessageTask(taskId string, userId string) (*model.AutoMessageTask, *model.AppError) {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(task.Message) == 0 && len(task.Attachments) == 0 {
+		return nil, model.NewAppError("StartAutoMessageTask", "app.auto_message_task.empty_message.app_error", nil, "task_id="+taskId, http.StatusBadRequest)
+	}
+
+	if err := task.IsValid(); err != nil {
+		return nil, err
+	}
+
+	return app.runAutoMessageTask(task, model.AUTO_MESSAGE_TASK_STATUS_CREATED, "", userId)
+}
+
+// PauseAutoMessageTask dừng job đang gửi, chiến dịch chạy tiếp từ vị trí đã dừng khi resume
+func (app *App) PauseAutoMessageTask(taskId string) (*model.AutoMessageTask, *model.AppError) {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.setAutoMessageTaskStatus(task, []string{model.AUTO_MESSAGE_TASK_STATUS_ACTIVE}, model.AUTO_MESSAGE_TASK_STATUS_PAUSED, ""); err != nil {
+		return nil, err
+	}
+
+	app.cancelAutoMessageTaskJob(task)
+	app.publishAutoMessageTaskProgress(task)
+
+	return task, nil
+}
+
+// ResumeAutoMessageTask tạo job mới chạy tiếp từ cursor của job đã bị pause
+func (app *App) ResumeAutoMessageTask(taskId string, userId string) (*model.AutoMessageTask, *model.AppError) {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	var cursor string
+	if len(task.JobId) > 0 {
+		if job, err := app.Srv.Jobs.GetJob(task.JobId); err == nil {
+			cursor = job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_CURSOR]
+		}
+	}
+
+	return app.runAutoMessageTask(task, model.AUTO_MESSAGE_TASK_STATUS_PAUSED, cursor, userId)
+}
+
+// CancelAutoMessageTask huỷ chiến dịch chưa kết thúc, các tin nhắn đã gửi được giữ nguyên
+func (app *App) CancelAutoMessageTask(taskId string) (*model.AutoMessageTask, *model.AppError) {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return nil, err
+	}
+
+	currentStatuses := []string{model.AUTO_MESSAGE_TASK_STATUS_CREATED, model.AUTO_MESSAGE_TASK_STATUS_ACTIVE, model.AUTO_MESSAGE_TASK_STATUS_PAUSED}
+	if err := app.setAutoMessageTaskStatus(task, currentStatuses, model.AUTO_MESSAGE_TASK_STATUS_CANCELED, ""); err != nil {
+		return nil, err
+	}
+
+	app.cancelAutoMessageTaskJob(task)
+	app.publishAutoMessageTaskProgress(task)
+
+	return task, nil
+}
+
+// DeleteAutoMessageTask huỷ chiến dịch nếu đang chạy rồi xoá
+func (app *App) DeleteAutoMessageTask(taskId string) *model.AppError {
+	task, err := app.GetAutoMessageTask(taskId)
+	if err != nil {
+		return err
+	}
+
+	if !task.IsFinished() {
+		if _, err := app.CancelAutoMessageTask(taskId); err != nil && err.StatusCode != http.StatusConflict {
+			return err
+		}
+	}
+
+	if result := <-app.Srv.Store.AutoMessageTask().Delete(taskId, model.GetMillis()); result.Err != nil {
+		return result.Err
+	}
+	return nil
+}
+
+// runAutoMessageTask chuyển chiến dịch từ fromStatus sang active và tạo job gửi tin nhắn bắt đầu từ cursor.
+// Chiến dịch được chuyển sang active trước khi tạo job để worker không bỏ qua job vừa tạo.
+func (app *App) runAutoMessageTask(task *model.AutoMessageTask, fromStatus string, cursor string, userId string) (*model.AutoMessageTask, *model.AppError) {
+	if err := app.setAutoMessageTaskStatus(task, []string{fromStatus}, model.AUTO_MESSAGE_TASK_STATUS_ACTIVE, ""); err != nil {
+		return nil, err
+	}
+
+	job, err := app.Srv.Jobs.CreateJob(model.JOB_TYPE_AUTO_MESSAGE_TASK, map[string]string{
+		model.JOB_DATA_AUTO_MESSAGE_TASK_ID:      task.Id,
+		model.JOB_DATA_AUTO_MESSAGE_TASK_USER_ID: userId,
+		model.JOB_DATA_AUTO_MESSAGE_TASK_CURSOR:  cursor,
+	})
+	if err != nil {
+		if result := <-app.Srv.Store.AutoMessageTask().UpdateStatus(task.Id, []string{model.AUTO_MESSAGE_TASK_STATUS_ACTIVE}, fromStatus, ""); result.Err != nil {
+			mlog.Error("Unable to revert auto message task status", mlog.String("task_id", task.Id), mlog.Err(result.Err))
+		}
+		return nil, err
+	}
+
+	if result := <-app.Srv.Store.AutoMessageTask().UpdateStatus(task.Id, []string{model.AUTO_MESSAGE_TASK_STATUS_ACTIVE}, model.AUTO_MESSAGE_TASK_STATUS_ACTIVE, job.Id); result.Err != nil {
+		mlog.Error("Unable to save auto message task job", mlog.String("task_id", task.Id), mlog.String("job_id", job.Id), mlog.Err(result.Err))
+	}
+	task.JobId = job.Id
+
+	app.publishAutoMessageTaskProgress(task)
+
+	return task, nil
+}
+
+func (app *App) setAutoMessageTaskStatus(task *model.AutoMessageTask, currentStatuses []string, status string, jobId string) *model.AppError {
+	result := <-app.Srv.Store.AutoMessageTask().UpdateStatus(task.Id, currentStatuses, status, jobId)
+	if result.Err != nil {
+		return result.Err
+	}
+	if !result.Data.(bool) {
+		return model.NewAppError("setAutoMessageTaskStatus", "app.auto_message_task.invalid_status.app_error", map[string]interface{}{"Status": task.Status}, "task_id="+task.Id+", status="+status, http.StatusConflict)
+	}
+
+	if status == model.AUTO_MESSAGE_TASK_STATUS_ACTIVE && task.StartAt == 0 {
+		task.StartAt = model.GetMillis()
+	}
+	task.Status = status
+	if len(jobId) > 0 {
+		task.JobId = jobId
+	}
+	return nil
+}
+
+// cancelAutoMessageTaskJob yêu cầu dừng job gửi tin nhắn của chiến dịch, job đã kết thúc thì bỏ qua
+func (app *App) cancelAutoMessageTaskJob(task *model.AutoMessageTask) {
+	if len(task.JobId) == 0 {
+		return
+	}
+
+	job, err := app.Srv.Jobs.GetJob(task.JobId)
+	if err != nil || (job.Status != model.JOB_STATUS_PENDING && job.Status != model.JOB_STATUS_IN_PROGRESS) {
+		return
+	}
+
+	if err := app.Srv.Jobs.RequestCancellation(job.Id); err != nil {
+		mlog.Warn("Unable to cancel auto message task job", mlog.String("task_id", task.Id), mlog.String("job_id", job.Id), mlog.Err(err))
+	}
+}
+
+func (app *App) StartAutoMessageTaskJob(job *model.Job) {
+	if task, err := app.GetAutoMessageTask(job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_ID]); err == nil {
+		app.publishAutoMessageTaskProgress(task)
+	}
+}
+
+// AutoMessageTaskJobStep gửi tin nhắn cho một trang hội thoại tiếp theo thoả bộ lọc của chiến dịch và lưu cursor vào job data.
+// Trả về true khi đã duyệt hết hội thoại hoặc chiến dịch không còn active (bị pause/cancel).
+func (app *App) AutoMessageTaskJobStep(job *model.Job) (bool, *model.AppError) {
+	task, err := app.GetAutoMessageTask(job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_ID])
+	if err != nil {
+		return false, err
+	}
+
+	if task.Status != model.AUTO_MESSAGE_TASK_STATUS_ACTIVE || task.DeleteAt > 0 {
+		return true, nil
+	}
+
+	// page đang bị Facebook giới hạn, chờ rồi thử lại ở bước sau
+	if app.Srv.FacebookOutbox != nil {
+		if until := app.Srv.FacebookOutbox.ThrottledUntil(task.PageId); until > 0 {
+			wait := time.Duration(until-model.GetMillis()) * time.Millisecond
+			if wait > AUTO_MESSAGE_TASK_THROTTLE_MAX_WAIT {
+				wait = AUTO_MESSAGE_TASK_THROTTLE_MAX_WAIT
+			}
+			time.Sleep(wait)
+			return false, nil
+		}
+	}
+
+	searchResult, err := app.SearchConversations(task.SearchOptions(job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_CURSOR], AUTO_MESSAGE_TASK_BATCH_SIZE))
+	if err != nil {
+		return false, err
+	}
+
+	conversations, err := app.filterAutoMessageRecipients(task, searchResult.Conversations)
+	if err != nil {
+		return false, err
+	}
+
+	var sent, failed, skipped, queued int64
+	userId := job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_USER_ID]
+	for _, conversation := range conversations {
+		// ghi nhận người nhận trước khi gửi để job chạy lại không gửi lặp cho hội thoại này
+		recipient := &model.AutoMessageTaskRecipient{
+			TaskId:         task.Id,
+			ConversationId: conversation.Id,
+			FacebookUid:    conversation.From,
+			Status:         model.AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED,
+		}
+		if result := <-app.Srv.Store.AutoMessageTask().SaveRecipient(recipient); result.Err != nil {
+			mlog.Error("Unable to save auto message task recipient", mlog.String("task_id", task.Id), mlog.String("conversation_id", conversation.Id), mlog.Err(result.Err))
+			continue
+		}
+
+		app.sendAutoMessage(task, conversation, recipient, userId)
+
+		if result := <-app.Srv.Store.AutoMessageTask().UpdateRecipient(recipient); result.Err != nil {
+			mlog.Error("Unable to update auto message task recipient", mlog.String("task_id", task.Id), mlog.String("conversation_id", conversation.Id), mlog.Err(result.Err))
+		}
+
+		switch recipient.Status {
+		case model.AUTO_MESSAGE_RECIPIENT_STATUS_SENT:
+			sent++
+		case model.AUTO_MESSAGE_RECIPIENT_STATUS_FAILED:
+			failed++
+		case model.AUTO_MESSAGE_RECIPIENT_STATUS_SKIPPED:
+			skipped++
+		case model.AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED:
+			queued++
+		}
+	}
+
+	if len(conversations) > 0 {
+		if result := <-app.Srv.Store.AutoMessageTask().IncrementCounts(task.Id, sent, failed, skipped, queued); result.Err != nil {
+			return false, result.Err
+		}
+		task.SentCount += sent
+		task.FailedCount += failed
+		task.SkippedCount += skipped
+		task.QueuedCount += queued
+	}
+
+	job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_CURSOR] = searchResult.NextCursor
+	if len(searchResult.NextCursor) == 0 {
+		job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_DONE] = "true"
+	}
+
+	app.publishAutoMessageTaskProgress(task)
+
+	return len(searchResult.NextCursor) == 0, nil
+}
+
+// filterAutoMessageRecipients áp dụng bộ lọc người nhận và đơn hàng, đồng thời bỏ các hội thoại đã được chiến dịch xử lý
+func (app *App) filterAutoMessageRecipients(task *model.AutoMessageTask, conversations []*model.FacebookConversation) ([]*model.FacebookConversation, *model.AppError) {
+	if len(conversations) == 0 {
+		return conversations, nil
+	}
+
+	receptions := map[string]bool{}
+	for _, uid := range task.GetFilterReceptions() {
+		receptions[uid] = true
+	}
+
+	var conversationIds, facebookUids []string
+	for _, conversation := range conversations {
+		conversationIds = append(conversationIds, conversation.Id)
+		facebookUids = append(facebookUids, conversation.From)
+	}
+
+	result := <-app.Srv.Store.AutoMessageTask().GetRecipientConversationIds(task.Id, conversationIds)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	processed := map[string]bool{}
+	for _, id := range result.Data.([]string) {
+		processed[id] = true
+	}
+
+	hasOrder := map[string]bool{}
+	if task.FilterHasOrder || task.FilterNotHasOrder {
+		result := <-app.Srv.Store.Order().GetFacebookUidsWithOrders(task.PageId, facebookUids)
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		for _, uid := range result.Data.([]string) {
+			hasOrder[uid] = true
+		}
+	}
+
+	var recipients []*model.FacebookConversation
+	for _, conversation := range conversations {
+		if processed[conversation.Id] || len(receptions) > 0 && !receptions[conversation.From] {
+			continue
+		}
+		if task.FilterHasOrder && !hasOrder[conversation.From] || task.FilterNotHasOrder && hasOrder[conversation.From] {
+			continue
+		}
+		recipients = append(recipients, conversation)
+	}
+	return recipients, nil
+}
+
+// sendAutoMessage gửi nội dung chiến dịch cho một hội thoại qua outbox và ghi kết quả gửi vào recipient.
+// Tin nhắn Facebook tạm thời từ chối vẫn nằm trong outbox để gửi lại, được ghi nhận là queued.
+func (app *App) sendAutoMessage(task *model.AutoMessageTask, conversation *model.FacebookConversation, recipient *model.AutoMessageTaskRecipient, userId string) {
+	recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_SENT
+
+	interval := time.Minute / time.Duration(*app.Config().FacebookAPISettings.AutoMessagePerMinute)
+
+	for _, reply := range autoMessageReplies(task, conversation) {
+		if err := reply.IsValid(); err != nil {
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_FAILED
+			recipient.Error = err.Error()
+			return
+		}
+
+		// ngoài cửa sổ nhắn tin mà chiến dịch không có tag phù hợp thì bỏ qua người nhận
+		if err := app.CheckReplyMessagingWindow(conversation, reply); err != nil {
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_SKIPPED
+			recipient.Error = err.Id
+			return
+		}
+
+		autoMessageLimiter.wait(task.PageId, interval)
+
+		message, err := app.saveOutboxReply(conversation.Id, reply, userId)
+		if err != nil {
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_FAILED
+			recipient.Error = err.Error()
+			return
+		}
+		if len(recipient.MessageId) == 0 {
+			recipient.MessageId = message.Id
+		}
+
+		app.SendOutboxMessage(message.Id)
+
+		result := <-app.Srv.Store.FacebookConversation().GetOutboxMessage(message.Id)
+		if result.Err != nil {
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED
+			continue
+		}
+
+		switch sentMessage := result.Data.(*model.FacebookConversationMessage); sentMessage.Status {
+		case model.MESSAGE_STATUS_FAILED:
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_FAILED
+			recipient.Error = sentMessage.LastError
+			return
+		case model.MESSAGE_STATUS_PENDING:
+			recipient.Status = model.AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED
+			if app.Srv.FacebookOutbox != nil {
+				app.Srv.FacebookOutbox.Push(sentMessage.Id)
+			}
+		}
+	}
+}
+
+// autoMessageReplies tạo các tin nhắn gửi cho hội thoại, Send API không cho gửi chữ và đính kèm trong cùng một tin nhắn
+// nên đính kèm được gửi thành tin nhắn riêng sau nội dung chữ
+func autoMessageReplies(task *model.AutoMessageTask, conversation *model.FacebookConversation) []*model.ConversationReply {
+	threadId := conversation.ScopedThreadKey
+	if len(threadId) == 0 {
+		threadId = conversation.Id
+	}
+
+	messagingType := facebookgraph.MESSAGING_TYPE_UPDATE
+	var tag string
+	if len(task.Tag) > 0 && !conversation.GetMessagingWindow(model.GetMillis()).Open {
+		messagingType = facebookgraph.MESSAGING_TYPE_MESSAGE_TAG
+		tag = task.Tag
+	}
+
+	newReply := func() *model.ConversationReply {
+		return &model.ConversationReply{
+			PageId:        conversation.PageId,
+			Type:          "message",
+			ThreadId:      threadId,
+			To:            conversation.From,
+			PageScopeId:   conversation.PageScopeId,
+			MessagingType: messagingType,
+			Tag:           tag,
+		}
+	}
+
+	var replies []*model.ConversationReply
+	if len(task.Message) > 0 {
+		reply := newReply()
+		reply.Message = task.Message
+		replies = append(replies, reply)
+	}
+
+	if len(task.Attachments) > 0 {
+		reply := newReply()
+		if model.IsValidId(task.Attachments) {
+			reply.FileId = task.Attachments
+		} else {
+			reply.AttachmentUrl = task.Attachments
+		}
+		replies = append(replies, reply)
+	}
+
+	return replies
+}
+
+func (app *App) FinishAutoMessageTaskJob(job *model.Job) {
+	app.finishAutoMessageTask(job, model.AUTO_MESSAGE_TASK_STATUS_SUCCESS)
+}
+
+func (app *App) FailAutoMessageTaskJob(job *model.Job, jobErr *model.AppError) {
+	mlog.Error("Auto message task job failed", mlog.String("task_id", job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_ID]), mlog.String("job_id", job.Id), mlog.Err(jobErr))
+	app.finishAutoMessageTask(job, model.AUTO_MESSAGE_TASK_STATUS_ERROR)
+}
+
+// CancelAutoMessageTaskJob được gọi khi job bị huỷ. Chiến dịch bị pause thì giữ nguyên để resume,
+// job bị huỷ trực tiếp qua jobs API thì chiến dịch cũng bị huỷ theo.
+func (app *App) CancelAutoMessageTaskJob(job *model.Job) {
+	task, err := app.GetAutoMessageTask(job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_ID])
+	if err != nil {
+		return
+	}
+
+	// chiến dịch đã được resume bằng job khác thì không huỷ theo job cũ
+	if task.Status == model.AUTO_MESSAGE_TASK_STATUS_ACTIVE && task.JobId == job.Id {
+		if err := app.setAutoMessageTaskStatus(task, []string{model.AUTO_MESSAGE_TASK_STATUS_ACTIVE}, model.AUTO_MESSAGE_TASK_STATUS_CANCELED, ""); err != nil {
+			mlog.Error("Unable to cancel auto message task", mlog.String("task_id", task.Id), mlog.Err(err))
+		}
+	}
+
+	app.publishAutoMessageTaskProgress(task)
+}
+
+// ReleaseAutoMessageTaskJob trả job đang chạy về pending khi server dừng, job sẽ chạy tiếp từ cursor đã lưu
+func (app *App) ReleaseAutoMessageTaskJob(job *model.Job) {
+	if result := <-app.Srv.Store.Job().UpdateStatusOptimistically(job.Id, model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_PENDING); result.Err != nil {
+		mlog.Error("Unable to release auto message task job", mlog.String("job_id", job.Id), mlog.Err(result.Err))
+	}
+}
+
+func (app *App) ResetStaleAutoMessageTaskJobs() {
+	app.resetStaleJobs(model.JOB_TYPE_AUTO_MESSAGE_TASK, AUTO_MESSAGE_TASK_JOB_STALE_MILLIS, app.CancelAutoMessageTaskJob)
+}
+
+// finishAutoMessageTask kết thúc chiến dịch còn active và báo số lượng cuối cùng cho các thành viên của page
+func (app *App) finishAutoMessageTask(job *model.Job, status string) {
+	task, err := app.GetAutoMessageTask(job.Data[model.JOB_DATA_AUTO_MESSAGE_TASK_ID])
+	if err != nil {
+		return
+	}
+
+	// job cũ của chiến dịch đã được resume bằng job khác
+	if task.JobId != job.Id {
+		return
+	}
+
+	if result := <-app.Srv.Store.AutoMessageTask().Finish(task.Id, status, model.GetMillis()); result.Err != nil {
+		mlog.Error("Unable to finish auto message task", mlog.String("task_id", task.Id), mlog.String("status", status), mlog.Err(result.Err))
+	} else if result.Data.(bool) {
+		task.Status = status
+		task.EndAt = model.GetMillis()
+	}
+
+	app.publishAutoMessageTaskProgress(task)
+}
+
+func (app *App) publishAutoMessageTaskProgress(task *model.AutoMessageTask) {
+	message := model.NewWebSocketEvent(model.AUTO_MESSAGE_TASK_UPDATED, "", task.PageId, "", nil)
+	message.Add("page_id", task.PageId)
+	message.Add("task_id", task.Id)
+	message.Add("progress", task.Progress().ToJson())
+	app.Publish(message)
+}
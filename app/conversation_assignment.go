@@ -0,0 +1,277 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"sort"
+	"sync"
+)
+
+// các hội thoại mới của cùng một page có thể đến đồng thời, khoá theo page để round-robin không giao trùng một nhân viên
+var conversationRoutingLocks = map[string]*sync.Mutex{}
+var conversationRoutingLocksMutex sync.Mutex
+
+func conversationRoutingLock(pageId string) *sync.Mutex {
+	conversationRoutingLocksMutex.Lock()
+	defer conversationRoutingLocksMutex.Unlock()
+
+	lock, ok := conversationRoutingLocks[pageId]
+	if !ok {
+		lock = &sync.Mutex{}
+		conversationRoutingLocks[pageId] = lock
+	}
+	return lock
+}
+
+// AssignConversation giao hội thoại cho assigneeId, assignedBy rỗng khi hội thoại được định tuyến tự động
+func (app *App) AssignConversation(conversationId, assigneeId, assignedBy string) (*model.FacebookConversation, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	member, err := app.GetFanpageMember(conversation.PageId, assigneeId)
+	if err != nil || !app.RolesGrantPermission(member.GetRoles(), model.PERMISSION_REPLY_CONVERSATIONS.Id) {
+		return nil, model.NewAppError("AssignConversation", "app.conversation.assign.invalid_assignee.app_error", nil, "conversation_id="+conversationId+", user_id="+assigneeId, http.StatusBadRequest)
+	}
+
+	return app.updateConversationAssignee(conversation, assigneeId, assignedBy)
+}
+
+func (app *App) UnassignConversation(conversationId, unassignedBy string) (*model.FacebookConversation, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	if len(conversation.AssignedTo) == 0 {
+		return conversation, nil
+	}
+
+	return app.updateConversationAssignee(conversation, "", unassignedBy)
+}
+
+func (app *App) updateConversationAssignee(conversation *model.FacebookConversation, assigneeId, assignedBy string) (*model.FacebookConversation, *model.AppError) {
+	assignedAt := model.GetMillis()
+	if result := <-app.Srv.Store.FacebookConversation().UpdateAssignee(conversation.Id, assigneeId, assignedBy, assignedAt); result.Err != nil {
+		return nil, result.Err
+	}
+
+	app.publishConversationAssignee(conversation, assigneeId, assignedBy, assignedAt)
+	return conversation, nil
+}
+
+func (app *App) publishConversationAssignee(conversation *model.FacebookConversation, assigneeId, assignedBy string, assignedAt int64) {
+	previousAssignee := conversation.AssignedTo
+	conversation.AssignedTo = assigneeId
+	conversation.AssignedBy = assignedBy
+	conversation.AssignedAt = assignedAt
+
+	message := model.NewWebSocketEvent(model.CONVERSATION_ASSIGNEE_UPDATED, "", conversation.PageId, "", nil)
+	message.Add("conversation_id", conversation.Id)
+	message.Add("page_id", conversation.PageId)
+	message.Add("assigned_to", assigneeId)
+	message.Add("assigned_by", assignedBy)
+	message.Add("previous_assigned_to", previousAssignee)
+	app.Publish(message)
+}
+
+func (app *App) GetConversationRouting(pageId string) (*model.ConversationRouting, *model.AppError) {
+	result := <-app.Srv.Store.ConversationRouting().Get(pageId)
+	if result.Err != nil {
+		if result.Err.StatusCode == http.StatusNotFound {
+			return &model.ConversationRouting{PageId: pageId, Method: model.ROUTING_METHOD_MANUAL}, nil
+		}
+		return nil, result.Err
+	}
+	return result.Data.(*model.ConversationRouting), nil
+}
+
+func (app *App) UpdateConversationRouting(routing *model.ConversationRouting) (*model.ConversationRouting, *model.AppError) {
+	oldRouting, err := app.GetConversationRouting(routing.PageId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, rule := range routing.TagRules {
+		if rule == nil {
+			continue
+		}
+		if tag, err := app.GetTag(rule.TagId); err != nil || tag.PageId != routing.PageId {
+			return nil, model.NewAppError("UpdateConversationRouting", "app.conversation_routing.invalid_tag.app_error", nil, "page_id="+routing.PageId+", tag_id="+rule.TagId, http.StatusBadRequest)
+		}
+	}
+
+	routing.LastAssignedTo = oldRouting.LastAssignedTo
+
+	result := <-app.Srv.Store.ConversationRouting().Save(routing)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.ConversationRouting), nil
+}
+
+// RouteConversation tự động giao hội thoại chưa có người phụ trách theo cấu hình định tuyến của page.
+// Luật theo thẻ được xét trước, sau đó tới round-robin hoặc least-busy. Không có nhân viên phù hợp thì hội thoại
+// vẫn chưa được giao.
+func (app *App) RouteConversation(conversationId string) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		mlog.Warn("Unable to load conversation for routing", mlog.String("conversation_id", conversationId), mlog.Err(err))
+		return
+	}
+
+	if len(conversation.AssignedTo) > 0 {
+		return
+	}
+
+	lock := conversationRoutingLock(conversation.PageId)
+	lock.Lock()
+	defer lock.Unlock()
+
+	routing, err := app.GetConversationRouting(conversation.PageId)
+	if err != nil {
+		mlog.Error("Unable to load conversation routing", mlog.String("page_id", conversation.PageId), mlog.Err(err))
+		return
+	}
+
+	method := routing.Method
+	candidates := []string(routing.UserIds)
+
+	if len(routing.TagRules) > 0 {
+		tags, err := app.GetConversationTags(conversation.Id)
+		if err != nil {
+			mlog.Warn("Unable to load conversation tags for routing", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+		}
+
+		var tagIds []string
+		for _, tag := range tags {
+			tagIds = append(tagIds, tag.TagId)
+		}
+
+		if rule := routing.TagRuleFor(tagIds); rule != nil {
+			candidates = rule.UserIds
+			if method == model.ROUTING_METHOD_MANUAL {
+				method = model.ROUTING_METHOD_ROUND_ROBIN
+			}
+		} else if method == model.ROUTING_METHOD_MANUAL {
+			return
+		}
+	} else if method == model.ROUTING_METHOD_MANUAL {
+		return
+	}
+
+	candidates = app.getRoutingCandidates(conversation.PageId, candidates, routing.OnlyAvailable || method == model.ROUTING_METHOD_LEAST_BUSY)
+	if len(candidates) == 0 {
+		return
+	}
+
+	var assigneeId string
+	if method == model.ROUTING_METHOD_LEAST_BUSY {
+		assigneeId = app.getLeastBusyAgent(conversation.PageId, candidates)
+	} else {
+		assigneeId = model.NextRoundRobin(candidates, routing.LastAssignedTo)
+	}
+
+	if len(assigneeId) == 0 {
+		return
+	}
+
+	// hội thoại có thể vừa được giao thủ công trong lúc định tuyến, chỉ giao khi vẫn chưa có người phụ trách
+	assignedAt := model.GetMillis()
+	result := <-app.Srv.Store.FacebookConversation().AssignIfUnassigned(conversation.Id, assigneeId, assignedAt)
+	if result.Err != nil {
+		mlog.Error("Unable to assign routed conversation", mlog.String("conversation_id", conversation.Id), mlog.String("user_id", assigneeId), mlog.Err(result.Err))
+		return
+	}
+	if !result.Data.(bool) {
+		return
+	}
+	app.publishConversationAssignee(conversation, assigneeId, "", assignedAt)
+
+	if result := <-app.Srv.Store.ConversationRouting().UpdateLastAssignedTo(conversation.PageId, assigneeId); result.Err != nil {
+		mlog.Warn("Unable to save round-robin position", mlog.String("page_id", conversation.PageId), mlog.Err(result.Err))
+	}
+}
+
+// routeConversationIfNeed định tuyến hội thoại chưa được giao sau khi đã trả lời webhook
+func (app *App) routeConversationIfNeed(conversation *model.FacebookConversation) {
+	if len(conversation.AssignedTo) > 0 {
+		return
+	}
+
+	app.Srv.Go(func() {
+		app.RouteConversation(conversation.Id)
+	})
+}
+
+// getRoutingCandidates giữ lại các nhân viên còn là thành viên có quyền trả lời của page, userIds rỗng là mọi thành viên.
+// Kết quả được sắp xếp theo id để round-robin đi theo thứ tự cố định
+func (app *App) getRoutingCandidates(pageId string, userIds []string, onlyAvailable bool) []string {
+	members, err := app.GetFanpageMembers(pageId)
+	if err != nil {
+		mlog.Error("Unable to load page members for routing", mlog.String("page_id", pageId), mlog.Err(err))
+		return nil
+	}
+
+	wanted := map[string]bool{}
+	for _, userId := range userIds {
+		wanted[userId] = true
+	}
+
+	var candidates []string
+	for _, member := range members {
+		if len(wanted) > 0 && !wanted[member.UserId] {
+			continue
+		}
+		if !app.RolesGrantPermission(member.GetRoles(), model.PERMISSION_REPLY_CONVERSATIONS.Id) {
+			continue
+		}
+		if onlyAvailable && !app.isAgentAvailable(member.UserId) {
+			continue
+		}
+		candidates = append(candidates, member.UserId)
+	}
+
+	sort.Strings(candidates)
+	return candidates
+}
+
+// isAgentAvailable cho biết nhân viên đang online hoặc away
+func (app *App) isAgentAvailable(userId string) bool {
+	status, err := app.GetStatus(userId)
+	if err != nil {
+		return false
+	}
+	return status.Status == model.STATUS_ONLINE || status.Status == model.STATUS_AWAY
+}
+
+// getLeastBusyAgent chọn nhân viên có ít hội thoại chưa trả lời nhất, ưu tiên người đang online hơn người đang away
+func (app *App) getLeastBusyAgent(pageId string, candidates []string) string {
+	result := <-app.Srv.Store.FacebookConversation().CountUnrepliedByAssignee(pageId, candidates)
+	if result.Err != nil {
+		mlog.Error("Unable to count assigned conversations", mlog.String("page_id", pageId), mlog.Err(result.Err))
+		return ""
+	}
+	counts := result.Data.(map[string]int64)
+
+	var best string
+	var bestOnline bool
+	var bestCount int64
+	for _, userId := range candidates {
+		online := false
+		if status, err := app.GetStatus(userId); err == nil {
+			online = status.Status == model.STATUS_ONLINE
+		}
+
+		count := counts[userId]
+		if len(best) == 0 || (online && !bestOnline) || (online == bestOnline && count < bestCount) {
+			best, bestOnline, bestCount = userId, online, count
+		}
+	}
+	return best
+}
@@ -0,0 +1,127 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	CONVERSATION_STATUS_AUDIT_ACTION = "conversation_status"
+
+	CONVERSATION_SNOOZE_CHECK_INTERVAL = time.Minute
+	CONVERSATION_SNOOZE_BATCH_SIZE     = 200
+)
+
+// UpdateConversationStatus đổi trạng thái hội thoại theo yêu cầu của nhân viên userId
+func (app *App) UpdateConversationStatus(conversationId string, update *model.ConversationStatusUpdate, userId string) (*model.FacebookConversation, *model.AppError) {
+	if err := update.IsValid(model.GetMillis()); err != nil {
+		return nil, err
+	}
+
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !conversation.CanTransitionTo(update.Status) {
+		return nil, model.NewAppError("UpdateConversationStatus", "app.conversation.update_status.invalid_transition.app_error", map[string]interface{}{"Status": update.Status}, "conversation_id="+conversationId+", status="+conversation.GetStatus(), http.StatusBadRequest)
+	}
+
+	return app.setConversationStatus(conversation, update.Status, update.SnoozedUntil, userId)
+}
+
+// setConversationStatus lưu trạng thái mới, ghi audit và báo cho các thành viên của page.
+// userId rỗng khi hệ thống tự đổi trạng thái (hết thời gian snooze hoặc khách hàng nhắn tin mới)
+func (app *App) setConversationStatus(conversation *model.FacebookConversation, status string, snoozedUntil int64, userId string) (*model.FacebookConversation, *model.AppError) {
+	oldStatus := conversation.GetStatus()
+	updateAt := model.GetMillis()
+
+	result := <-app.Srv.Store.FacebookConversation().UpdateStatus(conversation.Id, oldStatus, status, snoozedUntil, userId, updateAt)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	if !result.Data.(bool) {
+		// trạng thái đã bị đổi bởi người khác hoặc bởi job snooze
+		return nil, model.NewAppError("setConversationStatus", "app.conversation.update_status.conflict.app_error", nil, "conversation_id="+conversation.Id+", status="+oldStatus, http.StatusConflict)
+	}
+
+	conversation.Status = status
+	conversation.SnoozedUntil = snoozedUntil
+	conversation.StatusUpdateAt = updateAt
+	conversation.StatusUpdatedBy = userId
+
+	audit := &model.Audit{
+		UserId:    userId,
+		Action:    CONVERSATION_STATUS_AUDIT_ACTION,
+		ExtraInfo: "conversation_id=" + conversation.Id + " page_id=" + conversation.PageId + " old_status=" + oldStatus + " status=" + status,
+	}
+	if snoozedUntil > 0 {
+		audit.ExtraInfo += " snoozed_until=" + strconv.FormatInt(snoozedUntil, 10)
+	}
+	if err := app.Srv.Store.Audit().Save(audit); err != nil {
+		mlog.Warn("Unable to save conversation status audit", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+	}
+
+	message := model.NewWebSocketEvent(model.CONVERSATION_STATUS_UPDATED, "", conversation.PageId, "", nil)
+	message.Add("conversation_id", conversation.Id)
+	message.Add("page_id", conversation.PageId)
+	message.Add("status", status)
+	message.Add("previous_status", oldStatus)
+	message.Add("snoozed_until", snoozedUntil)
+	message.Add("updated_by", userId)
+	app.Publish(message)
+
+	return conversation, nil
+}
+
+// reopenConversationIfNeed mở lại hội thoại pending, snoozed hoặc closed khi khách hàng gửi tin nhắn mới
+func (app *App) reopenConversationIfNeed(conversation *model.FacebookConversation) {
+	if conversation == nil || !conversation.ShouldReopenOnInbound() {
+		return
+	}
+
+	if _, err := app.setConversationStatus(conversation, model.CONVERSATION_STATUS_OPEN, 0, ""); err != nil && err.StatusCode != http.StatusConflict {
+		mlog.Warn("Unable to reopen conversation", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+	}
+}
+
+// ReopenSnoozedConversations mở lại các hội thoại đã hết thời gian snooze
+func (app *App) ReopenSnoozedConversations() {
+	for {
+		result := <-app.Srv.Store.FacebookConversation().GetSnoozedDue(model.GetMillis(), CONVERSATION_SNOOZE_BATCH_SIZE)
+		if result.Err != nil {
+			mlog.Error("Unable to load snoozed conversations", mlog.Err(result.Err))
+			return
+		}
+
+		conversations := result.Data.([]*model.FacebookConversation)
+		reopened := 0
+		for _, conversation := range conversations {
+			if _, err := app.setConversationStatus(conversation, model.CONVERSATION_STATUS_OPEN, 0, ""); err != nil {
+				if err.StatusCode != http.StatusConflict {
+					mlog.Warn("Unable to reopen snoozed conversation", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+				}
+				continue
+			}
+			reopened++
+		}
+
+		// dừng khi đã hết hội thoại tới hạn, hoặc không mở lại được hội thoại nào để tránh lặp vô hạn
+		if len(conversations) < CONVERSATION_SNOOZE_BATCH_SIZE || reopened == 0 {
+			return
+		}
+	}
+}
+
+func runConversationSnoozeJob(a *App) {
+	a.ReopenSnoozedConversations()
+	model.CreateRecurringTask("Conversation Snooze", func() {
+		a.ReopenSnoozedConversations()
+	}, CONVERSATION_SNOOZE_CHECK_INTERVAL)
+}
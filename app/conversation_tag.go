@@ -26,6 +26,11 @@ func (a *App) AddOrRemoveConversationTag(tag *model.ConversationTag, pageId stri
 		message.Add("page_tag", pageTag)
 		a.Publish(message)
 
+		// hội thoại chưa được giao có thể khớp luật định tuyến theo thẻ vừa gắn
+		a.Srv.Go(func() {
+			a.RouteConversation(rtag.ConversationId)
+		})
+
 		return rtag, nil
 	} else {
 		message := model.NewWebSocketEvent(model.CONVERSATION_REMOVED_TAG, "", pageId, "", nil)
@@ -0,0 +1,407 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (app *App) GetCustomer(customerId string) (*model.CustomerRecord, *model.AppError) {
+	result := <-app.Srv.Store.Customer().Get(customerId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.CustomerRecord), nil
+}
+
+// GetCustomerForConversation trả về hồ sơ của người gửi hội thoại, tạo hồ sơ mới nếu người gửi chưa có
+func (app *App) GetCustomerForConversation(conversation *model.FacebookConversation) (*model.CustomerRecord, *model.AppError) {
+	if len(conversation.From) == 0 {
+		return nil, model.NewAppError("GetCustomerForConversation", "app.customer.missing_sender.app_error", nil, "conversation_id="+conversation.Id, http.StatusBadRequest)
+	}
+
+	return app.getOrCreateCustomer(&model.CustomerIdentity{
+		FacebookUid: conversation.From,
+		PageId:      conversation.PageId,
+		PageScopeId: conversation.PageScopeId,
+	})
+}
+
+// getOrCreateCustomer tìm hồ sơ chứa facebook uid của identity. Nếu chưa có, uid được gắn vào hồ sơ
+// có cùng page scoped id trên cùng page, hoặc vào một hồ sơ mới
+func (app *App) getOrCreateCustomer(identity *model.CustomerIdentity) (*model.CustomerRecord, *model.AppError) {
+	if result := <-app.Srv.Store.Customer().GetIdentity(identity.FacebookUid); result.Err == nil {
+		return app.GetCustomer(result.Data.(*model.CustomerIdentity).CustomerId)
+	} else if result.Err.StatusCode != http.StatusNotFound {
+		return nil, result.Err
+	}
+
+	if result := <-app.Srv.Store.FacebookUid().Get(identity.FacebookUid); result.Err == nil {
+		identity.Name = result.Data.(*model.FacebookUid).Name
+	}
+
+	var customer *model.CustomerRecord
+	if len(identity.PageId) > 0 && len(identity.PageScopeId) > 0 {
+		if result := <-app.Srv.Store.Customer().GetIdentityByPageScopeId(identity.PageId, identity.PageScopeId); result.Err == nil {
+			customer, _ = app.GetCustomer(result.Data.(*model.CustomerIdentity).CustomerId)
+		}
+	}
+
+	created := false
+	if customer == nil {
+		result := <-app.Srv.Store.Customer().Save(&model.CustomerRecord{Name: identity.Name})
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		customer = result.Data.(*model.CustomerRecord)
+		created = true
+	}
+
+	identity.CustomerId = customer.Id
+	if result := <-app.Srv.Store.Customer().SaveIdentity(identity); result.Err != nil {
+		if result.Err.StatusCode != http.StatusConflict {
+			return nil, result.Err
+		}
+
+		// một request khác vừa gắn uid này vào hồ sơ khác, dùng hồ sơ đó và bỏ hồ sơ vừa tạo
+		if created {
+			<-app.Srv.Store.Customer().Delete(customer.Id, model.GetMillis())
+		}
+		existing := <-app.Srv.Store.Customer().GetIdentity(identity.FacebookUid)
+		if existing.Err != nil {
+			return nil, existing.Err
+		}
+		return app.GetCustomer(existing.Data.(*model.CustomerIdentity).CustomerId)
+	}
+
+	return customer, nil
+}
+
+// GetCustomerProfile gom định danh, hội thoại, đơn hàng, thẻ và ghi chú của khách hàng trên mọi page
+func (app *App) GetCustomerProfile(customerId string) (*model.CustomerProfile, *model.AppError) {
+	customer, err := app.GetCustomer(customerId)
+	if err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.Customer().GetIdentities(customerId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	profile := &model.CustomerProfile{
+		Customer:   customer,
+		Identities: result.Data.([]*model.CustomerIdentity),
+		Tags:       []*model.PageTag{},
+		Notes:      []*model.ConversationNote{},
+	}
+
+	// số điện thoại trích từ tin nhắn được lưu theo từng định danh, gom lại để hiển thị cùng số nhập tay
+	for _, identity := range profile.Identities {
+		customer.AddPhones(identity.Phones...)
+	}
+	uids := profile.FacebookUids()
+
+	conversationsResult := <-app.Srv.Store.FacebookConversation().GetBySenderIds(uids, model.CUSTOMER_PROFILE_CONVERSATIONS_MAX)
+	if conversationsResult.Err != nil {
+		return nil, conversationsResult.Err
+	}
+	profile.Conversations = conversationsResult.Data.([]*model.FacebookConversation)
+
+	ordersResult := <-app.Srv.Store.Order().GetByFacebookUids(uids, model.CUSTOMER_PROFILE_ORDERS_MAX)
+	if ordersResult.Err != nil {
+		return nil, ordersResult.Err
+	}
+	profile.Orders = ordersResult.Data.([]*model.Order)
+
+	tagIds := map[string]bool{}
+	pageIds := map[string]bool{}
+	for _, conversation := range profile.Conversations {
+		tags, err := app.GetConversationTags(conversation.Id)
+		if err != nil {
+			return nil, err
+		}
+		for _, tag := range tags {
+			tagIds[tag.TagId] = true
+			pageIds[conversation.PageId] = true
+		}
+
+		notes, err := app.GetConversationNotes(conversation.Id)
+		if err != nil {
+			return nil, err
+		}
+		profile.Notes = append(profile.Notes, notes...)
+	}
+
+	for pageId := range pageIds {
+		pageTags, err := app.GetPageTags(pageId)
+		if err != nil {
+			return nil, err
+		}
+		for _, pageTag := range pageTags {
+			if tagIds[pageTag.Id] {
+				profile.Tags = append(profile.Tags, pageTag)
+			}
+		}
+	}
+
+	return profile, nil
+}
+
+func (app *App) GetCustomerProfileForConversation(conversationId string) (*model.CustomerProfile, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	customer, err := app.GetCustomerForConversation(conversation)
+	if err != nil {
+		return nil, err
+	}
+
+	return app.GetCustomerProfile(customer.Id)
+}
+
+func (app *App) UpdateCustomer(customer *model.CustomerRecord) (*model.CustomerRecord, *model.AppError) {
+	oldCustomer, err := app.GetCustomer(customer.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	customer.CreateAt = oldCustomer.CreateAt
+	customer.DeleteAt = oldCustomer.DeleteAt
+
+	result := <-app.Srv.Store.Customer().Update(customer)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.CustomerRecord), nil
+}
+
+// MergeCustomers gộp hồ sơ sourceId vào targetId: định danh và số điện thoại được chuyển sang hồ sơ đích,
+// hồ sơ nguồn bị xoá
+func (app *App) MergeCustomers(targetId, sourceId string) (*model.CustomerProfile, *model.AppError) {
+	if targetId == sourceId {
+		return nil, model.NewAppError("MergeCustomers", "app.customer.merge_same.app_error", nil, "customer_id="+targetId, http.StatusBadRequest)
+	}
+
+	target, err := app.GetCustomer(targetId)
+	if err != nil {
+		return nil, err
+	}
+
+	source, err := app.GetCustomer(sourceId)
+	if err != nil {
+		return nil, err
+	}
+
+	if result := <-app.Srv.Store.Customer().MoveAllIdentities(source.Id, target.Id); result.Err != nil {
+		return nil, result.Err
+	}
+
+	target.AddPhones(source.Phones...)
+	if len(target.Name) == 0 {
+		target.Name = source.Name
+	}
+	if result := <-app.Srv.Store.Customer().Update(target); result.Err != nil {
+		return nil, result.Err
+	}
+
+	if result := <-app.Srv.Store.Customer().Delete(source.Id, model.GetMillis()); result.Err != nil {
+		mlog.Warn("Unable to delete merged customer", mlog.String("customer_id", source.Id), mlog.Err(result.Err))
+	}
+
+	return app.GetCustomerProfile(target.Id)
+}
+
+// SplitCustomer tách các facebook uid ra khỏi hồ sơ thành một hồ sơ mới, hồ sơ cũ phải còn lại ít nhất một uid
+func (app *App) SplitCustomer(customerId string, facebookUids []string) (*model.CustomerProfile, *model.AppError) {
+	if _, err := app.GetCustomer(customerId); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.Customer().GetIdentities(customerId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	identities := result.Data.([]*model.CustomerIdentity)
+
+	owned := map[string]*model.CustomerIdentity{}
+	for _, identity := range identities {
+		owned[identity.FacebookUid] = identity
+	}
+
+	moving := map[string]bool{}
+	for _, uid := range facebookUids {
+		if owned[uid] == nil {
+			return nil, model.NewAppError("SplitCustomer", "app.customer.split_identity.app_error", nil, "customer_id="+customerId+", facebook_uid="+uid, http.StatusBadRequest)
+		}
+		moving[uid] = true
+	}
+
+	if len(moving) == 0 || len(moving) >= len(identities) {
+		return nil, model.NewAppError("SplitCustomer", "app.customer.split_all.app_error", nil, "customer_id="+customerId, http.StatusBadRequest)
+	}
+
+	saveResult := <-app.Srv.Store.Customer().Save(&model.CustomerRecord{Name: owned[facebookUids[0]].Name})
+	if saveResult.Err != nil {
+		return nil, saveResult.Err
+	}
+	newCustomer := saveResult.Data.(*model.CustomerRecord)
+
+	if result := <-app.Srv.Store.Customer().MoveIdentities(facebookUids, newCustomer.Id); result.Err != nil {
+		return nil, result.Err
+	}
+
+	return app.GetCustomerProfile(newCustomer.Id)
+}
+
+// addCustomerPhones lưu các số điện thoại khách hàng gửi trong tin nhắn hoặc bình luận vào hồ sơ của họ
+func (app *App) addCustomerPhones(conversation *model.FacebookConversation, text string) {
+	phones := model.ExtractPhoneNumbers(text)
+	if len(phones) == 0 || len(conversation.From) == 0 {
+		return
+	}
+
+	if _, err := app.GetCustomerForConversation(conversation); err != nil {
+		mlog.Warn("Unable to load customer for conversation", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+		return
+	}
+
+	// lưu vào định danh của người gửi để chỉ người xem được page này mới thấy các số này
+	result := <-app.Srv.Store.Customer().GetIdentity(conversation.From)
+	if result.Err != nil {
+		mlog.Warn("Unable to load customer identity", mlog.String("conversation_id", conversation.Id), mlog.Err(result.Err))
+		return
+	}
+	identity := result.Data.(*model.CustomerIdentity)
+
+	if !identity.AddPhones(phones...) {
+		return
+	}
+
+	if result := <-app.Srv.Store.Customer().UpdateIdentityPhones(identity.FacebookUid, identity.Phones); result.Err != nil {
+		mlog.Warn("Unable to save customer phones", mlog.String("customer_id", identity.CustomerId), mlog.Err(result.Err))
+	}
+}
+
+// SessionHasPermissionToCustomer kiểm tra session có quyền permission trên ít nhất một page mà khách hàng đã liên hệ
+func (app *App) SessionHasPermissionToCustomer(session model.Session, customerId string, permission *model.Permission) bool {
+	if app.SessionHasPermissionTo(session, permission) {
+		return true
+	}
+
+	result := <-app.Srv.Store.Customer().GetIdentities(customerId)
+	if result.Err != nil {
+		return false
+	}
+
+	for _, identity := range result.Data.([]*model.CustomerIdentity) {
+		if len(identity.PageId) > 0 && app.SessionHasPermissionToFanpage(session, identity.PageId, permission) {
+			return true
+		}
+	}
+	return false
+}
+
+// SessionCanManageCustomer kiểm tra session có quyền quản lý mọi page mà khách hàng đã liên hệ, dùng cho các thao tác
+// sửa, gộp và tách hồ sơ vì chúng ảnh hưởng đến dữ liệu khách hàng trên tất cả các page đó
+func (app *App) SessionCanManageCustomer(session model.Session, customerId string) bool {
+	if app.SessionHasPermissionTo(session, model.PERMISSION_MANAGE_SYSTEM) {
+		return true
+	}
+
+	result := <-app.Srv.Store.Customer().GetIdentities(customerId)
+	if result.Err != nil {
+		return false
+	}
+
+	identities := result.Data.([]*model.CustomerIdentity)
+	if len(identities) == 0 {
+		return false
+	}
+
+	for _, identity := range identities {
+		if len(identity.PageId) == 0 || !app.SessionHasPermissionToFanpage(session, identity.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+			return false
+		}
+	}
+	return true
+}
+
+// SanitizeCustomerProfile bỏ định danh, hội thoại, đơn hàng, thẻ và ghi chú thuộc các page mà user không có quyền đọc.
+// Nếu hồ sơ có định danh trên page không đọc được, tên và số điện thoại chỉ lấy từ các định danh đọc được
+func (app *App) SanitizeCustomerProfile(session model.Session, profile *model.CustomerProfile) *model.CustomerProfile {
+	if app.SessionHasPermissionTo(session, model.PERMISSION_READ_CONVERSATIONS) {
+		return profile
+	}
+
+	allowed := map[string]bool{}
+	for _, pageId := range app.GetPageIdsForSession(session, model.PERMISSION_READ_CONVERSATIONS) {
+		allowed[pageId] = true
+	}
+
+	identities := []*model.CustomerIdentity{}
+	for _, identity := range profile.Identities {
+		if allowed[identity.PageId] {
+			identities = append(identities, identity)
+		}
+	}
+
+	if len(identities) < len(profile.Identities) && profile.Customer != nil {
+		customer := &model.CustomerRecord{
+			Id:       profile.Customer.Id,
+			Phones:   model.StringArray{},
+			CreateAt: profile.Customer.CreateAt,
+			UpdateAt: profile.Customer.UpdateAt,
+			DeleteAt: profile.Customer.DeleteAt,
+		}
+		for _, identity := range identities {
+			if len(customer.Name) == 0 {
+				customer.Name = identity.Name
+			}
+			customer.AddPhones(identity.Phones...)
+		}
+		profile.Customer = customer
+	}
+	profile.Identities = identities
+
+	conversationIds := map[string]bool{}
+	conversations := []*model.FacebookConversation{}
+	for _, conversation := range profile.Conversations {
+		if allowed[conversation.PageId] {
+			conversations = append(conversations, conversation)
+			conversationIds[conversation.Id] = true
+		}
+	}
+	profile.Conversations = conversations
+
+	orders := []*model.Order{}
+	for _, order := range profile.Orders {
+		if allowed[order.PageId] {
+			orders = append(orders, order)
+		}
+	}
+	profile.Orders = orders
+
+	tags := []*model.PageTag{}
+	for _, tag := range profile.Tags {
+		if allowed[tag.PageId] {
+			tags = append(tags, tag)
+		}
+	}
+	profile.Tags = tags
+
+	notes := []*model.ConversationNote{}
+	for _, note := range profile.Notes {
+		if conversationIds[note.ConversationId] {
+			notes = append(notes, note)
+		}
+	}
+	profile.Notes = notes
+
+	return profile
+}
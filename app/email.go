@@ -381,6 +381,61 @@ func (es *EmailService) SendInviteEmails(team *model.Team, senderName string, se
 	return nil
 }
 
+// SendFanpageInviteEmails gửi lời mời tham gia quản lý page tới các email chưa có tài khoản,
+// roles được lưu trong token và gán cho user khi nhận lời mời
+func (es *EmailService) SendFanpageInviteEmails(fanpage *model.Fanpage, senderName string, senderUserId string, invites []string, roles string, siteURL string) *model.AppError {
+	if es.EmailRateLimiter == nil {
+		return model.NewAppError("SendFanpageInviteEmails", "app.email.no_rate_limiter.app_error", nil, fmt.Sprintf("user_id=%s, page_id=%s", senderUserId, fanpage.PageId), http.StatusInternalServerError)
+	}
+	rateLimited, result, err := es.EmailRateLimiter.RateLimit(senderUserId, len(invites))
+	if err != nil {
+		return model.NewAppError("SendFanpageInviteEmails", "app.email.setup_rate_limiter.app_error", nil, fmt.Sprintf("user_id=%s, page_id=%s, error=%v", senderUserId, fanpage.PageId, err), http.StatusInternalServerError)
+	}
+
+	if rateLimited {
+		return model.NewAppError("SendFanpageInviteEmails",
+			"app.email.rate_limit_exceeded.app_error", map[string]interface{}{"RetryAfter": result.RetryAfter.String(), "ResetAfter": result.ResetAfter.String()},
+			fmt.Sprintf("user_id=%s, page_id=%s, retry_after_secs=%f, reset_after_secs=%f",
+				senderUserId, fanpage.PageId, result.RetryAfter.Seconds(), result.ResetAfter.Seconds()),
+			http.StatusRequestEntityTooLarge)
+	}
+
+	for _, invite := range invites {
+		if len(invite) > 0 {
+			subject := utils.T("api.templates.fanpage_invite_subject",
+				map[string]interface{}{"SenderName": senderName,
+					"PageName": fanpage.Name,
+					"SiteName": es.srv.Config().TeamSettings.SiteName})
+
+			bodyPage := es.newEmailTemplate("invite_body", "")
+			bodyPage.Props["SiteURL"] = siteURL
+			bodyPage.Props["Title"] = utils.T("api.templates.invite_body.title")
+			bodyPage.Html["Info"] = utils.TranslateAsHtml(utils.T, "api.templates.fanpage_invite_body.info",
+				map[string]interface{}{"SenderName": senderName, "PageName": fanpage.Name})
+			bodyPage.Props["Button"] = utils.T("api.templates.invite_body.button")
+			bodyPage.Html["ExtraInfo"] = utils.TranslateAsHtml(utils.T, "api.templates.fanpage_invite_body.extra_info",
+				map[string]interface{}{"PageName": fanpage.Name})
+			bodyPage.Props["TeamURL"] = siteURL
+
+			token := model.NewToken(
+				TOKEN_TYPE_FANPAGE_INVITATION,
+				model.MapToJson(map[string]string{"pageId": fanpage.PageId, "email": invite, "roles": roles}),
+			)
+
+			if err := es.srv.Store.Token().Save(token); err != nil {
+				mlog.Error("Failed to send fanpage invite email successfully ", mlog.Err(err))
+				continue
+			}
+			bodyPage.Props["Link"] = fmt.Sprintf("%s/signup_user_complete/?page_invite=%s", siteURL, url.QueryEscape(token.Token))
+
+			if err := es.sendMail(invite, subject, bodyPage.Render()); err != nil {
+				mlog.Error("Failed to send fanpage invite email successfully ", mlog.Err(err))
+			}
+		}
+	}
+	return nil
+}
+
 func (es *EmailService) sendGuestInviteEmails(team *model.Team, channels []*model.Channel, senderName string, senderUserId string, senderProfileImage []byte, invites []string, siteURL string, message string) *model.AppError {
 	if es.EmailRateLimiter == nil {
 		return model.NewAppError("SendInviteEmails", "app.email.no_rate_limiter.app_error", nil, fmt.Sprintf("user_id=%s, team_id=%s", senderUserId, team.Id), http.StatusInternalServerError)
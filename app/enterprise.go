@@ -102,6 +102,24 @@ func RegisterJobsExpiryNotifyJobInterface(f func(*App) tjobs.ExpiryNotifyJobInte
 	jobsExpiryNotifyInterface = f
 }
 
+var jobsFanpageInitInterface func(*App) tjobs.FanpageInitJobInterface
+
+func RegisterJobsFanpageInitJobInterface(f func(*App) tjobs.FanpageInitJobInterface) {
+	jobsFanpageInitInterface = f
+}
+
+var jobsFanpageSyncInterface func(*App) tjobs.FanpageSyncJobInterface
+
+func RegisterJobsFanpageSyncJobInterface(f func(*App) tjobs.FanpageSyncJobInterface) {
+	jobsFanpageSyncInterface = f
+}
+
+var jobsAutoMessageTaskInterface func(*App) tjobs.AutoMessageTaskJobInterface
+
+func RegisterJobsAutoMessageTaskJobInterface(f func(*App) tjobs.AutoMessageTaskJobInterface) {
+	jobsAutoMessageTaskInterface = f
+}
+
 //var productNoticesJobInterface func(*App) tjobs.ProductNoticesJobInterface
 //
 //func RegisterProductNoticesJobInterface(f func(*App) tjobs.ProductNoticesJobInterface) {
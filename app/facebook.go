@@ -10,18 +10,15 @@ import (
 	"bitbucket.org/enesyteam/papo-server/mlog"
 	"bitbucket.org/enesyteam/papo-server/model"
 	"bitbucket.org/enesyteam/papo-server/utils"
-	"bytes"
 	"fmt"
 	"io"
 	"io/ioutil"
 	"net/http"
 	"net/url"
-	"strings"
 	"time"
 )
 
 const (
-	FACEBOOK_API_ROOT  = "https://graph.facebook.com/v3.2"
 	HEADER_REQUEST_ID  = "X-Request-ID"
 	HEADER_VERSION_ID  = "X-Version-ID"
 	HEADER_ETAG_SERVER = "ETag"
@@ -80,36 +77,33 @@ func BuildResponse(r *http.Response) *Response {
 
 func (app *App) doFacebookRequest(accessToken string, path string, method string, usingFullPath bool) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
 	if len(path) == 0 {
-		return nil, nil, model.NewAppError("doFacebookRequest", "missing", nil, "", http.StatusBadRequest)
+		return nil, nil, model.NewAppError("doFacebookRequest", "app.facebook_graph.missing_path.app_error", nil, "", http.StatusBadRequest)
 	}
 	if len(method) == 0 {
-		method = "GET"
+		method = http.MethodGet
 	}
 
-	reqUrl := FACEBOOK_API_ROOT + path
-	if usingFullPath {
-		reqUrl = path
-	}
-	req, _ := http.NewRequest(method, reqUrl, strings.NewReader(""))
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+	// client tự nhận biết URL đầy đủ (lấy từ paging) nên usingFullPath chỉ còn giữ cho các hàm gọi cũ
+	return app.doGraphRequest("doFacebookRequest", method, path, accessToken, "", nil)
+}
 
-	if resp, err := app.HTTPService.MakeClient(true).Do(req); err != nil {
-		// Cần xử lý thêm ở đoạn này
-		return nil, nil, model.NewAppError("doFacebookRequest", "missing", nil, "", http.StatusBadRequest)
-	} else {
-		var bodyBytes []byte
-		bodyBytes, _ = ioutil.ReadAll(resp.Body)
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
+// doGraphRequest gửi request qua GraphClient và chuyển lỗi kết nối thành AppError
+func (app *App) doGraphRequest(where string, method string, path string, accessToken string, contentType string, body []byte) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+	resp, fbErr, err := app.GraphClient().Do(method, path, accessToken, contentType, body)
+	if err != nil {
+		mlog.Error("Unable to send facebook graph request", mlog.String("path", path), mlog.Err(err))
+		return nil, nil, graphRequestAppError(where, err)
+	}
 
-		if resp.StatusCode != http.StatusOK {
-			return nil, facebookgraph.FacebookErrorFromJson(resp.Body), nil
-		}
+	return resp, fbErr, nil
+}
 
-		return resp.Body, nil, nil
+// graphRequestAppError chuyển lỗi kết nối hoặc lỗi đọc kết quả Graph API thành AppError, trả về nil nếu không có lỗi
+func graphRequestAppError(where string, err error) *model.AppError {
+	if err == nil {
+		return nil
 	}
+	return model.NewAppError(where, "app.facebook_graph.request.app_error", nil, err.Error(), http.StatusBadRequest)
 }
 
 // request với path có dạng "/me/accounts"
@@ -117,7 +111,7 @@ func (app *App) request(accessToken string, path string, method string) (io.Read
 	return app.doFacebookRequest(accessToken, path, method, false)
 }
 
-// request với path có dạng "https://graph.facebook.com/v3.2/t_170206597264241/messages?fields=created_.....
+// request với path có dạng "https://graph.facebook.com/<version>/t_170206597264241/messages?fields=created_.....
 // thường là một URL trong paging của kết quả trả về, mà ta cần graph đầy đủ kết quả
 func (app *App) requestUsingFullpath(accessToken string, path string, method string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
 	return app.doFacebookRequest(accessToken, path, method, true)
@@ -139,36 +133,19 @@ func (app *App) ExtendFacebookToken(token string) (*facebookgraph.FacebookToken,
 // TODO: Sửa lại không trả về access token
 // Trả về danh sách các fanpages của user
 func (app *App) GraphFanpages(token string) ([]facebookgraph.FacebookPage, *facebookgraph.FacebookError, *model.AppError) {
-	var nextPage = ""
 	var result []facebookgraph.FacebookPage
-	body, err, aerr := app.request(token, "/me/accounts?fields=username,access_token,id,name,category,category_list,tasks,phone,is_published=true", "GET")
-	if err != nil {
-		return nil, err, nil
-	}
-	if aerr != nil {
-		return nil, nil, aerr
-	}
-
-	pages := facebookgraph.FacebookPagesFromJson(body)
-	for _, p := range pages.Data {
-		result = append(result, p)
-	}
 
-	nextPage = pages.Paging.Next
-	for len(nextPage) > 0 {
-		body1, err1, aErr1 := app.requestUsingFullpath(token, nextPage, "GET")
-		if err1 != nil {
-			return nil, err1, nil
-		}
-		if aErr1 != nil {
-			return nil, nil, aErr1
+	it := app.GraphClient().Accounts(token, "/me/accounts?fields=username,access_token,id,name,category,category_list,tasks,phone,is_published=true")
+	for {
+		pages, ok := it.Next()
+		if !ok {
+			break
 		}
-		pages1 := facebookgraph.FacebookPagesFromJson(body1)
+		result = append(result, pages.Data...)
+	}
 
-		for _, p1 := range pages1.Data {
-			result = append(result, p1)
-		}
-		nextPage = pages1.Paging.Next
+	if fbErr, err := it.Err(); fbErr != nil || err != nil {
+		return nil, fbErr, graphRequestAppError("GraphFanpages", err)
 	}
 	return result, nil, nil
 }
@@ -205,18 +182,11 @@ func (app *App) GraphPageAndInit(pageId string, user *model.User, ) (string, *fa
 	}
 
 	if page != nil {
-		memberResult := <-app.Srv.Store.Fanpage().GetMember(page.Id, user.Id)
-		if memberResult.Err != nil {
-
-			pageMember := model.FanpageMember{FanpageId: page.Id, PageId: page.PageId, UserId: user.Id, AccessToken: fbPage.AccessToken}
-			if memberResult := <-app.Srv.Store.Fanpage().SaveFanPageMember(&pageMember); memberResult.Err != nil {
-				return "", nil, memberResult.Err
-			} else {
-				return memberResult.Data.(*model.FanpageMember).AccessToken, nil, nil
-			}
-		} else {
-			return memberResult.Data.(*model.FanpageMember).AccessToken, nil, nil
+		// luôn lưu token mới nhất (đã mã hoá), token trả về chỉ dùng nội bộ để khởi tạo page
+		if err := app.SavePageMemberToken(page.Id, page.PageId, user.Id, fbPage.AccessToken); err != nil {
+			return "", nil, err
 		}
+		return fbPage.AccessToken, nil, nil
 	}
 	return "", nil, nil
 }
@@ -295,6 +265,15 @@ func (app *App) GraphPageAndInit2(pageId string, user *model.User, pages []faceb
 		} else {
 			// page đã có trong DB, kiểm tra nếu page đã khởi tạo hoặc đang khoi tao thì không cho phép khởi tạo
 			newPage = pageResult.Data.(*model.Fanpage)
+
+			// page đã khởi tạo nhưng token bị vô hiệu: chỉ cần lưu token mới và khôi phục trạng thái, không khởi tạo lại
+			if newPage.Status == model.PAGE_STATUS_NEEDS_REAUTH {
+				if err := app.reauthorizePage(newPage, user.Id, fbPage.AccessToken); err != nil {
+					return "", false, nil, err
+				}
+				return "", true, nil, nil
+			}
+
 			if newPage.Status == model.PAGE_STATUS_INITIALIZING ||
 				newPage.Status == model.PAGE_STATUS_INITIALIZED ||
 				newPage.Status == model.PAGE_STATUS_ERROR ||
@@ -304,17 +283,10 @@ func (app *App) GraphPageAndInit2(pageId string, user *model.User, pages []faceb
 		}
 
 		if newPage != nil {
-			memberResult := <-app.Srv.Store.Fanpage().GetMember(newPage.Id, user.Id)
-			if memberResult.Err != nil {
-				pageMember := model.FanpageMember{FanpageId: newPage.Id, PageId: newPage.PageId, UserId: user.Id, AccessToken: fbPage.AccessToken}
-				if memberResult := <-app.Srv.Store.Fanpage().SaveFanPageMember(&pageMember); memberResult.Err != nil {
-					return "", false, nil, memberResult.Err
-				} else {
-					return memberResult.Data.(*model.FanpageMember).AccessToken, false, nil, nil
-				}
-			} else {
-				return memberResult.Data.(*model.FanpageMember).AccessToken, false, nil, nil
+			if err := app.SavePageMemberToken(newPage.Id, newPage.PageId, user.Id, fbPage.AccessToken); err != nil {
+				return "", false, nil, err
 			}
+			return fbPage.AccessToken, false, nil, nil
 		}
 		return "", false, nil, nil
 	} else {
@@ -392,6 +364,9 @@ func (app *App) GraphPagePostsAndInit(pageId string, pageToken string, requestUR
 		}
 	} else {
 		body, err, aerr = app.requestUsingFullpath(pageToken, requestURL, "GET")
+		if err != nil || aerr != nil {
+			return "", err, aerr
+		}
 	}
 
 	pr = facebookgraph.FacebookPostsFromJson(body)
@@ -746,86 +721,182 @@ func getConversationFromFromSenders(pageId string, senders []facebookgraph.Faceb
 // trả về 3 tham số
 // tham số thứ nhất là kết quả init, 2 tham số sau là lỗi phát sinh từ facebook và lỗi phát sinh từ ứng dụng
 func (app *App) GraphMessagesAndInit(pageId string, pageToken string, initResult *model.FanpageInitResult) (*facebookgraph.FacebookError, *model.AppError) {
+	nextPageLink := ""
+	for {
+		next, err, aerr := app.GraphConversationsPageAndInit(pageId, pageToken, nextPageLink, initResult)
+		if err != nil || aerr != nil {
+			return err, aerr
+		}
 
-	path := "/" + pageId + "/conversations?fields=can_reply,name,snippet,subject,updated_time,messages{created_time,from,id,message,sticker,attachments},id,scoped_thread_key,senders&limit=100"
+		if len(next) == 0 {
+			return nil, nil
+		}
+		nextPageLink = next
+	}
+}
 
-	nextPageLink := "next"
-	currentPageNumber := 1
+// GraphConversationsPageAndInit graph một trang hội thoại của page và thêm vào database.
+// Nếu requestURL rỗng thì graph trang đầu tiên, trả về link của trang kế tiếp hoặc rỗng nếu đã hết.
+// Hội thoại và tin nhắn đã có trong database được bỏ qua nên có thể graph lại một trang khi khởi tạo bị gián đoạn.
+func (app *App) GraphConversationsPageAndInit(pageId string, pageToken string, requestURL string, initResult *model.FanpageInitResult) (string, *facebookgraph.FacebookError, *model.AppError) {
+	// ============= counter
+	var conversationCount int64
+	var messageCount int64
+	// ============= end counter
 
-	for len(nextPageLink) > 0 {
-		// reset nextPageLink
-		//nextPageLink = ""
-		// ============= counter
-		var conversationCount int64
-		var messageCount int64
-		// ============= end counter
+	var body io.ReadCloser
+	var err *facebookgraph.FacebookError
+	var aerr *model.AppError
 
-		var url = path
+	if len(requestURL) > 0 {
+		body, err, aerr = app.requestUsingFullpath(pageToken, requestURL, "GET")
+	} else {
+		body, err, aerr = app.request(pageToken, "/"+pageId+"/conversations?fields=can_reply,name,snippet,subject,updated_time,messages{created_time,from,id,message,sticker,attachments},id,scoped_thread_key,senders&limit=100", "GET")
+	}
 
-		var body io.ReadCloser
-		var err *facebookgraph.FacebookError
-		var aerr *model.AppError
+	if err != nil {
+		mlog.Error(fmt.Sprint(err))
+		return "", err, nil
+	} else if aerr != nil {
+		mlog.Error(fmt.Sprint(aerr))
+		return "", nil, aerr
+	}
 
-		if currentPageNumber > 1 && len(nextPageLink) > 0 {
-			url = nextPageLink
-			body, err, aerr = app.requestUsingFullpath(pageToken, url, "GET")
-		} else {
-			body, err, aerr = app.request(pageToken, url, "GET")
+	cvs := *facebookgraph.FacebookConversationsFromJson(body)
+	// lặp từng hội thoại và load full tin nhắn của hội thoại đó
+	for _, c := range cvs.Data {
+		conversationCount ++
+		// thêm conversation vào database
+		fc := model.FacebookConversationModelFromGraphConversationItem(&c)
+		fc.From = getConversationFromFromSenders(pageId, c.Senders.Data)["id"].(string)
+		fc.PageId = pageId
+
+		// cập nhật user
+		for _, u := range c.Senders.Data {
+			if u.Id != pageId {
+				u.PageId = pageId
+				if r := <-app.Srv.Store.FacebookUid().UpsertFromFbUser(u); r.Err != nil {
+					return "", nil, r.Err
+				}
+			}
 		}
 
-		if err != nil {
+		updatedTime, _ := time.Parse("2006-01-02T15:04:05-0700", fc.UpdatedTime)
+
+		// Biến này cho biết hội thoại đã được trả lời hay chưa
+		replied := false
+
+		// Biến này cho biết trong hội thoại có bao nhiêu tin nhắn chưa xem,
+		// Tin nhắn được coi là chưa xem nếu nó được gửi từ User tới page và phải nằm sau tin nhắn cuối cùng từ Page
+		// Nếu tin nhắn cuối cùng trong hội thoại là từ Page thì unreadCount = 0
+		unreadCount := 0
+
+		// Biến này lưu giá trị thời gian của tin nhắn mới nhất trong hội thoại của User
+		var lastUserMessageAt string
+		var lastPageMessageAt string
+
+		// thêm conversation vào db
+		var rfc *model.FacebookConversation
+		var err *model.AppError // cần
+		if rfc, err = app.getOrCreateImportedConversation(fc); err != nil {
 			mlog.Error(fmt.Sprint(err))
-			return err, nil
-		} else if aerr != nil {
-			mlog.Error(fmt.Sprint(aerr))
-			return nil, aerr
+			return "", nil, err
 		}
 
-		cvs := *facebookgraph.FacebookConversationsFromJson(body)
-		// lặp từng hội thoại và load full tin nhắn của hội thoại đó
-		for _, c := range cvs.Data {
-			conversationCount ++
-			// thêm conversation vào database
-			fc := model.FacebookConversationModelFromGraphConversationItem(&c)
-			fc.From = getConversationFromFromSenders(pageId, c.Senders.Data)["id"].(string)
-			fc.PageId = pageId
-
-			// cập nhật user
-			for _, u := range c.Senders.Data {
-				if u.Id != pageId {
-					u.PageId = pageId
-					if r := <-app.Srv.Store.FacebookUid().UpsertFromFbUser(u); r.Err != nil {
-						return nil, r.Err
-					}
-				}
+		// lặp và thêm messages vào database
+		for _, m := range c.Messages.Data {
+
+			mes := &model.FacebookConversationMessage{
+				ConversationId: rfc.Id,
+				Type: 			"message",
+				PageId: 		pageId,
+				MessageId:      m.Id,
+				Message:        m.Message,
+				CreatedTime:    m.CreatedTime,
+				From:           m.From["id"].(string),
+				Sent: 			true,
 			}
 
-			updatedTime, _ := time.Parse("2006-01-02T15:04:05-0700", fc.UpdatedTime)
+			if len(m.Sticker) > 0 {
+				mes.Sticker = m.Sticker
+			}
 
-			// Biến này cho biết hội thoại đã được trả lời hay chưa
-			replied := false
+			// nếu message có attachments
+			if len(m.Attachments.Data) > 0 {
+				mes.HasAttachments = true
+				mes.AttachmentsCount = len(m.Attachments.Data)
 
-			// Biến này cho biết trong hội thoại có bao nhiêu tin nhắn chưa xem,
-			// Tin nhắn được coi là chưa xem nếu nó được gửi từ User tới page và phải nằm sau tin nhắn cuối cùng từ Page
-			// Nếu tin nhắn cuối cùng trong hội thoại là từ Page thì unreadCount = 0
-			unreadCount := 0
+				// chúng ta cần biết type của attachment để client có thể hiển thị placeholder tương ứng với loại attachment
+				// trong trường hợp chỉ có 1 attachment thì Attachment type luôn đúng với m.Attachments.Data[0].MimeType
+				// tuy nhiên khi có nhiều attachment type, xem xét có trường hợp nào mà người dùng gửi đồng thời nhiều file
+				// với định dạng khác nhau không?
+				mes.AttachmentType = m.Attachments.Data[0].MimeType
+			}
 
-			// Biến này lưu giá trị thời gian của tin nhắn mới nhất trong hội thoại của User
-			var lastUserMessageAt string
-			var lastPageMessageAt string
+			var er *model.AppError
 
-			// thêm conversation vào db
-			var rfc *model.FacebookConversation
-			var err *model.AppError // cần
-			if rfc, err = app.CreateConversation(fc); err != nil {
-				mlog.Error(fmt.Sprint(err))
-				return nil, err
+			if _, er = app.addImportedMessage(mes); er != nil {
+				mlog.Error(fmt.Sprint(er))
+				return "", nil, er
 			}
 
-			// lặp và thêm messages vào database
-			for _, m := range c.Messages.Data {
+			messageCount ++
+
+			// FSE122 Kiểm tra và cập nhật unread_count, replied cho hội thoại
+			from := m.From["id"].(string)
+			messageTime, _ := time.Parse("2006-01-02T15:04:05-0700", m.CreatedTime)
 
-				mes := &model.FacebookConversationMessage{
+			if messageTime.Equal(updatedTime) {
+				if from == pageId {
+					// Tin nhan tu page
+					replied = true
+					lastPageMessageAt = m.CreatedTime
+				} else {
+					// Tin nhan tu user
+					lastUserMessageAt = m.CreatedTime
+					replied = false
+				}
+			} else {
+				if len(lastUserMessageAt) == 0 {
+					// chưa tìm thấy tin nhắn nào từ user trước đó
+					if from != pageId {
+						lastUserMessageAt = m.CreatedTime
+					}
+				}
+
+				if len(lastPageMessageAt) == 0 {
+					// chưa tìm thấy tin nhắn mới nhất từ page
+					if from == pageId {
+						lastPageMessageAt = m.CreatedTime
+					}
+				}
+			}
+
+			if !replied {
+				lastPageMessageAtTime, _ := time.Parse("2006-01-02T15:04:05-0700", lastPageMessageAt)
+				messageTime, _ := time.Parse("2006-01-02T15:04:05-0700", m.CreatedTime)
+				if messageTime.After(lastPageMessageAtTime) {
+					unreadCount += 1
+				}
+			}
+			// END: FSE122
+		}
+
+		//fmt.Println("Conversation mới được tạo: "+rfc.Id)
+		// nếu conversation này có paging thì lặp paging và tiếp tục thêm vào database
+		nextPage := c.Messages.Paging.Next
+		for len(nextPage) > 0 {
+			b, e, ae := app.requestUsingFullpath(pageToken, nextPage, "GET")
+			if e != nil || ae != nil {
+				mlog.Error(fmt.Sprint(e))
+				return "", e, ae
+			}
+			pagingMessages := facebookgraph.FacebookConversationMessagesFromJson(b)
+
+			// lặp qua lần lượt từng message và thêm vào database
+			// đầu tiên lưu attachment
+			for _, m := range pagingMessages.Data {
+				message := &model.FacebookConversationMessage{
 					ConversationId: rfc.Id,
 					Type: 			"message",
 					PageId: 		pageId,
@@ -836,27 +907,23 @@ func (app *App) GraphMessagesAndInit(pageId string, pageToken string, initResult
 					Sent: 			true,
 				}
 
-				if len(m.Sticker) > 0 {
-					mes.Sticker = m.Sticker
-				}
-
 				// nếu message có attachments
 				if len(m.Attachments.Data) > 0 {
-					mes.HasAttachments = true
-					mes.AttachmentsCount = len(m.Attachments.Data)
+					message.HasAttachments = true
+					message.AttachmentsCount = len(m.Attachments.Data)
 
 					// chúng ta cần biết type của attachment để client có thể hiển thị placeholder tương ứng với loại attachment
 					// trong trường hợp chỉ có 1 attachment thì Attachment type luôn đúng với m.Attachments.Data[0].MimeType
 					// tuy nhiên khi có nhiều attachment type, xem xét có trường hợp nào mà người dùng gửi đồng thời nhiều file
 					// với định dạng khác nhau không?
-					mes.AttachmentType = m.Attachments.Data[0].MimeType
+					message.AttachmentType = m.Attachments.Data[0].MimeType
 				}
 
+				// thêm message vào database
 				var er *model.AppError
-
-				if _, _, er = app.AddMessage(mes, false, false, mes.From == pageId); er != nil {
+				if _, er = app.addImportedMessage(message); er != nil {
 					mlog.Error(fmt.Sprint(er))
-					return nil, er
+					return "", nil, er
 				}
 
 				messageCount ++
@@ -900,122 +967,32 @@ func (app *App) GraphMessagesAndInit(pageId string, pageToken string, initResult
 				}
 				// END: FSE122
 			}
-
-			//fmt.Println("Conversation mới được tạo: "+rfc.Id)
-			// nếu conversation này có paging thì lặp paging và tiếp tục thêm vào database
-			nextPage := c.Messages.Paging.Next
-			for len(nextPage) > 0 {
-				b, e, ae := app.requestUsingFullpath(pageToken, nextPage, "GET")
-				if e != nil || ae != nil {
-					mlog.Error(fmt.Sprint(e))
-					return e, ae
-				}
-				pagingMessages := facebookgraph.FacebookConversationMessagesFromJson(b)
-
-				// lặp qua lần lượt từng message và thêm vào database
-				// đầu tiên lưu attachment
-				for _, m := range pagingMessages.Data {
-					message := &model.FacebookConversationMessage{
-						ConversationId: rfc.Id,
-						Type: 			"message",
-						PageId: 		pageId,
-						MessageId:      m.Id,
-						Message:        m.Message,
-						CreatedTime:    m.CreatedTime,
-						From:           m.From["id"].(string),
-						Sent: 			true,
-					}
-
-					// nếu message có attachments
-					if len(m.Attachments.Data) > 0 {
-						message.HasAttachments = true
-						message.AttachmentsCount = len(m.Attachments.Data)
-
-						// chúng ta cần biết type của attachment để client có thể hiển thị placeholder tương ứng với loại attachment
-						// trong trường hợp chỉ có 1 attachment thì Attachment type luôn đúng với m.Attachments.Data[0].MimeType
-						// tuy nhiên khi có nhiều attachment type, xem xét có trường hợp nào mà người dùng gửi đồng thời nhiều file
-						// với định dạng khác nhau không?
-						message.AttachmentType = m.Attachments.Data[0].MimeType
-					}
-
-					// thêm message vào database
-					var er *model.AppError
-					if _, _, er = app.AddMessage(message, false, false, message.From == pageId); er != nil {
-						mlog.Error(fmt.Sprint(er))
-						return nil, er
-					}
-
-					messageCount ++
-
-					// FSE122 Kiểm tra và cập nhật unread_count, replied cho hội thoại
-					from := m.From["id"].(string)
-					messageTime, _ := time.Parse("2006-01-02T15:04:05-0700", m.CreatedTime)
-
-					if messageTime.Equal(updatedTime) {
-						if from == pageId {
-							// Tin nhan tu page
-							replied = true
-							lastPageMessageAt = m.CreatedTime
-						} else {
-							// Tin nhan tu user
-							lastUserMessageAt = m.CreatedTime
-							replied = false
-						}
-					} else {
-						if len(lastUserMessageAt) == 0 {
-							// chưa tìm thấy tin nhắn nào từ user trước đó
-							if from != pageId {
-								lastUserMessageAt = m.CreatedTime
-							}
-						}
-
-						if len(lastPageMessageAt) == 0 {
-							// chưa tìm thấy tin nhắn mới nhất từ page
-							if from == pageId {
-								lastPageMessageAt = m.CreatedTime
-							}
-						}
-					}
-
-					if !replied {
-						lastPageMessageAtTime, _ := time.Parse("2006-01-02T15:04:05-0700", lastPageMessageAt)
-						messageTime, _ := time.Parse("2006-01-02T15:04:05-0700", m.CreatedTime)
-						if messageTime.After(lastPageMessageAtTime) {
-							unreadCount += 1
-						}
-					}
-					// END: FSE122
-				}
-				nextPage = pagingMessages.Paging.Next
-			}
-
-			// do not update snippet
-			// cập nhật hội thoại gốc
-			updateResult := <-app.Srv.Store.FacebookConversation().UpdateConversationUnread(rfc.Id, replied, unreadCount, lastUserMessageAt)
-			if updateResult.Err != nil {
-				mlog.Error(fmt.Sprintf("Couldn't update pages status err=%v", updateResult.Err))
-			}
+			nextPage = pagingMessages.Paging.Next
 		}
 
-		currentPageNumber = currentPageNumber + 1
-		nextPageLink = cvs.Paging.Next
+		// do not update snippet
+		// cập nhật hội thoại gốc
+		updateResult := <-app.Srv.Store.FacebookConversation().UpdateConversationUnread(rfc.Id, replied, unreadCount, lastUserMessageAt)
+		if updateResult.Err != nil {
+			mlog.Error(fmt.Sprintf("Couldn't update pages status err=%v", updateResult.Err))
+		}
+	}
 
-		// update counter
-		if initResult != nil && len(initResult.Creator) > 0 {
-			app.UpdateFanpageInitResultConversationCount(initResult, conversationCount)
-			updatedMessageCount, _ := app.UpdateFanpageInitResultMessageCount(initResult, messageCount)
+	// update counter
+	if initResult != nil && len(initResult.Creator) > 0 {
+		app.UpdateFanpageInitResultConversationCount(initResult, conversationCount)
+		updatedMessageCount, _ := app.UpdateFanpageInitResultMessageCount(initResult, messageCount)
 
-			message := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE, "", "", initResult.Creator, nil)
-			message.Add("data", updatedMessageCount)
-			app.Publish(message)
+		message := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE, "", "", initResult.Creator, nil)
+		message.Add("data", updatedMessageCount)
+		app.Publish(message)
 
-			//message2 := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE, "", "", initResult.Creator, nil)
-			//message2.Add("data", updatedConversationCount)
-			//app.Publish(message2)
-		}
+		//message2 := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE, "", "", initResult.Creator, nil)
+		//message2.Add("data", updatedConversationCount)
+		//app.Publish(message2)
 	}
 
-	return nil, nil // chúng ta cần return kết quả init
+	return cvs.Paging.Next, nil, nil
 }
 
 // graph comments của một post và insert vào database
@@ -1153,18 +1130,20 @@ func (app *App) DoGraphCommentsAndInit(postId string, pageId string, pageToken s
 		}
 
 		// thêm message vào database
-		var er *model.AppError
-		var rms *model.FacebookConversationMessage
-		if rms, _, er = app.AddMessage(rootMessage, false, false, rootMessage.From == pageId); er != nil {
+		// bình luận đã có trong database (khi graph lại page) thì không tính lại vào hội thoại
+		rms, added, er := app.importMessage(rootMessage)
+		if er != nil {
 			return "", nil, er, 0, 0
 		}
 
-		commentCount ++
+		if added {
+			commentCount ++
+		}
 
 		timeFromComment, _ := time.Parse("2006-01-02T15:04:05-0700", rootMessage.CreatedTime)
 		timeFromConversation, _ := time.Parse("2006-01-02T15:04:05-0700", newConversation.UpdatedTime)
 
-		if timeFromComment.After(timeFromConversation) || timeFromComment.Equal(timeFromConversation) {
+		if added && (timeFromComment.After(timeFromConversation) || timeFromComment.Equal(timeFromConversation)) {
 			needUpdate = true
 			snippet = utils.GetSnippet(rootMessage.Message)
 			updatedTime = rootMessage.CreatedTime
@@ -1236,16 +1215,15 @@ func (app *App) DoGraphCommentsAndInit(postId string, pageId string, pageToken s
 				}
 
 				// thêm message vào database
-				var er *model.AppError
-				var x *model.FacebookConversationMessage
-				if x, _, er = app.AddMessage(subMessage, false, false, subMessage.From == pageId); er != nil {
+				x, added, er := app.importMessage(subMessage)
+				if er != nil {
 					return "", nil, er, 0, 0
 				}
 
 
 				timeFromComment, _ := time.Parse("2006-01-02T15:04:05-0700", x.CreatedTime)
 
-				if timeFromComment.After(timeFromConversation) {
+				if added && timeFromComment.After(timeFromConversation) {
 					needUpdate = true
 					snippet = utils.GetSnippet(subMessage.Message)
 					updatedTime = subMessage.CreatedTime
@@ -1344,16 +1322,15 @@ func (app *App) DoGraphCommentsAndInit(postId string, pageId string, pageToken s
 					}
 
 					// thêm message vào database
-					var er *model.AppError
-					var scms *model.FacebookConversationMessage
-					if scms, _, er = app.AddMessage(sbm, false, false, sbm.From == pageId); er != nil {
+					scms, added, er := app.importMessage(sbm)
+					if er != nil {
 						return "", nil, er, 0, 0
 					}
 
 
 					timeFromComment, _ := time.Parse("2006-01-02T15:04:05-0700", scms.CreatedTime)
 
-					if timeFromComment.After(timeFromConversation) {
+					if added && timeFromComment.After(timeFromConversation) {
 						needUpdate = true
 						snippet = utils.GetSnippet(sbm.Message)
 						updatedTime = sbm.CreatedTime
@@ -1484,74 +1461,32 @@ func (app *App) DoGraphOneComment(pageAccessToken string, commentId string) *fac
 }
 
 func (app *App) doFacebookPostRequest(token string, path string, data *model.ConversationReply) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
-	reqUrl := FACEBOOK_API_ROOT + path
-
 	p := url.Values{}
 	p.Set("message", data.Message)
 	p.Set("attachment_url", data.AttachmentUrl)
 
-	req, _ := http.NewRequest("POST", reqUrl, strings.NewReader(p.Encode()))
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	if resp, err := app.HTTPService.MakeClient(true).Do(req); err != nil {
-		mlog.Error(err.Error())
-		fmt.Println("===================================================================")
-		fmt.Println("err", err)
-		fmt.Println("===================================================================")
-		// Cần xử lý thêm ở đoạn này
-		return nil, nil, model.NewAppError("doFacebookRequest", "missing", nil, "", http.StatusBadRequest)
-	} else {
-		var bodyBytes []byte
-		bodyBytes, _ = ioutil.ReadAll(resp.Body)
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, facebookgraph.FacebookErrorFromJson(resp.Body), nil
-		}
-
-		return resp.Body, nil, nil
-	}
+	return app.doGraphRequest("doFacebookPostRequest", http.MethodPost, path, token, "application/x-www-form-urlencoded", []byte(p.Encode()))
 }
 
-func (app *App) replyMessage(token string, path string, data *model.MessageGraphReply) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
-	reqUrl := FACEBOOK_API_ROOT + path
-
+func (app *App) replyMessage(token string, path string, data *facebookgraph.SendRequest) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
 	p := url.Values{}
 	p.Set("message", data.Message.ToJson())
 	p.Set("recipient", data.Recipient.ToJson())
-
-	req, _ := http.NewRequest("POST", reqUrl, strings.NewReader(p.Encode()))
-
-	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
-	req.Header.Set("Accept", "application/json")
-	req.Header.Set("Authorization", "Bearer "+token)
-
-	if resp, err := app.HTTPService.MakeClient(true).Do(req); err != nil {
-		mlog.Error(err.Error())
-		fmt.Println("===================================================================")
-		fmt.Println("err", err)
-		fmt.Println("===================================================================")
-		// Cần xử lý thêm ở đoạn này
-		return nil, nil, model.NewAppError("doFacebookRequest", "missing", nil, "", http.StatusBadRequest)
-	} else {
-		var bodyBytes []byte
-		bodyBytes, _ = ioutil.ReadAll(resp.Body)
-		resp.Body = ioutil.NopCloser(bytes.NewBuffer(bodyBytes))
-
-		if resp.StatusCode != http.StatusOK {
-			return nil, facebookgraph.FacebookErrorFromJson(resp.Body), nil
-		}
-
-		return resp.Body, nil, nil
+	if len(data.MessagingType) > 0 {
+		p.Set("messaging_type", data.MessagingType)
+	}
+	if len(data.Tag) > 0 {
+		p.Set("tag", data.Tag)
 	}
+
+	return app.doGraphRequest("replyMessage", http.MethodPost, path, token, "application/x-www-form-urlencoded", []byte(p.Encode()))
 }
 
-func (app *App) ReplyComment(commentId string, replyItem *model.ConversationReply) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+func (app *App) ReplyComment(commentId string, replyItem *model.ConversationReply, userId string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
 
-	response, fErr, aErr := app.doFacebookPostRequest(replyItem.PageToken, "/"+commentId+"/comments", replyItem)
+	response, fErr, aErr := app.DoWithPageToken(replyItem.PageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		return app.doFacebookPostRequest(token, "/"+commentId+"/comments", replyItem)
+	})
 	if fErr != nil || aErr != nil {
 		fmt.Println("fErr", fErr)
 		fmt.Println("aErr", aErr)
@@ -1561,55 +1496,28 @@ func (app *App) ReplyComment(commentId string, replyItem *model.ConversationRepl
 	return response, nil, nil
 }
 
-func (app *App) ReplyMessage(threadId, psId string, replyItem *model.ConversationReply) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+func (app *App) ReplyMessage(threadId, psId string, replyItem *model.ConversationReply, userId string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
 
 	to := replyItem.To
 
 	if len(to) == 0 || len(psId) == 0 {
 		return nil, nil, model.NewAppError("ReplyMessage", "web.reply_message.missing_user_id.app_error", nil, "", http.StatusBadRequest)
 	}
-	//var psid string
-	//var getPsIdErr *facebookgraph.FacebookError
-	//var getAppErr *model.AppError
-	//if fbUser != nil && len(fbUser.PageScopeId) > 0 {
-	//	psid = fbUser.PageScopeId
-	//} else {
-	//	// get match Page Scope Id from App Scope Id
-	//	psid, getPsIdErr, getAppErr = app.MatchPageScopeId(replyItem.PageId, pageAccessToken, to)
-	//	if getPsIdErr != nil || getAppErr != nil {
-	//		return nil, getPsIdErr, getAppErr
-	//	}
-	//
-	//	// Update pageId if need (for old version)
-	//	if len(fbUser.PageId) == 0 {
-	//		_, updatedErr := app.UpdateFacebookUserPageId(to, replyItem.PageId)
-	//		if updatedErr != nil {
-	//			// TODO: Log this err to mlog
-	//		}
-	//	}
-	//
-	//	// update page scope id
-	//	_, updatedErr := app.UpdateFacebookUserPageScopeId(to, psid)
-	//	if updatedErr != nil {
-	//		// TODO: Log this err to mlog
-	//	}
-	//}
-
-	message := &model.MessageGraphReply{
-		Recipient: &model.Recipient{Id: psId},
-		Message: &model.Message{
-			Text: replyItem.Message,
-			//Attachment: &model.Attachment{
-			//	Type: "image",
-			//	Payload: &model.Payload{
-			//		Url: "https://wordtracker-swoop-uploads.s3.amazonaws.com/uploads/ckeditor/pictures/1217/content_emoji_smiles.jpg",
-			//		IsReusable: true,
-			//	},
-			//},
-		},
+
+	// file và attachment_url được upload lên page trước để lấy attachment_id dùng lại cho các lần gửi sau
+	reply, fErr, aErr := app.resolveReplyAttachment(replyItem, userId)
+	if fErr != nil || aErr != nil {
+		return nil, fErr, aErr
+	}
+
+	message := reply.SendRequest(psId)
+	if err := message.IsValid(); err != nil {
+		return nil, nil, model.NewAppError("ReplyMessage", "model.conversation_reply.is_valid.send_api.app_error", map[string]interface{}{"Reason": err.Error()}, err.Error(), http.StatusBadRequest)
 	}
 
-	response, fErr, aErr := app.replyMessage(replyItem.PageToken, "/me/messages", message)
+	response, fErr, aErr := app.DoWithPageToken(replyItem.PageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		return app.replyMessage(token, "/me/messages", message)
+	})
 	if fErr != nil || aErr != nil {
 		fmt.Println("fErr", fErr)
 		fmt.Println("aErr", aErr)
@@ -1620,15 +1528,12 @@ func (app *App) ReplyMessage(threadId, psId string, replyItem *model.Conversatio
 }
 
 // Get page_scope_id from app_scope_id and update to database
-func (a *App) MatchPageScopeId(pageId, pageAccessToken, appScopeId string) (string, *facebookgraph.FacebookError, *model.AppError) {
+func (a *App) MatchPageScopeId(pageId, appScopeId string) (string, *facebookgraph.FacebookError, *model.AppError) {
 	appToken := *a.Config().FacebookSettings.AppToken
-	appSecretProof := *a.Config().FacebookSettings.AppSecretProof
 
-	fmt.Println("appToken", appToken)
-	fmt.Println("appSecretProof", appSecretProof)
-	body, err, aerr := a.request(pageAccessToken, "/"+appScopeId+"/ids_for_pages?page="+pageId+"&access_token="+appToken+"&appsecret_proof="+appSecretProof, "GET")
+	// appsecret_proof được GraphClient thêm vào mọi request
+	body, err, aerr := a.request(appToken, "/"+appScopeId+"/ids_for_pages?page="+pageId, "GET")
 	if err != nil {
-		fmt.Println(err)
 		return "", err, nil
 	} else if aerr != nil {
 		return "", nil, aerr
@@ -0,0 +1,37 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"time"
+)
+
+// GraphClient trả về client Graph API dùng chung cho toàn server, giới hạn tốc độ được theo dõi trên client này
+// nên mọi request tới Graph API phải đi qua nó.
+func (app *App) GraphClient() *facebookgraph.Client {
+	app.Srv.facebookGraphOnce.Do(func() {
+		var metrics facebookgraph.Metrics
+		if appMetrics := app.Metrics(); appMetrics != nil {
+			metrics = appMetrics
+		}
+
+		app.Srv.FacebookGraphClient = facebookgraph.NewClient(app.HTTPService.MakeClient(true), app.graphClientSettings, metrics)
+	})
+
+	return app.Srv.FacebookGraphClient
+}
+
+func (app *App) graphClientSettings() *facebookgraph.ClientSettings {
+	cfg := app.Config()
+
+	return &facebookgraph.ClientSettings{
+		BaseUrl:              *cfg.FacebookSettings.GraphApiBaseUrl,
+		Version:              *cfg.FacebookSettings.GraphApiVersion,
+		AppSecret:            *cfg.FacebookSettings.Secret,
+		UsageThrottlePercent: *cfg.FacebookAPISettings.GraphUsageThrottlePercent,
+		MaxRetries:           *cfg.FacebookAPISettings.GraphMaxRetries,
+		MaxBackoff:           time.Duration(*cfg.FacebookAPISettings.GraphMaxBackoffSeconds) * time.Second,
+	}
+}
@@ -0,0 +1,213 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"io"
+	"net/http"
+	"strconv"
+)
+
+// facebook chỉ cho phép trả lời riêng bình luận trong vòng 7 ngày kể từ khi bình luận được tạo
+const COMMENT_PRIVATE_REPLY_WINDOW = 7 * 24 * 60 * 60 * 1000
+
+// getConversationComment trả về hội thoại bình luận và bình luận commentId thuộc hội thoại đó
+func (app *App) getConversationComment(conversationId, commentId string) (*model.FacebookConversation, *model.FacebookConversationMessage, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if conversation.Type != "comment" {
+		return nil, nil, model.NewAppError("getConversationComment", "app.comment_moderation.not_comment.app_error", nil, "conversation_id="+conversationId, http.StatusBadRequest)
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().GetCommentByCommentId(commentId)
+	if result.Err != nil {
+		return nil, nil, result.Err
+	}
+
+	comment := result.Data.(*model.FacebookConversationMessage)
+	if comment.ConversationId != conversationId || comment.DeleteAt > 0 {
+		return nil, nil, model.NewAppError("getConversationComment", "app.comment_moderation.comment_not_found.app_error", nil, "conversation_id="+conversationId+", comment_id="+commentId, http.StatusNotFound)
+	}
+
+	return conversation, comment, nil
+}
+
+// HideComment ẩn hoặc hiện lại bình luận trên facebook, bình luận bị ẩn chỉ người viết và bạn bè của họ nhìn thấy
+func (app *App) HideComment(conversationId, commentId string, isHidden bool, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	action := model.COMMENT_ACTION_HIDE
+	if !isHidden {
+		action = model.COMMENT_ACTION_UNHIDE
+	}
+
+	conversation, comment, err := app.getConversationComment(conversationId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !comment.CanHide {
+		return nil, commentActionNotAllowedError("HideComment", action, commentId)
+	}
+
+	if err := app.doCommentGraphRequest("HideComment", conversation.PageId, userId, "/"+commentId+"?is_hidden="+strconv.FormatBool(isHidden), "POST"); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().UpdateCommentHidden(commentId, isHidden, userId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	updated := result.Data.(*model.FacebookConversationMessage)
+	app.publishCommentUpdated(conversation, updated, action, userId)
+	return updated, nil
+}
+
+// LikeComment thích hoặc bỏ thích bình luận với tư cách page
+func (app *App) LikeComment(conversationId, commentId string, isLiked bool, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	action, method := model.COMMENT_ACTION_LIKE, "POST"
+	if !isLiked {
+		action, method = model.COMMENT_ACTION_UNLIKE, "DELETE"
+	}
+
+	conversation, comment, err := app.getConversationComment(conversationId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !comment.CanLike {
+		return nil, commentActionNotAllowedError("LikeComment", action, commentId)
+	}
+
+	if err := app.doCommentGraphRequest("LikeComment", conversation.PageId, userId, "/"+commentId+"/likes", method); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().UpdateCommentLiked(commentId, isLiked, userId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	updated := result.Data.(*model.FacebookConversationMessage)
+	app.publishCommentUpdated(conversation, updated, action, userId)
+	return updated, nil
+}
+
+// DeleteComment xoá bình luận trên facebook, bình luận trong papo chỉ được đánh dấu DeleteAt và DeleteBy
+func (app *App) DeleteComment(conversationId, commentId string, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	conversation, comment, err := app.getConversationComment(conversationId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !comment.CanRemove {
+		return nil, commentActionNotAllowedError("DeleteComment", model.COMMENT_ACTION_DELETE, commentId)
+	}
+
+	if err := app.doCommentGraphRequest("DeleteComment", conversation.PageId, userId, "/"+commentId, "DELETE"); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().DeleteCommentByCommentId(commentId, userId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	deleted := result.Data.(*model.FacebookConversationMessage)
+
+	event := model.NewWebSocketEvent(model.RECEIVE_COMMENT_DELETED, "", conversation.PageId, "", nil)
+	event.Add("id", conversation.Id)
+	event.Add("deletedComment", deleted)
+	event.Add("action", model.COMMENT_ACTION_DELETE)
+	event.Add("user_id", userId)
+	app.Publish(event)
+
+	return deleted, nil
+}
+
+// PrivateReplyComment gửi tin nhắn messenger cho người viết bình luận, tin nhắn sẽ về lại papo qua webhook như một hội thoại tin nhắn
+func (app *App) PrivateReplyComment(conversationId, commentId string, text string, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	conversation, comment, err := app.getConversationComment(conversationId, commentId)
+	if err != nil {
+		return nil, err
+	}
+
+	if !comment.CanReplyPrivately || comment.PrivateRepliedAt > 0 {
+		return nil, commentActionNotAllowedError("PrivateReplyComment", model.COMMENT_ACTION_PRIVATE_REPLY, commentId)
+	}
+
+	if createdAt := model.ParseFacebookTime(comment.CreatedTime); createdAt > 0 && createdAt+COMMENT_PRIVATE_REPLY_WINDOW < model.GetMillis() {
+		return nil, model.NewAppError("PrivateReplyComment", "app.comment_moderation.private_reply_expired.app_error", nil, "comment_id="+commentId, http.StatusForbidden)
+	}
+
+	request := facebookgraph.NewPrivateReplyRequest(commentId).WithText(text)
+	if vErr := request.IsValid(); vErr != nil {
+		return nil, model.NewAppError("PrivateReplyComment", "model.conversation_reply.is_valid.send_api.app_error", map[string]interface{}{"Reason": vErr.Error()}, vErr.Error(), http.StatusBadRequest)
+	}
+
+	body, fErr, aErr := app.DoWithPageToken(conversation.PageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		return app.replyMessage(token, "/me/messages", request)
+	})
+	if aErr != nil {
+		return nil, aErr
+	}
+	if fErr != nil {
+		return nil, commentGraphError("PrivateReplyComment", fErr)
+	}
+	if body != nil {
+		body.Close()
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().UpdateCommentPrivateReplied(commentId, userId, model.GetMillis())
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	updated := result.Data.(*model.FacebookConversationMessage)
+	app.publishCommentUpdated(conversation, updated, model.COMMENT_ACTION_PRIVATE_REPLY, userId)
+	return updated, nil
+}
+
+func (app *App) doCommentGraphRequest(where, pageId, userId, path, method string) *model.AppError {
+	body, fErr, aErr := app.DoWithPageToken(pageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		return app.request(token, path, method)
+	})
+	if aErr != nil {
+		return aErr
+	}
+	if fErr != nil {
+		return commentGraphError(where, fErr)
+	}
+
+	if body != nil {
+		body.Close()
+	}
+	return nil
+}
+
+func (app *App) publishCommentUpdated(conversation *model.FacebookConversation, comment *model.FacebookConversationMessage, action, userId string) {
+	event := model.NewWebSocketEvent(model.RECEIVE_COMMENT_UPDATED, "", conversation.PageId, "", nil)
+	event.Add("id", conversation.Id)
+	event.Add("conversation", conversation)
+	event.Add("newMessage", comment)
+	event.Add("action", action)
+	event.Add("user_id", userId)
+	app.Publish(event)
+}
+
+func commentActionNotAllowedError(where, action, commentId string) *model.AppError {
+	return model.NewAppError(where, "app.comment_moderation.not_allowed.app_error", map[string]interface{}{"Action": action}, "comment_id="+commentId, http.StatusForbidden)
+}
+
+func commentGraphError(where string, fErr *facebookgraph.FacebookError) *model.AppError {
+	statusCode := http.StatusBadRequest
+	if fErr.IsRateLimit() {
+		statusCode = http.StatusTooManyRequests
+	}
+	return model.NewAppError(where, "app.comment_moderation.graph.app_error", map[string]interface{}{"Message": fErr.Error.Message}, fErr.ToJson(), statusCode)
+}
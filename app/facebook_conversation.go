@@ -59,7 +59,9 @@ func (app *App) addMessageToConversation(mesage *model.FacebookConversationMessa
 		if fmr.Err != nil {
 			return nil, false, fmr.Err
 		}
-		return fmr.Data.(*model.FacebookConversationMessage), false, nil
+		// store trả về bản đã lưu trước đó nếu mid hoặc comment_id đã tồn tại
+		saved := fmr.Data.(*model.FacebookConversationMessage)
+		return saved, saved.Id != mesage.Id, nil
 	}
 
 	// already exists.  Check if deleted and and update, otherwise do nothing
@@ -199,21 +201,41 @@ func (app *App) GetConversationMessages(conversationId string, offset, limit int
 	return result.Data.([]*model.FacebookConversationMessage), nil
 }
 
-func (a *App) GetConversations(pageIds string, offset, limit int) ([]*model.FacebookConversation, *model.AppError) {
-	result := <-a.Srv.Store.FacebookConversation().GetConversations(pageIds, offset, limit)
-
+// SearchConversations tìm hội thoại theo các điều kiện lọc, next_cursor của kết quả dùng để lấy trang tiếp theo
+func (a *App) SearchConversations(options *model.ConversationSearchOptions) (*model.ConversationSearchResult, *model.AppError) {
+	result := <-a.Srv.Store.FacebookConversation().Search(options)
 	if result.Err != nil {
 		return nil, result.Err
 	}
-	return result.Data.([]*model.FacebookConversation), nil
+
+	searchResult := result.Data.(*model.ConversationSearchResult)
+	now := model.GetMillis()
+	for _, conversation := range searchResult.Conversations {
+		conversation.PopulateMessagingWindow(now)
+	}
+	return searchResult, nil
 }
 
-func (a *App) SearchConversations(term string, pageIds string, limit, offset int) ([]*model.ConversationResponse, *model.AppError) {
-	result := <-a.Srv.Store.FacebookConversation().Search(term, pageIds, limit, offset)
-	if result.Err != nil {
-		return nil, result.Err
+// markConversationHasPhone đánh dấu hội thoại có số điện thoại khi khách hàng gửi tin nhắn chứa số điện thoại
+func (a *App) markConversationHasPhone(conversation *model.FacebookConversation, text string) {
+	if conversation == nil || !model.ContainsPhoneNumber(text) {
+		return
+	}
+
+	// số điện thoại được lưu vào hồ sơ khách hàng kể cả khi hội thoại đã được đánh dấu
+	a.Srv.Go(func() {
+		a.addCustomerPhones(conversation, text)
+	})
+
+	if conversation.HasPhone {
+		return
+	}
+
+	if result := <-a.Srv.Store.FacebookConversation().SetHasPhone(conversation.Id); result.Err != nil {
+		mlog.Warn("Unable to mark conversation has phone", mlog.String("conversation_id", conversation.Id), mlog.Err(result.Err))
+		return
 	}
-	return result.Data.([]*model.ConversationResponse), nil
+	conversation.HasPhone = true
 }
 
 func (a *App) SanitizeMessage(session model.Session, message *model.FacebookConversationMessage) *model.FacebookConversationMessage {
@@ -0,0 +1,564 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/utils"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	OUTBOX_QUEUE_SIZE            = 1000
+	OUTBOX_POLL_INTERVAL         = 5 * time.Second
+	OUTBOX_POLL_LIMIT            = 200
+	OUTBOX_SEND_LEASE            = 2 * time.Minute
+	OUTBOX_RETRY_BACKOFF_BASE    = 5 * time.Second
+	OUTBOX_RATE_LIMIT_BACKOFF    = time.Minute
+	OUTBOX_RETRY_BACKOFF_MAXIMUM = 30 * time.Minute
+	OUTBOX_MESSAGE_TIME_FORMAT   = "2006-01-02T15:04:05-0700"
+)
+
+// FacebookOutbox gửi các tin nhắn trả lời đang chờ trong FacebookConversationMessages lên Facebook.
+// Khi Facebook báo vượt giới hạn (mã 4, 17, 32, 613) toàn bộ tin nhắn của page đó sẽ tạm dừng cho tới hết thời gian chờ.
+type FacebookOutbox struct {
+	app      *App
+	messages chan string
+	stop     chan struct{}
+	wg       sync.WaitGroup
+
+	mutex          sync.Mutex
+	throttledUntil map[string]int64
+}
+
+func NewFacebookOutbox(app *App) *FacebookOutbox {
+	return &FacebookOutbox{
+		app:            app,
+		messages:       make(chan string, OUTBOX_QUEUE_SIZE),
+		stop:           make(chan struct{}),
+		throttledUntil: make(map[string]int64),
+	}
+}
+
+func (o *FacebookOutbox) Start() {
+	workers := *o.app.Config().FacebookAPISettings.OutboxWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	mlog.Info("Starting facebook outbox", mlog.Int("workers", workers))
+
+	for i := 0; i < workers; i++ {
+		o.wg.Add(1)
+		go o.work()
+	}
+
+	o.wg.Add(1)
+	go o.poll()
+}
+
+func (o *FacebookOutbox) Stop() {
+	mlog.Info("Stopping facebook outbox")
+	close(o.stop)
+	o.wg.Wait()
+}
+
+// Push đưa tin nhắn vào hàng đợi gửi, nếu hàng đợi đầy tin nhắn sẽ được poller gửi sau
+func (o *FacebookOutbox) Push(messageId string) {
+	select {
+	case o.messages <- messageId:
+	default:
+	}
+}
+
+func (o *FacebookOutbox) work() {
+	defer o.wg.Done()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case messageId := <-o.messages:
+			o.app.SendOutboxMessage(messageId)
+		}
+	}
+}
+
+func (o *FacebookOutbox) poll() {
+	defer o.wg.Done()
+
+	ticker := time.NewTicker(OUTBOX_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-o.stop:
+			return
+		case <-ticker.C:
+			result := <-o.app.Srv.Store.FacebookConversation().GetDueOutboxMessages(model.GetMillis(), OUTBOX_POLL_LIMIT)
+			if result.Err != nil {
+				mlog.Error("Unable to load pending outbox messages", mlog.Err(result.Err))
+				continue
+			}
+
+			for _, message := range result.Data.([]*model.FacebookConversationMessage) {
+				o.Push(message.Id)
+			}
+		}
+	}
+}
+
+// Throttle tạm dừng gửi tin nhắn của page tới thời điểm until (millis)
+func (o *FacebookOutbox) Throttle(pageId string, until int64) {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	if until > o.throttledUntil[pageId] {
+		o.throttledUntil[pageId] = until
+	}
+}
+
+// ThrottledUntil trả về thời điểm page được gửi tiếp, 0 nếu page không bị giới hạn
+func (o *FacebookOutbox) ThrottledUntil(pageId string) int64 {
+	o.mutex.Lock()
+	defer o.mutex.Unlock()
+
+	until, ok := o.throttledUntil[pageId]
+	if ok && until <= model.GetMillis() {
+		delete(o.throttledUntil, pageId)
+		return 0
+	}
+	return until
+}
+
+func (app *App) StartFacebookOutbox() {
+	app.Srv.FacebookOutbox = NewFacebookOutbox(app)
+	app.Srv.FacebookOutbox.Start()
+}
+
+// EnqueueConversationReply lưu tin nhắn trả lời với trạng thái pending và đưa vào outbox, tin nhắn được gửi lên Facebook bất đồng bộ.
+// Page, người nhận và thread/bình luận được lấy từ hội thoại đã lưu, không dùng giá trị client gửi lên.
+func (app *App) EnqueueConversationReply(conversationId string, reply *model.ConversationReply, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := app.applyConversationToReply(conversation, reply); err != nil {
+		return nil, err
+	}
+
+	if err := reply.IsValid(); err != nil {
+		return nil, err
+	}
+
+	if err := app.CheckReplyMessagingWindow(conversation, reply); err != nil {
+		return nil, err
+	}
+
+	savedMessage, err := app.saveOutboxReply(conversationId, reply, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	if app.Srv.FacebookOutbox != nil {
+		app.Srv.FacebookOutbox.Push(savedMessage.Id)
+	}
+
+	return savedMessage, nil
+}
+
+// applyConversationToReply gán page, loại hội thoại và đích gửi của hội thoại vào tin nhắn trả lời.
+// Với hội thoại bình luận, bình luận được trả lời phải thuộc hội thoại.
+func (app *App) applyConversationToReply(conversation *model.FacebookConversation, reply *model.ConversationReply) *model.AppError {
+	reply.PageId = conversation.PageId
+	reply.Type = conversation.Type
+
+	switch conversation.Type {
+	case "comment":
+		if len(reply.CommentId) == 0 {
+			return model.NewAppError("EnqueueConversationReply", "app.outbox.missing_comment_id.app_error", nil, "", http.StatusBadRequest)
+		}
+
+		result := <-app.Srv.Store.FacebookConversation().GetCommentByCommentId(reply.CommentId)
+		if result.Err != nil || result.Data.(*model.FacebookConversationMessage).ConversationId != conversation.Id {
+			return model.NewAppError("EnqueueConversationReply", "app.outbox.comment_not_in_conversation.app_error", nil, "conversation_id="+conversation.Id+", comment_id="+reply.CommentId, http.StatusBadRequest)
+		}
+		reply.ThreadId = ""
+		reply.To = ""
+		reply.PageScopeId = ""
+	case "message":
+		reply.CommentId = ""
+		reply.ThreadId = conversation.ScopedThreadKey
+		if len(reply.ThreadId) == 0 {
+			reply.ThreadId = conversation.Id
+		}
+		reply.To = conversation.From
+		reply.PageScopeId = conversation.PageScopeId
+	default:
+		return model.NewAppError("EnqueueConversationReply", "app.outbox.invalid_type.app_error", nil, "type="+conversation.Type, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// saveOutboxReply lưu tin nhắn trả lời đã được kiểm tra với trạng thái pending vào hội thoại, chưa đưa vào hàng đợi gửi.
+// Hội thoại chỉ được đánh dấu đã trả lời trong completeOutboxMessage khi tin nhắn đã gửi thành công
+func (app *App) saveOutboxReply(conversationId string, reply *model.ConversationReply, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	now := model.GetMillis()
+	message := &model.FacebookConversationMessage{
+		Type:           reply.Type,
+		From:           reply.PageId,
+		PageId:         reply.PageId,
+		Message:        reply.Message,
+		ConversationId: conversationId,
+		CreatedTime:    time.Now().Format(OUTBOX_MESSAGE_TIME_FORMAT),
+		UserId:         userId,
+		Status:         model.MESSAGE_STATUS_PENDING,
+		NextAttemptAt:  now,
+		Props:          model.StringInterface{model.OUTBOX_PROP_REPLY: reply.ToJson()},
+	}
+	if reply.HasAttachment() || reply.Template != nil {
+		message.HasAttachments = true
+		message.AttachmentsCount = 1
+		message.AttachmentType = reply.GetAttachmentType()
+		if reply.Template != nil {
+			message.AttachmentType = facebookgraph.SEND_ATTACHMENT_TYPE_TEMPLATE
+		}
+		if len(reply.FileId) > 0 {
+			message.FileIds = model.StringArray{reply.FileId}
+		}
+	}
+	message.PreSave()
+
+	savedMessage, _, err := app.AddMessage(message, false, false, true)
+	if err != nil {
+		return nil, err
+	}
+
+	app.publishOutboxMessage(model.WEBSOCKET_EVENT_ADD_MESSAGE, savedMessage, reply)
+
+	return savedMessage, nil
+}
+
+// SendOutboxMessage gửi một tin nhắn trong outbox lên Facebook, lỗi tạm thời hoặc vượt giới hạn sẽ được gửi lại với backoff
+func (app *App) SendOutboxMessage(messageId string) {
+	result := <-app.Srv.Store.FacebookConversation().GetOutboxMessage(messageId)
+	if result.Err != nil {
+		mlog.Error("Unable to load outbox message", mlog.String("message_id", messageId), mlog.Err(result.Err))
+		return
+	}
+	message := result.Data.(*model.FacebookConversationMessage)
+
+	if message.Status != model.MESSAGE_STATUS_PENDING || message.DeleteAt > 0 || message.NextAttemptAt > model.GetMillis() {
+		return
+	}
+
+	// page đang bị giới hạn: dời lượt gửi tới khi hết thời gian chờ
+	leaseUntil := model.GetMillis() + int64(OUTBOX_SEND_LEASE/time.Millisecond)
+	var throttledUntil int64
+	if app.Srv.FacebookOutbox != nil {
+		throttledUntil = app.Srv.FacebookOutbox.ThrottledUntil(message.PageId)
+		if throttledUntil > 0 {
+			leaseUntil = throttledUntil
+		}
+	}
+
+	claimResult := <-app.Srv.Store.FacebookConversation().ClaimOutboxMessage(message.Id, message.NextAttemptAt, leaseUntil)
+	if claimResult.Err != nil {
+		mlog.Error("Unable to claim outbox message", mlog.String("message_id", messageId), mlog.Err(claimResult.Err))
+		return
+	}
+
+	if !claimResult.Data.(bool) || throttledUntil > 0 {
+		return
+	}
+	message.NextAttemptAt = leaseUntil
+
+	reply := message.OutboxReply()
+	if reply == nil {
+		app.failOutboxMessage(message, nil, model.NewAppError("SendOutboxMessage", "app.outbox.missing_reply.app_error", nil, "id="+message.Id, http.StatusInternalServerError))
+		return
+	}
+	reply.Metadata = message.Id
+
+	body, fErr, aErr := app.sendOutboxReply(message, reply)
+	message.SendAttempts++
+
+	if fErr == nil && aErr == nil {
+		app.completeOutboxMessage(message, reply, body)
+		return
+	}
+
+	maxAttempts := *app.Config().FacebookAPISettings.OutboxMaxAttempts
+	switch {
+	case fErr != nil && fErr.IsRateLimit() && message.SendAttempts < maxAttempts:
+		until := model.GetMillis() + int64(outboxRetryBackoff(OUTBOX_RATE_LIMIT_BACKOFF, message.SendAttempts)/time.Millisecond)
+		if app.Srv.FacebookOutbox != nil {
+			app.Srv.FacebookOutbox.Throttle(message.PageId, until)
+		}
+		app.retryOutboxMessage(message, fErr, until)
+	case (fErr != nil && fErr.IsTransient() || fErr == nil && aErr != nil && aErr.StatusCode >= http.StatusInternalServerError) && message.SendAttempts < maxAttempts:
+		until := model.GetMillis() + int64(outboxRetryBackoff(OUTBOX_RETRY_BACKOFF_BASE, message.SendAttempts)/time.Millisecond)
+		app.retryOutboxMessage(message, fErr, until)
+	default:
+		app.failOutboxMessage(message, fErr, aErr)
+	}
+}
+
+func (app *App) sendOutboxReply(message *model.FacebookConversationMessage, reply *model.ConversationReply) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+	if reply.Type == "comment" {
+		return app.ReplyComment(reply.CommentId, reply, message.UserId)
+	}
+
+	// tin nhắn có thể nằm trong outbox lâu hơn thời gian còn lại của cửa sổ 24h, kiểm tra lại trước khi gửi
+	conversation, err := app.GetConversation(message.ConversationId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := app.CheckReplyMessagingWindow(conversation, reply); err != nil {
+		return nil, nil, err
+	}
+
+	psId := reply.PageScopeId
+	if len(psId) == 0 {
+		// lấy page scope id từ app scope id, lưu lại để những lần trả lời sau không cần gọi lại
+		psid, fErr, aErr := app.MatchPageScopeId(reply.PageId, reply.To)
+		if fErr != nil || aErr != nil {
+			return nil, fErr, aErr
+		}
+		psId = psid
+
+		if err := app.UpdateConversationPageScopeId(message.ConversationId, psId); err != nil {
+			mlog.Warn("Unable to update conversation page scope id", mlog.String("conversation_id", message.ConversationId), mlog.Err(err))
+		}
+	}
+
+	return app.ReplyMessage(reply.ThreadId, psId, reply, message.UserId)
+}
+
+func (app *App) completeOutboxMessage(message *model.FacebookConversationMessage, reply *model.ConversationReply, body io.ReadCloser) {
+	var resp *facebookgraph.FacebookReplyCommentResponse
+	if body != nil {
+		defer body.Close()
+		data, _ := ioutil.ReadAll(body)
+		json.Unmarshal(data, &resp)
+	}
+
+	if resp != nil {
+		if message.Type == "comment" {
+			message.CommentId = resp.Id
+		} else {
+			message.MessageId = resp.MessageId
+		}
+	}
+
+	message.Status = model.MESSAGE_STATUS_SENT
+	message.Sent = true
+	message.SentAt = model.GetMillis()
+	message.LastError = ""
+	message.ErrorCode = 0
+
+	updated := app.updateOutboxMessage(message, model.MESSAGE_STATUS_PENDING)
+
+	// Send API đã nhận tin nhắn nên hội thoại mới được coi là đã đọc và đã trả lời
+	updateResult := <-app.Srv.Store.FacebookConversation().UpdateConversation(message.ConversationId, utils.GetSnippet(reply.Message), true, message.CreatedTime, 0, "")
+	if updateResult.Err != nil {
+		mlog.Warn("Unable to update conversation snippet", mlog.String("conversation_id", message.ConversationId), mlog.Err(updateResult.Err))
+	}
+
+	if !updated {
+		// echo webhook đã cập nhật tin nhắn trước
+		return
+	}
+
+	app.publishOutboxMessage(model.WEBSOCKET_EVENT_ADD_MESSAGE, message, reply)
+}
+
+func (app *App) retryOutboxMessage(message *model.FacebookConversationMessage, fErr *facebookgraph.FacebookError, nextAttemptAt int64) {
+	message.NextAttemptAt = nextAttemptAt
+	if fErr != nil {
+		message.LastError = fErr.Error.Message
+		message.ErrorCode = fErr.Error.Code
+	}
+
+	mlog.Warn("Failed to send outbox message, will retry",
+		mlog.String("message_id", message.Id),
+		mlog.String("page_id", message.PageId),
+		mlog.Int("attempts", message.SendAttempts),
+		mlog.Int("error_code", message.ErrorCode),
+	)
+
+	app.updateOutboxMessage(message, model.MESSAGE_STATUS_PENDING)
+}
+
+func (app *App) failOutboxMessage(message *model.FacebookConversationMessage, fErr *facebookgraph.FacebookError, aErr *model.AppError) {
+	message.Status = model.MESSAGE_STATUS_FAILED
+	if fErr != nil {
+		message.LastError = fErr.Error.Message
+		message.ErrorCode = fErr.Error.Code
+	} else if aErr != nil {
+		message.LastError = aErr.Error()
+		message.ErrorCode = 0
+	}
+	if len(message.LastError) > 1024 {
+		message.LastError = message.LastError[:1024]
+	}
+
+	mlog.Warn("Failed to send outbox message",
+		mlog.String("message_id", message.Id),
+		mlog.String("page_id", message.PageId),
+		mlog.Int("attempts", message.SendAttempts),
+		mlog.String("error", message.LastError),
+	)
+
+	if !app.updateOutboxMessage(message, model.MESSAGE_STATUS_PENDING) {
+		return
+	}
+
+	app.publishOutboxMessage(model.MESSAGE_FAILED, message, message.OutboxReply())
+}
+
+func (app *App) updateOutboxMessage(message *model.FacebookConversationMessage, expectedStatus string) bool {
+	result := <-app.Srv.Store.FacebookConversation().UpdateOutboxMessage(message, expectedStatus)
+	if result.Err != nil {
+		mlog.Error("Unable to update outbox message", mlog.String("message_id", message.Id), mlog.Err(result.Err))
+		return false
+	}
+	return result.Data.(bool)
+}
+
+func (app *App) publishOutboxMessage(event string, message *model.FacebookConversationMessage, reply *model.ConversationReply) {
+	m := model.NewWebSocketEvent(event, "", message.PageId, "", nil)
+	m.Add("page_id", message.PageId)
+	m.Add("conversation_id", message.ConversationId)
+	m.Add("id", message.Id)
+	m.Add("message", message)
+	if reply != nil {
+		m.Add("pending_message_id", reply.PendingMessageId)
+	}
+	app.Publish(m)
+}
+
+// outboxRetryBackoff trả về thời gian chờ trước lần gửi tiếp theo, tăng gấp đôi sau mỗi lần, tối đa 30 phút
+func outboxRetryBackoff(base time.Duration, attempts int) time.Duration {
+	backoff := base
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= OUTBOX_RETRY_BACKOFF_MAXIMUM {
+			return OUTBOX_RETRY_BACKOFF_MAXIMUM
+		}
+	}
+	return backoff
+}
+
+func (app *App) getConversationOutboxMessage(conversationId, messageId string) (*model.FacebookConversationMessage, *model.AppError) {
+	result := <-app.Srv.Store.FacebookConversation().GetOutboxMessage(messageId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	message := result.Data.(*model.FacebookConversationMessage)
+
+	if message.ConversationId != conversationId || !message.IsOutboxMessage() {
+		return nil, model.NewAppError("getConversationOutboxMessage", "app.outbox.not_found.app_error", nil, "id="+messageId, http.StatusNotFound)
+	}
+	return message, nil
+}
+
+// RetryOutboxMessage đưa tin nhắn gửi lỗi trở lại outbox theo yêu cầu của nhân viên
+func (app *App) RetryOutboxMessage(conversationId, messageId string) (*model.FacebookConversationMessage, *model.AppError) {
+	message, err := app.getConversationOutboxMessage(conversationId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if message.Status != model.MESSAGE_STATUS_FAILED || message.DeleteAt > 0 {
+		return nil, model.NewAppError("RetryOutboxMessage", "app.outbox.retry.invalid_status.app_error", nil, "status="+message.Status, http.StatusBadRequest)
+	}
+
+	message.Status = model.MESSAGE_STATUS_PENDING
+	message.SendAttempts = 0
+	message.NextAttemptAt = model.GetMillis()
+	message.LastError = ""
+	message.ErrorCode = 0
+
+	if !app.updateOutboxMessage(message, model.MESSAGE_STATUS_FAILED) {
+		return nil, model.NewAppError("RetryOutboxMessage", "app.outbox.retry.invalid_status.app_error", nil, "id="+messageId, http.StatusConflict)
+	}
+
+	app.publishOutboxMessage(model.MESSAGE_STATUS_UPDATED, message, message.OutboxReply())
+
+	if app.Srv.FacebookOutbox != nil {
+		app.Srv.FacebookOutbox.Push(message.Id)
+	}
+
+	return message, nil
+}
+
+// CancelOutboxMessage huỷ tin nhắn chưa gửi được, tin nhắn bị xoá khỏi hội thoại và không được gửi lại
+func (app *App) CancelOutboxMessage(conversationId, messageId, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	message, err := app.getConversationOutboxMessage(conversationId, messageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if (message.Status != model.MESSAGE_STATUS_FAILED && message.Status != model.MESSAGE_STATUS_PENDING) || message.DeleteAt > 0 {
+		return nil, model.NewAppError("CancelOutboxMessage", "app.outbox.cancel.invalid_status.app_error", nil, "status="+message.Status, http.StatusBadRequest)
+	}
+
+	expectedStatus := message.Status
+	message.Status = model.MESSAGE_STATUS_FAILED
+	message.DeleteAt = model.GetMillis()
+	message.DeleteBy = userId
+
+	if !app.updateOutboxMessage(message, expectedStatus) {
+		return nil, model.NewAppError("CancelOutboxMessage", "app.outbox.cancel.invalid_status.app_error", nil, "id="+messageId, http.StatusConflict)
+	}
+
+	app.publishOutboxMessage(model.MESSAGE_CANCELLED, message, message.OutboxReply())
+
+	return message, nil
+}
+
+// reconcileOutboxEcho đối chiếu echo webhook với tin nhắn trong outbox qua metadata (Id của tin nhắn),
+// echo có thể về trước khi worker kịp lưu message id trả về từ Send API
+func (app *App) reconcileOutboxEcho(pageId, metadata, mid string, timestamp int64) *model.FacebookConversationMessage {
+	if len(metadata) != 26 {
+		return nil
+	}
+
+	result := <-app.Srv.Store.FacebookConversation().GetOutboxMessage(metadata)
+	if result.Err != nil {
+		return nil
+	}
+	message := result.Data.(*model.FacebookConversationMessage)
+
+	if message.PageId != pageId || !message.IsOutboxMessage() {
+		return nil
+	}
+
+	if message.Status == model.MESSAGE_STATUS_PENDING || message.Status == model.MESSAGE_STATUS_FAILED {
+		expectedStatus := message.Status
+		message.Status = model.MESSAGE_STATUS_SENT
+		message.Sent = true
+		message.MessageId = mid
+		message.SentAt = timestamp
+		message.LastError = ""
+		message.ErrorCode = 0
+
+		if app.updateOutboxMessage(message, expectedStatus) {
+			app.publishOutboxMessage(model.WEBSOCKET_EVENT_ADD_MESSAGE, message, message.OutboxReply())
+		}
+	}
+
+	return message
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/utils"
+	"bytes"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+const (
+	PAGE_ATTACHMENT_ID_CACHE_SIZE = 10000
+	PAGE_ATTACHMENT_ID_CACHE_SEC  = 30 * 24 * 60 * 60 // 30 days
+
+	// giới hạn dung lượng attachment của Send API
+	PAGE_ATTACHMENT_MAX_SIZE = 25 * 1024 * 1024
+)
+
+// attachment_id do facebook cấp chỉ dùng được trên page đã upload, cache theo page và nguồn của attachment
+var pageAttachmentIdCache *utils.Cache = utils.NewLru(PAGE_ATTACHMENT_ID_CACHE_SIZE)
+
+func pageAttachmentIdCacheKey(pageId, source string) string {
+	return pageId + ":" + source
+}
+
+// sendAttachmentTypeFromMimeType chọn kiểu attachment của Send API từ mime type của file
+func sendAttachmentTypeFromMimeType(mimeType string) string {
+	switch {
+	case strings.HasPrefix(mimeType, "image/"):
+		return facebookgraph.SEND_ATTACHMENT_TYPE_IMAGE
+	case strings.HasPrefix(mimeType, "video/"):
+		return facebookgraph.SEND_ATTACHMENT_TYPE_VIDEO
+	case strings.HasPrefix(mimeType, "audio/"):
+		return facebookgraph.SEND_ATTACHMENT_TYPE_AUDIO
+	}
+	return facebookgraph.SEND_ATTACHMENT_TYPE_FILE
+}
+
+// resolveReplyAttachment upload file hoặc attachment_url của tin nhắn trả lời lên page và trả về bản sao đã gán attachment_id
+func (app *App) resolveReplyAttachment(reply *model.ConversationReply, userId string) (*model.ConversationReply, *facebookgraph.FacebookError, *model.AppError) {
+	if reply.Template != nil || len(reply.AttachmentId) > 0 || (len(reply.FileId) == 0 && len(reply.AttachmentUrl) == 0) {
+		return reply, nil, nil
+	}
+
+	resolved := *reply
+
+	if len(reply.FileId) > 0 {
+		attachmentType, attachmentId, fErr, aErr := app.UploadPageFileAttachment(reply.PageId, userId, reply.FileId)
+		if fErr != nil || aErr != nil {
+			return nil, fErr, aErr
+		}
+
+		resolved.FileId = ""
+		resolved.AttachmentType = attachmentType
+		resolved.AttachmentId = attachmentId
+		return &resolved, nil, nil
+	}
+
+	attachmentId, fErr, aErr := app.UploadPageAttachmentUrl(reply.PageId, userId, reply.GetAttachmentType(), reply.AttachmentUrl)
+	if fErr != nil || aErr != nil {
+		return nil, fErr, aErr
+	}
+
+	resolved.AttachmentUrl = ""
+	resolved.AttachmentId = attachmentId
+	return &resolved, nil, nil
+}
+
+// UploadPageAttachmentUrl upload attachment từ url lên page qua /me/message_attachments, trả về attachment_id dùng lại được
+func (app *App) UploadPageAttachmentUrl(pageId, userId, attachmentType, attachmentUrl string) (string, *facebookgraph.FacebookError, *model.AppError) {
+	cacheKey := pageAttachmentIdCacheKey(pageId, "url:"+attachmentType+":"+attachmentUrl)
+	if cacheItem, ok := pageAttachmentIdCache.Get(cacheKey); ok {
+		return cacheItem.(string), nil, nil
+	}
+
+	message := facebookgraph.NewAttachmentUploadMessage(attachmentType, attachmentUrl)
+
+	body, fErr, aErr := app.DoWithPageToken(pageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		p := url.Values{}
+		p.Set("message", message.ToJson())
+		return app.doFacebookUploadRequest(token, "/me/message_attachments", "application/x-www-form-urlencoded", []byte(p.Encode()))
+	})
+	if fErr != nil || aErr != nil {
+		return "", fErr, aErr
+	}
+
+	attachmentId, aErr := readAttachmentUploadResponse(body)
+	if aErr != nil {
+		return "", nil, aErr
+	}
+
+	pageAttachmentIdCache.AddWithExpiresInSecs(cacheKey, attachmentId, PAGE_ATTACHMENT_ID_CACHE_SEC)
+	return attachmentId, nil, nil
+}
+
+// UploadPageFileAttachment upload file người dùng đã tải lên papo lên page, trả về kiểu attachment và attachment_id
+func (app *App) UploadPageFileAttachment(pageId, userId, fileId string) (string, string, *facebookgraph.FacebookError, *model.AppError) {
+	info, err := app.GetFileInfo(fileId)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	if info.CreatorId != userId || info.DeleteAt > 0 {
+		return "", "", nil, model.NewAppError("UploadPageFileAttachment", "app.send_api.file_permission.app_error", nil, "file_id="+fileId, http.StatusForbidden)
+	}
+
+	if info.Size > PAGE_ATTACHMENT_MAX_SIZE {
+		return "", "", nil, model.NewAppError("UploadPageFileAttachment", "app.send_api.file_too_large.app_error", map[string]interface{}{"MaxSize": PAGE_ATTACHMENT_MAX_SIZE}, "file_id="+fileId, http.StatusBadRequest)
+	}
+
+	attachmentType := sendAttachmentTypeFromMimeType(info.MimeType)
+
+	cacheKey := pageAttachmentIdCacheKey(pageId, "file:"+fileId)
+	if cacheItem, ok := pageAttachmentIdCache.Get(cacheKey); ok {
+		return attachmentType, cacheItem.(string), nil, nil
+	}
+
+	data, err := app.ReadFile(info.Path)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	message := &facebookgraph.SendMessage{
+		Attachment: &facebookgraph.SendAttachment{
+			Type:    attachmentType,
+			Payload: &facebookgraph.SendAttachmentPayload{IsReusable: true},
+		},
+	}
+
+	contentType, payload, mErr := buildAttachmentUploadForm(message, info, data)
+	if mErr != nil {
+		return "", "", nil, model.NewAppError("UploadPageFileAttachment", "app.send_api.upload.app_error", nil, mErr.Error(), http.StatusInternalServerError)
+	}
+
+	body, fErr, aErr := app.DoWithPageToken(pageId, userId, func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+		return app.doFacebookUploadRequest(token, "/me/message_attachments", contentType, payload)
+	})
+	if fErr != nil || aErr != nil {
+		return "", "", fErr, aErr
+	}
+
+	attachmentId, aErr := readAttachmentUploadResponse(body)
+	if aErr != nil {
+		return "", "", nil, aErr
+	}
+
+	pageAttachmentIdCache.AddWithExpiresInSecs(cacheKey, attachmentId, PAGE_ATTACHMENT_ID_CACHE_SEC)
+	return attachmentType, attachmentId, nil, nil
+}
+
+func buildAttachmentUploadForm(message *facebookgraph.SendMessage, info *model.FileInfo, data []byte) (string, []byte, error) {
+	buf := &bytes.Buffer{}
+	writer := multipart.NewWriter(buf)
+
+	if err := writer.WriteField("message", message.ToJson()); err != nil {
+		return "", nil, err
+	}
+
+	mimeType := info.MimeType
+	if len(mimeType) == 0 {
+		mimeType = "application/octet-stream"
+	}
+
+	header := make(textproto.MIMEHeader)
+	header.Set("Content-Disposition", fmt.Sprintf(`form-data; name="filedata"; filename="%s"`, strings.Replace(info.Name, `"`, "", -1)))
+	header.Set("Content-Type", mimeType)
+
+	part, err := writer.CreatePart(header)
+	if err != nil {
+		return "", nil, err
+	}
+
+	if _, err := part.Write(data); err != nil {
+		return "", nil, err
+	}
+
+	if err := writer.Close(); err != nil {
+		return "", nil, err
+	}
+
+	return writer.FormDataContentType(), buf.Bytes(), nil
+}
+
+func readAttachmentUploadResponse(body io.ReadCloser) (string, *model.AppError) {
+	defer body.Close()
+	resp := facebookgraph.AttachmentUploadResponseFromJson(body)
+	if resp == nil || len(resp.AttachmentId) == 0 {
+		return "", model.NewAppError("readAttachmentUploadResponse", "app.send_api.upload.app_error", nil, "missing attachment_id", http.StatusInternalServerError)
+	}
+	return resp.AttachmentId, nil
+}
+
+func (app *App) doFacebookUploadRequest(token string, path string, contentType string, payload []byte) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+	return app.doGraphRequest("doFacebookUploadRequest", http.MethodPost, path, token, contentType, payload)
+}
@@ -0,0 +1,357 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	WEBHOOK_QUEUE_SIZE            = 1000
+	WEBHOOK_QUEUE_POLL_INTERVAL   = 5 * time.Second
+	WEBHOOK_QUEUE_POLL_LIMIT      = 200
+	WEBHOOK_QUEUE_STALE_AFTER     = 5 * time.Minute
+	WEBHOOK_RETRY_BACKOFF_BASE    = 10 * time.Second
+	WEBHOOK_RETRY_BACKOFF_MAXIMUM = time.Hour
+
+	PROCESSED_EVENT_CLEANUP_INTERVAL = time.Hour
+	PROCESSED_EVENT_RETENTION        = 30 * 24 * time.Hour
+)
+
+// FacebookWebhookQueue xử lý các webhook đã lưu trong FacebookWebhookEvents bằng một nhóm worker.
+// Event mới được đẩy thẳng vào channel, event cần thử lại (hoặc bị rơi khi channel đầy) sẽ được poller lấy lại từ database.
+type FacebookWebhookQueue struct {
+	app    *App
+	events chan string
+	stop   chan struct{}
+	wg     sync.WaitGroup
+}
+
+func NewFacebookWebhookQueue(app *App) *FacebookWebhookQueue {
+	return &FacebookWebhookQueue{
+		app:    app,
+		events: make(chan string, WEBHOOK_QUEUE_SIZE),
+		stop:   make(chan struct{}),
+	}
+}
+
+func (q *FacebookWebhookQueue) Start() {
+	workers := *q.app.Config().FacebookAPISettings.WebhookWorkers
+	if workers < 1 {
+		workers = 1
+	}
+
+	mlog.Info("Starting facebook webhook queue", mlog.Int("workers", workers))
+
+	for i := 0; i < workers; i++ {
+		q.wg.Add(1)
+		go q.work()
+	}
+
+	q.wg.Add(1)
+	go q.poll()
+}
+
+func (q *FacebookWebhookQueue) Stop() {
+	mlog.Info("Stopping facebook webhook queue")
+	close(q.stop)
+	q.wg.Wait()
+}
+
+// Push đưa event vào hàng đợi, nếu hàng đợi đầy event sẽ được poller xử lý sau
+func (q *FacebookWebhookQueue) Push(eventId string) {
+	select {
+	case q.events <- eventId:
+	default:
+	}
+}
+
+func (q *FacebookWebhookQueue) work() {
+	defer q.wg.Done()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case eventId := <-q.events:
+			q.app.ProcessFacebookWebhookEvent(eventId)
+		}
+	}
+}
+
+func (q *FacebookWebhookQueue) poll() {
+	defer q.wg.Done()
+
+	ticker := time.NewTicker(WEBHOOK_QUEUE_POLL_INTERVAL)
+	defer ticker.Stop()
+
+	cleanupTicker := time.NewTicker(PROCESSED_EVENT_CLEANUP_INTERVAL)
+	defer cleanupTicker.Stop()
+
+	for {
+		select {
+		case <-q.stop:
+			return
+		case <-cleanupTicker.C:
+			// Facebook chỉ gửi lại webhook trong vài ngày, không cần giữ sổ sự kiện quá lâu
+			before := model.GetMillis() - int64(PROCESSED_EVENT_RETENTION/time.Millisecond)
+			if result := <-q.app.Srv.Store.FacebookProcessedEvent().PermanentDeleteBefore(before); result.Err != nil {
+				mlog.Error("Unable to clean up processed facebook webhook events", mlog.Err(result.Err))
+			}
+		case <-ticker.C:
+			events, err := q.app.getDueFacebookWebhookEvents()
+			if err != nil {
+				mlog.Error("Unable to load pending facebook webhook events", mlog.Err(err))
+				continue
+			}
+
+			for _, event := range events {
+				q.Push(event.Id)
+			}
+		}
+	}
+}
+
+func (app *App) StartFacebookWebhookQueue() {
+	app.Srv.FacebookWebhookQueue = NewFacebookWebhookQueue(app)
+	app.Srv.FacebookWebhookQueue.Start()
+}
+
+// facebookWebhookPayload giữ nguyên JSON của từng entry để tách payload mà không làm mất trường nào
+type facebookWebhookPayload struct {
+	Object string            `json:"object"`
+	Entry  []json.RawMessage `json:"entry"`
+}
+
+// EnqueueFacebookWebhook tách payload webhook thành từng entry, lưu mỗi entry thành một event và đưa vào hàng đợi xử lý,
+// không chờ xử lý xong. Nhờ vậy một entry lỗi chỉ khiến chính entry đó được thử lại.
+func (app *App) EnqueueFacebookWebhook(payload []byte) ([]*model.FacebookWebhookEvent, *model.AppError) {
+	var hubPayload *facebookWebhookPayload
+	if err := json.Unmarshal(payload, &hubPayload); err != nil || hubPayload == nil {
+		details := ""
+		if err != nil {
+			details = err.Error()
+		}
+		return nil, model.NewAppError("EnqueueFacebookWebhook", "app.facebook_webhook.parse.app_error", nil, details, http.StatusBadRequest)
+	}
+
+	payloads := []string{string(payload)}
+	if len(hubPayload.Entry) > 1 {
+		payloads = nil
+		for _, entry := range hubPayload.Entry {
+			entryPayload, err := json.Marshal(&facebookWebhookPayload{Object: hubPayload.Object, Entry: []json.RawMessage{entry}})
+			if err != nil {
+				return nil, model.NewAppError("EnqueueFacebookWebhook", "app.facebook_webhook.parse.app_error", nil, err.Error(), http.StatusBadRequest)
+			}
+			payloads = append(payloads, string(entryPayload))
+		}
+	}
+
+	var events []*model.FacebookWebhookEvent
+	for _, entryPayload := range payloads {
+		event := &model.FacebookWebhookEvent{
+			Object:  hubPayload.Object,
+			Payload: entryPayload,
+		}
+
+		result := <-app.Srv.Store.FacebookWebhookEvent().Save(event)
+		if result.Err != nil {
+			return nil, result.Err
+		}
+		event = result.Data.(*model.FacebookWebhookEvent)
+		events = append(events, event)
+
+		if app.Srv.FacebookWebhookQueue != nil {
+			app.Srv.FacebookWebhookQueue.Push(event.Id)
+		}
+	}
+
+	return events, nil
+}
+
+// ProcessFacebookWebhookEvent chạy lại entry đã lưu, lỗi ở bất kỳ sự kiện nào của entry sẽ khiến event được thử lại với backoff.
+// Trả về false nếu worker khác đã nhận event, lỗi trả về chỉ là lỗi đọc ghi event chứ không phải lỗi xử lý entry
+func (app *App) ProcessFacebookWebhookEvent(eventId string) (bool, *model.AppError) {
+	claimResult := <-app.Srv.Store.FacebookWebhookEvent().Claim(eventId)
+	if claimResult.Err != nil {
+		mlog.Error("Unable to claim facebook webhook event", mlog.String("event_id", eventId), mlog.Err(claimResult.Err))
+		return false, claimResult.Err
+	}
+
+	if !claimResult.Data.(bool) {
+		// worker khác đã nhận event này
+		return false, nil
+	}
+
+	result := <-app.Srv.Store.FacebookWebhookEvent().Get(eventId)
+	if result.Err != nil {
+		mlog.Error("Unable to load facebook webhook event", mlog.String("event_id", eventId), mlog.Err(result.Err))
+		return true, result.Err
+	}
+	event := result.Data.(*model.FacebookWebhookEvent)
+
+	var errors []string
+	var hubEntries *facebookgraph.HubEntries
+	if err := json.Unmarshal([]byte(event.Payload), &hubEntries); err != nil {
+		errors = append(errors, err.Error())
+	} else {
+		for _, itemResult := range app.HandleFacebookWebhook(hubEntries) {
+			if itemResult.Err != nil {
+				errors = append(errors, itemResult.EntryId+"/"+itemResult.Kind+": "+itemResult.Err.Error())
+			}
+		}
+	}
+
+	event.Attempts++
+	if len(errors) == 0 {
+		event.Status = model.WEBHOOK_EVENT_STATUS_PROCESSED
+		event.LastError = ""
+		event.ProcessedAt = model.GetMillis()
+	} else {
+		event.LastError = strings.Join(errors, "\n")
+		if event.Attempts >= *app.Config().FacebookAPISettings.WebhookMaxAttempts {
+			event.Status = model.WEBHOOK_EVENT_STATUS_FAILED
+		} else {
+			event.Status = model.WEBHOOK_EVENT_STATUS_RETRYING
+			event.NextAttemptAt = model.GetMillis() + int64(webhookRetryBackoff(event.Attempts)/time.Millisecond)
+		}
+
+		mlog.Warn("Failed to process facebook webhook event",
+			mlog.String("event_id", event.Id),
+			mlog.Int("attempts", event.Attempts),
+			mlog.String("status", event.Status),
+			mlog.String("error", event.LastError),
+		)
+	}
+
+	if result := <-app.Srv.Store.FacebookWebhookEvent().Update(event); result.Err != nil {
+		mlog.Error("Unable to update facebook webhook event", mlog.String("event_id", event.Id), mlog.Err(result.Err))
+		return true, result.Err
+	}
+
+	return true, nil
+}
+
+// webhookRetryBackoff trả về thời gian chờ trước lần thử tiếp theo: 10s, 20s, 40s... tối đa 1 giờ
+func webhookRetryBackoff(attempts int) time.Duration {
+	backoff := WEBHOOK_RETRY_BACKOFF_BASE
+	for i := 1; i < attempts; i++ {
+		backoff *= 2
+		if backoff >= WEBHOOK_RETRY_BACKOFF_MAXIMUM {
+			return WEBHOOK_RETRY_BACKOFF_MAXIMUM
+		}
+	}
+	return backoff
+}
+
+func (app *App) getDueFacebookWebhookEvents() ([]*model.FacebookWebhookEvent, *model.AppError) {
+	now := model.GetMillis()
+	staleBefore := now - int64(WEBHOOK_QUEUE_STALE_AFTER/time.Millisecond)
+
+	result := <-app.Srv.Store.FacebookWebhookEvent().GetDue(now, staleBefore, WEBHOOK_QUEUE_POLL_LIMIT)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.FacebookWebhookEvent), nil
+}
+
+// ProcessDueFacebookWebhookEvents xử lý tuần tự toàn bộ event đến hạn, dùng cho CLI khi không có server chạy queue.
+// Chỉ đếm event đã nhận xử lý, dừng khi gặp lỗi database hoặc không nhận được event nào để không lặp mãi trên cùng các dòng
+func (app *App) ProcessDueFacebookWebhookEvents() (int, *model.AppError) {
+	processed := 0
+	for {
+		events, err := app.getDueFacebookWebhookEvents()
+		if err != nil {
+			return processed, err
+		}
+
+		claimedCount := 0
+		for _, event := range events {
+			claimed, err := app.ProcessFacebookWebhookEvent(event.Id)
+			if err != nil {
+				return processed, err
+			}
+			if claimed {
+				claimedCount++
+			}
+		}
+
+		if claimedCount == 0 {
+			return processed, nil
+		}
+		processed += claimedCount
+	}
+}
+
+func (app *App) GetFacebookWebhookEvent(eventId string) (*model.FacebookWebhookEvent, *model.AppError) {
+	result := <-app.Srv.Store.FacebookWebhookEvent().Get(eventId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.FacebookWebhookEvent), nil
+}
+
+func (app *App) GetFacebookWebhookEvents(since int64, status string, page, perPage int) ([]*model.FacebookWebhookEvent, *model.AppError) {
+	result := <-app.Srv.Store.FacebookWebhookEvent().GetSince(since, status, page*perPage, perPage)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.FacebookWebhookEvent), nil
+}
+
+// ReplayFacebookWebhookEvent đưa một event về trạng thái chờ để xử lý lại, kể cả event đã xử lý thành công
+func (app *App) ReplayFacebookWebhookEvent(eventId string) (*model.FacebookWebhookEvent, *model.AppError) {
+	event, err := app.GetFacebookWebhookEvent(eventId)
+	if err != nil {
+		return nil, err
+	}
+
+	if event.Status == model.WEBHOOK_EVENT_STATUS_PROCESSING {
+		return nil, model.NewAppError("ReplayFacebookWebhookEvent", "app.facebook_webhook.replay.processing.app_error", nil, "id="+eventId, http.StatusBadRequest)
+	}
+
+	// sự kiện đã xử lý xong vẫn nằm trong sổ chống trùng, phải xóa thì lần phát lại mới có tác dụng
+	if event.ProcessedAt > 0 {
+		if result := <-app.Srv.Store.FacebookProcessedEvent().ReleaseProcessedBetween(event.CreateAt, event.ProcessedAt); result.Err != nil {
+			return nil, result.Err
+		}
+	}
+
+	event.Status = model.WEBHOOK_EVENT_STATUS_PENDING
+	event.Attempts = 0
+	event.LastError = ""
+	event.NextAttemptAt = model.GetMillis()
+
+	result := <-app.Srv.Store.FacebookWebhookEvent().Update(event)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	if app.Srv.FacebookWebhookQueue != nil {
+		app.Srv.FacebookWebhookQueue.Push(event.Id)
+	}
+
+	return event, nil
+}
+
+// ReplayFacebookWebhookEventsSince đưa toàn bộ event nhận từ thời điểm since về trạng thái chờ, queue sẽ tự xử lý lại.
+// Các sự kiện đã xử lý trong khoảng này được xóa khỏi sổ chống trùng trước để không bị bỏ qua khi phát lại
+func (app *App) ReplayFacebookWebhookEventsSince(since int64) (int64, *model.AppError) {
+	if result := <-app.Srv.Store.FacebookProcessedEvent().ReleaseProcessedBetween(since, model.GetMillis()); result.Err != nil {
+		return 0, result.Err
+	}
+
+	result := <-app.Srv.Store.FacebookWebhookEvent().ResetForReplay(since)
+	if result.Err != nil {
+		return 0, result.Err
+	}
+	return result.Data.(int64), nil
+}
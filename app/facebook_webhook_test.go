@@ -0,0 +1,170 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"testing"
+
+	"bitbucket.org/enesyteam/papo-server/config"
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/facebook_graph/graphtest"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store/sqlstore"
+	"bitbucket.org/enesyteam/papo-server/store/storetest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// graphTestHelper chạy App với database thật và Graph API giả lập bởi graphtest
+type graphTestHelper struct {
+	App    *App
+	Graph  *graphtest.Server
+	Page   *graphtest.Page
+	UserId string
+}
+
+func setupGraphTestHelper(t *testing.T) *graphTestHelper {
+	if testing.Short() {
+		t.Skip("skipping test that needs a database in short mode")
+	}
+
+	driverName := os.Getenv("MM_SQLSETTINGS_DRIVERNAME")
+	if len(driverName) == 0 {
+		driverName = model.DATABASE_DRIVER_POSTGRES
+	}
+	settings := storetest.MakeSqlSettings(driverName)
+	t.Cleanup(func() { storetest.CleanupSqlSettings(settings) })
+
+	graph := graphtest.NewServer("secret")
+	t.Cleanup(graph.Close)
+	graph.AddUser("100", "Admin", "user_token")
+	page := graph.AddPage("user_token", &graphtest.Page{Id: "200", Name: "Papo Shop"})
+
+	memoryStore, err := config.NewMemoryStoreWithOptions(&config.MemoryStoreOptions{IgnoreEnvironmentOverrides: true})
+	require.Nil(t, err)
+	cfg := memoryStore.Get().Clone()
+	cfg.SqlSettings = *settings
+	*cfg.FacebookSettings.GraphApiBaseUrl = graph.URL
+	*cfg.FacebookSettings.GraphApiVersion = facebookgraph.GRAPH_API_DEFAULT_VERSION
+	*cfg.FacebookSettings.Secret = graph.AppSecret
+	*cfg.FacebookSettings.AppToken = graph.AppToken
+	*cfg.FacebookSettings.PageTokenEncryptKey = "page-token-key"
+	_, err = memoryStore.Set(cfg)
+	require.Nil(t, err)
+
+	s, err := NewServer(ConfigStore(memoryStore), StoreOverride(sqlstore.NewSqlSupplier(*settings, nil)))
+	require.Nil(t, err)
+	t.Cleanup(func() { s.Shutdown() })
+
+	th := &graphTestHelper{
+		App:    New(ServerConnector(s)),
+		Graph:  graph,
+		Page:   page,
+		UserId: model.NewId(),
+	}
+	require.Nil(t, th.App.SavePageMemberToken(model.NewId(), page.Id, th.UserId, page.AccessToken))
+
+	return th
+}
+
+// receive đưa sự kiện webhook qua hàng đợi như khi Facebook gọi webhook rồi xử lý ngay
+func (th *graphTestHelper) receive(t *testing.T, event graphtest.WebhookEvent) {
+	payload, err := json.Marshal(event)
+	require.Nil(t, err)
+
+	events, appErr := th.App.EnqueueFacebookWebhook(payload)
+	require.Nil(t, appErr)
+
+	for _, e := range events {
+		th.App.ProcessFacebookWebhookEvent(e.Id)
+
+		processed, appErr := th.App.GetFacebookWebhookEvent(e.Id)
+		require.Nil(t, appErr)
+		require.Equal(t, model.WEBHOOK_EVENT_STATUS_PROCESSED, processed.Status, processed.LastError)
+	}
+}
+
+func (th *graphTestHelper) conversationWith(t *testing.T, senderId string) *model.FacebookConversation {
+	result := <-th.App.Srv.Store.FacebookConversation().GetPageConversationBySenderId(th.Page.Id, senderId, "message")
+	require.Nil(t, result.Err)
+	conversations := result.Data.([]*model.FacebookConversation)
+	require.Len(t, conversations, 1)
+	return conversations[0]
+}
+
+func (th *graphTestHelper) messages(t *testing.T, conversationId string) []*model.FacebookConversationMessage {
+	result := <-th.App.Srv.Store.FacebookConversation().GetMessagesByConversationId(conversationId, 0, 100)
+	require.Nil(t, result.Err)
+	return result.Data.([]*model.FacebookConversationMessage)
+}
+
+func TestFacebookWebhookMessageToReply(t *testing.T) {
+	th := setupGraphTestHelper(t)
+
+	message := th.Graph.AddMessage(th.Page.Id, "300", "Customer", "xin chào", false)
+	th.receive(t, th.Graph.MessageEvent(message))
+
+	conversation := th.conversationWith(t, "300")
+	assert.Equal(t, "xin chào", conversation.Snippet)
+	require.Len(t, th.messages(t, conversation.Id), 1)
+
+	// Facebook gửi lại cùng sự kiện không tạo thêm tin nhắn
+	th.receive(t, th.Graph.MessageEvent(message))
+	require.Len(t, th.messages(t, conversation.Id), 1)
+
+	th.Graph.SetPageScopeId("300", th.Page.Id, "300")
+
+	reply, err := th.App.EnqueueConversationReply(conversation.Id, &model.ConversationReply{Message: "cảm ơn bạn"}, th.UserId)
+	require.Nil(t, err)
+	th.App.SendOutboxMessage(reply.Id)
+
+	sent := th.Graph.SentMessages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, th.Page.Id, sent[0].PageId)
+	assert.Equal(t, "300", sent[0].Request.Recipient.Id)
+	assert.Equal(t, "cảm ơn bạn", sent[0].Request.Message.Text)
+
+	result := <-th.App.Srv.Store.FacebookConversation().GetOutboxMessage(reply.Id)
+	require.Nil(t, result.Err)
+	assert.Equal(t, model.MESSAGE_STATUS_SENT, result.Data.(*model.FacebookConversationMessage).Status)
+}
+
+func TestFacebookWebhookSplitsEntries(t *testing.T) {
+	th := setupGraphTestHelper(t)
+
+	first := th.Graph.MessageEvent(th.Graph.AddMessage(th.Page.Id, "300", "Customer", "còn hàng không", false))
+	second := th.Graph.MessageEvent(th.Graph.AddMessage(th.Page.Id, "301", "Other", "giá bao nhiêu", false))
+	first["entry"] = append(first["entry"].([]interface{}), second["entry"].([]interface{})...)
+
+	th.receive(t, first)
+
+	assert.Equal(t, "còn hàng không", th.conversationWith(t, "300").Snippet)
+	assert.Equal(t, "giá bao nhiêu", th.conversationWith(t, "301").Snippet)
+}
+
+func TestFacebookWebhookReplyFailure(t *testing.T) {
+	th := setupGraphTestHelper(t)
+
+	th.receive(t, th.Graph.MessageEvent(th.Graph.AddMessage(th.Page.Id, "300", "Customer", "xin chào", false)))
+	conversation := th.conversationWith(t, "300")
+	th.Graph.SetPageScopeId("300", th.Page.Id, "300")
+
+	th.Graph.InjectError(http.MethodPost, "/me/messages", 1, http.StatusBadRequest, graphtest.NewError(graphtest.ERROR_CODE_INVALID_PARAMETER, "invalid parameter"))
+
+	reply, err := th.App.EnqueueConversationReply(conversation.Id, &model.ConversationReply{Message: "cảm ơn bạn"}, th.UserId)
+	require.Nil(t, err)
+	th.App.SendOutboxMessage(reply.Id)
+
+	assert.Empty(t, th.Graph.SentMessages())
+
+	result := <-th.App.Srv.Store.FacebookConversation().GetOutboxMessage(reply.Id)
+	require.Nil(t, result.Err)
+	failed := result.Data.(*model.FacebookConversationMessage)
+	assert.Equal(t, model.MESSAGE_STATUS_FAILED, failed.Status)
+	assert.NotEmpty(t, failed.LastError)
+}
@@ -80,10 +80,12 @@ func (app *App) CreateFanpageWithUser(fanpage *model.Fanpage, userId string) (*m
 // 1. Con trỏ tới fanpage member nếu thêm thành công
 // 2. bool: true nếu user đã được thêm vào fanpage từ trước, fale nếu user chưa được thêm
 // 3. Contrỏ tới 1 AppError nếu xảy ra lỗi
-func (app *App) addUserToFanpage(fanpage *model.Fanpage, user *model.User) (*model.FanpageMember, bool, *model.AppError) {
+func (app *App) addUserToFanpage(fanpage *model.Fanpage, user *model.User, roles string) (*model.FanpageMember, bool, *model.AppError) {
 	fm := &model.FanpageMember{
 		FanpageId: fanpage.Id,
+		PageId:    fanpage.PageId,
 		UserId:    user.Id,
+		Roles:     roles,
 	}
 
 	efmr := <-app.Srv.Store.Fanpage().GetMember(fanpage.Id, user.Id)
@@ -103,7 +105,7 @@ func (app *App) addUserToFanpage(fanpage *model.Fanpage, user *model.User) (*mod
 }
 
 func (app *App) AddUserToFanpage(fanpage *model.Fanpage, user *model.User, userRequestorId string) *model.AppError {
-	_, alreadyAdded, err := app.addUserToFanpage(fanpage, user)
+	_, alreadyAdded, err := app.addUserToFanpage(fanpage, user, model.FANPAGE_MEMBER_ADMIN_ROLES)
 	if err != nil {
 		return err
 	}
@@ -185,11 +187,9 @@ func (a *App) SetActivePage(userId string, pageId string) *model.AppError {
 }
 
 func (app *App) SanitizeFanpage(session model.Session, fanpage *model.Fanpage) *model.Fanpage {
-	// cần kiểm tra xem user có quyền truy cập page không
-	// tạm thời chưa dùng đến
-	//if !a.SessionHasPermissionToFanpage(session, fanpage.Id, model.PERMISSION_MANAGE_FANPAGE) {
-	//	fanpage.Sanitize()
-	//}
+	if !app.SessionHasPermissionToFanpage(session, fanpage.PageId, model.PERMISSION_MANAGE_FANPAGE) {
+		fanpage.Sanitize()
+	}
 
 	return fanpage
 }
@@ -239,7 +239,10 @@ func (app *App) GetPageSnippets(pageId string) ([]*model.ReplySnippet, *model.Ap
 
 ///////////////////////AUTO MESSAGE TASK
 func (app *App) CreateAutoMessageTask(task *model.AutoMessageTask) (*model.AutoMessageTask, *model.AppError) {
-	// snippet chưa có trong db
+	if err := task.IsValid(); err != nil {
+		return nil, err
+	}
+
 	result := <-app.Srv.Store.AutoMessageTask().Save(task)
 	if result.Err != nil {
 		mlog.Error(fmt.Sprintf("Couldn't save the auto message task err=%v", result.Err))
@@ -259,6 +262,10 @@ func (app *App) GetPageAutoMessageTasks(pageId string) ([]*model.AutoMessageTask
 }
 
 func (app *App) UpdateAutoMessageTask(task *model.AutoMessageTask) (*model.AutoMessageTask, *model.AppError) {
+	if err := task.IsValid(); err != nil {
+		return nil, err
+	}
+
 	result := <-app.Srv.Store.AutoMessageTask().Update(task)
 	if result.Err != nil {
 		mlog.Error(fmt.Sprintf("Couldn't update the auto message task err=%v", result.Err))
@@ -269,24 +276,6 @@ func (app *App) UpdateAutoMessageTask(task *model.AutoMessageTask) (*model.AutoM
 	return rTask, nil
 }
 
-// Khởi động một task, trả về thời điểm bắt đầu chạy
-func (app *App) StartAutoMessageTask(taskId string) (string, *model.AppError) {
-
-	return "", nil
-}
-
-// Pause một task, trả về thời điểm paused
-func (app *App) PauseAutoMessageTask(taskId string) (string, *model.AppError) {
-
-	return "", nil
-}
-
-// Delete một task, trả về thời điểm deleted
-func (app *App) DeleteAutoMessageTask(taskId string) (string, *model.AppError) {
-
-	return "", nil
-}
-
 func (app *App) GetFileInfosForPage(pageId string, readFromMaster bool, offset, limit int) ([]*model.FileInfo, *model.AppError) {
 	pchan := app.Srv.Store.Fanpage().GetFanpageByPageID(pageId)
 
@@ -0,0 +1,234 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"net/url"
+)
+
+const (
+	// job đang chạy mà không lưu checkpoint trong khoảng thời gian này được coi là bị bỏ dở do server dừng đột ngột
+	FANPAGE_INIT_JOB_STALE_MILLIS = 15 * 60 * 1000
+
+	FANPAGE_INIT_PROGRESS_POSTS = 50
+)
+
+// CreateFanpageInitJob tạo job khởi tạo page, việc graph dữ liệu do jobs worker thực hiện
+func (app *App) CreateFanpageInitJob(pageId string, userId string) (*model.Job, *model.AppError) {
+	if _, err := app.CreateFanpageInitResult(&model.FanpageInitResult{PageId: pageId, Creator: userId}); err != nil {
+		return nil, err
+	}
+
+	return app.Srv.Jobs.CreateJob(model.JOB_TYPE_FANPAGE_INIT, map[string]string{
+		model.JOB_DATA_FANPAGE_INIT_PAGE_ID: pageId,
+		model.JOB_DATA_FANPAGE_INIT_USER_ID: userId,
+		model.JOB_DATA_FANPAGE_INIT_PHASE:   model.FANPAGE_INIT_PHASE_MESSAGES,
+	})
+}
+
+func (app *App) StartFanpageInitJob(job *model.Job) {
+	app.updateFanpageInitPageStatus(job, model.PAGE_STATUS_INITIALIZING)
+	app.publishFanpageInitProgress(job, model.JOB_STATUS_IN_PROGRESS)
+}
+
+// FanpageInitJobStep graph một trang hội thoại hoặc bài viết tiếp theo và cập nhật cursor trong job data.
+// Trả về true khi đã graph hết dữ liệu của page.
+func (app *App) FanpageInitJobStep(job *model.Job) (bool, *model.AppError) {
+	pageId := job.Data[model.JOB_DATA_FANPAGE_INIT_PAGE_ID]
+	userId := job.Data[model.JOB_DATA_FANPAGE_INIT_USER_ID]
+	cursor := job.Data[model.JOB_DATA_FANPAGE_INIT_CURSOR]
+
+	token, err := app.GetPageAccessToken(pageId, userId)
+	if err != nil {
+		return false, err
+	}
+
+	// bộ đếm của kết quả khởi tạo được cập nhật theo PageId
+	initResult := &model.FanpageInitResult{PageId: pageId, Creator: userId}
+
+	var next string
+	var fbErr *facebookgraph.FacebookError
+
+	switch job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE] {
+	case model.FANPAGE_INIT_PHASE_MESSAGES:
+		next, fbErr, err = app.GraphConversationsPageAndInit(pageId, token, cursor, initResult)
+	case model.FANPAGE_INIT_PHASE_POSTS:
+		next, fbErr, err = app.GraphPagePostsAndInit(pageId, token, cursor, initResult)
+	case model.FANPAGE_INIT_PHASE_DONE:
+		return true, nil
+	default:
+		return false, model.NewAppError("FanpageInitJobStep", "app.fanpage_init.invalid_phase.app_error", nil, "job_id="+job.Id+", phase="+job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE], http.StatusInternalServerError)
+	}
+
+	if fbErr != nil {
+		app.HandlePageTokenError(pageId, fbErr)
+		return false, model.NewAppError("FanpageInitJobStep", "app.fanpage_init.graph.app_error", nil, "page_id="+pageId+", "+fbErr.Error.Message, http.StatusBadRequest)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if len(next) > 0 {
+		job.Data[model.JOB_DATA_FANPAGE_INIT_CURSOR] = removeAccessTokenFromUrl(next)
+	} else if job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE] == model.FANPAGE_INIT_PHASE_MESSAGES {
+		job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE] = model.FANPAGE_INIT_PHASE_POSTS
+		job.Data[model.JOB_DATA_FANPAGE_INIT_CURSOR] = ""
+		job.Progress = FANPAGE_INIT_PROGRESS_POSTS
+	} else {
+		job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE] = model.FANPAGE_INIT_PHASE_DONE
+		job.Data[model.JOB_DATA_FANPAGE_INIT_CURSOR] = ""
+		job.Progress = 100
+	}
+
+	app.publishFanpageInitProgress(job, model.JOB_STATUS_IN_PROGRESS)
+
+	return job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE] == model.FANPAGE_INIT_PHASE_DONE, nil
+}
+
+func (app *App) FinishFanpageInitJob(job *model.Job) {
+	app.updateFanpageInitPageStatus(job, model.PAGE_STATUS_INITIALIZED)
+	app.publishFanpageInitProgress(job, model.JOB_STATUS_SUCCESS)
+}
+
+// FailFanpageInitJob đánh dấu page lỗi, trừ khi page đã được chuyển sang cần đăng nhập lại do token bị vô hiệu
+func (app *App) FailFanpageInitJob(job *model.Job, jobErr *model.AppError) {
+	if page, err := app.GetFanpageByPageId(job.Data[model.JOB_DATA_FANPAGE_INIT_PAGE_ID]); err == nil && page.Status == model.PAGE_STATUS_NEEDS_REAUTH {
+		app.publishFanpageInitProgress(job, model.JOB_STATUS_ERROR)
+		return
+	}
+
+	app.updateFanpageInitPageStatus(job, model.PAGE_STATUS_ERROR)
+	app.publishFanpageInitProgress(job, model.JOB_STATUS_ERROR)
+}
+
+// CancelFanpageInitJob trả page về trạng thái ready để có thể khởi tạo lại, dữ liệu đã graph được giữ nguyên
+func (app *App) CancelFanpageInitJob(job *model.Job) {
+	app.updateFanpageInitPageStatus(job, model.PAGE_STATUS_READY)
+	app.publishFanpageInitProgress(job, model.JOB_STATUS_CANCELED)
+}
+
+// ReleaseFanpageInitJob trả job đang chạy về pending khi server dừng, job sẽ chạy tiếp từ cursor đã lưu
+func (app *App) ReleaseFanpageInitJob(job *model.Job) {
+	if result := <-app.Srv.Store.Job().UpdateStatusOptimistically(job.Id, model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_PENDING); result.Err != nil {
+		mlog.Error("Unable to release fanpage init job", mlog.String("job_id", job.Id), mlog.Err(result.Err))
+	}
+}
+
+// ResetStaleFanpageInitJobs tìm các job khởi tạo page bị bỏ dở khi server dừng đột ngột:
+// job đang chạy được trả về pending để chạy tiếp, job đang chờ huỷ thì được huỷ luôn.
+func (app *App) ResetStaleFanpageInitJobs() {
+	app.resetStaleJobs(model.JOB_TYPE_FANPAGE_INIT, FANPAGE_INIT_JOB_STALE_MILLIS, app.CancelFanpageInitJob)
+}
+
+// resetStaleJobs trả các job jobType không lưu checkpoint trong staleMillis về pending, job đang chờ huỷ thì huỷ luôn
+func (app *App) resetStaleJobs(jobType string, staleMillis int64, onCanceled func(job *model.Job)) {
+	staleBefore := model.GetMillis() - staleMillis
+
+	for _, status := range []string{model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_CANCEL_REQUESTED} {
+		result := <-app.Srv.Store.Job().GetAllByStatus(status)
+		if result.Err != nil {
+			mlog.Error("Unable to get jobs", mlog.String("type", jobType), mlog.String("status", status), mlog.Err(result.Err))
+			continue
+		}
+
+		for _, job := range result.Data.([]*model.Job) {
+			if job.Type != jobType || job.LastActivityAt > staleBefore {
+				continue
+			}
+
+			if status == model.JOB_STATUS_CANCEL_REQUESTED {
+				if r := <-app.Srv.Store.Job().UpdateStatusOptimistically(job.Id, status, model.JOB_STATUS_CANCELED); r.Err == nil && r.Data.(bool) {
+					onCanceled(job)
+				}
+				continue
+			}
+
+			if r := <-app.Srv.Store.Job().UpdateStatusOptimistically(job.Id, status, model.JOB_STATUS_PENDING); r.Err != nil {
+				mlog.Error("Unable to resume job", mlog.String("job_id", job.Id), mlog.Err(r.Err))
+			} else if r.Data.(bool) {
+				mlog.Info("Resuming interrupted job", mlog.String("type", jobType), mlog.String("job_id", job.Id))
+			}
+		}
+	}
+}
+
+func (app *App) updateFanpageInitPageStatus(job *model.Job, status string) {
+	pageId := job.Data[model.JOB_DATA_FANPAGE_INIT_PAGE_ID]
+	if err, _ := app.UpdatePageStatus(pageId, status, job.Data[model.JOB_DATA_FANPAGE_INIT_USER_ID]); err != nil {
+		mlog.Error("Unable to update page status", mlog.String("page_id", pageId), mlog.String("status", status), mlog.Err(err))
+	}
+}
+
+func (app *App) publishFanpageInitProgress(job *model.Job, jobStatus string) {
+	message := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE, "", "", job.Data[model.JOB_DATA_FANPAGE_INIT_USER_ID], nil)
+	message.Add("page_id", job.Data[model.JOB_DATA_FANPAGE_INIT_PAGE_ID])
+	message.Add("job_id", job.Id)
+	message.Add("job_status", jobStatus)
+	message.Add("phase", job.Data[model.JOB_DATA_FANPAGE_INIT_PHASE])
+	message.Add("progress", job.Progress)
+	app.Publish(message)
+}
+
+// link paging của Graph API chứa access token, không lưu token vào job data
+func removeAccessTokenFromUrl(rawUrl string) string {
+	u, err := url.Parse(rawUrl)
+	if err != nil {
+		return rawUrl
+	}
+
+	query := u.Query()
+	query.Del("access_token")
+	u.RawQuery = query.Encode()
+	return u.String()
+}
+
+// getOrCreateImportedConversation dùng lại hội thoại tin nhắn đã có của người gửi nếu page được graph lại
+func (app *App) getOrCreateImportedConversation(conversation *model.FacebookConversation) (*model.FacebookConversation, *model.AppError) {
+	if result := <-app.Srv.Store.FacebookConversation().GetPageConversationBySenderId(conversation.PageId, conversation.From, ""); result.Err == nil {
+		for _, existing := range result.Data.([]*model.FacebookConversation) {
+			if existing.Type == conversation.Type {
+				return existing, nil
+			}
+		}
+	}
+
+	return app.CreateConversation(conversation)
+}
+
+// addImportedMessage thêm tin nhắn hoặc bình luận graph được từ facebook, bỏ qua nếu mid hoặc comment_id đã có trong database
+func (app *App) addImportedMessage(message *model.FacebookConversationMessage) (*model.FacebookConversationMessage, *model.AppError) {
+	imported, _, err := app.importMessage(message)
+	return imported, err
+}
+
+// importMessage giống addImportedMessage, đồng thời cho biết tin nhắn có thực sự được thêm mới hay không
+func (app *App) importMessage(message *model.FacebookConversationMessage) (*model.FacebookConversationMessage, bool, *model.AppError) {
+	if existing := app.getImportedMessage(message); existing != nil {
+		return existing, false, nil
+	}
+
+	added, _, err := app.AddMessage(message, false, false, message.From == message.PageId)
+	if err != nil {
+		return nil, false, err
+	}
+	return added, true, nil
+}
+
+func (app *App) getImportedMessage(message *model.FacebookConversationMessage) *model.FacebookConversationMessage {
+	if len(message.CommentId) > 0 {
+		if result := <-app.Srv.Store.FacebookConversation().GetCommentByCommentId(message.CommentId); result.Err == nil {
+			return result.Data.(*model.FacebookConversationMessage)
+		}
+	} else if len(message.MessageId) > 0 {
+		if result := <-app.Srv.Store.FacebookConversation().GetPageMessageByMid(message.PageId, message.MessageId); result.Err == nil {
+			return result.Data.(*model.FacebookConversationMessage)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,222 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"strings"
+)
+
+func (app *App) GetFanpageMembers(pageId string) ([]*model.FanpageMember, *model.AppError) {
+	result := <-app.Srv.Store.Fanpage().GetMembers(pageId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.FanpageMember), nil
+}
+
+func (app *App) GetFanpageMember(pageId, userId string) (*model.FanpageMember, *model.AppError) {
+	result := <-app.Srv.Store.Fanpage().GetMemberByPageId(pageId, userId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.FanpageMember), nil
+}
+
+// AddFanpageMember thêm user vào page với roles cho trước, nếu user đã là thành viên thì trả về member hiện có
+func (app *App) AddFanpageMember(pageId, userId, roles string) (*model.FanpageMember, *model.AppError) {
+	if !model.IsValidFanpageMemberRoles(roles) {
+		return nil, model.NewAppError("AddFanpageMember", "app.fanpage_member.invalid_roles.app_error", nil, "roles="+roles, http.StatusBadRequest)
+	}
+
+	fanpage, err := app.GetFanpageByPageId(pageId)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := app.GetUser(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	member, alreadyAdded, err := app.addUserToFanpage(fanpage, user, roles)
+	if err != nil {
+		return nil, err
+	}
+	if alreadyAdded {
+		return member, nil
+	}
+
+	app.onFanpageMembershipChanged(model.PAGE_MEMBER_ADDED, member)
+
+	return member, nil
+}
+
+// InviteUsersToFanpage thêm ngay các email đã có tài khoản vào page, các email còn lại nhận thư mời
+func (app *App) InviteUsersToFanpage(pageId string, emails []string, roles string, senderId string) ([]*model.FanpageMember, *model.AppError) {
+	if !model.IsValidFanpageMemberRoles(roles) {
+		return nil, model.NewAppError("InviteUsersToFanpage", "app.fanpage_member.invalid_roles.app_error", nil, "roles="+roles, http.StatusBadRequest)
+	}
+
+	fanpage, err := app.GetFanpageByPageId(pageId)
+	if err != nil {
+		return nil, err
+	}
+
+	sender, err := app.GetUser(senderId)
+	if err != nil {
+		return nil, err
+	}
+
+	members := []*model.FanpageMember{}
+	invites := []string{}
+	for _, email := range emails {
+		email = strings.ToLower(strings.TrimSpace(email))
+		if len(email) == 0 {
+			continue
+		}
+
+		user, err := app.GetUserByEmail(email)
+		if err != nil {
+			if err.StatusCode != http.StatusNotFound {
+				return nil, err
+			}
+			invites = append(invites, email)
+			continue
+		}
+
+		member, err := app.AddFanpageMember(pageId, user.Id, roles)
+		if err != nil {
+			return nil, err
+		}
+		members = append(members, member)
+	}
+
+	if len(invites) > 0 {
+		senderName := sender.GetDisplayName(model.SHOW_NICKNAME_FULLNAME)
+		if err := app.Srv.EmailService.SendFanpageInviteEmails(fanpage, senderName, sender.Id, invites, roles, app.GetSiteURL()); err != nil {
+			return nil, err
+		}
+	}
+
+	return members, nil
+}
+
+// AddFanpageMemberByToken nhận lời mời qua email, email của user phải trùng với email được mời
+func (app *App) AddFanpageMemberByToken(tokenId, userId string) (*model.FanpageMember, *model.AppError) {
+	token, err := app.Srv.Store.Token().GetByToken(tokenId)
+	if err != nil || token.Type != TOKEN_TYPE_FANPAGE_INVITATION {
+		return nil, model.NewAppError("AddFanpageMemberByToken", "app.fanpage_member.invalid_invite_token.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if model.GetMillis()-token.CreateAt >= INVITATION_EXPIRY_TIME {
+		app.DeleteToken(token)
+		return nil, model.NewAppError("AddFanpageMemberByToken", "app.fanpage_member.invite_token_expired.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	tokenData := model.MapFromJson(strings.NewReader(token.Extra))
+
+	user, appErr := app.GetUser(userId)
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if strings.ToLower(user.Email) != tokenData["email"] {
+		return nil, model.NewAppError("AddFanpageMemberByToken", "app.fanpage_member.invite_email_mismatch.app_error", nil, "user_id="+userId, http.StatusForbidden)
+	}
+
+	member, appErr := app.AddFanpageMember(tokenData["pageId"], userId, tokenData["roles"])
+	if appErr != nil {
+		return nil, appErr
+	}
+
+	if err := app.DeleteToken(token); err != nil {
+		mlog.Warn("Unable to delete fanpage invite token", mlog.String("page_id", member.PageId), mlog.Err(err))
+	}
+
+	return member, nil
+}
+
+func (app *App) UpdateFanpageMemberRoles(pageId, userId, roles string) (*model.FanpageMember, *model.AppError) {
+	if !model.IsValidFanpageMemberRoles(roles) {
+		return nil, model.NewAppError("UpdateFanpageMemberRoles", "app.fanpage_member.invalid_roles.app_error", nil, "roles="+roles, http.StatusBadRequest)
+	}
+
+	member, err := app.GetFanpageMember(pageId, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	member.Roles = roles
+	if !member.IsAdmin() {
+		if err := app.ensureFanpageHasOtherAdmin(pageId, userId); err != nil {
+			return nil, err
+		}
+	}
+
+	if result := <-app.Srv.Store.Fanpage().UpdateMemberRoles(pageId, userId, roles); result.Err != nil {
+		return nil, result.Err
+	}
+
+	app.onFanpageMembershipChanged(model.PAGE_MEMBER_ROLES_UPDATED, member)
+
+	return member, nil
+}
+
+// RemoveFanpageMember xoá user khỏi page, page phải còn lại ít nhất một admin
+func (app *App) RemoveFanpageMember(pageId, userId string) *model.AppError {
+	member, err := app.GetFanpageMember(pageId, userId)
+	if err != nil {
+		return err
+	}
+
+	if member.IsAdmin() {
+		if err := app.ensureFanpageHasOtherAdmin(pageId, userId); err != nil {
+			return err
+		}
+	}
+
+	if result := <-app.Srv.Store.Fanpage().RemoveMember(pageId, userId); result.Err != nil {
+		return result.Err
+	}
+
+	app.onFanpageMembershipChanged(model.PAGE_MEMBER_REMOVED, member)
+
+	return nil
+}
+
+// ensureFanpageHasOtherAdmin trả về lỗi nếu userId là admin duy nhất của page
+func (app *App) ensureFanpageHasOtherAdmin(pageId, userId string) *model.AppError {
+	members, err := app.GetFanpageMembers(pageId)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range members {
+		if member.UserId != userId && member.IsAdmin() {
+			return nil
+		}
+	}
+
+	return model.NewAppError("ensureFanpageHasOtherAdmin", "app.fanpage_member.last_admin.app_error", nil, "page_id="+pageId+", user_id="+userId, http.StatusBadRequest)
+}
+
+// onFanpageMembershipChanged xoá cache quyền của user để thay đổi có hiệu lực ngay,
+// sau đó báo cho các thành viên của page và cho chính user đó
+func (app *App) onFanpageMembershipChanged(event string, member *model.FanpageMember) {
+	app.ClearSessionCacheForUser(member.UserId)
+	app.InvalidateCacheForUser(member.UserId)
+
+	message := model.NewWebSocketEvent(event, "", member.PageId, "", map[string]bool{member.UserId: true})
+	message.Add("page_id", member.PageId)
+	message.Add("member", member.ToJson())
+	app.Publish(message)
+
+	userMessage := model.NewWebSocketEvent(event, "", "", member.UserId, nil)
+	userMessage.Add("page_id", member.PageId)
+	userMessage.Add("member", member.ToJson())
+	app.Publish(userMessage)
+}
@@ -0,0 +1,390 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/utils"
+	"net/http"
+	"strconv"
+)
+
+const (
+	// mặc định đồng bộ lại dữ liệu trong 24 giờ gần nhất
+	FANPAGE_SYNC_DEFAULT_WINDOW_MILLIS = 24 * 60 * 60 * 1000
+
+	// job đồng bộ hằng đêm lấy dư 1 giờ để không bỏ sót dữ liệu giữa hai lần chạy
+	FANPAGE_SYNC_NIGHTLY_WINDOW_MILLIS = 25 * 60 * 60 * 1000
+
+	// job đồng bộ đang chạy mà không lưu checkpoint trong khoảng thời gian này được coi là bị bỏ dở
+	FANPAGE_SYNC_JOB_STALE_MILLIS = 15 * 60 * 1000
+
+	FANPAGE_SYNC_PROGRESS_POSTS = 50
+)
+
+// CreateFanpageSyncJob tạo job đồng bộ lại tin nhắn và bình luận của page kể từ since (milliseconds).
+// Nếu page đang có job đồng bộ chưa chạy xong thì trả về job đó.
+func (app *App) CreateFanpageSyncJob(pageId string, userId string, since int64) (*model.Job, *model.AppError) {
+	page, err := app.GetFanpageByPageId(pageId)
+	if err != nil {
+		return nil, err
+	}
+
+	if page.Status != model.PAGE_STATUS_INITIALIZED {
+		return nil, model.NewAppError("CreateFanpageSyncJob", "app.fanpage_sync.page_not_initialized.app_error", nil, "page_id="+pageId+", status="+page.Status, http.StatusBadRequest)
+	}
+
+	if job := app.getActiveFanpageSyncJob(pageId); job != nil {
+		return job, nil
+	}
+
+	if since <= 0 {
+		since = model.GetMillis() - FANPAGE_SYNC_DEFAULT_WINDOW_MILLIS
+	}
+
+	return app.Srv.Jobs.CreateJob(model.JOB_TYPE_FANPAGE_SYNC, map[string]string{
+		model.JOB_DATA_FANPAGE_SYNC_PAGE_ID: pageId,
+		model.JOB_DATA_FANPAGE_SYNC_USER_ID: userId,
+		model.JOB_DATA_FANPAGE_SYNC_PHASE:   model.FANPAGE_SYNC_PHASE_MESSAGES,
+		model.JOB_DATA_FANPAGE_SYNC_SINCE:   strconv.FormatInt(since, 10),
+	})
+}
+
+// CreateNightlyFanpageSyncJob tạo job đồng bộ không gắn với page nào, worker sẽ tạo job đồng bộ cho từng page đã khởi tạo
+func (app *App) CreateNightlyFanpageSyncJob() (*model.Job, *model.AppError) {
+	return app.Srv.Jobs.CreateJob(model.JOB_TYPE_FANPAGE_SYNC, map[string]string{
+		model.JOB_DATA_FANPAGE_SYNC_SINCE: strconv.FormatInt(model.GetMillis()-FANPAGE_SYNC_NIGHTLY_WINDOW_MILLIS, 10),
+	})
+}
+
+func (app *App) getActiveFanpageSyncJob(pageId string) *model.Job {
+	for _, status := range []string{model.JOB_STATUS_PENDING, model.JOB_STATUS_IN_PROGRESS} {
+		result := <-app.Srv.Store.Job().GetAllByStatus(status)
+		if result.Err != nil {
+			continue
+		}
+
+		for _, job := range result.Data.([]*model.Job) {
+			if job.Type == model.JOB_TYPE_FANPAGE_SYNC && job.Data[model.JOB_DATA_FANPAGE_SYNC_PAGE_ID] == pageId {
+				return job
+			}
+		}
+	}
+
+	return nil
+}
+
+// FanpageSyncJobStep đồng bộ một trang hội thoại hoặc bài viết tiếp theo, tương tự FanpageInitJobStep.
+// Job không có page_id thì tạo job đồng bộ cho tất cả các page đã khởi tạo rồi kết thúc.
+func (app *App) FanpageSyncJobStep(job *model.Job) (bool, *model.AppError) {
+	pageId := job.Data[model.JOB_DATA_FANPAGE_SYNC_PAGE_ID]
+	if len(pageId) == 0 {
+		return true, app.createFanpageSyncJobsForAllPages(job)
+	}
+
+	since, _ := strconv.ParseInt(job.Data[model.JOB_DATA_FANPAGE_SYNC_SINCE], 10, 64)
+	cursor := job.Data[model.JOB_DATA_FANPAGE_SYNC_CURSOR]
+
+	token, err := app.GetPageAccessToken(pageId, job.Data[model.JOB_DATA_FANPAGE_SYNC_USER_ID])
+	if err != nil {
+		return false, err
+	}
+
+	var next string
+	var added int64
+	var counterKey string
+	var fbErr *facebookgraph.FacebookError
+
+	switch job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE] {
+	case model.FANPAGE_SYNC_PHASE_MESSAGES:
+		counterKey = model.JOB_DATA_FANPAGE_SYNC_MESSAGES_ADDED
+		next, added, fbErr, err = app.SyncPageConversationsPage(pageId, token, cursor, since)
+	case model.FANPAGE_SYNC_PHASE_POSTS:
+		counterKey = model.JOB_DATA_FANPAGE_SYNC_COMMENTS_ADDED
+		next, added, fbErr, err = app.SyncPagePostsPage(pageId, token, cursor, since)
+	case model.FANPAGE_SYNC_PHASE_DONE:
+		return true, nil
+	default:
+		return false, model.NewAppError("FanpageSyncJobStep", "app.fanpage_sync.invalid_phase.app_error", nil, "job_id="+job.Id+", phase="+job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE], http.StatusInternalServerError)
+	}
+
+	if fbErr != nil {
+		app.HandlePageTokenError(pageId, fbErr)
+		return false, model.NewAppError("FanpageSyncJobStep", "app.fanpage_init.graph.app_error", nil, "page_id="+pageId+", "+fbErr.Error.Message, http.StatusBadRequest)
+	}
+	if err != nil {
+		return false, err
+	}
+
+	count, _ := strconv.ParseInt(job.Data[counterKey], 10, 64)
+	job.Data[counterKey] = strconv.FormatInt(count+added, 10)
+
+	if len(next) > 0 {
+		job.Data[model.JOB_DATA_FANPAGE_SYNC_CURSOR] = removeAccessTokenFromUrl(next)
+	} else if job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE] == model.FANPAGE_SYNC_PHASE_MESSAGES {
+		job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE] = model.FANPAGE_SYNC_PHASE_POSTS
+		job.Data[model.JOB_DATA_FANPAGE_SYNC_CURSOR] = ""
+		job.Progress = FANPAGE_SYNC_PROGRESS_POSTS
+	} else {
+		job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE] = model.FANPAGE_SYNC_PHASE_DONE
+		job.Data[model.JOB_DATA_FANPAGE_SYNC_CURSOR] = ""
+		job.Progress = 100
+	}
+
+	return job.Data[model.JOB_DATA_FANPAGE_SYNC_PHASE] == model.FANPAGE_SYNC_PHASE_DONE, nil
+}
+
+func (app *App) createFanpageSyncJobsForAllPages(job *model.Job) *model.AppError {
+	since, _ := strconv.ParseInt(job.Data[model.JOB_DATA_FANPAGE_SYNC_SINCE], 10, 64)
+
+	result := <-app.Srv.Store.Fanpage().GetFanpagesByStatus(model.PAGE_STATUS_INITIALIZED)
+	if result.Err != nil {
+		return result.Err
+	}
+
+	for _, page := range result.Data.([]*model.Fanpage) {
+		// đồng bộ định kỳ không có user thao tác, dùng token của người quản trị page
+		ownerId, err := app.GetPageOwnerId(page.PageId)
+		if err != nil {
+			mlog.Warn("Skipping fanpage sync for page without owner token", mlog.String("page_id", page.PageId), mlog.Err(err))
+			continue
+		}
+
+		if _, err := app.CreateFanpageSyncJob(page.PageId, ownerId, since); err != nil {
+			mlog.Error("Unable to create fanpage sync job", mlog.String("page_id", page.PageId), mlog.Err(err))
+		}
+	}
+
+	return nil
+}
+
+func (app *App) FinishFanpageSyncJob(job *model.Job) {
+	app.publishFanpageSyncResult(job, model.JOB_STATUS_SUCCESS)
+}
+
+func (app *App) FailFanpageSyncJob(job *model.Job) {
+	app.publishFanpageSyncResult(job, model.JOB_STATUS_ERROR)
+}
+
+func (app *App) CancelFanpageSyncJob(job *model.Job) {
+	app.publishFanpageSyncResult(job, model.JOB_STATUS_CANCELED)
+}
+
+// ReleaseFanpageSyncJob trả job đang chạy về pending khi server dừng, job sẽ chạy tiếp từ cursor đã lưu
+func (app *App) ReleaseFanpageSyncJob(job *model.Job) {
+	if result := <-app.Srv.Store.Job().UpdateStatusOptimistically(job.Id, model.JOB_STATUS_IN_PROGRESS, model.JOB_STATUS_PENDING); result.Err != nil {
+		mlog.Error("Unable to release fanpage sync job", mlog.String("job_id", job.Id), mlog.Err(result.Err))
+	}
+}
+
+func (app *App) ResetStaleFanpageSyncJobs() {
+	app.resetStaleJobs(model.JOB_TYPE_FANPAGE_SYNC, FANPAGE_SYNC_JOB_STALE_MILLIS, app.CancelFanpageSyncJob)
+}
+
+func (app *App) publishFanpageSyncResult(job *model.Job, jobStatus string) {
+	pageId := job.Data[model.JOB_DATA_FANPAGE_SYNC_PAGE_ID]
+	if len(pageId) == 0 {
+		return
+	}
+
+	message := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_PAGE_SYNC_UPDATED, "", pageId, "", nil)
+	message.Add("page_id", pageId)
+	message.Add("job_id", job.Id)
+	message.Add("job_status", jobStatus)
+	message.Add("messages_added", job.Data[model.JOB_DATA_FANPAGE_SYNC_MESSAGES_ADDED])
+	message.Add("comments_added", job.Data[model.JOB_DATA_FANPAGE_SYNC_COMMENTS_ADDED])
+	app.Publish(message)
+}
+
+// SyncPageConversationsPage đồng bộ một trang hội thoại của page. Hội thoại được trả về theo thứ tự cập nhật mới nhất trước
+// nên việc graph dừng lại ở hội thoại đầu tiên cập nhật trước since. Trả về link trang tiếp theo và số tin nhắn được thêm mới.
+func (app *App) SyncPageConversationsPage(pageId string, pageToken string, requestURL string, since int64) (string, int64, *facebookgraph.FacebookError, *model.AppError) {
+	path := requestURL
+	if len(path) == 0 {
+		path = "/" + pageId + "/conversations?fields=updated_time,id,senders,messages{created_time,from,id,message,sticker,attachments}&limit=50"
+	}
+
+	it := app.GraphClient().Conversations(pageToken, path)
+	conversations, ok := it.Next()
+	if !ok {
+		fbErr, err := it.Err()
+		return "", 0, fbErr, graphRequestAppError("SyncPageConversationsPage", err)
+	}
+
+	var added int64
+	for i := range conversations.Data {
+		item := &conversations.Data[i]
+		if model.ParseFacebookTime(item.UpdatedTime) < since {
+			return "", added, nil, nil
+		}
+
+		count, fbErr, err := app.syncGraphConversation(pageId, pageToken, item, since)
+		if fbErr != nil || err != nil {
+			return "", added, fbErr, err
+		}
+		added += count
+	}
+
+	return it.Cursor(), added, nil, nil
+}
+
+// syncGraphConversation thêm các tin nhắn gửi sau since chưa có trong database (theo mid) vào hội thoại
+func (app *App) syncGraphConversation(pageId string, pageToken string, item *facebookgraph.FacebookConversationItem, since int64) (int64, *facebookgraph.FacebookError, *model.AppError) {
+	fc := model.FacebookConversationModelFromGraphConversationItem(item)
+	fc.From = getConversationFromFromSenders(pageId, item.Senders.Data)["id"].(string)
+	fc.PageId = pageId
+
+	for _, u := range item.Senders.Data {
+		if u.Id != pageId {
+			u.PageId = pageId
+			if r := <-app.Srv.Store.FacebookUid().UpsertFromFbUser(u); r.Err != nil {
+				return 0, nil, r.Err
+			}
+		}
+	}
+
+	conversation, err := app.getOrCreateImportedConversation(fc)
+	if err != nil {
+		return 0, nil, err
+	}
+
+	// tin nhắn cũng được trả về theo thứ tự mới nhất trước
+	var missing []facebookgraph.FacebookMessageItem
+	var it *facebookgraph.MessageIterator
+	messages := &item.Messages
+	for {
+		reachedSince := false
+		for _, m := range messages.Data {
+			if model.ParseFacebookTime(m.CreatedTime) < since {
+				reachedSince = true
+				break
+			}
+
+			if app.getImportedMessage(&model.FacebookConversationMessage{PageId: pageId, MessageId: m.Id}) == nil {
+				missing = append(missing, m)
+			}
+		}
+
+		if reachedSince || len(messages.Paging.Next) == 0 {
+			break
+		}
+
+		if it == nil {
+			it = app.GraphClient().Messages(pageToken, messages.Paging.Next)
+		}
+		nextMessages, ok := it.Next()
+		if !ok {
+			if fbErr, err := it.Err(); fbErr != nil || err != nil {
+				return 0, fbErr, graphRequestAppError("syncGraphConversation", err)
+			}
+			break
+		}
+		messages = nextMessages
+	}
+
+	if len(missing) == 0 {
+		return 0, nil, nil
+	}
+
+	// thêm theo thứ tự cũ tới mới để tính lại số tin nhắn chưa đọc sau tin nhắn cuối cùng của page
+	var last *model.FacebookConversationMessage
+	var lastUserMessageAt string
+	unreadCount := 0
+	for i := len(missing) - 1; i >= 0; i-- {
+		message := graphMessageToConversationMessage(conversation.Id, pageId, &missing[i])
+		added, _, err := app.AddMessage(message, false, false, message.From == pageId)
+		if err != nil {
+			return 0, nil, err
+		}
+
+		if added.From == pageId {
+			unreadCount = 0
+		} else {
+			unreadCount++
+			lastUserMessageAt = added.CreatedTime
+		}
+		last = added
+	}
+
+	// webhook có thể đã nhận tin nhắn mới hơn, khi đó giữ nguyên snippet và thời gian cập nhật của hội thoại
+	if model.ParseFacebookTime(last.CreatedTime) >= model.ParseFacebookTime(conversation.UpdatedTime) {
+		if result := <-app.Srv.Store.FacebookConversation().UpdateConversation(conversation.Id, utils.GetSnippet(last.Message), last.From == pageId, last.CreatedTime, unreadCount, lastUserMessageAt); result.Err != nil {
+			return 0, nil, result.Err
+		}
+	}
+
+	if updated, err := app.GetConversation(conversation.Id); err == nil {
+		event := model.NewWebSocketEvent(model.RECEIVE_CONVERSATION_UPDATED, "", pageId, "", nil)
+		event.Add("id", updated.Id)
+		event.Add("conversation", updated)
+		event.Add("newMessage", last)
+		app.Publish(event)
+	}
+
+	return int64(len(missing)), nil, nil
+}
+
+func graphMessageToConversationMessage(conversationId string, pageId string, m *facebookgraph.FacebookMessageItem) *model.FacebookConversationMessage {
+	from, _ := m.From["id"].(string)
+
+	message := &model.FacebookConversationMessage{
+		ConversationId: conversationId,
+		Type:           "message",
+		PageId:         pageId,
+		MessageId:      m.Id,
+		Message:        m.Message,
+		CreatedTime:    m.CreatedTime,
+		From:           from,
+		Sent:           true,
+		Sticker:        m.Sticker,
+	}
+
+	if len(m.Attachments.Data) > 0 {
+		message.HasAttachments = true
+		message.AttachmentsCount = len(m.Attachments.Data)
+		message.AttachmentType = m.Attachments.Data[0].MimeType
+	}
+
+	return message
+}
+
+// SyncPagePostsPage đồng bộ bình luận của một trang bài viết. Bài viết có updated_time sau since được graph lại bình luận,
+// bình luận đã có trong database (theo comment_id) được bỏ qua. Bài viết tạo trước since quá FanpageSyncPostLookbackDays
+// thì dừng đồng bộ. Trả về link trang tiếp theo và số bình luận được thêm mới.
+func (app *App) SyncPagePostsPage(pageId string, pageToken string, requestURL string, since int64) (string, int64, *facebookgraph.FacebookError, *model.AppError) {
+	path := requestURL
+	if len(path) == 0 {
+		path = "/" + pageId + "/feed?fields=id,created_time,updated_time&include_hidden=true&limit=100"
+	}
+
+	it := app.GraphClient().Posts(pageToken, path)
+	posts, ok := it.Next()
+	if !ok {
+		fbErr, err := it.Err()
+		return "", 0, fbErr, graphRequestAppError("SyncPagePostsPage", err)
+	}
+
+	oldest := since - int64(*app.Config().FacebookAPISettings.FanpageSyncPostLookbackDays)*24*60*60*1000
+
+	var added int64
+	for _, post := range posts.Data {
+		if model.ParseFacebookTime(post.CreatedTime) < oldest {
+			return "", added, nil, nil
+		}
+
+		if model.ParseFacebookTime(post.UpdatedTime) < since {
+			continue
+		}
+
+		_, commentAdded, fbErr, err := app.InitConversationsFromPost(post.Id, pageId, pageToken)
+		if fbErr != nil || err != nil {
+			return "", added, fbErr, err
+		}
+		added += commentAdded
+	}
+
+	return it.Cursor(), added, nil, nil
+}
@@ -0,0 +1,65 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+	"strings"
+)
+
+func (app *App) GetConversation(conversationId string) (*model.FacebookConversation, *model.AppError) {
+	result := <-app.Srv.Store.FacebookConversation().Get(conversationId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	conversation := result.Data.(*model.FacebookConversation)
+	conversation.PopulateMessagingWindow(model.GetMillis())
+	return conversation, nil
+}
+
+func (app *App) GetConversationMessagingWindow(conversationId string) (*model.MessagingWindow, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	return conversation.GetMessagingWindow(model.GetMillis()), nil
+}
+
+// CheckReplyMessagingWindow kiểm tra tin nhắn trả lời theo chính sách 24h của Messenger: ngoài cửa sổ 24h tin nhắn
+// phải có tag được phép, riêng HUMAN_AGENT chỉ dùng được trong 7 ngày kể từ tin nhắn cuối của khách hàng.
+// Trả lời bình luận không bị giới hạn.
+func (app *App) CheckReplyMessagingWindow(conversation *model.FacebookConversation, reply *model.ConversationReply) *model.AppError {
+	if reply.Type != "message" {
+		return nil
+	}
+
+	if len(reply.Tag) > 0 && !model.IsAllowedMessageTag(reply.Tag) {
+		return model.NewAppError("CheckReplyMessagingWindow", "app.messaging_window.invalid_tag.app_error", map[string]interface{}{"Tag": reply.Tag, "AllowedTags": strings.Join(model.ALLOWED_MESSAGE_TAGS, ", ")}, "tag="+reply.Tag, http.StatusBadRequest)
+	}
+
+	if reply.MessagingType == facebookgraph.MESSAGING_TYPE_MESSAGE_TAG && len(reply.Tag) == 0 {
+		return model.NewAppError("CheckReplyMessagingWindow", "app.messaging_window.missing_tag.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	window := conversation.GetMessagingWindow(model.GetMillis())
+
+	if len(reply.Tag) == 0 {
+		if !window.Open {
+			return model.NewAppError("CheckReplyMessagingWindow", "app.messaging_window.closed.app_error", map[string]interface{}{"AllowedTags": strings.Join(window.AllowedTags, ", ")}, "conversation_id="+conversation.Id, http.StatusForbidden)
+		}
+		return nil
+	}
+
+	for _, tag := range window.AllowedTags {
+		if tag == reply.Tag {
+			return nil
+		}
+	}
+
+	return model.NewAppError("CheckReplyMessagingWindow", "app.messaging_window.human_agent_expired.app_error", nil, "conversation_id="+conversation.Id, http.StatusForbidden)
+}
@@ -15,6 +15,7 @@ import (
 const EMOJIS_PERMISSIONS_MIGRATION_KEY = "EmojisPermissionsMigrationComplete"
 const GUEST_ROLES_CREATION_MIGRATION_KEY = "GuestRolesCreationMigrationComplete"
 const SYSTEM_CONSOLE_ROLES_CREATION_MIGRATION_KEY = "SystemConsoleRolesCreationMigrationComplete"
+const FANPAGE_ROLES_CREATION_MIGRATION_KEY = "FanpageRolesCreationMigrationComplete"
 
 // This function migrates the default built in roles from code/config to the database.
 func (a *App) DoAdvancedPermissionsMigration() {
@@ -283,11 +284,44 @@ func (a *App) DoSystemConsoleRolesCreationMigration() {
 	}
 }
 
+func (a *App) DoFanpageRolesCreationMigration() {
+	// If the migration is already marked as completed, don't do it again.
+	if _, err := a.Srv().Store.System().GetByName(FANPAGE_ROLES_CREATION_MIGRATION_KEY); err == nil {
+		return
+	}
+
+	roles := model.MakeDefaultRoles()
+
+	allSucceeded := true
+	for _, roleName := range []string{model.FANPAGE_VIEWER_ROLE_ID, model.FANPAGE_AGENT_ROLE_ID, model.FANPAGE_ADMIN_ROLE_ID} {
+		if _, err := a.Srv().Store.Role().GetByName(roleName); err != nil {
+			if _, err := a.Srv().Store.Role().Save(roles[roleName]); err != nil {
+				mlog.Critical("Failed to create new role.", mlog.Err(err), mlog.String("role", roleName))
+				allSucceeded = false
+			}
+		}
+	}
+
+	if !allSucceeded {
+		return
+	}
+
+	system := model.System{
+		Name:  FANPAGE_ROLES_CREATION_MIGRATION_KEY,
+		Value: "true",
+	}
+
+	if err := a.Srv().Store.System().Save(&system); err != nil {
+		mlog.Critical("Failed to mark fanpage roles creation migration as completed.", mlog.Err(err))
+	}
+}
+
 func (a *App) DoAppMigrations() {
 	a.DoAdvancedPermissionsMigration()
 	a.DoEmojisPermissionsMigration()
 	a.DoGuestRolesCreationMigration()
 	a.DoSystemConsoleRolesCreationMigration()
+	a.DoFanpageRolesCreationMigration()
 	// This migration always must be the last, because can be based on previous
 	// migrations. For example, it needs the guest roles migration.
 	err := a.DoPermissionsMigrations()
@@ -0,0 +1,231 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/utils"
+	"net/http"
+	"time"
+)
+
+const (
+	MODERATION_RULES_CACHE_SIZE = 5000
+	MODERATION_RULES_CACHE_SEC  = 5 * 60
+
+	// hệ thống ghi lại mỗi thao tác kiểm duyệt tự động thành một tin nhắn hệ thống trong hội thoại
+	MODERATION_PROP_RULE_ID    = "rule_id"
+	MODERATION_PROP_ACTION     = "action"
+	MODERATION_PROP_COMMENT_ID = "comment_id"
+	MODERATION_PROP_ERROR      = "error"
+)
+
+// luật kiểm duyệt được đọc cho mỗi bình luận mới, cache theo page và xoá khi luật của page thay đổi
+var moderationRulesCache *utils.Cache = utils.NewLru(MODERATION_RULES_CACHE_SIZE)
+
+func (app *App) CreateModerationRule(rule *model.ModerationRule) (*model.ModerationRule, *model.AppError) {
+	if err := app.checkModerationRuleTag(rule); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.ModerationRule().Save(rule)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	moderationRulesCache.Remove(rule.PageId)
+	return result.Data.(*model.ModerationRule), nil
+}
+
+func (app *App) GetModerationRule(ruleId string) (*model.ModerationRule, *model.AppError) {
+	result := <-app.Srv.Store.ModerationRule().Get(ruleId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.ModerationRule), nil
+}
+
+func (app *App) GetModerationRules(pageId string) ([]*model.ModerationRule, *model.AppError) {
+	result := <-app.Srv.Store.ModerationRule().GetByPageId(pageId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.ModerationRule), nil
+}
+
+func (app *App) UpdateModerationRule(rule *model.ModerationRule) (*model.ModerationRule, *model.AppError) {
+	oldRule, err := app.GetModerationRule(rule.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	rule.PageId = oldRule.PageId
+	rule.CreatorId = oldRule.CreatorId
+	rule.CreateAt = oldRule.CreateAt
+	rule.DeleteAt = oldRule.DeleteAt
+
+	if err := app.checkModerationRuleTag(rule); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.ModerationRule().Update(rule)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	moderationRulesCache.Remove(rule.PageId)
+	return result.Data.(*model.ModerationRule), nil
+}
+
+func (app *App) DeleteModerationRule(rule *model.ModerationRule) *model.AppError {
+	if result := <-app.Srv.Store.ModerationRule().Delete(rule.Id, model.GetMillis()); result.Err != nil {
+		return result.Err
+	}
+
+	moderationRulesCache.Remove(rule.PageId)
+	return nil
+}
+
+// checkModerationRuleTag kiểm tra nhãn dùng cho thao tác tag phải thuộc cùng page với luật
+func (app *App) checkModerationRuleTag(rule *model.ModerationRule) *model.AppError {
+	if rule.Action != model.MODERATION_ACTION_TAG {
+		return nil
+	}
+
+	tag, err := app.GetTag(rule.TagId)
+	if err != nil || tag.PageId != rule.PageId || tag.DeleteAt > 0 {
+		return model.NewAppError("checkModerationRuleTag", "model.moderation_rule.is_valid.tag_id.app_error", nil, "tag_id="+rule.TagId, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (app *App) getEnabledModerationRules(pageId string) []*model.ModerationRule {
+	if cacheItem, ok := moderationRulesCache.Get(pageId); ok {
+		return cacheItem.([]*model.ModerationRule)
+	}
+
+	rules, err := app.GetModerationRules(pageId)
+	if err != nil {
+		mlog.Error("Unable to load moderation rules", mlog.String("page_id", pageId), mlog.Err(err))
+		return nil
+	}
+
+	var enabled []*model.ModerationRule
+	for _, rule := range rules {
+		if rule.Enabled {
+			rule.CompilePattern()
+			enabled = append(enabled, rule)
+		}
+	}
+
+	moderationRulesCache.AddWithExpiresInSecs(pageId, enabled, MODERATION_RULES_CACHE_SEC)
+	return enabled
+}
+
+// ApplyModerationRules chạy các luật kiểm duyệt của page với bình luận mới của khách hàng theo thứ tự tạo luật.
+// Bình luận đã bị xoá thì các luật phía sau không được áp dụng nữa.
+func (app *App) ApplyModerationRules(conversation *model.FacebookConversation, comment *model.FacebookConversationMessage) {
+	for _, rule := range app.getEnabledModerationRules(conversation.PageId) {
+		if !rule.Match(comment.Message, comment.From) {
+			continue
+		}
+
+		err := app.doModerationAction(conversation, comment, rule)
+		app.logModerationAction(conversation, comment, rule, err)
+
+		if err == nil && rule.Action == model.MODERATION_ACTION_DELETE {
+			return
+		}
+	}
+}
+
+// thao tác tự động không gắn với user nào nên dùng page token của người quản trị page
+func (app *App) doModerationAction(conversation *model.FacebookConversation, comment *model.FacebookConversationMessage, rule *model.ModerationRule) *model.AppError {
+	if rule.Action == model.MODERATION_ACTION_TAG {
+		return app.addConversationTagIfNeed(conversation, rule.TagId, rule.CreatorId)
+	}
+
+	ownerId, err := app.GetPageOwnerId(conversation.PageId)
+	if err != nil {
+		return err
+	}
+
+	switch rule.Action {
+	case model.MODERATION_ACTION_HIDE:
+		_, err := app.HideComment(conversation.Id, comment.CommentId, true, ownerId)
+		return err
+	case model.MODERATION_ACTION_DELETE:
+		_, err := app.DeleteComment(conversation.Id, comment.CommentId, ownerId)
+		return err
+	case model.MODERATION_ACTION_PRIVATE_REPLY:
+		_, err := app.PrivateReplyComment(conversation.Id, comment.CommentId, rule.ReplyMessage, ownerId)
+		return err
+	}
+	return nil
+}
+
+func (app *App) addConversationTagIfNeed(conversation *model.FacebookConversation, tagId string, creator string) *model.AppError {
+	tags, err := app.GetConversationTags(conversation.Id)
+	if err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		if tag.TagId == tagId {
+			return nil
+		}
+	}
+
+	pageTag, err := app.GetTag(tagId)
+	if err != nil {
+		return err
+	}
+
+	_, err = app.AddOrRemoveConversationTag(&model.ConversationTag{ConversationId: conversation.Id, TagId: tagId, Creator: creator}, conversation.PageId, pageTag)
+	return err
+}
+
+// logModerationAction ghi thao tác kiểm duyệt tự động thành tin nhắn hệ thống trong hội thoại, kể cả khi thao tác bị lỗi
+func (app *App) logModerationAction(conversation *model.FacebookConversation, comment *model.FacebookConversationMessage, rule *model.ModerationRule, actionErr *model.AppError) {
+	props := model.StringInterface{
+		MODERATION_PROP_RULE_ID:    rule.Id,
+		MODERATION_PROP_ACTION:     rule.Action,
+		MODERATION_PROP_COMMENT_ID: comment.CommentId,
+	}
+	if actionErr != nil {
+		props[MODERATION_PROP_ERROR] = actionErr.Error()
+		mlog.Warn("Automatic moderation action failed",
+			mlog.String("page_id", conversation.PageId),
+			mlog.String("rule_id", rule.Id),
+			mlog.String("comment_id", comment.CommentId),
+			mlog.Err(actionErr),
+		)
+	}
+
+	message := &model.FacebookConversationMessage{
+		ConversationId: conversation.Id,
+		Type:           model.CONVERSATION_MESSAGE_TYPE_SYSTEM,
+		SystemType:     model.SYSTEM_MESSAGE_AUTO_MODERATION,
+		PageId:         conversation.PageId,
+		Message:        rule.Name,
+		CreatedTime:    time.Now().Format(time.RFC3339),
+		From:           conversation.PageId,
+		Props:          props,
+	}
+
+	logged, _, err := app.AddMessage(message, false, false, true)
+	if err != nil {
+		mlog.Error("Unable to log automatic moderation action", mlog.String("conversation_id", conversation.Id), mlog.Err(err))
+		return
+	}
+
+	event := model.NewWebSocketEvent(model.WEBSOCKET_EVENT_ADD_MESSAGE, "", conversation.PageId, "", nil)
+	event.Add("page_id", conversation.PageId)
+	event.Add("conversation_id", conversation.Id)
+	event.Add("id", logged.Id)
+	event.Add("message", logged)
+	app.Publish(event)
+}
@@ -602,6 +602,7 @@ func (app *App) LoginByOAuth(service string, userData io.Reader, facebookToken s
 		if err != nil {
 			// Lỗi không thêm được page vào db
 			fmt.Println("err", err)
+			continue
 		}
 
 		if isExist && rPage != nil {
@@ -609,11 +610,13 @@ func (app *App) LoginByOAuth(service string, userData io.Reader, facebookToken s
 			fmt.Println("page đã tồn tại, update page token ", rPage.PageId)
 		}
 
-		// đồng thời thêm member page
-		pageMember := model.FanpageMember{FanpageId: rPage.Id, PageId: rPage.PageId, UserId: user.Id, AccessToken: fPage.AccessToken}
-		if memberResult := <-app.Srv.Store.Fanpage().SaveFanPageMember(&pageMember); memberResult.Err != nil {
-			//return "", nil, memberResult.Err
-			fmt.Println("Không thể thêm fanpage member, lỗi: ", memberResult.Err.Message)
+		// đồng thời thêm member page, đăng nhập lại cũng là lúc khôi phục các page đang chờ xác thực lại
+		if rPage.Status == model.PAGE_STATUS_NEEDS_REAUTH {
+			if err := app.reauthorizePage(rPage, user.Id, fPage.AccessToken); err != nil {
+				mlog.Error("Unable to reauthorize page", mlog.String("page_id", rPage.PageId), mlog.String("user_id", user.Id), mlog.Err(err))
+			}
+		} else if err := app.SavePageMemberToken(rPage.Id, rPage.PageId, user.Id, fPage.AccessToken); err != nil {
+			mlog.Error("Unable to save page member token", mlog.String("page_id", rPage.PageId), mlog.String("user_id", user.Id), mlog.Err(err))
 		} else {
 			fmt.Println("Lưu fanpage member thành công")
 		}
@@ -1,39 +1,254 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
 package app
 
 import (
+	"bitbucket.org/enesyteam/papo-server/mlog"
 	"bitbucket.org/enesyteam/papo-server/model"
-	"fmt"
+	"net/http"
 )
 
-func (app *App) CreateOrder(order *model.Order) (*model.AppError, *model.Order) {
-
-	fmt.Println("order", order)
+func (app *App) CreateOrder(order *model.Order) (*model.Order, *model.AppError) {
+	if err := app.prepareOrder(order); err != nil {
+		return nil, err
+	}
 
 	result := <-app.Srv.Store.Order().Save(order)
 	if result.Err != nil {
-		return result.Err, nil
+		return nil, result.Err
 	}
 
 	addedOrder := result.Data.(*model.Order)
+	app.publishOrderEvent(model.ADDED_ORDER, addedOrder)
+	return addedOrder, nil
+}
+
+func (app *App) GetOrder(orderId string) (*model.Order, *model.AppError) {
+	result := <-app.Srv.Store.Order().Get(orderId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.Order), nil
+}
+
+func (app *App) SearchOrders(options *model.OrderSearchOptions) (*model.OrderSearchResult, *model.AppError) {
+	result := <-app.Srv.Store.Order().Search(options)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.OrderSearchResult), nil
+}
+
+// UpdateOrder cập nhật đơn hàng, page, người tạo và thời điểm tạo của đơn không thay đổi được
+func (app *App) UpdateOrder(order *model.Order) (*model.Order, *model.AppError) {
+	oldOrder, err := app.GetOrder(order.Id)
+	if err != nil {
+		return nil, err
+	}
 
-	// socket ve client
-	webhookData := model.NewWebSocketEvent(model.ADDED_ORDER, "", "461787374317076", "", nil)
-	webhookData.Add("orders", addedOrder)
-	webhookData.Add("test", "test1")
-	app.Publish(webhookData)
+	order.PageId = oldOrder.PageId
+	order.CreatorId = oldOrder.CreatorId
+	order.CreateAt = oldOrder.CreateAt
+	order.DeleteAt = oldOrder.DeleteAt
 
-	//return model.NewAppError("CreateOrder", "order.create_new_order.app_error", nil, "", http.StatusBadRequest), nil
+	if err := app.prepareOrder(order); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.Order().Update(order)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	updated := result.Data.(*model.Order)
+	app.publishOrderEvent(model.ORDER_UPDATED, updated)
+	return updated, nil
+}
+
+func (app *App) DeleteOrder(order *model.Order) *model.AppError {
+	if result := <-app.Srv.Store.Order().Delete(order.Id, model.GetMillis()); result.Err != nil {
+		return result.Err
+	}
 
-	return nil, addedOrder
+	app.publishOrderEvent(model.ORDER_DELETED, order)
+	return nil
 }
 
-func (app *App) GetOrders(limit, offset int) (*model.AppError, []*model.Order) {
-	if limit == 0 {
-		limit = 25
+// prepareOrder kiểm tra sản phẩm, hội thoại, trạng thái và nhân viên phụ trách cùng thuộc page của đơn hàng
+func (app *App) prepareOrder(order *model.Order) *model.AppError {
+	if _, err := app.GetFanpageByPageId(order.PageId); err != nil {
+		return err
 	}
-	result := <-app.Srv.Store.Order().GetOrders(limit, offset)
+
+	if err := app.applyProductsToOrder(order); err != nil {
+		return err
+	}
+
+	if len(order.ConversationId) > 0 {
+		conversation, err := app.GetConversation(order.ConversationId)
+		if err != nil || conversation.PageId != order.PageId {
+			return model.NewAppError("prepareOrder", "model.order.is_valid.conversation_id.app_error", nil, "conversation_id="+order.ConversationId, http.StatusBadRequest)
+		}
+
+		if len(order.FacebookUid) == 0 {
+			order.FacebookUid = conversation.From
+		}
+	}
+
+	if len(order.StatusId) == 0 {
+		status, err := app.GetDefaultOrderStatus(order.PageId)
+		if err != nil {
+			return err
+		}
+		order.StatusId = status.Id
+	} else if status, err := app.GetOrderStatus(order.StatusId); err != nil || status.PageId != order.PageId {
+		return model.NewAppError("prepareOrder", "model.order.is_valid.status_id.app_error", nil, "status_id="+order.StatusId, http.StatusBadRequest)
+	}
+
+	if len(order.AssignedTo) > 0 {
+		if result := <-app.Srv.Store.Fanpage().GetMemberByPageId(order.PageId, order.AssignedTo); result.Err != nil {
+			return model.NewAppError("prepareOrder", "model.order.is_valid.assigned_to.app_error", nil, "assigned_to="+order.AssignedTo, http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+func (app *App) publishOrderEvent(event string, order *model.Order) {
+	message := model.NewWebSocketEvent(event, "", order.PageId, "", nil)
+	message.Add("order", order)
+	app.Publish(message)
+}
+
+// GetOrderStatuses trả về các trạng thái đơn hàng của page, tạo bộ trạng thái mặc định nếu page chưa có
+func (app *App) GetOrderStatuses(pageId string) ([]*model.OrderStatus, *model.AppError) {
+	result := <-app.Srv.Store.OrderStatus().GetByPageId(pageId)
 	if result.Err != nil {
-		return result.Err, nil
+		return nil, result.Err
+	}
+
+	statuses := result.Data.([]*model.OrderStatus)
+	if len(statuses) > 0 {
+		return statuses, nil
 	}
-	return nil, result.Data.([]*model.Order)
+
+	for _, status := range model.DefaultOrderStatuses(pageId) {
+		if result := <-app.Srv.Store.OrderStatus().Save(status); result.Err != nil {
+			mlog.Error("Unable to create default order status", mlog.String("page_id", pageId), mlog.Err(result.Err))
+			return nil, result.Err
+		}
+		statuses = append(statuses, status)
+	}
+
+	return statuses, nil
+}
+
+func (app *App) GetOrderStatus(statusId string) (*model.OrderStatus, *model.AppError) {
+	result := <-app.Srv.Store.OrderStatus().Get(statusId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.OrderStatus), nil
+}
+
+// GetDefaultOrderStatus trả về trạng thái gán cho đơn mới, nếu page không đánh dấu trạng thái nào thì dùng trạng thái đầu tiên
+func (app *App) GetDefaultOrderStatus(pageId string) (*model.OrderStatus, *model.AppError) {
+	statuses, err := app.GetOrderStatuses(pageId)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, status := range statuses {
+		if status.IsDefault {
+			return status, nil
+		}
+	}
+	return statuses[0], nil
+}
+
+func (app *App) CreateOrderStatus(status *model.OrderStatus) (*model.OrderStatus, *model.AppError) {
+	// tạo bộ trạng thái mặc định trước để trạng thái đầu tiên page tự tạo không thay thế chúng
+	if _, err := app.GetOrderStatuses(status.PageId); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.OrderStatus().Save(status)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	created := result.Data.(*model.OrderStatus)
+	if err := app.afterOrderStatusChanged(created); err != nil {
+		return nil, err
+	}
+	return created, nil
+}
+
+func (app *App) UpdateOrderStatus(status *model.OrderStatus) (*model.OrderStatus, *model.AppError) {
+	oldStatus, err := app.GetOrderStatus(status.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	status.PageId = oldStatus.PageId
+	status.CreatorId = oldStatus.CreatorId
+	status.CreateAt = oldStatus.CreateAt
+	status.DeleteAt = oldStatus.DeleteAt
+
+	result := <-app.Srv.Store.OrderStatus().Update(status)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+
+	updated := result.Data.(*model.OrderStatus)
+	if err := app.afterOrderStatusChanged(updated); err != nil {
+		return nil, err
+	}
+	return updated, nil
+}
+
+// DeleteOrderStatus xoá trạng thái không còn đơn hàng nào sử dụng, trạng thái mặc định không xoá được
+func (app *App) DeleteOrderStatus(status *model.OrderStatus) *model.AppError {
+	if status.IsDefault {
+		return model.NewAppError("DeleteOrderStatus", "app.order_status.delete_default.app_error", nil, "id="+status.Id, http.StatusBadRequest)
+	}
+
+	result := <-app.Srv.Store.Order().CountByStatusId(status.Id)
+	if result.Err != nil {
+		return result.Err
+	}
+	if result.Data.(int64) > 0 {
+		return model.NewAppError("DeleteOrderStatus", "app.order_status.in_use.app_error", map[string]interface{}{"Count": result.Data.(int64)}, "id="+status.Id, http.StatusBadRequest)
+	}
+
+	if result := <-app.Srv.Store.OrderStatus().Delete(status.Id, model.GetMillis()); result.Err != nil {
+		return result.Err
+	}
+
+	app.publishOrderStatusesUpdated(status.PageId)
+	return nil
+}
+
+func (app *App) afterOrderStatusChanged(status *model.OrderStatus) *model.AppError {
+	if status.IsDefault {
+		if result := <-app.Srv.Store.OrderStatus().ClearDefault(status.PageId, status.Id); result.Err != nil {
+			return result.Err
+		}
+	}
+
+	app.publishOrderStatusesUpdated(status.PageId)
+	return nil
+}
+
+func (app *App) publishOrderStatusesUpdated(pageId string) {
+	statuses, err := app.GetOrderStatuses(pageId)
+	if err != nil {
+		mlog.Error("Unable to load order statuses", mlog.String("page_id", pageId), mlog.Err(err))
+		return
+	}
+
+	message := model.NewWebSocketEvent(model.ORDER_STATUSES_UPDATED, "", pageId, "", nil)
+	message.Add("statuses", statuses)
+	app.Publish(message)
 }
@@ -0,0 +1,207 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/utils"
+	"io"
+	"net/http"
+)
+
+func (app *App) pageTokenEncryptKey() string {
+	if key := *app.Config().FacebookSettings.PageTokenEncryptKey; len(key) > 0 {
+		return key
+	}
+	return *app.Config().SqlSettings.AtRestEncryptKey
+}
+
+func (app *App) encryptPageToken(token string) (string, *model.AppError) {
+	encrypted, err := utils.EncryptString(app.pageTokenEncryptKey(), token)
+	if err != nil {
+		return "", model.NewAppError("encryptPageToken", "app.page_token.encrypt.app_error", nil, err.Error(), http.StatusInternalServerError)
+	}
+	return encrypted, nil
+}
+
+func (app *App) decryptPageToken(token string) (string, *model.AppError) {
+	decrypted, err := utils.DecryptString(app.pageTokenEncryptKey(), token)
+	if err != nil {
+		return "", model.NewAppError("decryptPageToken", "app.page_token.decrypt.app_error", nil, err.Error(), http.StatusInternalServerError)
+	}
+	return decrypted, nil
+}
+
+// SavePageMemberToken mã hoá page token rồi lưu vào FanpageMember, tạo member nếu chưa có
+func (app *App) SavePageMemberToken(fanpageId, pageId, userId, token string) *model.AppError {
+	encrypted, err := app.encryptPageToken(token)
+	if err != nil {
+		return err
+	}
+
+	member := &model.FanpageMember{FanpageId: fanpageId, PageId: pageId, UserId: userId, Roles: model.FANPAGE_MEMBER_ADMIN_ROLES, AccessToken: encrypted}
+	if result := <-app.Srv.Store.Fanpage().SaveFanPageMember(member); result.Err != nil {
+		return result.Err
+	}
+	return nil
+}
+
+// reauthorizePage lưu token mới cho page đang chờ xác thực lại và đưa page về trạng thái đã khởi tạo
+func (app *App) reauthorizePage(page *model.Fanpage, userId, token string) *model.AppError {
+	if err := app.SavePageMemberToken(page.Id, page.PageId, userId, token); err != nil {
+		return err
+	}
+
+	if err, _ := app.UpdatePageStatus(page.PageId, model.PAGE_STATUS_INITIALIZED, userId); err != nil {
+		return err
+	}
+
+	page.Status = model.PAGE_STATUS_INITIALIZED
+	return nil
+}
+
+// GetPageOwnerId trả về user quản trị page đang giữ page token, dùng cho các thao tác tự động
+// không gắn với user nào như kiểm duyệt comment hay đồng bộ định kỳ.
+func (app *App) GetPageOwnerId(pageId string) (string, *model.AppError) {
+	result := <-app.Srv.Store.Fanpage().GetOwnerMember(pageId)
+	if result.Err != nil {
+		return "", model.NewAppError("GetPageOwnerId", "app.page_token.missing.app_error", nil, "page_id="+pageId+", "+result.Err.Error(), http.StatusForbidden)
+	}
+	return result.Data.(*model.FanpageMember).UserId, nil
+}
+
+// GetPageAccessToken trả về page token đã giải mã dùng để gọi Graph API thay cho page.
+// Dùng token của chính user, nếu user chưa có token riêng sẽ dùng token của người quản trị page (GetOwnerMember).
+// userId bắt buộc phải có, thao tác tự động cần lấy userId qua GetPageOwnerId.
+func (app *App) GetPageAccessToken(pageId, userId string) (string, *model.AppError) {
+	if len(userId) == 0 {
+		return "", model.NewAppError("GetPageAccessToken", "app.page_token.missing_user.app_error", nil, "page_id="+pageId, http.StatusBadRequest)
+	}
+
+	page, err := app.GetFanpageByPageId(pageId)
+	if err != nil {
+		return "", err
+	}
+
+	if page.Status == model.PAGE_STATUS_NEEDS_REAUTH {
+		return "", model.NewAppError("GetPageAccessToken", "app.page_token.needs_reauth.app_error", nil, "page_id="+pageId, http.StatusForbidden)
+	}
+
+	var member *model.FanpageMember
+	if result := <-app.Srv.Store.Fanpage().GetMemberByPageId(pageId, userId); result.Err == nil {
+		member = result.Data.(*model.FanpageMember)
+	} else if result.Err.StatusCode != http.StatusNotFound {
+		return "", result.Err
+	}
+
+	if member == nil || len(member.AccessToken) == 0 {
+		result := <-app.Srv.Store.Fanpage().GetOwnerMember(pageId)
+		if result.Err != nil {
+			return "", model.NewAppError("GetPageAccessToken", "app.page_token.missing.app_error", nil, "page_id="+pageId+", "+result.Err.Error(), http.StatusForbidden)
+		}
+		member = result.Data.(*model.FanpageMember)
+	}
+
+	token, err := app.decryptPageToken(member.AccessToken)
+	if err != nil {
+		return "", err
+	}
+
+	// token cũ còn lưu plaintext, mã hoá lại ngay khi đọc
+	if !utils.IsEncrypted(member.AccessToken) {
+		if err := app.SavePageMemberToken(member.FanpageId, member.PageId, member.UserId, token); err != nil {
+			mlog.Warn("Unable to encrypt legacy page token", mlog.String("page_id", pageId), mlog.String("user_id", member.UserId), mlog.Err(err))
+		}
+	}
+
+	return token, nil
+}
+
+// RefreshPageAccessToken lấy lại page token từ user token dài hạn của user và đổi sang token dài hạn qua ExtendFacebookToken
+func (app *App) RefreshPageAccessToken(pageId, userId string) (string, *facebookgraph.FacebookError, *model.AppError) {
+	page, err := app.GetFanpageByPageId(pageId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	user, err := app.GetUser(userId)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pageToken, fErr, aErr := app.GraphPageToken(user.FacebookToken, pageId)
+	if fErr != nil || aErr != nil {
+		return "", fErr, aErr
+	}
+
+	if pageToken == nil || len(pageToken.AccessToken) == 0 {
+		return "", nil, model.NewAppError("RefreshPageAccessToken", "app.page_token.missing.app_error", nil, "page_id="+pageId, http.StatusForbidden)
+	}
+
+	token := pageToken.AccessToken
+	if extended, fErr, _ := app.ExtendFacebookToken(token); fErr == nil && extended != nil && len(extended.AccessToken) > 0 {
+		token = extended.AccessToken
+	}
+
+	if err := app.SavePageMemberToken(page.Id, pageId, userId, token); err != nil {
+		return "", nil, err
+	}
+
+	return token, nil, nil
+}
+
+// HandlePageTokenError kiểm tra lỗi Facebook trả về khi dùng page token, nếu token đã bị vô hiệu (mã 190)
+// page sẽ được đánh dấu cần đăng nhập lại và toàn bộ member của page được thông báo qua websocket.
+// Trả về true nếu lỗi là lỗi token.
+func (app *App) HandlePageTokenError(pageId string, fErr *facebookgraph.FacebookError) bool {
+	if fErr == nil || fErr.Error.Code != facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN {
+		return false
+	}
+
+	mlog.Warn("Facebook page token has been invalidated",
+		mlog.String("page_id", pageId),
+		mlog.Int("error_subcode", fErr.Error.ErrorSubcode),
+		mlog.String("message", fErr.Error.Message),
+	)
+
+	if result := <-app.Srv.Store.Fanpage().UpdateStatus(pageId, model.PAGE_STATUS_NEEDS_REAUTH); result.Err != nil {
+		mlog.Error("Unable to mark page as needing re-authentication", mlog.String("page_id", pageId), mlog.Err(result.Err))
+	}
+
+	message := model.NewWebSocketEvent(model.PAGE_NEEDS_REAUTH, "", pageId, "", nil)
+	message.Add("page_id", pageId)
+	message.Add("status", model.PAGE_STATUS_NEEDS_REAUTH)
+	message.Add("error_subcode", fErr.Error.ErrorSubcode)
+	app.Publish(message)
+
+	return true
+}
+
+// DoWithPageToken gọi Graph API bằng page token của user trên page. Nếu Facebook báo token không hợp lệ,
+// token sẽ được làm mới một lần rồi gọi lại; nếu vẫn lỗi thì page bị đánh dấu cần đăng nhập lại.
+func (app *App) DoWithPageToken(pageId, userId string, do func(token string) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError)) (io.ReadCloser, *facebookgraph.FacebookError, *model.AppError) {
+	token, err := app.GetPageAccessToken(pageId, userId)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	body, fErr, aErr := do(token)
+	if fErr == nil || fErr.Error.Code != facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN {
+		return body, fErr, aErr
+	}
+
+	if len(userId) > 0 {
+		if refreshed, refreshErr, _ := app.RefreshPageAccessToken(pageId, userId); refreshErr == nil && len(refreshed) > 0 {
+			body, fErr, aErr = do(refreshed)
+			if fErr == nil || fErr.Error.Code != facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN {
+				return body, fErr, aErr
+			}
+		}
+	}
+
+	app.HandlePageTokenError(pageId, fErr)
+	return nil, fErr, aErr
+}
@@ -0,0 +1,199 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package app
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"net/http"
+)
+
+func (app *App) CreateProduct(product *model.Product) (*model.Product, *model.AppError) {
+	if _, err := app.GetFanpageByPageId(product.PageId); err != nil {
+		return nil, err
+	}
+
+	if err := app.checkProduct(product, product.CreatorId, nil); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.Product().Save(product)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.Product), nil
+}
+
+func (app *App) GetProduct(productId string) (*model.Product, *model.AppError) {
+	result := <-app.Srv.Store.Product().Get(productId)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.Product), nil
+}
+
+func (app *App) SearchProducts(options *model.ProductSearchOptions) ([]*model.Product, *model.AppError) {
+	result := <-app.Srv.Store.Product().Search(options)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.([]*model.Product), nil
+}
+
+// UpdateProduct cập nhật sản phẩm, userId là người sửa dùng để kiểm tra ảnh mới thêm vào sản phẩm
+func (app *App) UpdateProduct(product *model.Product, userId string) (*model.Product, *model.AppError) {
+	oldProduct, err := app.GetProduct(product.Id)
+	if err != nil {
+		return nil, err
+	}
+
+	product.PageId = oldProduct.PageId
+	product.CreatorId = oldProduct.CreatorId
+	product.CreateAt = oldProduct.CreateAt
+	product.DeleteAt = oldProduct.DeleteAt
+
+	if err := app.checkProduct(product, userId, oldProduct.FileIds); err != nil {
+		return nil, err
+	}
+
+	result := <-app.Srv.Store.Product().Update(product)
+	if result.Err != nil {
+		return nil, result.Err
+	}
+	return result.Data.(*model.Product), nil
+}
+
+func (app *App) DeleteProduct(product *model.Product) *model.AppError {
+	if result := <-app.Srv.Store.Product().Delete(product.Id, model.GetMillis()); result.Err != nil {
+		return result.Err
+	}
+	return nil
+}
+
+// checkProduct kiểm tra SKU không trùng với sản phẩm khác của page và ảnh sản phẩm là file ảnh đã upload lên papo.
+// Ảnh phải thuộc page của sản phẩm hoặc do userId upload, ảnh đã có sẵn trong existingFileIds được giữ nguyên.
+func (app *App) checkProduct(product *model.Product, userId string, existingFileIds []string) *model.AppError {
+	if len(product.Sku) > 0 {
+		result := <-app.Srv.Store.Product().GetBySku(product.PageId, product.Sku)
+		if result.Err == nil && result.Data.(*model.Product).Id != product.Id {
+			return model.NewAppError("checkProduct", "app.product.sku_exists.app_error", map[string]interface{}{"Sku": product.Sku}, "page_id="+product.PageId, http.StatusBadRequest)
+		}
+		if result.Err != nil && result.Err.StatusCode != http.StatusNotFound {
+			return result.Err
+		}
+	}
+
+	existing := map[string]bool{}
+	for _, fileId := range existingFileIds {
+		existing[fileId] = true
+	}
+
+	for _, fileId := range product.FileIds {
+		if existing[fileId] {
+			continue
+		}
+
+		info, err := app.GetFileInfo(fileId)
+		if err != nil || info.DeleteAt > 0 || !info.IsImage() {
+			return model.NewAppError("checkProduct", "model.product.is_valid.file_ids.app_error", map[string]interface{}{"Max": model.PRODUCT_IMAGES_MAX}, "file_id="+fileId, http.StatusBadRequest)
+		}
+
+		if info.PageId != product.PageId && (len(info.PageId) > 0 || info.CreatorId != userId) {
+			return model.NewAppError("checkProduct", "app.product.file_not_allowed.app_error", nil, "file_id="+fileId+", page_id="+product.PageId, http.StatusForbidden)
+		}
+	}
+
+	return nil
+}
+
+// applyProductsToOrder điền thông tin từ danh mục cho các dòng sản phẩm có ProductId,
+// sản phẩm phải thuộc cùng page với đơn hàng
+func (app *App) applyProductsToOrder(order *model.Order) *model.AppError {
+	products := map[string]*model.Product{}
+
+	for _, item := range order.Items {
+		if item == nil || len(item.ProductId) == 0 {
+			continue
+		}
+
+		product, ok := products[item.ProductId]
+		if !ok {
+			var err *model.AppError
+			if product, err = app.GetProduct(item.ProductId); err != nil || product.PageId != order.PageId {
+				return model.NewAppError("applyProductsToOrder", "model.order.is_valid.item_product.app_error", nil, "product_id="+item.ProductId, http.StatusBadRequest)
+			}
+			products[item.ProductId] = product
+		}
+
+		if err := product.ApplyToOrderItem(item); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ProductImageUrl trả về public link của ảnh đại diện sản phẩm để facebook tải về khi gửi template,
+// để trống nếu sản phẩm không có ảnh hoặc public link bị tắt
+func (app *App) ProductImageUrl(product *model.Product) string {
+	if len(product.FileIds) == 0 || !*app.Config().FileSettings.EnablePublicLink {
+		return ""
+	}
+
+	info, err := app.GetFileInfo(product.FileIds[0])
+	if err != nil {
+		mlog.Warn("Unable to load product image", mlog.String("product_id", product.Id), mlog.Err(err))
+		return ""
+	}
+
+	return app.GeneratePublicLink(app.GetSiteURL(), info)
+}
+
+// SendProductToConversation gửi sản phẩm cho khách hàng của hội thoại dưới dạng generic template qua outbox
+func (app *App) SendProductToConversation(conversationId, productId, variantId, userId string) (*model.FacebookConversationMessage, *model.AppError) {
+	conversation, err := app.GetConversation(conversationId)
+	if err != nil {
+		return nil, err
+	}
+
+	if conversation.Type != "message" {
+		return nil, model.NewAppError("SendProductToConversation", "app.product.send_comment.app_error", nil, "conversation_id="+conversationId, http.StatusBadRequest)
+	}
+
+	product, err := app.GetProduct(productId)
+	if err != nil {
+		return nil, err
+	}
+
+	if product.PageId != conversation.PageId {
+		return nil, model.NewAppError("SendProductToConversation", "api.product.page_mismatch.app_error", nil, "product_id="+productId+", conversation_id="+conversationId, http.StatusNotFound)
+	}
+
+	var variant *model.ProductVariant
+	if len(variantId) > 0 {
+		if variant = product.GetVariant(variantId); variant == nil {
+			return nil, model.NewAppError("SendProductToConversation", "model.order.is_valid.item_variant.app_error", nil, "product_id="+productId+", variant_id="+variantId, http.StatusBadRequest)
+		}
+	}
+
+	threadId := conversation.ScopedThreadKey
+	if len(threadId) == 0 {
+		threadId = conversation.Id
+	}
+
+	reply := &model.ConversationReply{
+		PageId:      conversation.PageId,
+		Type:        "message",
+		ThreadId:    threadId,
+		To:          conversation.From,
+		PageScopeId: conversation.PageScopeId,
+		Template: &facebookgraph.SendAttachmentPayload{
+			TemplateType: facebookgraph.TEMPLATE_TYPE_GENERIC,
+			Elements:     []*facebookgraph.TemplateElement{product.TemplateElement(variant, app.ProductImageUrl(product))},
+		},
+	}
+
+	return app.EnqueueConversationReply(conversationId, reply, userId)
+}
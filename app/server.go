@@ -32,6 +32,7 @@ import (
 	"bitbucket.org/enesyteam/papo-server/audit"
 	"bitbucket.org/enesyteam/papo-server/config"
 	"bitbucket.org/enesyteam/papo-server/einterfaces"
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"bitbucket.org/enesyteam/papo-server/jobs"
 	"bitbucket.org/enesyteam/papo-server/mlog"
 	"bitbucket.org/enesyteam/papo-server/model"
@@ -105,6 +106,12 @@ type Server struct {
 	runjobs bool
 	Jobs    *jobs.JobServer
 
+	FacebookWebhookQueue *FacebookWebhookQueue
+	FacebookOutbox       *FacebookOutbox
+
+	facebookGraphOnce   sync.Once
+	FacebookGraphClient *facebookgraph.Client
+
 	clusterLeaderListeners sync.Map
 
 	licenseValue       atomic.Value
@@ -762,6 +769,14 @@ func (s *Server) Shutdown() error {
 		s.Metrics.StopServer()
 	}
 
+	if s.FacebookWebhookQueue != nil {
+		s.FacebookWebhookQueue.Stop()
+	}
+
+	if s.FacebookOutbox != nil {
+		s.FacebookOutbox.Stop()
+	}
+
 	// This must be done after the cluster is stopped.
 	if s.Jobs != nil && s.runjobs {
 		s.Jobs.StopWorkers()
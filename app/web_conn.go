@@ -47,7 +47,6 @@ type WebConn struct {
 	session                   atomic.Value
 	endWritePump              chan struct{}
 	pumpFinished              chan struct{}
-	PageIds 			   	[]string
 }
 
 // NewWebConn returns a new WebConn instance.
@@ -378,26 +377,20 @@ func (wc *WebConn) shouldSendEvent(msg *model.WebSocketEvent) bool {
 			wc.LastAllPageMembersTime = 0
 		}
 
-		if contains(wc.PageIds, msg.Broadcast.PageId) {
-			return true
+		if wc.AllPageMembers == nil {
+			result := <-wc.App.Srv().Store.Fanpage().GetAllPageMembersForUser(wc.UserId, true, false)
+			if result.Err != nil {
+				mlog.Error("webhub.shouldSendEvent.", mlog.Err(result.Err))
+				return false
+			}
+			wc.AllPageMembers = result.Data.(map[string]string)
+			wc.LastAllPageMembersTime = model.GetMillis()
 		}
 
+		if _, ok := wc.AllPageMembers[msg.GetBroadcast().PageId]; ok {
+			return true
+		}
 		return false
-
-		//if wc.AllPageMembers == nil {
-		//	result, err := wc.App.Srv().Store.Channel().GetAllChannelMembersForUser(wc.UserId, true, false)
-		//	if err != nil {
-		//		mlog.Error("webhub.shouldSendEvent.", mlog.Err(err))
-		//		return false
-		//	}
-		//	wc.allChannelMembers = result
-		//	wc.lastAllChannelMembersTime = model.GetMillis()
-		//}
-		//
-		//if _, ok := wc.AllPageMembers[msg.GetBroadcast().ChannelId]; ok {
-		//	return true
-		//}
-		//return false
 	}
 
 	// Only report events to users who are in the team for the event
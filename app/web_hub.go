@@ -96,6 +96,7 @@ func (a *App) HubStart() {
 
 func (a *App) invalidateCacheForUserSkipClusterSend(userId string) {
 	//a.Srv().Store.Channel().InvalidateAllChannelMembersForUser(userId)
+	a.Srv().Store.Fanpage().InvalidateAllPageMembersForUser(userId)
 	a.InvalidateWebConnSessionCacheForUser(userId)
 }
 
@@ -17,107 +17,128 @@ import (
 	"time"
 )
 
-func (app *App) HandleFacebookWebhook(hubEntries *facebookgraph.HubEntries) *model.AppError {
-	if hubEntries != nil && len(hubEntries.Entry) > 0 {
-		entry := hubEntries.Entry[0]
-		if len(entry.Messaging) > 0 {
-			event := entry.Messaging[0]
-
-			if len(event.Message.Text) > 0 || len(event.Message.Attachments) > 0 {
-				err := app.receiveMessage(event)
-
-				if event.Message.QuickReply.Payload != nil {
-					// at this time, we don't known what to do with quick reply message
-					//fmt.Println("handle quick reply message")
-					//fmt.Println(event.Message.QuickReply)
-					return nil
-				}
+// Loại sự kiện trong một lần webhook gửi về
+const (
+	WEBHOOK_ITEM_MESSAGING = "messaging"
+	WEBHOOK_ITEM_CHANGE    = "change"
+)
 
-				if err != nil {
-					return err
-				}
+// WebhookItemResult là kết quả xử lý của từng sự kiện (messaging hoặc change) trong một entry.
+// Facebook có thể gộp nhiều entry và nhiều sự kiện trong một request, lỗi ở một sự kiện
+// không được làm hỏng các sự kiện còn lại.
+type WebhookItemResult struct {
+	EntryId string          `json:"entry_id"`
+	Kind    string          `json:"kind"`
+	Index   int             `json:"index"`
+	Err     *model.AppError `json:"error,omitempty"`
+}
 
-				return nil
-
-			} else if event.PostBack.Payload != nil {
-				fmt.Println("Sender...", event.Sender)
-				fmt.Println("Recipient...", event.Recipient)
-				fmt.Println(event.PostBack)
-				fmt.Println("handle message post back")
-			} else if len(event.Delivery.Mids) > 0 {
-				fmt.Println("Sender...", event.Sender)
-				fmt.Println("Recipient...", event.Recipient)
-				fmt.Println("handle message delivery")
-				fmt.Println(event.Delivery)
-				isUpdateError := false
-				var updateError *model.AppError
-				for _, mid := range event.Delivery.Mids {
-					err := app.receiveMessageDelivery(event.Recipient.Id, "m_"+mid)
-					if err != nil {
-						updateError = err
-					}
-					isUpdateError = isUpdateError && err != nil
-				}
-				return updateError
-			} else if event.Read.Watermark > 0 {
-				fmt.Println("Sender...", event.Sender)
-				fmt.Println("Recipient...", event.Recipient)
-				fmt.Println("handle message read")
-				fmt.Println(event.Read)
-				err := app.receivedMessageRead(event.Recipient.Id, event.Sender.Id, event.Read.Watermark)
-				if err != nil {
-					return err
-				}
+// HandleFacebookWebhook xử lý toàn bộ entry và toàn bộ sự kiện trong mỗi entry, trả về kết quả theo từng sự kiện.
+func (app *App) HandleFacebookWebhook(hubEntries *facebookgraph.HubEntries) []*WebhookItemResult {
+	var results []*WebhookItemResult
+	if hubEntries == nil {
+		return results
+	}
 
-				return nil
+	for _, entry := range hubEntries.Entry {
+		for i, event := range entry.Messaging {
+			results = append(results, &WebhookItemResult{
+				EntryId: entry.Id,
+				Kind:    WEBHOOK_ITEM_MESSAGING,
+				Index:   i,
+				Err:     app.handleMessagingEvent(event),
+			})
+		}
 
-			} else {
-				fmt.Println("Webhook received unhandle event: ", event)
-				return nil
+		for i, change := range entry.Changes {
+			results = append(results, &WebhookItemResult{
+				EntryId: entry.Id,
+				Kind:    WEBHOOK_ITEM_CHANGE,
+				Index:   i,
+				Err:     app.handleChangeEvent(change),
+			})
+		}
+	}
+
+	return results
+}
+
+func (app *App) handleMessagingEvent(event facebookgraph.HubEntryMessaging) *model.AppError {
+	if event.PolicyEnforcement != nil {
+		return app.receivePolicyEnforcement(event)
+	} else if len(event.Message.Text) > 0 || len(event.Message.Attachments) > 0 {
+		return app.receiveMessage(event)
+	} else if event.PostBack != nil {
+		return app.receivePostback(event)
+	} else if event.Reaction != nil {
+		return app.receiveReaction(event)
+	} else if event.Referral != nil {
+		return app.receiveReferral(event)
+	} else if event.Optin != nil {
+		return app.receiveOptin(event)
+	} else if len(event.Delivery.Mids) > 0 {
+		var updateError *model.AppError
+		for _, mid := range event.Delivery.Mids {
+			if err := app.receiveMessageDelivery(event.Recipient.Id, "m_"+mid); err != nil {
+				updateError = err
 			}
+		}
+		return updateError
+	} else if event.Read.Watermark > 0 {
+		return app.receivedMessageRead(event.Recipient.Id, event.Sender.Id, event.Read.Watermark)
+	}
+
+	mlog.Debug("Webhook received unhandled messaging event", mlog.String("sender", event.Sender.Id), mlog.String("recipient", event.Recipient.Id))
+	return nil
+}
+
+func (app *App) handleChangeEvent(change facebookgraph.HubEntryChange) *model.AppError {
+	switch change.Field {
+	case "feed":
+		switch change.Value.Item {
+		case "comment":
+			return app.receiveComment(change)
+		case "status", "photo":
+			// Page đăng trạng thái mới có thể đính kèm ảnh
+			return app.receivePost(change)
+		default:
+			mlog.Debug("Webhook received unhandled feed change", mlog.String("item", change.Value.Item))
+		}
+	case "conversations":
+		// do not handle anything here, messages come through messaging events
+	}
 
-		} else if len(entry.Changes) > 0 {
+	return nil
+}
 
-			if entry.Changes[0].Field == "feed" {
-				// handle feed changes
-				change := entry.Changes[0]
-				fmt.Println("change", change)
+// claimProcessedEvent nhận xử lý sự kiện, trả về false nếu sự kiện đã được xử lý.
+// Người gọi phải gọi finishProcessedEvent khi xử lý xong hoặc thất bại
+func (app *App) claimProcessedEvent(pageId, eventKey string) (bool, *model.AppError) {
+	result := <-app.Srv.Store.FacebookProcessedEvent().Claim(pageId, eventKey)
+	if result.Err != nil {
+		return false, result.Err
+	}
 
-				if change.Value.Item == "comment" {
-					if err := app.receiveComment(change); err != nil {
-						fmt.Println("----Loi webhook comment")
-						fmt.Println(err)
-						return err
-					}
-					return nil
-				} else if change.Value.Item == "post" {
-					fmt.Println("receive post")
-					fmt.Println("change", change)
-				} else if change.Value.Item == "status" || change.Value.Item == "photo" {
-					fmt.Println("Page đăng trạng thái mới có thể đính kèm ảnh")
-					//fmt.Println("change", change)
-					app.receivePost(change)
-				} else {
-					fmt.Println("unhandle changes")
-				}
+	if !result.Data.(bool) {
+		mlog.Debug("Skipping duplicated facebook webhook event", mlog.String("page_id", pageId), mlog.String("event_key", eventKey))
+		return false, nil
+	}
 
-				return nil
+	return true, nil
+}
 
-			} else if entry.Changes[0].Field == "conversations" {
-				// do not handle anything here
-				return nil
-				//fmt.Println("handle message")
-				//fmt.Println("entry.Changes[0].Value", entry.Changes[0].Value)
-				//fmt.Println("From: ", entry.Id)
-				//fmt.Println("entry.Changes[0].Value.PageId: ", entry.Changes[0].Value.PageId)
-				//fmt.Println("entry.Changes[0].Value.ThreadId: ", entry.Changes[0].Value.ThreadId)
-			} else {
-				return nil
-			}
+// finishProcessedEvent đánh dấu sự kiện đã xử lý nếu dữ liệu đã được lưu, ngược lại xóa khỏi sổ để lần gửi lại xử lý tiếp
+func (app *App) finishProcessedEvent(pageId, eventKey string, processed bool) {
+	if processed {
+		if result := <-app.Srv.Store.FacebookProcessedEvent().Complete(pageId, eventKey); result.Err != nil {
+			mlog.Error("Unable to complete facebook webhook event", mlog.String("page_id", pageId), mlog.String("event_key", eventKey), mlog.Err(result.Err))
 		}
+		return
 	}
 
-	return nil
+	if result := <-app.Srv.Store.FacebookProcessedEvent().Release(pageId, eventKey); result.Err != nil {
+		mlog.Error("Unable to release facebook webhook event", mlog.String("page_id", pageId), mlog.String("event_key", eventKey), mlog.Err(result.Err))
+	}
 }
 
 func (app *App) receivedMessageRead(pageId, userId string, timestamp int64) *model.AppError {
@@ -150,6 +171,11 @@ func (app *App) receivedMessageRead(pageId, userId string, timestamp int64) *mod
 			// bỏ qua lỗi
 			return nil
 		} else {
+			// các tin nhắn outbox gửi trước watermark chuyển sang đã đọc
+			if readResult := <-app.Srv.Store.FacebookConversation().UpdateMessagesRead(conversation.Id, timestamp); readResult.Err != nil {
+				mlog.Error("Unable to mark outbox messages as read", mlog.String("conversation_id", conversation.Id), mlog.Err(readResult.Err))
+			}
+
 			webhookData := model.NewWebSocketEvent(model.RECEIVE_CONVERSATION_READ, "", pageId, "", nil)
 			webhookData.Add("id", conversation.Id)
 			webhookData.Add("read_watermark", timestamp)
@@ -161,13 +187,14 @@ func (app *App) receivedMessageRead(pageId, userId string, timestamp int64) *mod
 }
 
 func (app *App) receiveMessageDelivery(pageId, messageId string) *model.AppError {
-	fmt.Println("receive message delivery: pageId: ", pageId, "-messageId: ", messageId )
+	mlog.Debug("Received facebook message delivery", mlog.String("page_id", pageId), mlog.String("message_id", messageId))
 	if len(messageId) > 0 {
 		updateResult := <-app.Srv.Store.FacebookConversation().UpdateMessageSent(messageId)
 		if updateResult.Err == nil {
 			webhookData := model.NewWebSocketEvent(model.MESSAGE_SENT, "", pageId, "", nil)
 			webhookData.Add("pageId", pageId)
 			webhookData.Add("messageId", messageId)
+			webhookData.Add("status", model.MESSAGE_STATUS_DELIVERED)
 			app.Publish(webhookData)
 
 		} else {
@@ -179,7 +206,7 @@ func (app *App) receiveMessageDelivery(pageId, messageId string) *model.AppError
 }
 
 func (app *App) receiveMessage(message facebookgraph.HubEntryMessaging) *model.AppError {
-	fmt.Println("messagemessagemessage=", message)
+	mlog.Debug("Received facebook message", mlog.String("sender_id", message.Sender.Id), mlog.String("recipient_id", message.Recipient.Id), mlog.String("mid", message.Message.Mid))
 
 	messageId := "m_" + message.Message.Mid
 	senderId := message.Sender.Id
@@ -205,6 +232,16 @@ func (app *App) receiveMessage(message facebookgraph.HubEntryMessaging) *model.A
 		return model.NewAppError("receiveTextMessage", "webhook.facebook_missing_information.app_error", nil, "", http.StatusBadRequest)
 	}
 
+	// Facebook có thể gửi lại cùng một tin nhắn, chỉ xử lý mỗi mid một lần
+	eventKey := model.MessageProcessedEventKey(messageId)
+	if claimed, err := app.claimProcessedEvent(pageId, eventKey); err != nil || !claimed {
+		return err
+	}
+	processed := false
+	defer func() {
+		app.finishProcessedEvent(pageId, eventKey, processed)
+	}()
+
 	var attachmentType string
 	if len(attachments) > 0 {
 
@@ -231,51 +268,24 @@ func (app *App) receiveMessage(message facebookgraph.HubEntryMessaging) *model.A
 		}
 	}
 
-	var conversations []*model.FacebookConversation
-	var conversation *model.FacebookConversation
-	result := <-app.Srv.Store.FacebookConversation().GetPageConversationBySenderId(pageId, from, "message")
-	if result.Err != nil {
-		newConversation := model.FacebookConversation {
-			Type: "message",
-			PageId: pageId,
-			From: senderId,
-			UpdatedTime: time.Unix(messageTime/1000, 10).Format(time.RFC3339),
-			Snippet: snippet,
-		}
-
-		cResult := <-app.Srv.Store.FacebookConversation().Save(&newConversation)
-		if cResult.Err == nil {
-			conversation = cResult.Data.(*model.FacebookConversation)
-		} else {
-			return model.NewAppError("receiveTextMessage", "webhook.facebook_add_new_conversation.app_error", nil, "", http.StatusBadRequest)
-		}
-	} else {
-		conversations = result.Data.([]*model.FacebookConversation)
+	conversation, err := app.getOrCreateMessageConversation(pageId, from, senderId, messageTime, snippet)
+	if err != nil {
+		return err
 	}
 
-	if len(conversations) > 0 {
-		if len(conversations) > 1 {
-			return model.NewAppError("receiveTextMessage", "webhook.facebook_duplicate_conversation_from_user.app_error", nil, pageId + from, http.StatusBadRequest)
-		} else {
-			conversation = conversations[0]
-		}
-	}
-
-	// finnaly check to ensure conversation is not nil
-	if conversation == nil {
-		return model.NewAppError("receiveTextMessage", "webhook.facebook_general_error.app_error", nil, "pageId: " + pageId + " from: " + from, http.StatusBadRequest)
-	}
-
-
 	var conversationMessage *model.FacebookConversationMessage
 
 	// message is sent by page, check if message sent from papo.
 	// if a message sent from papo, it have created before the time received this webhook
 	// so we need to find and update this message
 	if isEcho {
-		mResult := <-app.Srv.Store.FacebookConversation().GetPageMessageByMid(pageId, messageId)
-		if mResult.Err == nil {
-			conversationMessage = mResult.Data.(*model.FacebookConversationMessage)
+		conversationMessage = app.reconcileOutboxEcho(pageId, message.Message.Metadata, messageId, messageTime)
+
+		if conversationMessage == nil {
+			mResult := <-app.Srv.Store.FacebookConversation().GetPageMessageByMid(pageId, messageId)
+			if mResult.Err == nil {
+				conversationMessage = mResult.Data.(*model.FacebookConversationMessage)
+			}
 		}
 	}
 
@@ -299,12 +309,32 @@ func (app *App) receiveMessage(message facebookgraph.HubEntryMessaging) *model.A
 			newMessage.AttachmentType = attachmentType
 		}
 
+		if message.Message.QuickReply != nil {
+			newMessage.Props = model.StringInterface{"quick_reply_payload": message.Message.QuickReply.Payload}
+		}
+
 		addedMessage, _, err := app.AddMessage(newMessage, true, true, isEcho)
 		if err != nil {
 			return model.NewAppError("receiveTextMessage", "webhook.facebook_add_message_error.app_error", nil, "", http.StatusBadRequest)
 		} else {
 			conversationMessage = addedMessage
 		}
+
+		if stickerId == 0 && len(attachments) > 0 {
+			var sourceUrls []string
+			for _, attachment := range attachments {
+				if sourceUrl, ok := attachment.Payload["url"].(string); ok {
+					sourceUrls = append(sourceUrls, sourceUrl)
+				}
+			}
+			app.MirrorMessageAttachmentsInBackground(addedMessage, sourceUrls)
+		}
+
+		if !isEcho {
+			app.markConversationHasPhone(conversation, messageText)
+			app.reopenConversationIfNeed(conversation)
+			app.routeConversationIfNeed(conversation)
+		}
 	}
 
 	if conversationMessage != nil {
@@ -321,8 +351,202 @@ func (app *App) receiveMessage(message facebookgraph.HubEntryMessaging) *model.A
 		webhookData.Add("conversation", conversation)
 		webhookData.Add("newMessage", conversationMessage)
 		app.Publish(webhookData)
+
+		if message.Message.QuickReply != nil {
+			quickReplyData := model.NewWebSocketEvent(model.RECEIVE_QUICK_REPLY, "", pageId, "", nil)
+			quickReplyData.Add("id", conversation.Id)
+			quickReplyData.Add("newMessage", conversationMessage)
+			quickReplyData.Add("payload", message.Message.QuickReply.Payload)
+			app.Publish(quickReplyData)
+		}
+	}
+
+	processed = true
+	return nil
+}
+
+// getOrCreateMessageConversation trả về hội thoại tin nhắn giữa page và người dùng, tạo mới nếu chưa có
+func (app *App) getOrCreateMessageConversation(pageId, from, senderId string, messageTime int64, snippet string) (*model.FacebookConversation, *model.AppError) {
+	var conversations []*model.FacebookConversation
+	var conversation *model.FacebookConversation
+	result := <-app.Srv.Store.FacebookConversation().GetPageConversationBySenderId(pageId, from, "message")
+	if result.Err != nil {
+		newConversation := model.FacebookConversation{
+			Type:        "message",
+			PageId:      pageId,
+			From:        senderId,
+			UpdatedTime: time.Unix(messageTime/1000, 10).Format(time.RFC3339),
+			Snippet:     snippet,
+		}
+
+		cResult := <-app.Srv.Store.FacebookConversation().Save(&newConversation)
+		if cResult.Err == nil {
+			conversation = cResult.Data.(*model.FacebookConversation)
+		} else {
+			return nil, model.NewAppError("receiveTextMessage", "webhook.facebook_add_new_conversation.app_error", nil, "", http.StatusBadRequest)
+		}
+	} else {
+		conversations = result.Data.([]*model.FacebookConversation)
+	}
+
+	if len(conversations) > 0 {
+		if len(conversations) > 1 {
+			return nil, model.NewAppError("receiveTextMessage", "webhook.facebook_duplicate_conversation_from_user.app_error", nil, pageId+from, http.StatusBadRequest)
+		} else {
+			conversation = conversations[0]
+		}
+	}
+
+	// finnaly check to ensure conversation is not nil
+	if conversation == nil {
+		return nil, model.NewAppError("receiveTextMessage", "webhook.facebook_general_error.app_error", nil, "pageId: "+pageId+" from: "+from, http.StatusBadRequest)
+	}
+
+	return conversation, nil
+}
+
+func (app *App) receivePostback(event facebookgraph.HubEntryMessaging) *model.AppError {
+	props := model.StringInterface{
+		"title":   event.PostBack.Title,
+		"payload": event.PostBack.Payload,
+	}
+	if event.PostBack.Referral != nil {
+		props["referral"] = event.PostBack.Referral
+	}
+
+	return app.receiveSystemEvent(event, model.SYSTEM_MESSAGE_POSTBACK, event.PostBack.Title, props, true, model.RECEIVE_POSTBACK)
+}
+
+func (app *App) receiveReaction(event facebookgraph.HubEntryMessaging) *model.AppError {
+	props := model.StringInterface{
+		"mid":      "m_" + event.Reaction.Mid,
+		"action":   event.Reaction.Action,
+		"reaction": event.Reaction.Reaction,
+		"emoji":    event.Reaction.Emoji,
+	}
+
+	// cảm xúc không tính là tin nhắn chưa đọc
+	return app.receiveSystemEvent(event, model.SYSTEM_MESSAGE_REACTION, event.Reaction.Emoji, props, false, model.RECEIVE_REACTION)
+}
+
+func (app *App) receiveReferral(event facebookgraph.HubEntryMessaging) *model.AppError {
+	props := model.StringInterface{
+		"ref":         event.Referral.Ref,
+		"source":      event.Referral.Source,
+		"type":        event.Referral.Type,
+		"ad_id":       event.Referral.AdId,
+		"referer_uri": event.Referral.RefererUri,
+	}
+
+	return app.receiveSystemEvent(event, model.SYSTEM_MESSAGE_REFERRAL, event.Referral.Source, props, true, model.RECEIVE_REFERRAL)
+}
+
+func (app *App) receiveOptin(event facebookgraph.HubEntryMessaging) *model.AppError {
+	props := model.StringInterface{
+		"ref":                  event.Optin.Ref,
+		"user_ref":             event.Optin.UserRef,
+		"type":                 event.Optin.Type,
+		"payload":              event.Optin.Payload,
+		"one_time_notif_token": event.Optin.OneTimeNotifToken,
 	}
 
+	return app.receiveSystemEvent(event, model.SYSTEM_MESSAGE_OPTIN, event.Optin.Type, props, false, model.RECEIVE_OPTIN)
+}
+
+// receiveSystemEvent lưu sự kiện messenger (postback, reaction...) thành tin nhắn hệ thống trong hội thoại
+// và gửi websocket event riêng cho từng loại sự kiện
+func (app *App) receiveSystemEvent(event facebookgraph.HubEntryMessaging, systemType string, text string, props model.StringInterface, shouldUpdateConversation bool, websocketEvent string) *model.AppError {
+	pageId := event.Recipient.Id
+	from := event.Sender.Id
+
+	if len(from) == 0 || len(pageId) == 0 {
+		return model.NewAppError("receiveSystemEvent", "webhook.facebook_missing_information.app_error", nil, "type="+systemType, http.StatusBadRequest)
+	}
+
+	eventKey := model.SystemProcessedEventKey(systemType, from, event.Timestamp)
+	if claimed, err := app.claimProcessedEvent(pageId, eventKey); err != nil || !claimed {
+		return err
+	}
+	processed := false
+	defer func() {
+		app.finishProcessedEvent(pageId, eventKey, processed)
+	}()
+
+	conversation, err := app.getOrCreateMessageConversation(pageId, from, from, event.Timestamp, text)
+	if err != nil {
+		return err
+	}
+
+	message := &model.FacebookConversationMessage{
+		ConversationId: conversation.Id,
+		Type:           model.CONVERSATION_MESSAGE_TYPE_SYSTEM,
+		SystemType:     systemType,
+		PageId:         pageId,
+		Message:        text,
+		CreatedTime:    time.Unix(event.Timestamp/1000, 10).Format(time.RFC3339),
+		From:           from,
+		Props:          props,
+	}
+
+	addedMessage, _, err := app.AddMessage(message, true, shouldUpdateConversation, false)
+	if err != nil {
+		return err
+	}
+
+	webhookData := model.NewWebSocketEvent(websocketEvent, "", pageId, "", nil)
+	webhookData.Add("id", conversation.Id)
+	webhookData.Add("conversation", conversation)
+	webhookData.Add("newMessage", addedMessage)
+	app.Publish(webhookData)
+
+	processed = true
+	return nil
+}
+
+// receivePolicyEnforcement xử lý khi Facebook cảnh báo, chặn hoặc bỏ chặn page.
+// Sự kiện này thuộc về page chứ không thuộc hội thoại nào.
+func (app *App) receivePolicyEnforcement(event facebookgraph.HubEntryMessaging) *model.AppError {
+	pageId := event.Recipient.Id
+	if len(pageId) == 0 {
+		return model.NewAppError("receivePolicyEnforcement", "webhook.facebook_missing_information.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	mlog.Warn("Facebook policy enforcement received",
+		mlog.String("page_id", pageId),
+		mlog.String("action", event.PolicyEnforcement.Action),
+		mlog.String("reason", event.PolicyEnforcement.Reason),
+	)
+
+	var status string
+	switch event.PolicyEnforcement.Action {
+	case "block":
+		status = model.PAGE_STATUS_BLOCKED
+	case "unblock":
+		// Chỉ gỡ trạng thái bị chặn, không ghi đè các trạng thái khác như cần xác thực lại
+		page, err := app.GetFanpageByPageId(pageId)
+		if err != nil {
+			return err
+		}
+		if page.Status == model.PAGE_STATUS_BLOCKED {
+			status = model.PAGE_STATUS_INITIALIZED
+		}
+	}
+
+	if len(status) > 0 {
+		if result := <-app.Srv.Store.Fanpage().UpdateStatus(pageId, status); result.Err != nil {
+			return result.Err
+		}
+	}
+
+	webhookData := model.NewWebSocketEvent(model.RECEIVE_POLICY_ENFORCEMENT, "", pageId, "", nil)
+	webhookData.Add("page_id", pageId)
+	webhookData.Add("action", event.PolicyEnforcement.Action)
+	webhookData.Add("reason", event.PolicyEnforcement.Reason)
+	if len(status) > 0 {
+		webhookData.Add("status", status)
+	}
+	app.Publish(webhookData)
+
 	return nil
 }
 
@@ -341,7 +565,7 @@ func (app *App) receivePost(change facebookgraph.HubEntryChange) *model.AppError
 			Picture: picture,
 		}
 
-		fmt.Println(post)
+		mlog.Debug("Received facebook post", mlog.String("post_id", post.PostId))
 
 	}
 
@@ -352,7 +576,7 @@ func (app *App) receivePost(change facebookgraph.HubEntryChange) *model.AppError
 func (app *App) receiveComment(change facebookgraph.HubEntryChange) *model.AppError {
 
 	rawComment := change.Value
-	fmt.Println(rawComment)
+	mlog.Debug("Received facebook comment", mlog.String("comment_id", rawComment.CommentId), mlog.String("post_id", rawComment.PostId), mlog.String("verb", rawComment.Verb))
 
 	// thêm user nếu cần
 	from := <-app.Srv.Store.FacebookUid().UpsertFromMap(rawComment.From)
@@ -389,6 +613,16 @@ func (app *App) receiveComment(change facebookgraph.HubEntryChange) *model.AppEr
 	}
 
 	if rawComment.Verb == "add" {
+		// Facebook có thể gửi lại cùng một comment, chỉ thêm mỗi comment_id một lần
+		eventKey := model.CommentProcessedEventKey(commentId)
+		if claimed, err := app.claimProcessedEvent(pageId, eventKey); err != nil || !claimed {
+			return err
+		}
+		processed := false
+		defer func() {
+			app.finishProcessedEvent(pageId, eventKey, processed)
+		}()
+
 		foundConversation := <-app.Srv.Store.FacebookConversation().InsertConversationFromCommentIfNeed(parentId, commentId, pageId, postId, userId, commentTime, snippet)
 		if foundConversation.Data == nil {
 			return model.NewAppError("HandleIncomingWebhook", "web.incoming_webhook.parse.app_error", nil, "", http.StatusBadRequest)
@@ -397,14 +631,6 @@ func (app *App) receiveComment(change facebookgraph.HubEntryChange) *model.AppEr
 			isNew := foundConversation.Data.(*model.UpsertConversationResult).IsNew
 			isFromPage := from.Data.(*model.FacebookUid).Id == pageId
 
-			// chỉ cập nhật conversation nếu nó chưa được tạo mới
-			if !isNew {
-				updatedResult := <-app.Srv.Store.FacebookConversation().UpdateConversation(conversation.Id, snippet, isFromPage, commentTime, 1, commentTime)
-				if updatedResult.Err != nil {
-					return model.NewAppError("HandleIncomingWebhook", "web.incoming_webhook.parse.app_error", nil, "", http.StatusBadRequest)
-				}
-			}
-
 			message := &model.FacebookConversationMessage{
 				Type: "comment",
 				PageId: pageId,
@@ -430,10 +656,17 @@ func (app *App) receiveComment(change facebookgraph.HubEntryChange) *model.AppEr
 
 			var newMessage *model.FacebookConversationMessage
 
+			// hội thoại chỉ được cập nhật khi bình luận thực sự được thêm mới và hội thoại không phải vừa tạo
 			if newMessage, _, _ = app.AddMessage(message, true, !isNew, isFromPage); newMessage == nil {
 				return model.NewAppError("HandleIncomingWebhook", "web.incoming_webhook.parse.app_error", nil, "", http.StatusBadRequest)
 			}
 
+			if !isFromPage {
+				app.markConversationHasPhone(conversation, rawComment.Message)
+				app.reopenConversationIfNeed(conversation)
+				app.routeConversationIfNeed(conversation)
+			}
+
 			if newMessage != nil {
 
 				var omitUsers map[string]bool
@@ -449,7 +682,23 @@ func (app *App) receiveComment(change facebookgraph.HubEntryChange) *model.AppEr
 				webhookData.Add("conversation", conversation)
 				webhookData.Add("newMessage", newMessage)
 				app.Publish(webhookData)
+
+				// link ảnh trong webhook là link CDN có hạn dùng nên cần lưu lại bản sao
+				sourceUrls := rawComment.Photos
+				if len(rawComment.Photo) > 0 {
+					sourceUrls = append([]string{rawComment.Photo}, sourceUrls...)
+				}
+				app.MirrorMessageAttachmentsInBackground(newMessage, sourceUrls)
+
+				// luật kiểm duyệt gọi Graph API nên chạy sau khi đã trả lời webhook
+				if !isFromPage {
+					app.Srv.Go(func() {
+						app.ApplyModerationRules(conversation, newMessage)
+					})
+				}
 			}
+
+			processed = true
 		}
 	} else if rawComment.Verb == "edit" {
 		fmt.Println("edit comment")
@@ -0,0 +1,127 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package commands
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"bitbucket.org/enesyteam/papo-server/model"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+var WebhookCmd = &cobra.Command{
+	Use:   "webhook",
+	Short: "Facebook webhook commands",
+}
+
+var WebhookSignCmd = &cobra.Command{
+	Use:     "sign [payload]",
+	Short:   "Sign a webhook payload.",
+	Long:    "Print the X-Hub-Signature-256 header for a Facebook webhook payload so local tools can post signed requests to /webhooks/facebook.",
+	Example: "  webhook sign fixtures/comment.json\n  webhook sign --secret appsecret fixtures/comment.json",
+	RunE:    webhookSignCmdF,
+}
+
+var WebhookReplayCmd = &cobra.Command{
+	Use:     "replay",
+	Short:   "Replay stored webhook payloads.",
+	Long:    "Reset every Facebook webhook payload received since the given time so the webhook queue processes it again.",
+	Example: "  webhook replay --since 2h\n  webhook replay --since 2020-11-20T08:00:00+07:00 --process",
+	RunE:    webhookReplayCmdF,
+}
+
+func init() {
+	WebhookSignCmd.Flags().String("secret", "", "App secret to sign with. Defaults to FacebookSettings.Secret from the config.")
+	WebhookReplayCmd.Flags().String("since", "", "Replay payloads received since this time. Accepts a duration (2h), an RFC3339 time or unix milliseconds.")
+	WebhookReplayCmd.Flags().Bool("process", false, "Process the payloads in this command instead of waiting for a running server.")
+	WebhookCmd.AddCommand(WebhookSignCmd)
+	WebhookCmd.AddCommand(WebhookReplayCmd)
+	RootCmd.AddCommand(WebhookCmd)
+}
+
+func webhookSignCmdF(command *cobra.Command, args []string) error {
+	if len(args) != 1 {
+		return errors.New("Enter one payload file to sign")
+	}
+
+	body, err := ioutil.ReadFile(args[0])
+	if err != nil {
+		return err
+	}
+
+	secret, _ := command.Flags().GetString("secret")
+	if len(secret) == 0 {
+		a, err := InitDBCommandContextCobra(command)
+		if err != nil {
+			return err
+		}
+		defer a.Shutdown()
+
+		secret = *a.Config().FacebookSettings.Secret
+	}
+
+	if len(secret) == 0 {
+		return errors.New("No app secret configured, use --secret")
+	}
+
+	CommandPrintln(facebookgraph.WEBHOOK_SIGNATURE_HEADER + ": " + facebookgraph.SignWebhookPayload(secret, body))
+
+	return nil
+}
+
+func webhookReplayCmdF(command *cobra.Command, args []string) error {
+	sinceFlag, _ := command.Flags().GetString("since")
+	since, err := parseReplaySince(sinceFlag)
+	if err != nil {
+		return err
+	}
+
+	a, err := InitDBCommandContextCobra(command)
+	if err != nil {
+		return err
+	}
+	defer a.Shutdown()
+
+	count, appErr := a.ReplayFacebookWebhookEventsSince(since)
+	if appErr != nil {
+		return appErr
+	}
+
+	CommandPrettyPrintln(fmt.Sprintf("Queued %d webhook payloads for replay", count))
+
+	if process, _ := command.Flags().GetBool("process"); process {
+		processed, appErr := a.ProcessDueFacebookWebhookEvents()
+		if appErr != nil {
+			return appErr
+		}
+		CommandPrettyPrintln(fmt.Sprintf("Processed %d webhook payloads", processed))
+	}
+
+	return nil
+}
+
+func parseReplaySince(value string) (int64, error) {
+	if len(value) == 0 {
+		return 0, errors.New("--since is required")
+	}
+
+	if d, err := time.ParseDuration(value); err == nil {
+		return model.GetMillisForTime(time.Now().Add(-d)), nil
+	}
+
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return model.GetMillisForTime(t), nil
+	}
+
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return millis, nil
+	}
+
+	return 0, errors.New("Invalid --since value " + value)
+}
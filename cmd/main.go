@@ -5,6 +5,10 @@ package main
 
 import (
 	"bitbucket.org/enesyteam/papo-server/cmd/commands"
+	// Jobs
+	_ "bitbucket.org/enesyteam/papo-server/jobs/fanpage_init"
+	_ "bitbucket.org/enesyteam/papo-server/jobs/fanpage_sync"
+	_ "bitbucket.org/enesyteam/papo-server/jobs/auto_message_task"
 	// Plugins
 	_ "bitbucket.org/enesyteam/papo-server/model/facebook"
 	_ "github.com/go-ldap/ldap"
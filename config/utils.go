@@ -48,6 +48,9 @@ func desanitize(actual, target *model.Config) {
 	if *target.SqlSettings.AtRestEncryptKey == model.FAKE_SETTING {
 		target.SqlSettings.AtRestEncryptKey = actual.SqlSettings.AtRestEncryptKey
 	}
+	if target.FacebookSettings.PageTokenEncryptKey != nil && *target.FacebookSettings.PageTokenEncryptKey == model.FAKE_SETTING {
+		target.FacebookSettings.PageTokenEncryptKey = actual.FacebookSettings.PageTokenEncryptKey
+	}
 
 	if *target.ElasticsearchSettings.Password == model.FAKE_SETTING {
 		*target.ElasticsearchSettings.Password = *actual.ElasticsearchSettings.Password
@@ -60,5 +60,12 @@ type MetricsInterface interface {
 	ObservePluginApiDuration(pluginID, apiName string, success bool, elapsed float64)
 
 	ObserveEnabledUsers(users int64)
+
+	IncrementFacebookGraphRequest(method string)
+	IncrementFacebookGraphError(code string)
+	IncrementFacebookGraphThrottled(usageType string)
+	ObserveFacebookGraphRequestDuration(method string, elapsed float64)
+	ObserveFacebookGraphUsage(usageType string, percent float64)
+
 	GetLoggerMetricsCollector() logr.MetricsCollector
 }
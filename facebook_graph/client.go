@@ -0,0 +1,397 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	GRAPH_API_BASE_URL        = "https://graph.facebook.com"
+	GRAPH_API_DEFAULT_VERSION = "v3.2"
+
+	// https://developers.facebook.com/docs/graph-api/overview/rate-limiting
+	HEADER_APP_USAGE               = "X-App-Usage"
+	HEADER_BUSINESS_USE_CASE_USAGE = "X-Business-Use-Case-Usage"
+
+	USAGE_TYPE_APP      = "app"
+	USAGE_TYPE_BUSINESS = "business"
+
+	// thời gian chờ khi Facebook báo vượt giới hạn mà không cho biết khi nào được gọi lại, tăng gấp đôi sau mỗi lần thử
+	RATE_LIMIT_BACKOFF_BASE = 30 * time.Second
+	TRANSIENT_BACKOFF_BASE  = time.Second
+
+	throttleScopeApp = "app"
+)
+
+// Metrics là phần của einterfaces.MetricsInterface mà client dùng tới,
+// package này không import einterfaces để tránh vòng lặp import qua model.
+type Metrics interface {
+	IncrementFacebookGraphRequest(method string)
+	IncrementFacebookGraphError(code string)
+	IncrementFacebookGraphThrottled(usageType string)
+	ObserveFacebookGraphRequestDuration(method string, elapsed float64)
+	ObserveFacebookGraphUsage(usageType string, percent float64)
+}
+
+// ClientSettings được đọc lại trước mỗi request nên thay đổi cấu hình có hiệu lực ngay
+type ClientSettings struct {
+	BaseUrl   string
+	Version   string
+	AppSecret string
+
+	// tỉ lệ sử dụng (%) theo header usage từ đó client bắt đầu giãn các request
+	UsageThrottlePercent int
+	MaxRetries           int
+	// thời gian chờ tối đa trong một request, nếu phải chờ lâu hơn request trả về lỗi vượt giới hạn ngay
+	MaxBackoff time.Duration
+}
+
+// Client gọi Graph API: tự thêm version và appsecret_proof, theo dõi header usage để giãn request trước khi
+// bị Facebook chặn, và thử lại khi gặp lỗi vượt giới hạn (4, 17, 32, 613) hoặc lỗi tạm thời.
+type Client struct {
+	httpClient *http.Client
+	settings   func() *ClientSettings
+	metrics    Metrics
+
+	sleep func(time.Duration)
+	now   func() time.Time
+
+	mutex        sync.Mutex
+	blockedUntil map[string]time.Time
+}
+
+func NewClient(httpClient *http.Client, settings func() *ClientSettings, metrics Metrics) *Client {
+	return &Client{
+		httpClient:   httpClient,
+		settings:     settings,
+		metrics:      metrics,
+		sleep:        time.Sleep,
+		now:          time.Now,
+		blockedUntil: make(map[string]time.Time),
+	}
+}
+
+// AppSecretProof là HMAC-SHA256 của access token với app secret
+// https://developers.facebook.com/docs/graph-api/securing-requests#appsecret_proof
+func AppSecretProof(appSecret string, accessToken string) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write([]byte(accessToken))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Url trả về địa chỉ đầy đủ của path dạng "/me/accounts", link paging đầy đủ được giữ nguyên
+func (c *Client) Url(path string) string {
+	if strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://") {
+		return path
+	}
+
+	settings := c.settings()
+	baseUrl := settings.BaseUrl
+	if len(baseUrl) == 0 {
+		baseUrl = GRAPH_API_BASE_URL
+	}
+	version := settings.Version
+	if len(version) == 0 {
+		version = GRAPH_API_DEFAULT_VERSION
+	}
+
+	return strings.TrimRight(baseUrl, "/") + "/" + version + path
+}
+
+func (c *Client) requestUrl(settings *ClientSettings, path string, accessToken string) (string, error) {
+	u, err := url.Parse(c.Url(path))
+	if err != nil {
+		return "", err
+	}
+
+	if len(settings.AppSecret) > 0 && len(accessToken) > 0 {
+		query := u.Query()
+		query.Set("appsecret_proof", AppSecretProof(settings.AppSecret, accessToken))
+		u.RawQuery = query.Encode()
+	}
+
+	return u.String(), nil
+}
+
+// Get gửi request GET tới path, xem Do
+func (c *Client) Get(path string, accessToken string) (io.ReadCloser, *FacebookError, error) {
+	return c.Do(http.MethodGet, path, accessToken, "", nil)
+}
+
+// Do gửi request tới Graph API. Body trả về chỉ có khi request thành công, lỗi Graph API trả về qua FacebookError,
+// lỗi kết nối trả về qua error.
+func (c *Client) Do(method string, path string, accessToken string, contentType string, body []byte) (io.ReadCloser, *FacebookError, error) {
+	settings := c.settings()
+
+	reqUrl, err := c.requestUrl(settings, path, accessToken)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	for attempt := 0; ; attempt++ {
+		if wait := c.waitTime(accessToken); wait > 0 {
+			if wait > settings.MaxBackoff {
+				return nil, throttledError(wait), nil
+			}
+			c.sleep(wait)
+		}
+
+		data, header, statusCode, err := c.send(method, reqUrl, accessToken, contentType, body)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		c.updateUsage(settings, accessToken, header)
+
+		if statusCode == http.StatusOK {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil, nil
+		}
+
+		fbErr := FacebookErrorFromJson(bytes.NewReader(data))
+		if fbErr == nil || fbErr.Error.Code == 0 {
+			fbErr = &FacebookError{Error: Error{Message: http.StatusText(statusCode), Code: ERROR_CODE_UNKNOWN}}
+		}
+
+		if c.metrics != nil {
+			c.metrics.IncrementFacebookGraphError(strconv.Itoa(fbErr.Error.Code))
+		}
+
+		if fbErr.IsRateLimit() {
+			backoff := estimatedTimeToRegainAccess(header)
+			if backoff == 0 {
+				backoff = RATE_LIMIT_BACKOFF_BASE << uint(attempt)
+			}
+
+			c.block(rateLimitScope(fbErr.Error.Code, accessToken), backoff)
+			if backoff > settings.MaxBackoff {
+				return nil, fbErr, nil
+			}
+		}
+
+		// request ghi (gửi tin nhắn, ẩn bình luận...) có thể đã được Facebook thực hiện dù trả về lỗi,
+		// chỉ tự thử lại GET để không gửi trùng, việc thử lại request ghi do nơi gọi quyết định
+		if attempt >= settings.MaxRetries || method != http.MethodGet {
+			return nil, fbErr, nil
+		}
+
+		switch {
+		case fbErr.IsRateLimit():
+			// scope đã bị chặn ở trên, lượt sau sẽ chờ qua waitTime
+		case fbErr.IsTransient():
+			c.sleep(TRANSIENT_BACKOFF_BASE << uint(attempt))
+		default:
+			return nil, fbErr, nil
+		}
+	}
+}
+
+func (c *Client) send(method string, reqUrl string, accessToken string, contentType string, body []byte) ([]byte, http.Header, int, error) {
+	req, err := http.NewRequest(method, reqUrl, bytes.NewReader(body))
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	if len(contentType) == 0 {
+		contentType = "application/x-www-form-urlencoded"
+	}
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("Accept", "application/json")
+	if len(accessToken) > 0 {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	start := c.now()
+	resp, err := c.httpClient.Do(req)
+	if c.metrics != nil {
+		c.metrics.IncrementFacebookGraphRequest(method)
+		c.metrics.ObserveFacebookGraphRequestDuration(method, c.now().Sub(start).Seconds())
+	}
+	if err != nil {
+		return nil, nil, 0, err
+	}
+	defer resp.Body.Close()
+
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, nil, 0, err
+	}
+
+	return data, resp.Header, resp.StatusCode, nil
+}
+
+// lỗi 4 là giới hạn của cả app nên chặn mọi request, các lỗi còn lại chỉ chặn request dùng token đó (user hoặc page)
+func rateLimitScope(code int, accessToken string) string {
+	if code == ERROR_CODE_APP_TOO_MANY_CALLS {
+		return throttleScopeApp
+	}
+	return tokenScope(accessToken)
+}
+
+// không giữ token trong bộ nhớ, chỉ giữ mã băm để phân biệt
+func tokenScope(accessToken string) string {
+	sum := sha256.Sum256([]byte(accessToken))
+	return "token:" + hex.EncodeToString(sum[:8])
+}
+
+func (c *Client) block(scope string, d time.Duration) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	until := c.now().Add(d)
+	if until.After(c.blockedUntil[scope]) {
+		c.blockedUntil[scope] = until
+	}
+}
+
+func (c *Client) waitTime(accessToken string) time.Duration {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	now := c.now()
+	var wait time.Duration
+	for _, scope := range []string{throttleScopeApp, tokenScope(accessToken)} {
+		until, ok := c.blockedUntil[scope]
+		if !ok {
+			continue
+		}
+		if !until.After(now) {
+			delete(c.blockedUntil, scope)
+			continue
+		}
+		if d := until.Sub(now); d > wait {
+			wait = d
+		}
+	}
+	return wait
+}
+
+// updateUsage đọc header usage sau mỗi request, khi tỉ lệ sử dụng vượt UsageThrottlePercent các request tiếp theo
+// được giãn ra tương ứng, tới 100% thì chờ hết thời gian Facebook báo hoặc MaxBackoff.
+func (c *Client) updateUsage(settings *ClientSettings, accessToken string, header http.Header) {
+	if percent, ok := parseAppUsage(header.Get(HEADER_APP_USAGE)); ok {
+		c.throttleUsage(settings, USAGE_TYPE_APP, throttleScopeApp, percent, 0)
+	}
+
+	if percent, regain, ok := parseBusinessUseCaseUsage(header.Get(HEADER_BUSINESS_USE_CASE_USAGE)); ok {
+		c.throttleUsage(settings, USAGE_TYPE_BUSINESS, tokenScope(accessToken), percent, regain)
+	}
+}
+
+func (c *Client) throttleUsage(settings *ClientSettings, usageType string, scope string, percent int, regain time.Duration) {
+	if c.metrics != nil {
+		c.metrics.ObserveFacebookGraphUsage(usageType, float64(percent))
+	}
+
+	delay := UsageDelay(percent, settings.UsageThrottlePercent, settings.MaxBackoff)
+	if regain > delay {
+		delay = regain
+	}
+	if delay <= 0 {
+		return
+	}
+
+	if c.metrics != nil {
+		c.metrics.IncrementFacebookGraphThrottled(usageType)
+	}
+	c.block(scope, delay)
+}
+
+// UsageDelay tính thời gian giãn request theo tỉ lệ sử dụng: 0 dưới ngưỡng, tăng tuyến tính tới maxDelay khi đạt 100%
+func UsageDelay(percent int, threshold int, maxDelay time.Duration) time.Duration {
+	if threshold <= 0 || threshold >= 100 || percent < threshold {
+		return 0
+	}
+	if percent >= 100 {
+		return maxDelay
+	}
+	return maxDelay * time.Duration(percent-threshold) / time.Duration(100-threshold)
+}
+
+type appUsage struct {
+	CallCount    int `json:"call_count"`
+	TotalTime    int `json:"total_time"`
+	TotalCputime int `json:"total_cputime"`
+}
+
+func (u *appUsage) percent() int {
+	percent := u.CallCount
+	if u.TotalTime > percent {
+		percent = u.TotalTime
+	}
+	if u.TotalCputime > percent {
+		percent = u.TotalCputime
+	}
+	return percent
+}
+
+type businessUseCaseUsage struct {
+	appUsage
+	Type                        string `json:"type"`
+	EstimatedTimeToRegainAccess int    `json:"estimated_time_to_regain_access"`
+}
+
+func parseAppUsage(value string) (int, bool) {
+	if len(value) == 0 {
+		return 0, false
+	}
+
+	var usage appUsage
+	if err := json.Unmarshal([]byte(value), &usage); err != nil {
+		return 0, false
+	}
+	return usage.percent(), true
+}
+
+// header có dạng {"<business_object_id>": [{"type": "pages", "call_count": 10, ...}]},
+// trả về tỉ lệ sử dụng và thời gian chờ cao nhất trong các mục
+func parseBusinessUseCaseUsage(value string) (int, time.Duration, bool) {
+	if len(value) == 0 {
+		return 0, 0, false
+	}
+
+	var usages map[string][]*businessUseCaseUsage
+	if err := json.Unmarshal([]byte(value), &usages); err != nil {
+		return 0, 0, false
+	}
+
+	percent, regainMinutes := 0, 0
+	for _, items := range usages {
+		for _, usage := range items {
+			if usage.percent() > percent {
+				percent = usage.percent()
+			}
+			if usage.EstimatedTimeToRegainAccess > regainMinutes {
+				regainMinutes = usage.EstimatedTimeToRegainAccess
+			}
+		}
+	}
+	return percent, time.Duration(regainMinutes) * time.Minute, true
+}
+
+func estimatedTimeToRegainAccess(header http.Header) time.Duration {
+	_, regain, _ := parseBusinessUseCaseUsage(header.Get(HEADER_BUSINESS_USE_CASE_USAGE))
+	return regain
+}
+
+// lỗi trả về khi client tự chặn request vì đang chờ hết giới hạn, không gửi tới Facebook
+func throttledError(wait time.Duration) *FacebookError {
+	return &FacebookError{Error: Error{
+		Message: "Request throttled by client for " + wait.Round(time.Second).String(),
+		Type:    "OAuthException",
+		Code:    ERROR_CODE_CUSTOM_RATE_LIMIT,
+	}}
+}
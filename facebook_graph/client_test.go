@@ -0,0 +1,193 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestClient(t *testing.T, handler http.HandlerFunc) (*Client, *[]time.Duration) {
+	server := httptest.NewServer(handler)
+	t.Cleanup(server.Close)
+
+	settings := &ClientSettings{
+		BaseUrl:              server.URL,
+		Version:              "v9.0",
+		AppSecret:            "secret",
+		UsageThrottlePercent: 80,
+		MaxRetries:           2,
+		MaxBackoff:           time.Minute,
+	}
+
+	var slept []time.Duration
+	client := NewClient(server.Client(), func() *ClientSettings { return settings }, nil)
+	client.sleep = func(d time.Duration) { slept = append(slept, d) }
+	return client, &slept
+}
+
+func TestClientRequest(t *testing.T) {
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v9.0/me/accounts", r.URL.Path)
+		assert.Equal(t, AppSecretProof("secret", "token"), r.URL.Query().Get("appsecret_proof"))
+		assert.Equal(t, "Bearer token", r.Header.Get("Authorization"))
+		w.Write([]byte(`{"data":[]}`))
+	})
+
+	body, fbErr, err := client.Get("/me/accounts?fields=id", "token")
+	require.Nil(t, fbErr)
+	require.Nil(t, err)
+	data, _ := ioutil.ReadAll(body)
+	assert.Equal(t, `{"data":[]}`, string(data))
+}
+
+func TestClientRateLimit(t *testing.T) {
+	t.Run("retry after estimated time to regain access", func(t *testing.T) {
+		calls := 0
+		client, slept := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			if calls == 1 {
+				w.Header().Set(HEADER_BUSINESS_USE_CASE_USAGE, `{"123":[{"type":"pages","call_count":100,"estimated_time_to_regain_access":0}]}`)
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":{"message":"limit","code":32}}`))
+				return
+			}
+			w.Write([]byte(`{}`))
+		})
+
+		_, fbErr, err := client.Get("/123/conversations", "token")
+		require.Nil(t, fbErr)
+		require.Nil(t, err)
+		assert.Equal(t, 2, calls)
+		require.NotEmpty(t, *slept)
+		assert.InDelta(t, float64(time.Minute), float64((*slept)[0]), float64(time.Second))
+	})
+
+	t.Run("give up when backoff is too long", func(t *testing.T) {
+		calls := 0
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.Header().Set(HEADER_BUSINESS_USE_CASE_USAGE, `{"123":[{"type":"pages","call_count":100,"estimated_time_to_regain_access":30}]}`)
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"limit","code":613}}`))
+		})
+
+		_, fbErr, _ := client.Get("/123/conversations", "token")
+		require.NotNil(t, fbErr)
+		assert.True(t, fbErr.IsRateLimit())
+		assert.Equal(t, 1, calls)
+
+		// token bị chặn nên request sau không được gửi đi, token khác vẫn gửi bình thường
+		_, fbErr, _ = client.Get("/123/conversations", "token")
+		require.NotNil(t, fbErr)
+		assert.Equal(t, 1, calls)
+
+		client.Get("/456/conversations", "other")
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("app limit blocks every token", func(t *testing.T) {
+		calls := 0
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"limit","code":4}}`))
+		})
+		client.settings().MaxBackoff = 10 * time.Second
+
+		client.Get("/me", "token")
+		client.Get("/me", "other")
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("do not retry other errors", func(t *testing.T) {
+		calls := 0
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusBadRequest)
+			w.Write([]byte(`{"error":{"message":"invalid","code":190}}`))
+		})
+
+		_, fbErr, _ := client.Get("/me", "token")
+		require.NotNil(t, fbErr)
+		assert.Equal(t, ERROR_CODE_INVALID_OAUTH_TOKEN, fbErr.Error.Code)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("retry transient errors only for GET", func(t *testing.T) {
+		calls := 0
+		client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+			calls++
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte(`{"error":{"message":"unknown","code":2}}`))
+		})
+
+		_, fbErr, _ := client.Get("/me", "token")
+		require.NotNil(t, fbErr)
+		assert.Equal(t, 3, calls)
+
+		calls = 0
+		_, fbErr, _ = client.Do(http.MethodPost, "/me/messages", "token", "application/json", []byte(`{}`))
+		require.NotNil(t, fbErr)
+		assert.Equal(t, 1, calls)
+	})
+}
+
+func TestClientUsageThrottle(t *testing.T) {
+	client, slept := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(HEADER_APP_USAGE, `{"call_count":90,"total_time":10,"total_cputime":5}`)
+		w.Write([]byte(`{}`))
+	})
+
+	client.Get("/me", "token")
+	assert.Empty(t, *slept)
+
+	client.Get("/me", "other")
+	require.Len(t, *slept, 1)
+	assert.InDelta(t, float64(30*time.Second), float64((*slept)[0]), float64(time.Second))
+}
+
+func TestUsageDelay(t *testing.T) {
+	assert.Equal(t, time.Duration(0), UsageDelay(50, 80, time.Minute))
+	assert.Equal(t, time.Duration(0), UsageDelay(90, 0, time.Minute))
+	assert.Equal(t, 30*time.Second, UsageDelay(90, 80, time.Minute))
+	assert.Equal(t, time.Minute, UsageDelay(120, 80, time.Minute))
+}
+
+func TestPageIterator(t *testing.T) {
+	var serverUrl string
+	client, _ := newTestClient(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("after") == "" {
+			fmt.Fprintf(w, `{"data":[{"id":"1"},{"id":"2"}],"paging":{"next":"%s/v9.0/123/feed?after=2"}}`, serverUrl)
+			return
+		}
+		w.Write([]byte(`{"data":[{"id":"3"}],"paging":{}}`))
+	})
+	serverUrl = client.settings().BaseUrl
+
+	var ids []string
+	it := client.Posts("token", "/123/feed")
+	for {
+		page, ok := it.Next()
+		if !ok {
+			break
+		}
+		for _, post := range page.Data {
+			ids = append(ids, post.Id)
+		}
+	}
+
+	fbErr, err := it.Err()
+	assert.Nil(t, fbErr)
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"1", "2", "3"}, ids)
+	assert.Empty(t, it.Cursor())
+}
@@ -9,6 +9,32 @@ import (
 	"io/ioutil"
 )
 
+// Các mã lỗi Graph API cần xử lý riêng
+// https://developers.facebook.com/docs/graph-api/using-graph-api/error-handling
+const (
+	ERROR_CODE_UNKNOWN             = 1
+	ERROR_CODE_SERVICE             = 2
+	ERROR_CODE_APP_TOO_MANY_CALLS  = 4
+	ERROR_CODE_USER_TOO_MANY_CALLS = 17
+	ERROR_CODE_PAGE_TOO_MANY_CALLS = 32
+	ERROR_CODE_CUSTOM_RATE_LIMIT   = 613
+	ERROR_CODE_INVALID_OAUTH_TOKEN = 190
+)
+
+// IsRateLimit cho biết request bị giới hạn tốc độ (ở cấp app, user hoặc page), nên gửi lại sau
+func (p *FacebookError) IsRateLimit() bool {
+	switch p.Error.Code {
+	case ERROR_CODE_APP_TOO_MANY_CALLS, ERROR_CODE_USER_TOO_MANY_CALLS, ERROR_CODE_PAGE_TOO_MANY_CALLS, ERROR_CODE_CUSTOM_RATE_LIMIT:
+		return true
+	}
+	return false
+}
+
+// IsTransient cho biết lỗi tạm thời phía Facebook, có thể gửi lại ngay sau đó
+func (p *FacebookError) IsTransient() bool {
+	return p.Error.Code == ERROR_CODE_UNKNOWN || p.Error.Code == ERROR_CODE_SERVICE
+}
+
 // Cần nghiên cứu thêm xem facebook API có kiểu lỗi trả về nào khác kiểu dưới đây không
 type FacebookError struct {
 	Error Error `json:"error"`
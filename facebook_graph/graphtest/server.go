@@ -0,0 +1,858 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+// Package graphtest giả lập Graph API của Facebook bằng httptest để chạy test và phát triển offline.
+// Server giữ page, bài viết, comment, hội thoại trong bộ nhớ, nhận Send API, gửi webhook có chữ ký
+// và có thể trả lỗi theo ý muốn để kiểm tra các nhánh xử lý lỗi.
+package graphtest
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+)
+
+const (
+	GRAPH_TIME_FORMAT  = "2006-01-02T15:04:05-0700"
+	DEFAULT_PAGE_LIMIT = 25
+
+	ERROR_CODE_INVALID_PARAMETER = 100
+)
+
+var versionPrefix = regexp.MustCompile(`^/v[0-9]+\.[0-9]+`)
+
+type User struct {
+	Id          string
+	Name        string
+	AccessToken string
+	Pages       []string
+}
+
+type Page struct {
+	Id          string
+	Name        string
+	Category    string
+	AccessToken string
+	Tasks       []string
+
+	posts         []string
+	conversations []string
+}
+
+type Post struct {
+	Id          string
+	PageId      string
+	Message     string
+	Story       string
+	IsHidden    bool
+	CreatedTime time.Time
+	UpdatedTime time.Time
+
+	comments []string
+}
+
+type Comment struct {
+	Id          string
+	PostId      string
+	ParentId    string // rỗng với comment gốc
+	FromId      string
+	FromName    string
+	Message     string
+	IsHidden    bool
+	IsDeleted   bool
+	Liked       bool
+	CreatedTime time.Time
+
+	replies []string
+}
+
+type Conversation struct {
+	Id          string
+	PageId      string
+	UserId      string
+	UserName    string
+	UpdatedTime time.Time
+
+	messages []string
+}
+
+type Message struct {
+	Id             string
+	ConversationId string
+	FromId         string
+	FromName       string
+	ToId           string
+	ToName         string
+	Text           string
+	CreatedTime    time.Time
+}
+
+// SentMessage là một lần gọi Send API thành công
+type SentMessage struct {
+	PageId    string
+	Request   *facebookgraph.SendRequest
+	MessageId string
+}
+
+// Request là một request server đã nhận, path không có phần version
+type Request struct {
+	Method string
+	Path   string
+	Query  url.Values
+	Token  string
+}
+
+type injectedError struct {
+	method     string
+	path       string
+	times      int
+	statusCode int
+	err        *facebookgraph.FacebookError
+}
+
+type Server struct {
+	*httptest.Server
+
+	AppSecret string
+	AppToken  string
+
+	// WebhookUrl là địa chỉ nhận webhook, ReceiveMessage và ReceiveComment gửi sự kiện tới đây
+	WebhookUrl string
+
+	mu            sync.Mutex
+	seq           int
+	now           func() time.Time
+	users         map[string]*User
+	pages         map[string]*Page
+	posts         map[string]*Post
+	comments      map[string]*Comment
+	conversations map[string]*Conversation
+	messages      map[string]*Message
+	pageScopeIds  map[string]string
+	sent          []*SentMessage
+	requests      []*Request
+	errors        []*injectedError
+	headers       http.Header
+}
+
+// NewServer khởi động server giả lập, appSecret dùng để kiểm tra appsecret_proof và ký webhook.
+// Gọi Close khi dùng xong.
+func NewServer(appSecret string) *Server {
+	s := &Server{
+		AppSecret:     appSecret,
+		AppToken:      "app_token",
+		now:           time.Now,
+		users:         make(map[string]*User),
+		pages:         make(map[string]*Page),
+		posts:         make(map[string]*Post),
+		comments:      make(map[string]*Comment),
+		conversations: make(map[string]*Conversation),
+		messages:      make(map[string]*Message),
+		pageScopeIds:  make(map[string]string),
+		headers:       make(http.Header),
+	}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+// ClientSettings trả về cấu hình để facebookgraph.Client gửi request tới server này
+func (s *Server) ClientSettings() *facebookgraph.ClientSettings {
+	return &facebookgraph.ClientSettings{
+		BaseUrl:   s.URL,
+		Version:   facebookgraph.GRAPH_API_DEFAULT_VERSION,
+		AppSecret: s.AppSecret,
+	}
+}
+
+func (s *Server) nextId(prefix string) string {
+	s.seq++
+	return prefix + strconv.Itoa(s.seq)
+}
+
+func (s *Server) AddUser(id, name, accessToken string) *User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := &User{Id: id, Name: name, AccessToken: accessToken}
+	s.users[accessToken] = user
+	return user
+}
+
+// AddPage thêm page do user quản lý, page chưa có access token sẽ được cấp một token mới
+func (s *Server) AddPage(userToken string, page *Page) *Page {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(page.AccessToken) == 0 {
+		page.AccessToken = s.nextId("page_token_")
+	}
+	if len(page.Category) == 0 {
+		page.Category = "Shopping & Retail"
+	}
+	if page.Tasks == nil {
+		page.Tasks = []string{"ANALYZE", "ADVERTISE", "MODERATE", "CREATE_CONTENT", "MANAGE"}
+	}
+
+	s.pages[page.Id] = page
+	if user, ok := s.users[userToken]; ok {
+		user.Pages = append(user.Pages, page.Id)
+	}
+	return page
+}
+
+// SetPageScopeId khai báo page scoped id của người dùng có app scoped id appScopeId trên page
+func (s *Server) SetPageScopeId(appScopeId, pageId, pageScopeId string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.pageScopeIds[appScopeId+"|"+pageId] = pageScopeId
+}
+
+func (s *Server) AddPost(pageId, message string) *Post {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	post := &Post{
+		Id:          pageId + "_" + s.nextId(""),
+		PageId:      pageId,
+		Message:     message,
+		CreatedTime: now,
+		UpdatedTime: now,
+	}
+	s.posts[post.Id] = post
+	if page, ok := s.pages[pageId]; ok {
+		page.posts = append([]string{post.Id}, page.posts...)
+	}
+	return post
+}
+
+// AddComment thêm comment vào bài viết, parentId rỗng là comment gốc, ngược lại là trả lời comment parentId
+func (s *Server) AddComment(postId, parentId, fromId, fromName, message string) *Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addComment(postId, parentId, fromId, fromName, message)
+}
+
+func (s *Server) addComment(postId, parentId, fromId, fromName, message string) *Comment {
+	objectId := postId
+	if i := strings.Index(postId, "_"); i >= 0 {
+		objectId = postId[i+1:]
+	}
+
+	now := s.now()
+	comment := &Comment{
+		Id:          objectId + "_" + s.nextId(""),
+		PostId:      postId,
+		ParentId:    parentId,
+		FromId:      fromId,
+		FromName:    fromName,
+		Message:     message,
+		CreatedTime: now,
+	}
+	s.comments[comment.Id] = comment
+
+	if parent, ok := s.comments[parentId]; ok {
+		parent.replies = append(parent.replies, comment.Id)
+	} else if post, ok := s.posts[postId]; ok {
+		post.comments = append(post.comments, comment.Id)
+	}
+	if post, ok := s.posts[postId]; ok {
+		post.UpdatedTime = now
+	}
+	return comment
+}
+
+// AddMessage thêm tin nhắn giữa page và người dùng userId, tạo hội thoại nếu chưa có
+func (s *Server) AddMessage(pageId, userId, userName, text string, fromPage bool) *Message {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.addMessage(pageId, userId, userName, text, fromPage)
+}
+
+func (s *Server) addMessage(pageId, userId, userName, text string, fromPage bool) *Message {
+	conversation := s.conversationWith(pageId, userId)
+	if conversation == nil {
+		conversation = &Conversation{Id: s.nextId("t_"), PageId: pageId, UserId: userId, UserName: userName}
+		s.conversations[conversation.Id] = conversation
+	}
+	if len(userName) > 0 {
+		conversation.UserName = userName
+	}
+
+	pageName := ""
+	page := s.pages[pageId]
+	if page != nil {
+		pageName = page.Name
+	}
+
+	message := &Message{
+		Id:             s.nextId("m_"),
+		ConversationId: conversation.Id,
+		Text:           text,
+		CreatedTime:    s.now(),
+	}
+	if fromPage {
+		message.FromId, message.FromName, message.ToId, message.ToName = pageId, pageName, userId, conversation.UserName
+	} else {
+		message.FromId, message.FromName, message.ToId, message.ToName = userId, conversation.UserName, pageId, pageName
+	}
+
+	s.messages[message.Id] = message
+	conversation.messages = append([]string{message.Id}, conversation.messages...)
+	conversation.UpdatedTime = message.CreatedTime
+
+	// hội thoại mới cập nhật được đưa lên đầu giống Graph API
+	if page != nil {
+		conversations := []string{conversation.Id}
+		for _, id := range page.conversations {
+			if id != conversation.Id {
+				conversations = append(conversations, id)
+			}
+		}
+		page.conversations = conversations
+	}
+	return message
+}
+
+func (s *Server) conversationWith(pageId, userId string) *Conversation {
+	for _, conversation := range s.conversations {
+		if conversation.PageId == pageId && conversation.UserId == userId {
+			return conversation
+		}
+	}
+	return nil
+}
+
+func (s *Server) Comment(id string) *Comment {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if comment, ok := s.comments[id]; ok {
+		c := *comment
+		return &c
+	}
+	return nil
+}
+
+// SentMessages trả về các tin nhắn đã gửi qua Send API theo thứ tự gửi
+func (s *Server) SentMessages() []*SentMessage {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*SentMessage{}, s.sent...)
+}
+
+func (s *Server) Requests() []*Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return append([]*Request{}, s.requests...)
+}
+
+// InjectError làm các request khớp method và path (không có version, so khớp phần đầu) trả về lỗi fbErr
+// trong times lần kế tiếp, times <= 0 là trả lỗi mãi. method rỗng khớp mọi method.
+func (s *Server) InjectError(method, path string, times int, statusCode int, fbErr *facebookgraph.FacebookError) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors = append(s.errors, &injectedError{method: method, path: path, times: times, statusCode: statusCode, err: fbErr})
+}
+
+func (s *Server) ClearErrors() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.errors = nil
+}
+
+// SetResponseHeader thêm header vào mọi response, dùng cho X-App-Usage và X-Business-Use-Case-Usage
+func (s *Server) SetResponseHeader(name, value string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(value) == 0 {
+		s.headers.Del(name)
+	} else {
+		s.headers.Set(name, value)
+	}
+}
+
+func NewError(code int, message string) *facebookgraph.FacebookError {
+	return &facebookgraph.FacebookError{
+		Error: facebookgraph.Error{
+			Message:   message,
+			Type:      "OAuthException",
+			Code:      code,
+			FbtraceId: "graphtest",
+		},
+	}
+}
+
+func (s *Server) takeError(method, path string) *injectedError {
+	for i, e := range s.errors {
+		if (len(e.method) > 0 && e.method != method) || !strings.HasPrefix(path, e.path) {
+			continue
+		}
+
+		if e.times > 0 {
+			e.times--
+			if e.times == 0 {
+				s.errors = append(s.errors[:i], s.errors[i+1:]...)
+			}
+		}
+		return e
+	}
+	return nil
+}
+
+func (s *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	path := versionPrefix.ReplaceAllString(r.URL.Path, "")
+	token := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	if len(token) == 0 {
+		token = r.Form.Get("access_token")
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.requests = append(s.requests, &Request{Method: r.Method, Path: path, Query: r.URL.Query(), Token: token})
+
+	for name := range s.headers {
+		w.Header().Set(name, s.headers.Get(name))
+	}
+
+	if e := s.takeError(r.Method, path); e != nil {
+		writeError(w, e.statusCode, e.err)
+		return
+	}
+
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	if path == "/oauth/access_token" {
+		s.extendToken(w, r)
+		return
+	}
+
+	if len(s.AppSecret) > 0 && len(token) > 0 && r.Form.Get("appsecret_proof") != facebookgraph.AppSecretProof(s.AppSecret, token) {
+		writeError(w, http.StatusBadRequest, NewError(ERROR_CODE_INVALID_PARAMETER, "Invalid appsecret_proof provided in the API argument"))
+		return
+	}
+
+	user, page := s.users[token], s.pageByToken(token)
+	if user == nil && page == nil && token != s.AppToken {
+		writeError(w, http.StatusBadRequest, NewError(facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN, "Invalid OAuth access token."))
+		return
+	}
+
+	if segments[0] == "me" && page != nil {
+		segments[0] = page.Id
+	}
+
+	switch {
+	case len(segments) == 2 && segments[0] == "me" && segments[1] == "accounts" && user != nil:
+		s.getAccounts(w, r, user)
+	case len(segments) == 2 && segments[1] == "messages" && r.Method == http.MethodPost && page != nil:
+		s.send(w, r, page)
+	case len(segments) == 2 && segments[1] == "message_attachments" && r.Method == http.MethodPost && page != nil:
+		writeJson(w, map[string]interface{}{"attachment_id": s.nextId("")})
+	case len(segments) == 2 && segments[1] == "ids_for_pages":
+		s.getIdsForPages(w, r, segments[0])
+	case len(segments) == 2 && (segments[1] == "posts" || segments[1] == "feed") && s.pages[segments[0]] != nil:
+		s.getPosts(w, r, s.pages[segments[0]])
+	case len(segments) == 2 && segments[1] == "conversations" && s.pages[segments[0]] != nil:
+		s.getConversations(w, r, s.pages[segments[0]])
+	case len(segments) == 2 && segments[1] == "messages" && s.conversations[segments[0]] != nil:
+		writeJson(w, s.messagesPage(r, s.conversations[segments[0]]))
+	case len(segments) == 2 && segments[1] == "comments":
+		s.handleComments(w, r, segments[0])
+	case len(segments) == 2 && segments[1] == "likes" && s.comments[segments[0]] != nil:
+		s.comments[segments[0]].Liked = r.Method == http.MethodPost
+		writeJson(w, map[string]interface{}{"success": true})
+	case len(segments) == 1:
+		s.handleObject(w, r, segments[0])
+	default:
+		writeError(w, http.StatusBadRequest, unsupportedRequest(r.Method, path))
+	}
+}
+
+func (s *Server) pageByToken(token string) *Page {
+	if len(token) == 0 {
+		return nil
+	}
+	for _, page := range s.pages {
+		if page.AccessToken == token {
+			return page
+		}
+	}
+	return nil
+}
+
+func unsupportedRequest(method, path string) *facebookgraph.FacebookError {
+	return NewError(ERROR_CODE_INVALID_PARAMETER, "Unsupported "+strings.ToLower(method)+" request. Object with ID '"+strings.Trim(path, "/")+"' does not exist")
+}
+
+// token được extend giữ nguyên giá trị để các request sau vẫn dùng được
+func (s *Server) extendToken(w http.ResponseWriter, r *http.Request) {
+	token := r.Form.Get("fb_exchange_token")
+	if _, ok := s.users[token]; !ok {
+		writeError(w, http.StatusBadRequest, NewError(facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN, "Invalid OAuth access token."))
+		return
+	}
+	writeJson(w, map[string]interface{}{"access_token": token, "token_type": "bearer", "expires_in": 5183944})
+}
+
+func (s *Server) getAccounts(w http.ResponseWriter, r *http.Request, user *User) {
+	var items []interface{}
+	for _, id := range user.Pages {
+		items = append(items, pageJson(s.pages[id], true))
+	}
+	writeJson(w, s.paged(r, items))
+}
+
+func (s *Server) getIdsForPages(w http.ResponseWriter, r *http.Request, appScopeId string) {
+	pageId := r.Form.Get("page")
+	data := []interface{}{}
+	if id, ok := s.pageScopeIds[appScopeId+"|"+pageId]; ok {
+		data = append(data, map[string]interface{}{"id": id, "page": map[string]interface{}{"id": pageId, "name": s.pages[pageId].Name}})
+	}
+	writeJson(w, map[string]interface{}{"data": data})
+}
+
+func (s *Server) getPosts(w http.ResponseWriter, r *http.Request, page *Page) {
+	var items []interface{}
+	for _, id := range page.posts {
+		post := s.posts[id]
+		if post.IsHidden && r.Form.Get("include_hidden") != "true" {
+			continue
+		}
+		items = append(items, s.postJson(post))
+	}
+	writeJson(w, s.paged(r, items))
+}
+
+func (s *Server) getConversations(w http.ResponseWriter, r *http.Request, page *Page) {
+	var items []interface{}
+	for _, id := range page.conversations {
+		items = append(items, s.conversationJson(r, s.conversations[id]))
+	}
+	writeJson(w, s.paged(r, items))
+}
+
+func (s *Server) messagesPage(r *http.Request, conversation *Conversation) map[string]interface{} {
+	var items []interface{}
+	for _, id := range conversation.messages {
+		items = append(items, messageJson(s.messages[id]))
+	}
+
+	// lần graph đầu tiên messages nằm trong hội thoại
+	if !strings.HasSuffix(r.URL.Path, "/messages") {
+		return s.nestedPaged(r, conversation.Id, "messages", items, DEFAULT_PAGE_LIMIT)
+	}
+	return s.paged(r, items)
+}
+
+func (s *Server) commentsPage(r *http.Request, objectId string) map[string]interface{} {
+	var ids []string
+	if post, ok := s.posts[objectId]; ok {
+		ids = post.comments
+	} else if comment, ok := s.comments[objectId]; ok {
+		ids = comment.replies
+	}
+
+	var items []interface{}
+	for _, id := range ids {
+		if comment := s.comments[id]; !comment.IsDeleted {
+			items = append(items, s.commentJson(comment))
+		}
+	}
+
+	// limit của request lấy bài viết kèm comments được áp dụng cho comments
+	if !strings.HasSuffix(r.URL.Path, "/comments") {
+		limit, _ := strconv.Atoi(r.Form.Get("limit"))
+		return s.nestedPaged(r, objectId, "comments", items, limit)
+	}
+	return s.paged(r, items)
+}
+
+func (s *Server) handleComments(w http.ResponseWriter, r *http.Request, objectId string) {
+	_, isPost := s.posts[objectId]
+	parent, isComment := s.comments[objectId]
+	if !isPost && !isComment {
+		writeError(w, http.StatusBadRequest, unsupportedRequest(r.Method, objectId))
+		return
+	}
+
+	if r.Method != http.MethodPost {
+		writeJson(w, s.commentsPage(r, objectId))
+		return
+	}
+
+	postId, parentId := objectId, ""
+	if isComment {
+		postId, parentId = parent.PostId, parent.Id
+	}
+	page := s.pages[s.posts[postId].PageId]
+
+	comment := s.addComment(postId, parentId, page.Id, page.Name, r.Form.Get("message"))
+	writeJson(w, map[string]interface{}{"id": comment.Id})
+}
+
+func (s *Server) handleObject(w http.ResponseWriter, r *http.Request, id string) {
+	if comment, ok := s.comments[id]; ok && !comment.IsDeleted {
+		switch r.Method {
+		case http.MethodPost:
+			if hidden := r.Form.Get("is_hidden"); len(hidden) > 0 {
+				comment.IsHidden = hidden == "true"
+			}
+			if message := r.Form.Get("message"); len(message) > 0 {
+				comment.Message = message
+			}
+			writeJson(w, map[string]interface{}{"success": true})
+		case http.MethodDelete:
+			comment.IsDeleted = true
+			writeJson(w, map[string]interface{}{"success": true})
+		default:
+			writeJson(w, s.commentJson(comment))
+		}
+		return
+	}
+
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusBadRequest, unsupportedRequest(r.Method, id))
+		return
+	}
+
+	if page, ok := s.pages[id]; ok {
+		writeJson(w, pageJson(page, true))
+	} else if post, ok := s.posts[id]; ok {
+		item := s.postJson(post)
+		if strings.Contains(r.Form.Get("fields"), "comments") {
+			item["comments"] = s.commentsPage(r, id)
+		}
+		writeJson(w, item)
+	} else if message, ok := s.messages[id]; ok {
+		writeJson(w, messageJson(message))
+	} else if conversation, ok := s.conversations[id]; ok {
+		writeJson(w, s.conversationJson(r, conversation))
+	} else {
+		writeError(w, http.StatusBadRequest, unsupportedRequest(r.Method, id))
+	}
+}
+
+// send xử lý Send API, tin nhắn tới người dùng được thêm vào hội thoại như khi gửi thật
+func (s *Server) send(w http.ResponseWriter, r *http.Request, page *Page) {
+	request := &facebookgraph.SendRequest{
+		MessagingType: r.Form.Get("messaging_type"),
+		Tag:           r.Form.Get("tag"),
+	}
+	json.Unmarshal([]byte(r.Form.Get("recipient")), &request.Recipient)
+	json.Unmarshal([]byte(r.Form.Get("message")), &request.Message)
+
+	if err := request.IsValid(); err != nil {
+		writeError(w, http.StatusBadRequest, NewError(ERROR_CODE_INVALID_PARAMETER, err.Error()))
+		return
+	}
+
+	recipientId := request.Recipient.Id
+	if len(request.Recipient.CommentId) > 0 {
+		comment, ok := s.comments[request.Recipient.CommentId]
+		if !ok {
+			writeError(w, http.StatusBadRequest, unsupportedRequest(r.Method, request.Recipient.CommentId))
+			return
+		}
+		recipientId = comment.FromId
+	}
+
+	text := request.Message.Text
+	if len(text) == 0 && request.Message.Attachment != nil {
+		text = "[" + request.Message.Attachment.Type + "]"
+	}
+
+	message := s.addMessage(page.Id, recipientId, "", text, true)
+	s.sent = append(s.sent, &SentMessage{PageId: page.Id, Request: request, MessageId: message.Id})
+
+	writeJson(w, &facebookgraph.SendResponse{RecipientId: recipientId, MessageId: message.Id})
+}
+
+func (s *Server) paged(r *http.Request, items []interface{}) map[string]interface{} {
+	limit, _ := strconv.Atoi(r.Form.Get("limit"))
+	offset, _ := strconv.Atoi(r.Form.Get("after"))
+	return s.pagedAt(r.URL.Path, r.URL.Query(), items, limit, offset)
+}
+
+// nestedPaged là edge lồng trong object khác (messages của hội thoại, comments của bài viết),
+// link next trỏ tới edge của object đó
+func (s *Server) nestedPaged(r *http.Request, objectId, edge string, items []interface{}, limit int) map[string]interface{} {
+	version := versionPrefix.FindString(r.URL.Path)
+	if len(version) == 0 {
+		version = "/" + facebookgraph.GRAPH_API_DEFAULT_VERSION
+	}
+	return s.pagedAt(version+"/"+objectId+"/"+edge, url.Values{}, items, limit, 0)
+}
+
+// pagedAt cắt items theo limit và offset, link next trỏ về path với query giữ nguyên và after là vị trí kế tiếp
+func (s *Server) pagedAt(path string, query url.Values, items []interface{}, limit int, offset int) map[string]interface{} {
+	if limit <= 0 {
+		limit = DEFAULT_PAGE_LIMIT
+	}
+	if offset < 0 || offset > len(items) {
+		offset = len(items)
+	}
+
+	end := offset + limit
+	if end > len(items) {
+		end = len(items)
+	}
+
+	data := items[offset:end]
+	if data == nil {
+		data = []interface{}{}
+	}
+
+	paging := map[string]interface{}{
+		"cursors": map[string]interface{}{"before": strconv.Itoa(offset), "after": strconv.Itoa(end)},
+	}
+	if end < len(items) {
+		next := url.Values{}
+		for key, values := range query {
+			if key != "appsecret_proof" && key != "access_token" {
+				next[key] = values
+			}
+		}
+		next.Set("limit", strconv.Itoa(limit))
+		next.Set("after", strconv.Itoa(end))
+		paging["next"] = s.URL + path + "?" + next.Encode()
+	}
+
+	return map[string]interface{}{"data": data, "paging": paging}
+}
+
+func pageJson(page *Page, withToken bool) map[string]interface{} {
+	item := map[string]interface{}{
+		"id":            page.Id,
+		"name":          page.Name,
+		"category":      page.Category,
+		"category_list": []interface{}{map[string]interface{}{"id": "2201", "name": page.Category}},
+		"tasks":         page.Tasks,
+	}
+	if withToken {
+		item["access_token"] = page.AccessToken
+	}
+	return item
+}
+
+func (s *Server) postJson(post *Post) map[string]interface{} {
+	from := map[string]interface{}{"id": post.PageId}
+	if page, ok := s.pages[post.PageId]; ok {
+		from["name"] = page.Name
+	}
+
+	return map[string]interface{}{
+		"id":            post.Id,
+		"message":       post.Message,
+		"story":         post.Story,
+		"is_hidden":     post.IsHidden,
+		"from":          from,
+		"created_time":  post.CreatedTime.Format(GRAPH_TIME_FORMAT),
+		"updated_time":  post.UpdatedTime.Format(GRAPH_TIME_FORMAT),
+		"permalink_url": "https://www.facebook.com/" + post.Id,
+	}
+}
+
+func (s *Server) commentJson(comment *Comment) map[string]interface{} {
+	item := map[string]interface{}{
+		"id":                  comment.Id,
+		"message":             comment.Message,
+		"from":                map[string]interface{}{"id": comment.FromId, "name": comment.FromName},
+		"created_time":        comment.CreatedTime.Format(GRAPH_TIME_FORMAT),
+		"is_hidden":           comment.IsHidden,
+		"is_private":          false,
+		"can_comment":         true,
+		"can_hide":            true,
+		"can_like":            true,
+		"can_remove":          true,
+		"can_reply_privately": true,
+		"can_reply":           true,
+		"permalink_url":       "https://www.facebook.com/" + comment.PostId + "?comment_id=" + comment.Id,
+	}
+
+	if parent, ok := s.comments[comment.ParentId]; ok {
+		item["parent"] = map[string]interface{}{
+			"id":           parent.Id,
+			"message":      parent.Message,
+			"created_time": parent.CreatedTime.Format(GRAPH_TIME_FORMAT),
+			"from":         map[string]interface{}{"id": parent.FromId, "name": parent.FromName},
+		}
+	}
+
+	if len(comment.replies) > 0 {
+		var replies []interface{}
+		for _, id := range comment.replies {
+			if reply := s.comments[id]; !reply.IsDeleted {
+				replies = append(replies, s.commentJson(reply))
+			}
+		}
+		item["comments"] = map[string]interface{}{"data": replies, "paging": map[string]interface{}{}}
+	}
+	return item
+}
+
+func (s *Server) conversationJson(r *http.Request, conversation *Conversation) map[string]interface{} {
+	pageName := ""
+	if page, ok := s.pages[conversation.PageId]; ok {
+		pageName = page.Name
+	}
+
+	snippet := ""
+	if len(conversation.messages) > 0 {
+		snippet = s.messages[conversation.messages[0]].Text
+	}
+
+	return map[string]interface{}{
+		"id":           conversation.Id,
+		"can_reply":    true,
+		"snippet":      snippet,
+		"updated_time": conversation.UpdatedTime.Format(GRAPH_TIME_FORMAT),
+		"senders": map[string]interface{}{"data": []interface{}{
+			map[string]interface{}{"id": conversation.UserId, "name": conversation.UserName},
+			map[string]interface{}{"id": conversation.PageId, "name": pageName},
+		}},
+		"messages": s.messagesPage(r, conversation),
+	}
+}
+
+func messageJson(message *Message) map[string]interface{} {
+	return map[string]interface{}{
+		"id":           message.Id,
+		"message":      message.Text,
+		"created_time": message.CreatedTime.Format(GRAPH_TIME_FORMAT),
+		"from":         map[string]interface{}{"id": message.FromId, "name": message.FromName},
+		"to":           map[string]interface{}{"data": []interface{}{map[string]interface{}{"id": message.ToId, "name": message.ToName}}},
+	}
+}
+
+func writeJson(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, statusCode int, err *facebookgraph.FacebookError) {
+	if statusCode == 0 {
+		statusCode = http.StatusBadRequest
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	json.NewEncoder(w).Encode(err)
+}
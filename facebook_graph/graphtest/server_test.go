@@ -0,0 +1,176 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package graphtest
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+)
+
+func setupServer(t *testing.T) (*Server, *facebookgraph.Client, *Page) {
+	s := NewServer("secret")
+	t.Cleanup(s.Close)
+
+	s.AddUser("100", "Admin", "user_token")
+	page := s.AddPage("user_token", &Page{Id: "200", Name: "Papo Shop"})
+
+	settings := s.ClientSettings()
+	client := facebookgraph.NewClient(s.Client(), func() *facebookgraph.ClientSettings { return settings }, nil)
+	return s, client, page
+}
+
+func TestAccounts(t *testing.T) {
+	s, client, page := setupServer(t)
+
+	body, fbErr, err := client.Get("/me/accounts?fields=id,name,access_token", "user_token")
+	require.Nil(t, err)
+	require.Nil(t, fbErr)
+
+	pages := facebookgraph.FacebookPagesFromJson(body)
+	require.Len(t, pages.Data, 1)
+	assert.Equal(t, page.AccessToken, pages.Data[0].AccessToken)
+
+	_, fbErr, _ = client.Get("/me/accounts", "unknown")
+	require.NotNil(t, fbErr)
+	assert.Equal(t, facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN, fbErr.Error.Code)
+
+	// thiếu appsecret_proof
+	resp, err := http.Get(s.URL + "/v3.2/me/accounts?access_token=user_token")
+	require.Nil(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusBadRequest, resp.StatusCode)
+}
+
+func TestPaging(t *testing.T) {
+	s, client, page := setupServer(t)
+
+	for i := 0; i < 5; i++ {
+		s.AddPost(page.Id, "post "+strconv.Itoa(i))
+	}
+
+	var messages []string
+	it := client.Posts(page.AccessToken, "/"+page.Id+"/feed?fields=id,message&limit=2")
+	for {
+		posts, ok := it.Next()
+		if !ok {
+			break
+		}
+		for _, post := range posts.Data {
+			messages = append(messages, post.Message)
+		}
+	}
+
+	fbErr, err := it.Err()
+	require.Nil(t, fbErr)
+	require.Nil(t, err)
+	assert.Equal(t, []string{"post 4", "post 3", "post 2", "post 1", "post 0"}, messages)
+}
+
+func TestConversationsAndSend(t *testing.T) {
+	s, client, page := setupServer(t)
+
+	s.AddMessage(page.Id, "300", "Customer", "xin chào", false)
+
+	body, fbErr, _ := client.Get("/"+page.Id+"/conversations?fields=id,snippet,messages{id,message,from}", page.AccessToken)
+	require.Nil(t, fbErr)
+	conversations := facebookgraph.FacebookConversationsFromJson(body)
+	require.Len(t, conversations.Data, 1)
+	assert.Equal(t, "xin chào", conversations.Data[0].Snippet)
+	require.Len(t, conversations.Data[0].Messages.Data, 1)
+
+	request := facebookgraph.NewSendRequest("300").WithText("cảm ơn bạn")
+	form := url.Values{}
+	form.Set("recipient", request.Recipient.ToJson())
+	form.Set("message", request.Message.ToJson())
+	form.Set("messaging_type", request.MessagingType)
+
+	body, fbErr, _ = client.Do(http.MethodPost, "/me/messages", page.AccessToken, "", []byte(form.Encode()))
+	require.Nil(t, fbErr)
+	response := facebookgraph.SendResponseFromJson(body)
+	assert.Equal(t, "300", response.RecipientId)
+
+	sent := s.SentMessages()
+	require.Len(t, sent, 1)
+	assert.Equal(t, "cảm ơn bạn", sent[0].Request.Message.Text)
+	assert.Equal(t, response.MessageId, sent[0].MessageId)
+}
+
+func TestComments(t *testing.T) {
+	s, client, page := setupServer(t)
+
+	post := s.AddPost(page.Id, "sale")
+	comment := s.AddComment(post.Id, "", "300", "Customer", "giá bao nhiêu?")
+
+	body, fbErr, _ := client.Get("/"+post.Id+"?fields=comments{id,message,from}", page.AccessToken)
+	require.Nil(t, fbErr)
+	response := facebookgraph.FacebookGraphPostCommentsResponseFromJson(body)
+	require.Len(t, response.Comments.Data, 1)
+	assert.Equal(t, comment.Id, response.Comments.Data[0].Id)
+
+	_, fbErr, _ = client.Do(http.MethodPost, "/"+comment.Id+"?is_hidden=true", page.AccessToken, "", nil)
+	require.Nil(t, fbErr)
+	assert.True(t, s.Comment(comment.Id).IsHidden)
+
+	_, fbErr, _ = client.Do(http.MethodDelete, "/"+comment.Id, page.AccessToken, "", nil)
+	require.Nil(t, fbErr)
+	_, fbErr, _ = client.Get("/"+comment.Id, page.AccessToken)
+	require.NotNil(t, fbErr)
+}
+
+func TestInjectError(t *testing.T) {
+	s, client, page := setupServer(t)
+
+	s.InjectError(http.MethodGet, "/"+page.Id+"/conversations", 1, http.StatusInternalServerError, NewError(facebookgraph.ERROR_CODE_SERVICE, "Service temporarily unavailable"))
+
+	_, fbErr, _ := client.Get("/"+page.Id+"/conversations", page.AccessToken)
+	require.NotNil(t, fbErr)
+	assert.True(t, fbErr.IsTransient())
+
+	_, fbErr, _ = client.Get("/"+page.Id+"/conversations", page.AccessToken)
+	require.Nil(t, fbErr)
+	assert.Len(t, s.Requests(), 2)
+
+	s.InjectError("", "/"+page.Id, 0, http.StatusBadRequest, NewError(facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN, "Error validating access token"))
+	for i := 0; i < 2; i++ {
+		_, fbErr, _ = client.Get("/"+page.Id, page.AccessToken)
+		require.NotNil(t, fbErr)
+		assert.Equal(t, facebookgraph.ERROR_CODE_INVALID_OAUTH_TOKEN, fbErr.Error.Code)
+	}
+
+	s.ClearErrors()
+	_, fbErr, _ = client.Get("/"+page.Id, page.AccessToken)
+	assert.Nil(t, fbErr)
+}
+
+func TestWebhook(t *testing.T) {
+	s, _, page := setupServer(t)
+
+	var verified bool
+	hook := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		verified = facebookgraph.VerifyWebhookSignature("secret", body, r.Header.Get(facebookgraph.WEBHOOK_SIGNATURE_HEADER))
+
+		var entries facebookgraph.HubEntries
+		require.Nil(t, json.Unmarshal(body, &entries))
+		require.Len(t, entries.Entry, 1)
+		assert.Equal(t, page.Id, entries.Entry[0].Id)
+		assert.Equal(t, "xin chào", entries.Entry[0].Messaging[0].Message.Text)
+	}))
+	defer hook.Close()
+
+	s.WebhookUrl = hook.URL
+	_, err := s.ReceiveMessage(page.Id, "300", "Customer", "xin chào")
+	require.Nil(t, err)
+	assert.True(t, verified)
+}
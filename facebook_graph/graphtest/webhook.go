@@ -0,0 +1,126 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package graphtest
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+)
+
+// WebhookEvent là body một lần Facebook gọi webhook của page
+type WebhookEvent map[string]interface{}
+
+func pageEvent(entries ...interface{}) WebhookEvent {
+	return WebhookEvent{"object": "page", "entry": entries}
+}
+
+// MessageEvent tạo sự kiện messages (hoặc message_echoes nếu tin nhắn do page gửi) cho tin nhắn
+func (s *Server) MessageEvent(message *Message) WebhookEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	conversation := s.conversations[message.ConversationId]
+	timestamp := message.CreatedTime.UnixNano() / int64(1e6)
+
+	messaging := map[string]interface{}{
+		"sender":    map[string]interface{}{"id": message.FromId},
+		"recipient": map[string]interface{}{"id": message.ToId},
+		"timestamp": timestamp,
+		"message": map[string]interface{}{
+			"mid":     message.Id,
+			"text":    message.Text,
+			"is_echo": message.FromId == conversation.PageId,
+		},
+	}
+
+	return pageEvent(map[string]interface{}{
+		"id":        conversation.PageId,
+		"time":      timestamp,
+		"messaging": []interface{}{messaging},
+	})
+}
+
+// CommentEvent tạo sự kiện feed cho comment, verb là add, edited, remove, hide hoặc unhide
+func (s *Server) CommentEvent(comment *Comment, verb string) WebhookEvent {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	post := s.posts[comment.PostId]
+	parentId := comment.ParentId
+	if len(parentId) == 0 {
+		parentId = comment.PostId
+	}
+
+	value := map[string]interface{}{
+		"from":         map[string]interface{}{"id": comment.FromId, "name": comment.FromName},
+		"item":         "comment",
+		"verb":         verb,
+		"post_id":      comment.PostId,
+		"comment_id":   comment.Id,
+		"parent_id":    parentId,
+		"message":      comment.Message,
+		"created_time": comment.CreatedTime.Unix(),
+		"is_hidden":    comment.IsHidden,
+		"post": map[string]interface{}{
+			"id":           comment.PostId,
+			"status_type":  "mobile_status_update",
+			"is_published": true,
+			"updated_time": post.UpdatedTime.Format(GRAPH_TIME_FORMAT),
+		},
+	}
+
+	return pageEvent(map[string]interface{}{
+		"id":      post.PageId,
+		"time":    s.now().Unix(),
+		"changes": []interface{}{map[string]interface{}{"field": "feed", "value": value}},
+	})
+}
+
+// Deliver gửi sự kiện tới url kèm chữ ký X-Hub-Signature-256 tạo từ AppSecret như Facebook
+func (s *Server) Deliver(url string, event WebhookEvent) (*http.Response, error) {
+	if len(url) == 0 {
+		return nil, errors.New("graphtest: missing webhook url")
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(facebookgraph.WEBHOOK_SIGNATURE_HEADER, facebookgraph.SignWebhookPayload(s.AppSecret, body))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return resp, errors.New("graphtest: webhook returned " + resp.Status)
+	}
+	return resp, nil
+}
+
+// ReceiveMessage giả lập người dùng nhắn tin tới page: thêm tin nhắn rồi gửi webhook tới WebhookUrl
+func (s *Server) ReceiveMessage(pageId, userId, userName, text string) (*Message, error) {
+	message := s.AddMessage(pageId, userId, userName, text, false)
+	_, err := s.Deliver(s.WebhookUrl, s.MessageEvent(message))
+	return message, err
+}
+
+// ReceiveComment giả lập người dùng comment vào bài viết: thêm comment rồi gửi webhook tới WebhookUrl
+func (s *Server) ReceiveComment(postId, parentId, fromId, fromName, message string) (*Comment, error) {
+	comment := s.AddComment(postId, parentId, fromId, fromName, message)
+	_, err := s.Deliver(s.WebhookUrl, s.CommentEvent(comment, "add"))
+	return comment, err
+}
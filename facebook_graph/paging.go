@@ -3,6 +3,8 @@
 
 package facebookgraph
 
+import "encoding/json"
+
 type FacebookCursors struct {
 	Before string `json:"before"`
 	After  string `json:"after"`
@@ -12,4 +14,103 @@ type FacebookPaging struct {
 	Cursors  FacebookCursors `json:"cursors"`
 	Next     string          `json:"next"`
 	Previous string          `json:"previous"`
-}
\ No newline at end of file
+}
+
+// Paged là kết quả một trang của Graph API có paging
+type Paged interface {
+	GetPaging() FacebookPaging
+}
+
+func (p *FacebookPages) GetPaging() FacebookPaging                { return p.Paging }
+func (p *FacebookGraphPosts) GetPaging() FacebookPaging           { return p.Paging }
+func (p *FacebookConversations) GetPaging() FacebookPaging        { return p.Paging }
+func (p *FacebookConversationMessages) GetPaging() FacebookPaging { return p.Paging }
+
+// PageIterator lần lượt tải các trang kết quả theo link paging.next. Cursor là link trang kế tiếp,
+// có thể lưu lại để chạy tiếp bằng NewPageIterator(cursor) sau đó.
+type PageIterator struct {
+	client      *Client
+	accessToken string
+	next        string
+	fbErr       *FacebookError
+	err         error
+}
+
+func (c *Client) NewPageIterator(accessToken string, path string) *PageIterator {
+	return &PageIterator{client: c, accessToken: accessToken, next: path}
+}
+
+// Next tải trang tiếp theo vào page, trả về false khi đã hết trang hoặc gặp lỗi
+func (it *PageIterator) Next(page Paged) bool {
+	if len(it.next) == 0 || it.fbErr != nil || it.err != nil {
+		return false
+	}
+
+	body, fbErr, err := it.client.Get(it.next, it.accessToken)
+	if fbErr != nil || err != nil {
+		it.fbErr, it.err = fbErr, err
+		return false
+	}
+	defer body.Close()
+
+	if err := json.NewDecoder(body).Decode(page); err != nil {
+		it.err = err
+		return false
+	}
+
+	it.next = page.GetPaging().Next
+	return true
+}
+
+func (it *PageIterator) Cursor() string {
+	return it.next
+}
+
+// Err trả về lỗi làm iterator dừng lại, cả hai đều nil nếu dừng vì đã hết trang
+func (it *PageIterator) Err() (*FacebookError, error) {
+	return it.fbErr, it.err
+}
+
+type AccountIterator struct{ *PageIterator }
+
+func (c *Client) Accounts(accessToken string, path string) *AccountIterator {
+	return &AccountIterator{c.NewPageIterator(accessToken, path)}
+}
+
+func (it *AccountIterator) Next() (*FacebookPages, bool) {
+	var page FacebookPages
+	return &page, it.PageIterator.Next(&page)
+}
+
+type PostIterator struct{ *PageIterator }
+
+func (c *Client) Posts(accessToken string, path string) *PostIterator {
+	return &PostIterator{c.NewPageIterator(accessToken, path)}
+}
+
+func (it *PostIterator) Next() (*FacebookGraphPosts, bool) {
+	var page FacebookGraphPosts
+	return &page, it.PageIterator.Next(&page)
+}
+
+type ConversationIterator struct{ *PageIterator }
+
+func (c *Client) Conversations(accessToken string, path string) *ConversationIterator {
+	return &ConversationIterator{c.NewPageIterator(accessToken, path)}
+}
+
+func (it *ConversationIterator) Next() (*FacebookConversations, bool) {
+	var page FacebookConversations
+	return &page, it.PageIterator.Next(&page)
+}
+
+type MessageIterator struct{ *PageIterator }
+
+func (c *Client) Messages(accessToken string, path string) *MessageIterator {
+	return &MessageIterator{c.NewPageIterator(accessToken, path)}
+}
+
+func (it *MessageIterator) Next() (*FacebookConversationMessages, bool) {
+	var page FacebookConversationMessages
+	return &page, it.PageIterator.Next(&page)
+}
@@ -0,0 +1,435 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"unicode/utf8"
+)
+
+// https://developers.facebook.com/docs/messenger-platform/reference/send-api/
+const (
+	MESSAGING_TYPE_RESPONSE    = "RESPONSE"
+	MESSAGING_TYPE_UPDATE      = "UPDATE"
+	MESSAGING_TYPE_MESSAGE_TAG = "MESSAGE_TAG"
+
+	SEND_ATTACHMENT_TYPE_IMAGE    = "image"
+	SEND_ATTACHMENT_TYPE_VIDEO    = "video"
+	SEND_ATTACHMENT_TYPE_AUDIO    = "audio"
+	SEND_ATTACHMENT_TYPE_FILE     = "file"
+	SEND_ATTACHMENT_TYPE_TEMPLATE = "template"
+
+	TEMPLATE_TYPE_GENERIC = "generic"
+	TEMPLATE_TYPE_BUTTON  = "button"
+
+	BUTTON_TYPE_WEB_URL      = "web_url"
+	BUTTON_TYPE_POSTBACK     = "postback"
+	BUTTON_TYPE_PHONE_NUMBER = "phone_number"
+
+	QUICK_REPLY_CONTENT_TYPE_TEXT         = "text"
+	QUICK_REPLY_CONTENT_TYPE_PHONE_NUMBER = "user_phone_number"
+	QUICK_REPLY_CONTENT_TYPE_EMAIL        = "user_email"
+
+	SEND_TEXT_MAX_LENGTH                 = 2000
+	SEND_QUICK_REPLIES_MAX               = 13
+	SEND_QUICK_REPLY_TITLE_MAX_LENGTH    = 20
+	SEND_QUICK_REPLY_PAYLOAD_MAX_LENGTH  = 1000
+	SEND_BUTTONS_MAX                     = 3
+	SEND_BUTTON_TITLE_MAX_LENGTH         = 20
+	SEND_BUTTON_PAYLOAD_MAX_LENGTH       = 1000
+	SEND_BUTTON_TEMPLATE_TEXT_MAX_LENGTH = 640
+	SEND_GENERIC_ELEMENTS_MAX            = 10
+	SEND_GENERIC_TITLE_MAX_LENGTH        = 80
+	SEND_GENERIC_SUBTITLE_MAX_LENGTH     = 80
+	SEND_METADATA_MAX_LENGTH             = 1000
+)
+
+// SendRecipient là người nhận tin nhắn: page scoped id, hoặc comment_id khi gửi private reply cho một bình luận
+type SendRecipient struct {
+	Id        string `json:"id,omitempty"`
+	CommentId string `json:"comment_id,omitempty"`
+}
+
+type QuickReply struct {
+	ContentType string `json:"content_type"`
+	Title       string `json:"title,omitempty"`
+	Payload     string `json:"payload,omitempty"`
+	ImageUrl    string `json:"image_url,omitempty"`
+}
+
+type TemplateButton struct {
+	Type    string `json:"type"`
+	Title   string `json:"title"`
+	Url     string `json:"url,omitempty"`
+	Payload string `json:"payload,omitempty"`
+}
+
+type TemplateDefaultAction struct {
+	Type string `json:"type"`
+	Url  string `json:"url"`
+}
+
+type TemplateElement struct {
+	Title         string                 `json:"title"`
+	Subtitle      string                 `json:"subtitle,omitempty"`
+	ImageUrl      string                 `json:"image_url,omitempty"`
+	DefaultAction *TemplateDefaultAction `json:"default_action,omitempty"`
+	Buttons       []*TemplateButton      `json:"buttons,omitempty"`
+}
+
+// SendAttachmentPayload dùng chung cho attachment media (url, attachment_id) và template (template_type, elements, buttons)
+type SendAttachmentPayload struct {
+	Url          string             `json:"url,omitempty"`
+	IsReusable   bool               `json:"is_reusable,omitempty"`
+	AttachmentId string             `json:"attachment_id,omitempty"`
+	TemplateType string             `json:"template_type,omitempty"`
+	Text         string             `json:"text,omitempty"`
+	Elements     []*TemplateElement `json:"elements,omitempty"`
+	Buttons      []*TemplateButton  `json:"buttons,omitempty"`
+}
+
+type SendAttachment struct {
+	Type    string                 `json:"type"`
+	Payload *SendAttachmentPayload `json:"payload"`
+}
+
+type SendMessage struct {
+	Text         string          `json:"text,omitempty"`
+	Attachment   *SendAttachment `json:"attachment,omitempty"`
+	QuickReplies []*QuickReply   `json:"quick_replies,omitempty"`
+	Metadata     string          `json:"metadata,omitempty"`
+}
+
+type SendRequest struct {
+	MessagingType string         `json:"messaging_type,omitempty"`
+	Tag           string         `json:"tag,omitempty"`
+	Recipient     *SendRecipient `json:"recipient"`
+	Message       *SendMessage   `json:"message"`
+}
+
+type SendResponse struct {
+	RecipientId string `json:"recipient_id"`
+	MessageId   string `json:"message_id"`
+}
+
+type AttachmentUploadResponse struct {
+	AttachmentId string `json:"attachment_id"`
+}
+
+// NewSendRequest tạo request gửi tin nhắn trả lời tới người dùng có page scope id psId
+func NewSendRequest(psId string) *SendRequest {
+	return &SendRequest{
+		MessagingType: MESSAGING_TYPE_RESPONSE,
+		Recipient:     &SendRecipient{Id: psId},
+		Message:       &SendMessage{},
+	}
+}
+
+// NewPrivateReplyRequest tạo tin nhắn trả lời riêng cho người viết bình luận, mỗi bình luận chỉ được trả lời riêng một lần
+func NewPrivateReplyRequest(commentId string) *SendRequest {
+	return &SendRequest{
+		MessagingType: MESSAGING_TYPE_RESPONSE,
+		Recipient:     &SendRecipient{CommentId: commentId},
+		Message:       &SendMessage{},
+	}
+}
+
+func (r *SendRequest) WithText(text string) *SendRequest {
+	r.Message.Text = text
+	return r
+}
+
+func (r *SendRequest) WithMetadata(metadata string) *SendRequest {
+	r.Message.Metadata = metadata
+	return r
+}
+
+// WithTag chuyển request sang MESSAGE_TAG, dùng khi gửi ngoài cửa sổ 24h
+func (r *SendRequest) WithTag(tag string) *SendRequest {
+	r.Tag = tag
+	if len(tag) > 0 {
+		r.MessagingType = MESSAGING_TYPE_MESSAGE_TAG
+	}
+	return r
+}
+
+func (r *SendRequest) WithAttachmentUrl(attachmentType, url string) *SendRequest {
+	r.Message.Attachment = &SendAttachment{
+		Type:    attachmentType,
+		Payload: &SendAttachmentPayload{Url: url},
+	}
+	return r
+}
+
+// WithAttachmentId gửi lại attachment đã upload lên page qua /me/message_attachments
+func (r *SendRequest) WithAttachmentId(attachmentType, attachmentId string) *SendRequest {
+	r.Message.Attachment = &SendAttachment{
+		Type:    attachmentType,
+		Payload: &SendAttachmentPayload{AttachmentId: attachmentId},
+	}
+	return r
+}
+
+func (r *SendRequest) WithQuickReplies(quickReplies ...*QuickReply) *SendRequest {
+	r.Message.QuickReplies = append(r.Message.QuickReplies, quickReplies...)
+	return r
+}
+
+func (r *SendRequest) WithGenericTemplate(elements ...*TemplateElement) *SendRequest {
+	r.Message.Attachment = &SendAttachment{
+		Type: SEND_ATTACHMENT_TYPE_TEMPLATE,
+		Payload: &SendAttachmentPayload{
+			TemplateType: TEMPLATE_TYPE_GENERIC,
+			Elements:     elements,
+		},
+	}
+	return r
+}
+
+func (r *SendRequest) WithButtonTemplate(text string, buttons ...*TemplateButton) *SendRequest {
+	r.Message.Attachment = &SendAttachment{
+		Type: SEND_ATTACHMENT_TYPE_TEMPLATE,
+		Payload: &SendAttachmentPayload{
+			TemplateType: TEMPLATE_TYPE_BUTTON,
+			Text:         text,
+			Buttons:      buttons,
+		},
+	}
+	return r
+}
+
+func (r *SendRequest) IsValid() error {
+	if r.Recipient == nil || len(r.Recipient.Id) == 0 && len(r.Recipient.CommentId) == 0 {
+		return errors.New("recipient is required")
+	}
+
+	switch r.MessagingType {
+	case MESSAGING_TYPE_RESPONSE, MESSAGING_TYPE_UPDATE:
+	case MESSAGING_TYPE_MESSAGE_TAG:
+		if len(r.Tag) == 0 {
+			return errors.New("tag is required for MESSAGE_TAG messaging type")
+		}
+	default:
+		return fmt.Errorf("invalid messaging type %q", r.MessagingType)
+	}
+
+	if r.Message == nil {
+		return errors.New("message is required")
+	}
+
+	return r.Message.IsValid()
+}
+
+// IsValid kiểm tra message theo giới hạn của Send API trước khi gửi, tránh để facebook trả lỗi sau khi đã vào outbox
+func (m *SendMessage) IsValid() error {
+	hasText := len(m.Text) > 0
+	hasAttachment := m.Attachment != nil
+
+	if !hasText && !hasAttachment {
+		return errors.New("message must have text or attachment")
+	}
+
+	if hasText && hasAttachment {
+		return errors.New("message can not have both text and attachment")
+	}
+
+	if utf8.RuneCountInString(m.Text) > SEND_TEXT_MAX_LENGTH {
+		return fmt.Errorf("text must be at most %v characters", SEND_TEXT_MAX_LENGTH)
+	}
+
+	if len(m.Metadata) > SEND_METADATA_MAX_LENGTH {
+		return fmt.Errorf("metadata must be at most %v characters", SEND_METADATA_MAX_LENGTH)
+	}
+
+	if hasAttachment {
+		if err := m.Attachment.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	if len(m.QuickReplies) > SEND_QUICK_REPLIES_MAX {
+		return fmt.Errorf("message can have at most %v quick replies", SEND_QUICK_REPLIES_MAX)
+	}
+
+	for _, quickReply := range m.QuickReplies {
+		if err := quickReply.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (a *SendAttachment) IsValid() error {
+	if a.Payload == nil {
+		return errors.New("attachment payload is required")
+	}
+
+	switch a.Type {
+	case SEND_ATTACHMENT_TYPE_IMAGE, SEND_ATTACHMENT_TYPE_VIDEO, SEND_ATTACHMENT_TYPE_AUDIO, SEND_ATTACHMENT_TYPE_FILE:
+		if len(a.Payload.Url) == 0 && len(a.Payload.AttachmentId) == 0 {
+			return errors.New("attachment must have url or attachment_id")
+		}
+		if len(a.Payload.Url) > 0 && len(a.Payload.AttachmentId) > 0 {
+			return errors.New("attachment can not have both url and attachment_id")
+		}
+		return nil
+	case SEND_ATTACHMENT_TYPE_TEMPLATE:
+		return a.Payload.isValidTemplate()
+	}
+
+	return fmt.Errorf("invalid attachment type %q", a.Type)
+}
+
+func (p *SendAttachmentPayload) isValidTemplate() error {
+	switch p.TemplateType {
+	case TEMPLATE_TYPE_GENERIC:
+		if len(p.Elements) == 0 {
+			return errors.New("generic template must have at least one element")
+		}
+		if len(p.Elements) > SEND_GENERIC_ELEMENTS_MAX {
+			return fmt.Errorf("generic template can have at most %v elements", SEND_GENERIC_ELEMENTS_MAX)
+		}
+		for _, element := range p.Elements {
+			if err := element.IsValid(); err != nil {
+				return err
+			}
+		}
+		return nil
+	case TEMPLATE_TYPE_BUTTON:
+		if len(p.Text) == 0 {
+			return errors.New("button template text is required")
+		}
+		if utf8.RuneCountInString(p.Text) > SEND_BUTTON_TEMPLATE_TEXT_MAX_LENGTH {
+			return fmt.Errorf("button template text must be at most %v characters", SEND_BUTTON_TEMPLATE_TEXT_MAX_LENGTH)
+		}
+		if len(p.Buttons) == 0 {
+			return errors.New("button template must have at least one button")
+		}
+		return isValidButtons(p.Buttons)
+	}
+
+	return fmt.Errorf("invalid template type %q", p.TemplateType)
+}
+
+func (e *TemplateElement) IsValid() error {
+	if len(e.Title) == 0 {
+		return errors.New("template element title is required")
+	}
+	if utf8.RuneCountInString(e.Title) > SEND_GENERIC_TITLE_MAX_LENGTH {
+		return fmt.Errorf("template element title must be at most %v characters", SEND_GENERIC_TITLE_MAX_LENGTH)
+	}
+	if utf8.RuneCountInString(e.Subtitle) > SEND_GENERIC_SUBTITLE_MAX_LENGTH {
+		return fmt.Errorf("template element subtitle must be at most %v characters", SEND_GENERIC_SUBTITLE_MAX_LENGTH)
+	}
+	if e.DefaultAction != nil && (e.DefaultAction.Type != BUTTON_TYPE_WEB_URL || len(e.DefaultAction.Url) == 0) {
+		return errors.New("template element default action must be a web_url with url")
+	}
+	return isValidButtons(e.Buttons)
+}
+
+func isValidButtons(buttons []*TemplateButton) error {
+	if len(buttons) > SEND_BUTTONS_MAX {
+		return fmt.Errorf("template can have at most %v buttons", SEND_BUTTONS_MAX)
+	}
+
+	for _, button := range buttons {
+		if err := button.IsValid(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (b *TemplateButton) IsValid() error {
+	if len(b.Title) == 0 {
+		return errors.New("button title is required")
+	}
+	if utf8.RuneCountInString(b.Title) > SEND_BUTTON_TITLE_MAX_LENGTH {
+		return fmt.Errorf("button title must be at most %v characters", SEND_BUTTON_TITLE_MAX_LENGTH)
+	}
+
+	switch b.Type {
+	case BUTTON_TYPE_WEB_URL:
+		if len(b.Url) == 0 {
+			return errors.New("web_url button must have url")
+		}
+		return nil
+	case BUTTON_TYPE_POSTBACK, BUTTON_TYPE_PHONE_NUMBER:
+		if len(b.Payload) == 0 {
+			return fmt.Errorf("%v button must have payload", b.Type)
+		}
+		if len(b.Payload) > SEND_BUTTON_PAYLOAD_MAX_LENGTH {
+			return fmt.Errorf("button payload must be at most %v characters", SEND_BUTTON_PAYLOAD_MAX_LENGTH)
+		}
+		return nil
+	}
+
+	return fmt.Errorf("invalid button type %q", b.Type)
+}
+
+func (q *QuickReply) IsValid() error {
+	switch q.ContentType {
+	case QUICK_REPLY_CONTENT_TYPE_TEXT:
+		if len(q.Title) == 0 && len(q.ImageUrl) == 0 {
+			return errors.New("text quick reply must have title or image_url")
+		}
+		if utf8.RuneCountInString(q.Title) > SEND_QUICK_REPLY_TITLE_MAX_LENGTH {
+			return fmt.Errorf("quick reply title must be at most %v characters", SEND_QUICK_REPLY_TITLE_MAX_LENGTH)
+		}
+		if len(q.Payload) == 0 {
+			return errors.New("text quick reply must have payload")
+		}
+		if len(q.Payload) > SEND_QUICK_REPLY_PAYLOAD_MAX_LENGTH {
+			return fmt.Errorf("quick reply payload must be at most %v characters", SEND_QUICK_REPLY_PAYLOAD_MAX_LENGTH)
+		}
+		return nil
+	case QUICK_REPLY_CONTENT_TYPE_PHONE_NUMBER, QUICK_REPLY_CONTENT_TYPE_EMAIL:
+		return nil
+	}
+
+	return fmt.Errorf("invalid quick reply content type %q", q.ContentType)
+}
+
+// NewAttachmentUploadMessage tạo message dùng cho /me/message_attachments, attachment được đánh dấu is_reusable
+// để lấy attachment_id dùng lại cho các lần gửi sau
+func NewAttachmentUploadMessage(attachmentType, url string) *SendMessage {
+	return &SendMessage{
+		Attachment: &SendAttachment{
+			Type:    attachmentType,
+			Payload: &SendAttachmentPayload{Url: url, IsReusable: true},
+		},
+	}
+}
+
+func (p *SendRecipient) ToJson() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func (p *SendMessage) ToJson() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func (p *SendRequest) ToJson() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
+func SendResponseFromJson(data io.Reader) *SendResponse {
+	var o *SendResponse
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func AttachmentUploadResponseFromJson(data io.Reader) *AttachmentUploadResponse {
+	var o *AttachmentUploadResponse
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
@@ -0,0 +1,111 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSendRequestIsValid(t *testing.T) {
+	t.Run("text with quick replies", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello").WithQuickReplies(
+			&QuickReply{ContentType: QUICK_REPLY_CONTENT_TYPE_TEXT, Title: "Yes", Payload: "YES"},
+			&QuickReply{ContentType: QUICK_REPLY_CONTENT_TYPE_PHONE_NUMBER},
+		)
+		assert.Nil(t, request.IsValid())
+		assert.Equal(t, MESSAGING_TYPE_RESPONSE, request.MessagingType)
+	})
+
+	t.Run("tag switches messaging type", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello").WithTag("HUMAN_AGENT")
+		assert.Nil(t, request.IsValid())
+		assert.Equal(t, MESSAGING_TYPE_MESSAGE_TAG, request.MessagingType)
+	})
+
+	t.Run("messaging type", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello")
+		request.MessagingType = MESSAGING_TYPE_MESSAGE_TAG
+		assert.NotNil(t, request.IsValid())
+
+		request.MessagingType = "OTHER"
+		assert.NotNil(t, request.IsValid())
+	})
+
+	t.Run("missing recipient", func(t *testing.T) {
+		assert.NotNil(t, NewSendRequest("").WithText("hello").IsValid())
+		assert.Nil(t, NewPrivateReplyRequest("123_456").WithText("hello").IsValid())
+	})
+
+	t.Run("empty message", func(t *testing.T) {
+		assert.NotNil(t, NewSendRequest("psid").IsValid())
+	})
+
+	t.Run("text and attachment", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello").WithAttachmentId(SEND_ATTACHMENT_TYPE_IMAGE, "123")
+		assert.NotNil(t, request.IsValid())
+	})
+
+	t.Run("text too long", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText(strings.Repeat("a", SEND_TEXT_MAX_LENGTH+1))
+		assert.NotNil(t, request.IsValid())
+	})
+
+	t.Run("attachment", func(t *testing.T) {
+		assert.Nil(t, NewSendRequest("psid").WithAttachmentUrl(SEND_ATTACHMENT_TYPE_FILE, "https://example.com/a.pdf").IsValid())
+		assert.Nil(t, NewSendRequest("psid").WithAttachmentId(SEND_ATTACHMENT_TYPE_IMAGE, "123").IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithAttachmentUrl("sticker", "https://example.com/a.png").IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithAttachmentUrl(SEND_ATTACHMENT_TYPE_IMAGE, "").IsValid())
+	})
+
+	t.Run("too many quick replies", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello")
+		for i := 0; i <= SEND_QUICK_REPLIES_MAX; i++ {
+			request.WithQuickReplies(&QuickReply{ContentType: QUICK_REPLY_CONTENT_TYPE_TEXT, Title: "Yes", Payload: "YES"})
+		}
+		assert.NotNil(t, request.IsValid())
+	})
+
+	t.Run("quick reply without payload", func(t *testing.T) {
+		request := NewSendRequest("psid").WithText("hello").WithQuickReplies(&QuickReply{ContentType: QUICK_REPLY_CONTENT_TYPE_TEXT, Title: "Yes"})
+		assert.NotNil(t, request.IsValid())
+	})
+
+	t.Run("generic template", func(t *testing.T) {
+		element := &TemplateElement{
+			Title:    "Áo thun",
+			Subtitle: "150.000đ",
+			ImageUrl: "https://example.com/a.png",
+			Buttons: []*TemplateButton{
+				{Type: BUTTON_TYPE_POSTBACK, Title: "Đặt hàng", Payload: "ORDER"},
+				{Type: BUTTON_TYPE_WEB_URL, Title: "Xem", Url: "https://example.com"},
+			},
+		}
+		assert.Nil(t, NewSendRequest("psid").WithGenericTemplate(element).IsValid())
+
+		element.Buttons = append(element.Buttons, element.Buttons[0], element.Buttons[0])
+		assert.NotNil(t, NewSendRequest("psid").WithGenericTemplate(element).IsValid())
+
+		assert.NotNil(t, NewSendRequest("psid").WithGenericTemplate().IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithGenericTemplate(&TemplateElement{}).IsValid())
+	})
+
+	t.Run("button template", func(t *testing.T) {
+		button := &TemplateButton{Type: BUTTON_TYPE_PHONE_NUMBER, Title: "Gọi", Payload: "+84900000000"}
+		assert.Nil(t, NewSendRequest("psid").WithButtonTemplate("Liên hệ", button).IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithButtonTemplate("", button).IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithButtonTemplate("Liên hệ").IsValid())
+		assert.NotNil(t, NewSendRequest("psid").WithButtonTemplate("Liên hệ", &TemplateButton{Type: BUTTON_TYPE_WEB_URL, Title: "Xem"}).IsValid())
+	})
+}
+
+func TestNewAttachmentUploadMessage(t *testing.T) {
+	message := NewAttachmentUploadMessage(SEND_ATTACHMENT_TYPE_IMAGE, "https://example.com/a.png")
+	require.NotNil(t, message.Attachment)
+	assert.True(t, message.Attachment.Payload.IsReusable)
+	assert.Equal(t, `{"attachment":{"type":"image","payload":{"url":"https://example.com/a.png","is_reusable":true}}}`, message.ToJson())
+}
@@ -0,0 +1,39 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+)
+
+const (
+	WEBHOOK_SIGNATURE_HEADER = "X-Hub-Signature-256"
+	WEBHOOK_SIGNATURE_PREFIX = "sha256="
+)
+
+// SignWebhookPayload trả về giá trị header X-Hub-Signature-256 cho body, giống cách Facebook ký webhook.
+func SignWebhookPayload(appSecret string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return WEBHOOK_SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyWebhookSignature kiểm tra header X-Hub-Signature-256 với HMAC-SHA256 của body thô.
+func VerifyWebhookSignature(appSecret string, body []byte, signature string) bool {
+	if len(appSecret) == 0 || !strings.HasPrefix(signature, WEBHOOK_SIGNATURE_PREFIX) {
+		return false
+	}
+
+	expected, err := hex.DecodeString(strings.TrimPrefix(signature, WEBHOOK_SIGNATURE_PREFIX))
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(appSecret))
+	mac.Write(body)
+	return hmac.Equal(mac.Sum(nil), expected)
+}
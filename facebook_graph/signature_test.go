@@ -0,0 +1,22 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package facebookgraph
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerifyWebhookSignature(t *testing.T) {
+	body := []byte(`{"object":"page","entry":[]}`)
+	signature := SignWebhookPayload("secret", body)
+
+	assert.True(t, VerifyWebhookSignature("secret", body, signature))
+	assert.False(t, VerifyWebhookSignature("other", body, signature))
+	assert.False(t, VerifyWebhookSignature("secret", []byte(`{"object":"page"}`), signature))
+	assert.False(t, VerifyWebhookSignature("secret", body, ""))
+	assert.False(t, VerifyWebhookSignature("secret", body, "sha256=zz"))
+	assert.False(t, VerifyWebhookSignature("", body, SignWebhookPayload("", body)))
+}
@@ -22,9 +22,13 @@ type HubEntryMessaging struct {
 	Recipient 	MessagingRecipient 					`json:"recipient"`
 	Message   	MessagingMessage   					`json:"message"`
 	Timestamp 	int64 			 					`json:"timestamp"`
-	PostBack  	MessagingMessageAttachment    		`json:"post_back"`
+	PostBack  	*MessagingPostback    				`json:"postback"`
 	Delivery   	MessageAction 						`json:"delivery"`
 	Read		MessageAction						`json:"read"`
+	Reaction 	*MessagingReaction 					`json:"reaction"`
+	Referral 	*MessagingReferral 					`json:"referral"`
+	Optin 		*MessagingOptin 					`json:"optin"`
+	PolicyEnforcement *MessagingPolicyEnforcement 	`json:"policy-enforcement"`
 }
 
 type MessagingMessage struct {
@@ -35,7 +39,52 @@ type MessagingMessage struct {
 	StickerId   int64                          		`json:"sticker_id"`
 	Attachments []MessagingMessageAttachment 		`json:"attachments"`
 	IsEcho 	  	bool 				 				`json:"is_echo"`
-	QuickReply  MessagingMessageAttachment  		`json:"quick_reply"`
+	QuickReply  *MessagingQuickReply  				`json:"quick_reply"`
+	Metadata    string                       		`json:"metadata"` // chỉ có ở echo, là metadata gửi kèm khi gọi Send API
+	AppId       int64                        		`json:"app_id"`   // chỉ có ở echo
+}
+
+// Người dùng bấm vào nút postback, Get Started hoặc persistent menu
+type MessagingPostback struct {
+	Mid      string             `json:"mid"`
+	Title    string             `json:"title"`
+	Payload  string             `json:"payload"`
+	Referral *MessagingReferral `json:"referral"`
+}
+
+type MessagingQuickReply struct {
+	Payload string `json:"payload"`
+}
+
+// Người dùng thả hoặc gỡ cảm xúc trên một tin nhắn
+type MessagingReaction struct {
+	Mid      string `json:"mid"`
+	Action   string `json:"action"` // react hoặc unreact
+	Reaction string `json:"reaction"`
+	Emoji    string `json:"emoji"`
+}
+
+// Người dùng vào hội thoại qua m.me link, quảng cáo, messenger code hoặc chat plugin
+type MessagingReferral struct {
+	Ref        string `json:"ref"`
+	Source     string `json:"source"`
+	Type       string `json:"type"`
+	AdId       string `json:"ad_id"`
+	RefererUri string `json:"referer_uri"`
+}
+
+type MessagingOptin struct {
+	Ref               string `json:"ref"`
+	UserRef           string `json:"user_ref"`
+	Type              string `json:"type"`
+	Payload           string `json:"payload"`
+	OneTimeNotifToken string `json:"one_time_notif_token"`
+}
+
+// Facebook cảnh báo, chặn hoặc bỏ chặn page vì vi phạm chính sách
+type MessagingPolicyEnforcement struct {
+	Action string `json:"action"` // warning, block hoặc unblock
+	Reason string `json:"reason"`
 }
 
 type MessageAction struct {
@@ -0,0 +1,167 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package auto_message_task
+
+import (
+	"context"
+	"time"
+
+	"bitbucket.org/enesyteam/papo-server/app"
+	"bitbucket.org/enesyteam/papo-server/jobs"
+	tjobs "bitbucket.org/enesyteam/papo-server/jobs/interfaces"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+)
+
+const (
+	JobName = "AutoMessageTask"
+
+	// chu kỳ kiểm tra các job bị bỏ dở do server dừng đột ngột
+	STALE_JOBS_CHECK_INTERVAL = 60 * time.Second
+)
+
+type Worker struct {
+	name      string
+	stop      chan bool
+	stopped   chan bool
+	jobs      chan model.Job
+	jobServer *jobs.JobServer
+	app       *app.App
+}
+
+func init() {
+	app.RegisterJobsAutoMessageTaskJobInterface(func(a *app.App) tjobs.AutoMessageTaskJobInterface {
+		return &AutoMessageTaskJobInterfaceImpl{a}
+	})
+}
+
+type AutoMessageTaskJobInterfaceImpl struct {
+	App *app.App
+}
+
+func (m *AutoMessageTaskJobInterfaceImpl) MakeWorker() model.Worker {
+	worker := Worker{
+		name:      JobName,
+		stop:      make(chan bool, 1),
+		stopped:   make(chan bool, 1),
+		jobs:      make(chan model.Job),
+		jobServer: m.App.Srv().Jobs,
+		app:       m.App,
+	}
+	return &worker
+}
+
+func (worker *Worker) Run() {
+	mlog.Debug("Worker started", mlog.String("worker", worker.name))
+
+	defer func() {
+		mlog.Debug("Worker finished", mlog.String("worker", worker.name))
+		worker.stopped <- true
+	}()
+
+	worker.app.ResetStaleAutoMessageTaskJobs()
+
+	for {
+		select {
+		case <-worker.stop:
+			mlog.Debug("Worker received stop signal", mlog.String("worker", worker.name))
+			return
+		case job := <-worker.jobs:
+			mlog.Debug("Worker received a new candidate job.", mlog.String("worker", worker.name))
+			worker.DoJob(&job)
+		case <-time.After(STALE_JOBS_CHECK_INTERVAL):
+			worker.app.ResetStaleAutoMessageTaskJobs()
+		}
+	}
+}
+
+func (worker *Worker) Stop() {
+	mlog.Debug("Worker stopping", mlog.String("worker", worker.name))
+	worker.stop <- true
+	<-worker.stopped
+}
+
+func (worker *Worker) JobChannel() chan<- model.Job {
+	return worker.jobs
+}
+
+// DoJob gửi tin nhắn của chiến dịch cho lần lượt từng trang hội thoại, sau mỗi trang cursor được lưu vào job data
+// để job có thể chạy tiếp từ đó khi server khởi động lại hoặc khi chiến dịch được resume.
+func (worker *Worker) DoJob(job *model.Job) {
+	if claimed, err := worker.jobServer.ClaimJob(job); err != nil {
+		mlog.Warn("Worker experienced an error while trying to claim job",
+			mlog.String("worker", worker.name),
+			mlog.String("job_id", job.Id),
+			mlog.String("error", err.Error()))
+		return
+	} else if !claimed {
+		return
+	}
+
+	cancelCtx, cancelCancelWatcher := context.WithCancel(context.Background())
+	cancelWatcherChan := make(chan interface{}, 1)
+	go worker.jobServer.CancellationWatcher(cancelCtx, job.Id, cancelWatcherChan)
+	defer cancelCancelWatcher()
+
+	worker.app.StartAutoMessageTaskJob(job)
+
+	for {
+		select {
+		case <-cancelWatcherChan:
+			mlog.Info("Worker: Job has been canceled via CancellationWatcher", mlog.String("worker", worker.name), mlog.String("job_id", job.Id))
+			worker.app.CancelAutoMessageTaskJob(job)
+			worker.setJobCanceled(job)
+			return
+		case <-worker.stop:
+			// server đang dừng: trả job về pending để chạy tiếp từ cursor đã lưu, báo lại cho Run để dừng worker
+			mlog.Info("Worker: Job has been interrupted by stop signal", mlog.String("worker", worker.name), mlog.String("job_id", job.Id))
+			worker.app.ReleaseAutoMessageTaskJob(job)
+			worker.stop <- true
+			return
+		default:
+		}
+
+		done, err := worker.app.AutoMessageTaskJobStep(job)
+		if err != nil {
+			mlog.Error("Worker: Failed to send auto messages", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+			worker.app.FailAutoMessageTaskJob(job, err)
+			worker.setJobError(job, err)
+			return
+		}
+
+		if done {
+			mlog.Info("Worker: Job is complete", mlog.String("worker", worker.name), mlog.String("job_id", job.Id))
+			worker.app.FinishAutoMessageTaskJob(job)
+			worker.setJobSuccess(job)
+			return
+		}
+
+		if err := worker.jobServer.UpdateInProgressJobData(job); err != nil {
+			mlog.Error("Worker: Failed to checkpoint job", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+		}
+	}
+}
+
+func (worker *Worker) setJobSuccess(job *model.Job) {
+	if err := worker.jobServer.SetJobProgress(job, 100); err != nil {
+		mlog.Error("Worker: Failed to update progress for job", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+	}
+
+	if err := worker.jobServer.SetJobSuccess(job); err != nil {
+		mlog.Error("Worker: Failed to set success for job", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+		worker.setJobError(job, err)
+	}
+}
+
+func (worker *Worker) setJobError(job *model.Job, appError *model.AppError) {
+	if err := worker.jobServer.SetJobError(job, appError); err != nil {
+		mlog.Error("Worker: Failed to set job error", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+	}
+}
+
+func (worker *Worker) setJobCanceled(job *model.Job) {
+	if err := worker.jobServer.SetJobCanceled(job); err != nil {
+		mlog.Error("Worker: Failed to mark job as canceled", mlog.String("worker", worker.name), mlog.String("job_id", job.Id), mlog.String("error", err.Error()))
+	}
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package fanpage_sync
+
+import (
+	"time"
+
+	"bitbucket.org/enesyteam/papo-server/app"
+	"bitbucket.org/enesyteam/papo-server/jobs"
+	"bitbucket.org/enesyteam/papo-server/mlog"
+	"bitbucket.org/enesyteam/papo-server/model"
+)
+
+type Scheduler struct {
+	App *app.App
+}
+
+func (m *FanpageSyncJobInterfaceImpl) MakeScheduler() model.Scheduler {
+	return &Scheduler{m.App}
+}
+
+func (scheduler *Scheduler) Name() string {
+	return JobName + "Scheduler"
+}
+
+func (scheduler *Scheduler) JobType() string {
+	return model.JOB_TYPE_FANPAGE_SYNC
+}
+
+func (scheduler *Scheduler) Enabled(cfg *model.Config) bool {
+	return *cfg.FacebookAPISettings.EnableNightlyFanpageSync
+}
+
+func (scheduler *Scheduler) NextScheduleTime(cfg *model.Config, now time.Time, pendingJobs bool, lastSuccessfulJob *model.Job) *time.Time {
+	parsedTime, err := time.Parse("15:04", *cfg.FacebookAPISettings.FanpageSyncStartTime)
+	if err != nil {
+		mlog.Error("Cannot determine next schedule time for fanpage sync. FanpageSyncStartTime config value is invalid.", mlog.Err(err))
+		return nil
+	}
+
+	return jobs.GenerateNextStartDateTime(now, parsedTime)
+}
+
+func (scheduler *Scheduler) ScheduleJob(cfg *model.Config, pendingJobs bool, lastSuccessfulJob *model.Job) (*model.Job, *model.AppError) {
+	return scheduler.App.CreateNightlyFanpageSyncJob()
+}
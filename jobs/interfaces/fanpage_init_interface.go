@@ -0,0 +1,10 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package interfaces
+
+import "bitbucket.org/enesyteam/papo-server/model"
+
+type FanpageInitJobInterface interface {
+	MakeWorker() model.Worker
+}
@@ -120,6 +120,27 @@ func (watcher *Watcher) PollAndNotify() {
 					default:
 					}
 				}
+			} else if job.Type == model.JOB_TYPE_FANPAGE_INIT {
+				if watcher.workers.FanpageInit != nil {
+					select {
+					case watcher.workers.FanpageInit.JobChannel() <- *job:
+					default:
+					}
+				}
+			} else if job.Type == model.JOB_TYPE_FANPAGE_SYNC {
+				if watcher.workers.FanpageSync != nil {
+					select {
+					case watcher.workers.FanpageSync.JobChannel() <- *job:
+					default:
+					}
+				}
+			} else if job.Type == model.JOB_TYPE_AUTO_MESSAGE_TASK {
+				if watcher.workers.AutoMessageTask != nil {
+					select {
+					case watcher.workers.AutoMessageTask.JobChannel() <- *job:
+					default:
+					}
+				}
 			}
 		}
 	}
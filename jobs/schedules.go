@@ -59,6 +59,10 @@ func (srv *JobServer) InitSchedulers() *Schedulers {
 		schedulers.schedulers = append(schedulers.schedulers, pluginsInterface.MakeScheduler())
 	}
 
+	if fanpageSyncInterface := srv.FanpageSync; fanpageSyncInterface != nil {
+		schedulers.schedulers = append(schedulers.schedulers, fanpageSyncInterface.MakeScheduler())
+	}
+
 	schedulers.nextRunTimes = make([]*time.Time, len(schedulers.schedulers))
 	return schedulers
 }
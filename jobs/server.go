@@ -28,6 +28,9 @@ type JobServer struct {
 	ExpiryNotify            tjobs.ExpiryNotifyJobInterface
 	ProductNotices          tjobs.ProductNoticesJobInterface
 	ActiveUsers             tjobs.ActiveUsersJobInterface
+	FanpageInit             tjobs.FanpageInitJobInterface
+	FanpageSync             tjobs.FanpageSyncJobInterface
+	AutoMessageTask         tjobs.AutoMessageTaskJobInterface
 }
 
 func NewJobServer(configService configservice.ConfigService, store store.Store) *JobServer {
@@ -22,6 +22,9 @@ type Workers struct {
 	LdapSync                 model.Worker
 	Migrations               model.Worker
 	Plugins                  model.Worker
+	FanpageInit              model.Worker
+	FanpageSync              model.Worker
+	AutoMessageTask          model.Worker
 
 	listenerId string
 }
@@ -60,6 +63,18 @@ func (srv *JobServer) InitWorkers() *Workers {
 		workers.Plugins = pluginsInterface.MakeWorker()
 	}
 
+	if fanpageInitInterface := srv.FanpageInit; fanpageInitInterface != nil {
+		workers.FanpageInit = fanpageInitInterface.MakeWorker()
+	}
+
+	if fanpageSyncInterface := srv.FanpageSync; fanpageSyncInterface != nil {
+		workers.FanpageSync = fanpageSyncInterface.MakeWorker()
+	}
+
+	if autoMessageTaskInterface := srv.AutoMessageTask; autoMessageTaskInterface != nil {
+		workers.AutoMessageTask = autoMessageTaskInterface.MakeWorker()
+	}
+
 	return workers
 }
 
@@ -95,6 +110,18 @@ func (workers *Workers) Start() *Workers {
 			go workers.Plugins.Run()
 		}
 
+		if workers.FanpageInit != nil {
+			go workers.FanpageInit.Run()
+		}
+
+		if workers.FanpageSync != nil {
+			go workers.FanpageSync.Run()
+		}
+
+		if workers.AutoMessageTask != nil {
+			go workers.AutoMessageTask.Run()
+		}
+
 		go workers.Watcher.Start()
 	})
 
@@ -180,6 +207,18 @@ func (workers *Workers) Stop() *Workers {
 		workers.Plugins.Stop()
 	}
 
+	if workers.FanpageInit != nil {
+		workers.FanpageInit.Stop()
+	}
+
+	if workers.FanpageSync != nil {
+		workers.FanpageSync.Stop()
+	}
+
+	if workers.AutoMessageTask != nil {
+		workers.AutoMessageTask.Stop()
+	}
+
 	mlog.Info("Stopped workers")
 
 	return workers
@@ -6,6 +6,24 @@ package model
 import (
 	"encoding/json"
 	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// trạng thái của chiến dịch gửi tin nhắn
+	AUTO_MESSAGE_TASK_STATUS_CREATED  = "created"
+	AUTO_MESSAGE_TASK_STATUS_ACTIVE   = "active" // job gửi tin nhắn đang chờ hoặc đang chạy
+	AUTO_MESSAGE_TASK_STATUS_PAUSED   = "paused"
+	AUTO_MESSAGE_TASK_STATUS_SUCCESS  = "success"
+	AUTO_MESSAGE_TASK_STATUS_ERROR    = "error"
+	AUTO_MESSAGE_TASK_STATUS_CANCELED = "canceled"
+
+	// kết quả gửi tin nhắn cho từng người nhận
+	AUTO_MESSAGE_RECIPIENT_STATUS_SENT    = "sent"
+	AUTO_MESSAGE_RECIPIENT_STATUS_FAILED  = "failed"
+	AUTO_MESSAGE_RECIPIENT_STATUS_SKIPPED = "skipped" // ngoài cửa sổ nhắn tin và chiến dịch không có tag phù hợp
+	AUTO_MESSAGE_RECIPIENT_STATUS_QUEUED  = "queued"  // facebook tạm thời từ chối, outbox sẽ gửi lại sau
 )
 
 // Tự động gửi tin nhắn đến các hội thoại theo bộ lọc được chỉ định
@@ -15,9 +33,15 @@ type AutoMessageTask struct {
 	Description 			string 			`json:"description"`
 	PageId 					string 			`json:"page_id"` // TASK của page nào
 	Message 				string 			`json:"message"` // nội dung tin nhắn
-	Attachments 			string 			`json:"attachments"` // gửi đính kèm
+	Attachments 			string 			`json:"attachments"` // gửi đính kèm: file id đã upload lên papo hoặc url
+	Tag 					string 			`json:"tag,omitempty"` // message tag dùng cho người nhận ngoài cửa sổ 24h, rỗng thì bỏ qua những người này
 	Creator 				string 			`json:"creator"`
-	Status 					string 			`json:"status"` // trạng thái của TASK = 'created', 'pending', 'active', 'succes', 'error'
+	Status 					string 			`json:"status"` // trạng thái của TASK = 'created', 'active', 'paused', 'success', 'error', 'canceled'
+	JobId 					string 			`json:"job_id,omitempty"` // job gửi tin nhắn gần nhất
+	SentCount 				int64 			`json:"sent_count"`
+	FailedCount 			int64 			`json:"failed_count"`
+	SkippedCount 			int64 			`json:"skipped_count"`
+	QueuedCount 			int64 			`json:"queued_count"` // tin nhắn còn nằm trong outbox chờ gửi lại
 	CreateAt 				int64 			`json:"create_at"`
 	UpdateAt 				int64 			`json:"update_at"`
 	StartAt 				int64 			`json:"start_at"`
@@ -25,19 +49,50 @@ type AutoMessageTask struct {
 	DeleteAt 				int64 			`json:"delete_at"`
 	FilterFromDate			int64 			`json:"filter_from_date"` // từ hội thoại ngày ...
 	FilterToDate 			int64 			`json:"filter_to_date"` // đến hội thoại ngày ...
-	FilterTags 				string 			`json:"filter_tags"` // gửi cho hội thoại có chứa tags
+	FilterTags 				string 			`json:"filter_tags"` // gửi cho hội thoại có chứa tags, các tag id cách nhau bởi dấu phẩy
 	FilterHasPhone 			bool 			`json:"filter_has_phone"` // true => gửi cho hội thoại có số đt
 	FilterNotHasPhone 		bool 			`json:"filter_not_has_phone"` // true => gửi cho hội thoại k có số đt
 	FilterHasOrder 			bool 			`json:"filter_has_order"` // true => gửi cho hội thoại đã có order
 	FilterNotHasOrder 		bool 			`json:"filter_not_has_order"` // true => gửi cho hội thoại chưa có order
-	FilterReceptions 		string 			`json:"filer_receptions"` // nếu có thì sẽ chỉ gửi tin nhắn cho những người này
+	FilterReceptions 		string 			`json:"filer_receptions"` // nếu có thì sẽ chỉ gửi tin nhắn cho những người này, các facebook uid cách nhau bởi dấu phẩy
+}
+
+// AutoMessageTaskRecipient lưu kết quả gửi tin nhắn của chiến dịch cho một hội thoại
+type AutoMessageTaskRecipient struct {
+	Id             string `json:"id"`
+	TaskId         string `json:"task_id"`
+	ConversationId string `json:"conversation_id"`
+	FacebookUid    string `json:"facebook_uid"`
+	Status         string `json:"status"`
+	MessageId      string `json:"message_id,omitempty"` // tin nhắn trong outbox
+	Error          string `json:"error,omitempty"`
+	CreateAt       int64  `json:"create_at"`
+}
+
+// AutoMessageTaskProgress là tiến độ của chiến dịch, trả về qua API và websocket
+type AutoMessageTaskProgress struct {
+	TaskId       string `json:"task_id"`
+	Status       string `json:"status"`
+	JobId        string `json:"job_id,omitempty"`
+	JobStatus    string `json:"job_status,omitempty"`
+	SentCount    int64  `json:"sent_count"`
+	FailedCount  int64  `json:"failed_count"`
+	SkippedCount int64  `json:"skipped_count"`
+	QueuedCount  int64  `json:"queued_count"`
+	StartAt      int64  `json:"start_at"`
+	EndAt        int64  `json:"end_at"`
 }
 
 func (p *AutoMessageTask) PreSave() {
 	if p.Id == "" {
 		p.Id = NewId()
 	}
-	p.Status = "created"
+	p.Status = AUTO_MESSAGE_TASK_STATUS_CREATED
+	p.JobId = ""
+	p.SentCount = 0
+	p.FailedCount = 0
+	p.SkippedCount = 0
+	p.QueuedCount = 0
 	p.CreateAt = GetMillis()
 	p.UpdateAt = p.CreateAt
 	p.DeleteAt = 0
@@ -69,3 +124,110 @@ func AutoMessageTasksFromJson(data io.Reader) []*AutoMessageTask {
 	return tasks
 }
 
+func (p *AutoMessageTask) IsValid() *AppError {
+	if len(p.Tag) > 0 && !IsAllowedMessageTag(p.Tag) {
+		return NewAppError("AutoMessageTask.IsValid", "model.auto_message_task.is_valid.tag.app_error", nil, "id="+p.Id+", tag="+p.Tag, http.StatusBadRequest)
+	}
+
+	if p.FilterHasPhone && p.FilterNotHasPhone || p.FilterHasOrder && p.FilterNotHasOrder {
+		return NewAppError("AutoMessageTask.IsValid", "model.auto_message_task.is_valid.filters.app_error", nil, "id="+p.Id, http.StatusBadRequest)
+	}
+
+	if p.FilterFromDate > 0 && p.FilterToDate > 0 && p.FilterFromDate > p.FilterToDate {
+		return NewAppError("AutoMessageTask.IsValid", "model.auto_message_task.is_valid.date_range.app_error", nil, "id="+p.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// IsFinished cho biết chiến dịch đã kết thúc và không thể chạy lại
+func (p *AutoMessageTask) IsFinished() bool {
+	switch p.Status {
+	case AUTO_MESSAGE_TASK_STATUS_SUCCESS, AUTO_MESSAGE_TASK_STATUS_ERROR, AUTO_MESSAGE_TASK_STATUS_CANCELED:
+		return true
+	}
+	return false
+}
+
+func (p *AutoMessageTask) GetFilterTagIds() []string {
+	return splitAutoMessageTaskList(p.FilterTags)
+}
+
+func (p *AutoMessageTask) GetFilterReceptions() []string {
+	return splitAutoMessageTaskList(p.FilterReceptions)
+}
+
+// SearchOptions đổi các bộ lọc của chiến dịch sang điều kiện tìm hội thoại tin nhắn của page,
+// bộ lọc đơn hàng và người nhận được áp dụng sau khi tìm
+func (p *AutoMessageTask) SearchOptions(cursor string, limit int) *ConversationSearchOptions {
+	options := &ConversationSearchOptions{
+		PageIds: []string{p.PageId},
+		Type:    "message",
+		TagIds:  p.GetFilterTagIds(),
+		Since:   p.FilterFromDate,
+		Until:   p.FilterToDate,
+		Cursor:  cursor,
+		Limit:   limit,
+	}
+
+	if p.FilterHasPhone || p.FilterNotHasPhone {
+		hasPhone := p.FilterHasPhone
+		options.HasPhone = &hasPhone
+	}
+
+	return options
+}
+
+// Progress trả về tiến độ hiện tại của chiến dịch, trạng thái job do nơi gọi gán
+func (p *AutoMessageTask) Progress() *AutoMessageTaskProgress {
+	return &AutoMessageTaskProgress{
+		TaskId:       p.Id,
+		Status:       p.Status,
+		JobId:        p.JobId,
+		SentCount:    p.SentCount,
+		FailedCount:  p.FailedCount,
+		SkippedCount: p.SkippedCount,
+		QueuedCount:  p.QueuedCount,
+		StartAt:      p.StartAt,
+		EndAt:        p.EndAt,
+	}
+}
+
+func (o *AutoMessageTaskProgress) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func AutoMessageTaskProgressFromJson(data io.Reader) *AutoMessageTaskProgress {
+	var o *AutoMessageTaskProgress
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *AutoMessageTaskRecipient) PreSave() {
+	if o.Id == "" {
+		o.Id = NewId()
+	}
+	o.CreateAt = GetMillis()
+}
+
+func AutoMessageTaskRecipientsToJson(o []*AutoMessageTaskRecipient) string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func AutoMessageTaskRecipientsFromJson(data io.Reader) []*AutoMessageTaskRecipient {
+	var o []*AutoMessageTaskRecipient
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func splitAutoMessageTaskList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); len(item) > 0 {
+			items = append(items, item)
+		}
+	}
+	return items
+}
@@ -0,0 +1,47 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAutoMessageTaskIsValid(t *testing.T) {
+	task := &AutoMessageTask{PageId: "1234", Message: "hello"}
+	task.PreSave()
+	assert.Equal(t, AUTO_MESSAGE_TASK_STATUS_CREATED, task.Status)
+	assert.Nil(t, task.IsValid())
+
+	task.Tag = "PROMOTION"
+	assert.NotNil(t, task.IsValid())
+
+	task.Tag = MESSAGE_TAG_POST_PURCHASE_UPDATE
+	task.FilterHasOrder = true
+	task.FilterNotHasOrder = true
+	assert.NotNil(t, task.IsValid())
+
+	task.FilterNotHasOrder = false
+	task.FilterFromDate = 2000
+	task.FilterToDate = 1000
+	assert.NotNil(t, task.IsValid())
+}
+
+func TestAutoMessageTaskSearchOptions(t *testing.T) {
+	task := &AutoMessageTask{PageId: "1234", FilterTags: " a, ,b", FilterNotHasPhone: true, FilterFromDate: 1000}
+
+	options := task.SearchOptions("cursor", 50)
+	assert.Equal(t, []string{"1234"}, options.PageIds)
+	assert.Equal(t, "message", options.Type)
+	assert.Equal(t, []string{"a", "b"}, options.TagIds)
+	assert.Equal(t, int64(1000), options.Since)
+	if assert.NotNil(t, options.HasPhone) {
+		assert.False(t, *options.HasPhone)
+	}
+
+	task.FilterNotHasPhone = false
+	assert.Nil(t, task.SearchOptions("", 50).HasPhone)
+	assert.Empty(t, task.GetFilterReceptions())
+}
@@ -30,6 +30,7 @@ const (
 	HEADER_AUTH               = "Authorization"
 	HEADER_REQUESTED_WITH     = "X-Requested-With"
 	HEADER_REQUESTED_WITH_XML = "XMLHttpRequest"
+	HEADER_NEXT_CURSOR        = "X-Next-Cursor"
 	STATUS                    = "status"
 	STATUS_OK                 = "OK"
 	STATUS_FAIL               = "FAIL"
@@ -409,6 +410,62 @@ func (c *Client4) GetTermsOfServiceRoute() string {
 	return "/terms_of_service"
 }
 
+func (c *Client4) GetConversationsRoute() string {
+	return fmt.Sprintf("/conversations")
+}
+
+func (c *Client4) GetConversationRoute(conversationId string) string {
+	return fmt.Sprintf(c.GetConversationsRoute()+"/%v", conversationId)
+}
+
+func (c *Client4) GetConversationCommentRoute(conversationId, commentId string) string {
+	return fmt.Sprintf(c.GetConversationRoute(conversationId)+"/comments/%v", commentId)
+}
+
+func (c *Client4) GetOrdersRoute() string {
+	return fmt.Sprintf("/orders")
+}
+
+func (c *Client4) GetOrderRoute(orderId string) string {
+	return fmt.Sprintf(c.GetOrdersRoute()+"/%v", orderId)
+}
+
+func (c *Client4) GetOrderStatusesRoute(pageId string) string {
+	return fmt.Sprintf("/fanpages/%v/order_statuses", pageId)
+}
+
+func (c *Client4) GetOrderStatusRoute(pageId, statusId string) string {
+	return fmt.Sprintf(c.GetOrderStatusesRoute(pageId)+"/%v", statusId)
+}
+
+func (c *Client4) GetProductsRoute(pageId string) string {
+	return fmt.Sprintf("/fanpages/%v/products", pageId)
+}
+
+func (c *Client4) GetProductRoute(pageId, productId string) string {
+	return fmt.Sprintf(c.GetProductsRoute(pageId)+"/%v", productId)
+}
+
+func (c *Client4) GetCustomersRoute() string {
+	return fmt.Sprintf("/customers")
+}
+
+func (c *Client4) GetCustomerRoute(customerId string) string {
+	return fmt.Sprintf(c.GetCustomersRoute()+"/%v", customerId)
+}
+
+func (c *Client4) GetFanpageMembersRoute(pageId string) string {
+	return fmt.Sprintf("/fanpages/%v/members", pageId)
+}
+
+func (c *Client4) GetFanpageMemberRoute(pageId, userId string) string {
+	return fmt.Sprintf(c.GetFanpageMembersRoute(pageId)+"/%v", userId)
+}
+
+func (c *Client4) GetAutoMessageTaskRoute(pageId, taskId string) string {
+	return fmt.Sprintf("/fanpages/%v/auto_message_tasks/%v", pageId, taskId)
+}
+
 func (c *Client4) DoApiGet(url string, etag string) (*http.Response, *AppError) {
 	return c.DoApiRequest(http.MethodGet, c.ApiUrl+url, "", etag)
 }
@@ -1676,3 +1733,449 @@ func (c *Client4) GetSamlCertificateStatus() (*SamlCertificateStatus, *Response)
 	}
 }
 
+// Conversation Section
+
+// SearchConversations tìm hội thoại theo các điều kiện lọc, dùng NextCursor của kết quả để lấy trang tiếp theo.
+func (c *Client4) SearchConversations(options *ConversationSearchOptions) (*ConversationSearchResult, *Response) {
+	if r, err := c.DoApiPost(c.GetConversationsRoute()+"/search", options.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ConversationSearchResultFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// HideComment ẩn bình luận trên facebook.
+func (c *Client4) HideComment(conversationId, commentId string) (*FacebookConversationMessage, *Response) {
+	return c.doCommentAction(c.GetConversationCommentRoute(conversationId, commentId)+"/hide", "")
+}
+
+// UnhideComment hiện lại bình luận đã bị ẩn.
+func (c *Client4) UnhideComment(conversationId, commentId string) (*FacebookConversationMessage, *Response) {
+	return c.doCommentAction(c.GetConversationCommentRoute(conversationId, commentId)+"/unhide", "")
+}
+
+// LikeComment thích bình luận với tư cách page.
+func (c *Client4) LikeComment(conversationId, commentId string) (*FacebookConversationMessage, *Response) {
+	return c.doCommentAction(c.GetConversationCommentRoute(conversationId, commentId)+"/like", "")
+}
+
+// UnlikeComment bỏ thích bình luận.
+func (c *Client4) UnlikeComment(conversationId, commentId string) (*FacebookConversationMessage, *Response) {
+	return c.doCommentAction(c.GetConversationCommentRoute(conversationId, commentId)+"/unlike", "")
+}
+
+// PrivateReplyComment gửi tin nhắn messenger cho người viết bình luận.
+func (c *Client4) PrivateReplyComment(conversationId, commentId, message string) (*FacebookConversationMessage, *Response) {
+	return c.doCommentAction(c.GetConversationCommentRoute(conversationId, commentId)+"/private_replies", MapToJson(map[string]string{"message": message}))
+}
+
+// DeleteComment xoá bình luận trên facebook.
+func (c *Client4) DeleteComment(conversationId, commentId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetConversationCommentRoute(conversationId, commentId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+// GetConversationMessageFiles lấy các file attachment đã được sao lưu của một tin nhắn.
+func (c *Client4) GetConversationMessageFiles(conversationId, messageId string) ([]*FileInfo, *Response) {
+	if r, err := c.DoApiGet(c.GetConversationRoute(conversationId)+"/messages/"+messageId+"/files", ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FileInfosFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) doCommentAction(url string, data string) (*FacebookConversationMessage, *Response) {
+	if r, err := c.DoApiPost(url, data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FacebookConversationMessageFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// Order Section
+
+// CreateOrder tạo đơn hàng, trạng thái mặc định của page được dùng nếu StatusId để trống.
+func (c *Client4) CreateOrder(order *Order) (*Order, *Response) {
+	if r, err := c.DoApiPost(c.GetOrdersRoute(), order.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) GetOrder(orderId string) (*Order, *Response) {
+	if r, err := c.DoApiGet(c.GetOrderRoute(orderId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateOrder(order *Order) (*Order, *Response) {
+	if r, err := c.DoApiPut(c.GetOrderRoute(order.Id), order.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) DeleteOrder(orderId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetOrderRoute(orderId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+// SearchOrders tìm đơn hàng theo các điều kiện lọc, TotalCount của kết quả là tổng số đơn khớp điều kiện.
+func (c *Client4) SearchOrders(options *OrderSearchOptions) (*OrderSearchResult, *Response) {
+	if r, err := c.DoApiPost(c.GetOrdersRoute()+"/search", options.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderSearchResultFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// GetOrderStatuses lấy các trạng thái đơn hàng của page.
+func (c *Client4) GetOrderStatuses(pageId string) ([]*OrderStatus, *Response) {
+	if r, err := c.DoApiGet(c.GetOrderStatusesRoute(pageId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderStatusListFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) CreateOrderStatus(status *OrderStatus) (*OrderStatus, *Response) {
+	if r, err := c.DoApiPost(c.GetOrderStatusesRoute(status.PageId), status.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderStatusFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateOrderStatus(status *OrderStatus) (*OrderStatus, *Response) {
+	if r, err := c.DoApiPut(c.GetOrderStatusRoute(status.PageId, status.Id), status.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return OrderStatusFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// DeleteOrderStatus xoá trạng thái đơn hàng, trạng thái mặc định hoặc đang có đơn sử dụng không xoá được.
+func (c *Client4) DeleteOrderStatus(pageId, statusId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetOrderStatusRoute(pageId, statusId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+// Product Section
+
+// GetProducts lấy sản phẩm trong danh mục của page, term tìm theo tên hoặc SKU.
+func (c *Client4) GetProducts(pageId, term string, offset, limit int) ([]*Product, *Response) {
+	query := fmt.Sprintf("?term=%v&offset=%v&limit=%v", url.QueryEscape(term), offset, limit)
+	if r, err := c.DoApiGet(c.GetProductsRoute(pageId)+query, ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ProductListFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) GetProduct(pageId, productId string) (*Product, *Response) {
+	if r, err := c.DoApiGet(c.GetProductRoute(pageId, productId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ProductFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) CreateProduct(product *Product) (*Product, *Response) {
+	if r, err := c.DoApiPost(c.GetProductsRoute(product.PageId), product.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ProductFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateProduct(product *Product) (*Product, *Response) {
+	if r, err := c.DoApiPut(c.GetProductRoute(product.PageId, product.Id), product.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ProductFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) DeleteProduct(pageId, productId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetProductRoute(pageId, productId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+// SendProduct gửi sản phẩm cho khách hàng của hội thoại dưới dạng generic template, variantId có thể để trống.
+func (c *Client4) SendProduct(conversationId, productId, variantId string) (*FacebookConversationMessage, *Response) {
+	data := MapToJson(map[string]string{"variant_id": variantId})
+	if r, err := c.DoApiPost(c.GetConversationRoute(conversationId)+"/products/"+productId+"/send", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FacebookConversationMessageFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// Customer Section
+
+// GetCustomerProfile lấy hồ sơ khách hàng đã gộp: định danh, hội thoại, đơn hàng, thẻ và ghi chú.
+func (c *Client4) GetCustomerProfile(customerId string) (*CustomerProfile, *Response) {
+	if r, err := c.DoApiGet(c.GetCustomerRoute(customerId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CustomerProfileFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// GetConversationCustomer lấy hồ sơ khách hàng của người gửi hội thoại.
+func (c *Client4) GetConversationCustomer(conversationId string) (*CustomerProfile, *Response) {
+	if r, err := c.DoApiGet(c.GetConversationRoute(conversationId)+"/customer", ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CustomerProfileFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateCustomer(customer *CustomerRecord) (*CustomerRecord, *Response) {
+	if r, err := c.DoApiPut(c.GetCustomerRoute(customer.Id), customer.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CustomerRecordFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// MergeCustomers gộp hồ sơ sourceId vào hồ sơ targetId, trả về hồ sơ đã gộp.
+func (c *Client4) MergeCustomers(targetId, sourceId string) (*CustomerProfile, *Response) {
+	data := MapToJson(map[string]string{"customer_id": sourceId})
+	if r, err := c.DoApiPost(c.GetCustomerRoute(targetId)+"/merge", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CustomerProfileFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// SplitCustomer tách các facebook uid ra khỏi hồ sơ thành hồ sơ mới, trả về hồ sơ mới.
+func (c *Client4) SplitCustomer(customerId string, facebookUids []string) (*CustomerProfile, *Response) {
+	data := StringInterfaceToJson(map[string]interface{}{"facebook_uids": facebookUids})
+	if r, err := c.DoApiPost(c.GetCustomerRoute(customerId)+"/split", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CustomerProfileFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// Fanpage Member Section
+
+func (c *Client4) GetFanpageMembers(pageId string) ([]*FanpageMember, *Response) {
+	if r, err := c.DoApiGet(c.GetFanpageMembersRoute(pageId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FanpageMembersFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// AddFanpageMember thêm user có sẵn vào page với roles, ví dụ FANPAGE_AGENT_ROLE_ID.
+func (c *Client4) AddFanpageMember(pageId, userId, roles string) (*FanpageMember, *Response) {
+	data := MapToJson(map[string]string{"user_id": userId, "roles": roles})
+	if r, err := c.DoApiPost(c.GetFanpageMembersRoute(pageId), data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FanpageMemberFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// InviteUsersToFanpage mời theo email, trả về các member được thêm ngay vì email đã có tài khoản.
+func (c *Client4) InviteUsersToFanpage(pageId string, emails []string, roles string) ([]*FanpageMember, *Response) {
+	invite := &FanpageMemberInvite{Emails: emails, Roles: roles}
+	if r, err := c.DoApiPost(c.GetFanpageMembersRoute(pageId)+"/invite", invite.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FanpageMembersFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// AcceptFanpageInvite thêm user đang đăng nhập vào page từ token trong email mời.
+func (c *Client4) AcceptFanpageInvite(token string) (*FanpageMember, *Response) {
+	data := MapToJson(map[string]string{"token": token})
+	if r, err := c.DoApiPost("/fanpages/members/invite/accept", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FanpageMemberFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateFanpageMemberRoles(pageId, userId, roles string) (*FanpageMember, *Response) {
+	data := MapToJson(map[string]string{"roles": roles})
+	if r, err := c.DoApiPut(c.GetFanpageMemberRoute(pageId, userId)+"/roles", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FanpageMemberFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) RemoveFanpageMember(pageId, userId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetFanpageMemberRoute(pageId, userId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+// Conversation Assignment Section
+
+// AssignConversation giao hội thoại cho userId, userId rỗng là tự nhận hội thoại.
+func (c *Client4) AssignConversation(conversationId, userId string) (*FacebookConversation, *Response) {
+	data := MapToJson(map[string]string{"user_id": userId})
+	if r, err := c.DoApiPost(c.GetConversationRoute(conversationId)+"/assign", data); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FacebookConversationFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UnassignConversation(conversationId string) (*FacebookConversation, *Response) {
+	if r, err := c.DoApiDelete(c.GetConversationRoute(conversationId) + "/assign"); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FacebookConversationFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) GetConversationRouting(pageId string) (*ConversationRouting, *Response) {
+	if r, err := c.DoApiGet(fmt.Sprintf("/fanpages/%v/routing", pageId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ConversationRoutingFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) UpdateConversationRouting(routing *ConversationRouting) (*ConversationRouting, *Response) {
+	if r, err := c.DoApiPut(fmt.Sprintf("/fanpages/%v/routing", routing.PageId), routing.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return ConversationRoutingFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// Conversation Status Section
+
+// UpdateConversationStatus đổi trạng thái hội thoại, snoozedUntil (millis) chỉ dùng khi status là snoozed.
+func (c *Client4) UpdateConversationStatus(conversationId, status string, snoozedUntil int64) (*FacebookConversation, *Response) {
+	update := &ConversationStatusUpdate{Status: status, SnoozedUntil: snoozedUntil}
+	if r, err := c.DoApiPut(c.GetConversationRoute(conversationId)+"/status", update.ToJson()); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return FacebookConversationFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// Auto Message Task Section
+
+func (c *Client4) GetAutoMessageTask(pageId, taskId string) (*AutoMessageTask, *Response) {
+	if r, err := c.DoApiGet(c.GetAutoMessageTaskRoute(pageId, taskId), ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return AutoMessageTaskFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) DeleteAutoMessageTask(pageId, taskId string) (bool, *Response) {
+	if r, err := c.DoApiDelete(c.GetAutoMessageTaskRoute(pageId, taskId)); err != nil {
+		return false, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return CheckStatusOK(r), BuildResponse(r)
+	}
+}
+
+func (c *Client4) GetAutoMessageTaskProgress(pageId, taskId string) (*AutoMessageTaskProgress, *Response) {
+	if r, err := c.DoApiGet(c.GetAutoMessageTaskRoute(pageId, taskId)+"/progress", ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return AutoMessageTaskProgressFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+// GetAutoMessageTaskRecipients trả về kết quả gửi của chiến dịch, status rỗng là mọi kết quả.
+func (c *Client4) GetAutoMessageTaskRecipients(pageId, taskId, status string, page, perPage int) ([]*AutoMessageTaskRecipient, *Response) {
+	query := fmt.Sprintf("?status=%v&page=%v&per_page=%v", url.QueryEscape(status), page, perPage)
+	if r, err := c.DoApiGet(c.GetAutoMessageTaskRoute(pageId, taskId)+"/recipients"+query, ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return AutoMessageTaskRecipientsFromJson(r.Body), BuildResponse(r)
+	}
+}
+
+func (c *Client4) StartAutoMessageTask(pageId, taskId string) (*AutoMessageTask, *Response) {
+	return c.doAutoMessageTaskAction(pageId, taskId, "start")
+}
+
+func (c *Client4) PauseAutoMessageTask(pageId, taskId string) (*AutoMessageTask, *Response) {
+	return c.doAutoMessageTaskAction(pageId, taskId, "pause")
+}
+
+func (c *Client4) ResumeAutoMessageTask(pageId, taskId string) (*AutoMessageTask, *Response) {
+	return c.doAutoMessageTaskAction(pageId, taskId, "resume")
+}
+
+func (c *Client4) CancelAutoMessageTask(pageId, taskId string) (*AutoMessageTask, *Response) {
+	return c.doAutoMessageTaskAction(pageId, taskId, "cancel")
+}
+
+func (c *Client4) doAutoMessageTaskAction(pageId, taskId, action string) (*AutoMessageTask, *Response) {
+	if r, err := c.DoApiPost(c.GetAutoMessageTaskRoute(pageId, taskId)+"/"+action, ""); err != nil {
+		return nil, BuildErrorResponse(r, err)
+	} else {
+		defer closeBody(r)
+		return AutoMessageTaskFromJson(r.Body), BuildResponse(r)
+	}
+}
@@ -224,6 +224,8 @@ const (
 
 	CLOUD_SETTINGS_DEFAULT_CWS_URL = "https://customers.mattermost.com"
 
+	FACEBOOK_SETTINGS_DEFAULT_GRAPH_API_BASE_URL = "https://graph.facebook.com"
+
 	LOCAL_MODE_SOCKET_PATH = "/var/tmp/mattermost_local.socket"
 )
 
@@ -2570,6 +2572,150 @@ func (s *JobSettings) SetDefaults() {
 	}
 }
 
+type FacebookSettings struct {
+	Id                                 *string `access:"authentication"`
+	Secret                             *string `access:"authentication"`
+	AppToken                           *string `access:"authentication"`
+	GraphApiBaseUrl                    *string `access:"authentication"`
+	GraphApiVersion                    *string `access:"authentication"`
+	SuccessRedirect                    *string `access:"authentication"`
+	EnableWebhookSignatureVerification *bool   `access:"authentication"`
+	PageTokenEncryptKey                *string `access:"authentication"`
+}
+
+func (s *FacebookSettings) SetDefaults() {
+	if s.Id == nil {
+		s.Id = NewString("")
+	}
+
+	if s.Secret == nil {
+		s.Secret = NewString("")
+	}
+
+	if s.AppToken == nil {
+		s.AppToken = NewString("")
+	}
+
+	// đổi sang địa chỉ của server giả lập (facebook_graph/graphtest) để chạy test hoặc phát triển offline
+	if s.GraphApiBaseUrl == nil {
+		s.GraphApiBaseUrl = NewString(FACEBOOK_SETTINGS_DEFAULT_GRAPH_API_BASE_URL)
+	}
+
+	// appsecret_proof được tính từ Secret cho từng access token nên không cần cấu hình
+	if s.GraphApiVersion == nil {
+		s.GraphApiVersion = NewString("v3.2")
+	}
+
+	if s.SuccessRedirect == nil {
+		s.SuccessRedirect = NewString("")
+	}
+
+	if s.EnableWebhookSignatureVerification == nil {
+		s.EnableWebhookSignatureVerification = NewBool(true)
+	}
+
+	// Để trống thì page token sẽ được mã hoá bằng SqlSettings.AtRestEncryptKey
+	if s.PageTokenEncryptKey == nil {
+		s.PageTokenEncryptKey = NewString("")
+	}
+}
+
+func (s *FacebookSettings) isValid() *AppError {
+	if !IsValidHttpUrl(*s.GraphApiBaseUrl) {
+		return NewAppError("Config.IsValid", "model.config.is_valid.facebook_graph_api_base_url.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if *s.PageTokenEncryptKey != "" && len(*s.PageTokenEncryptKey) < 32 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.facebook_page_token_encrypt_key.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+type FacebookAPISettings struct {
+	WebhookToken       *string `access:"authentication"`
+	WebhookWorkers     *int    `access:"environment"`
+	WebhookMaxAttempts *int    `access:"environment"`
+	OutboxWorkers      *int    `access:"environment"`
+	OutboxMaxAttempts  *int    `access:"environment"`
+	// sao lưu attachment khách hàng gửi về FileBackend vì url CDN của facebook sẽ hết hạn
+	EnableAttachmentMirroring *bool `access:"environment"`
+	// đồng bộ lại hằng đêm tin nhắn và bình luận mà webhook có thể đã bỏ sót
+	EnableNightlyFanpageSync *bool   `access:"environment"`
+	FanpageSyncStartTime     *string `access:"environment"`
+	// bài viết tạo trước mốc đồng bộ quá số ngày này sẽ không được kiểm tra bình luận mới
+	FanpageSyncPostLookbackDays *int `access:"environment"`
+	// tỉ lệ sử dụng (%) theo X-App-Usage/X-Business-Use-Case-Usage từ đó các request Graph API được giãn ra
+	GraphUsageThrottlePercent *int `access:"environment"`
+	GraphMaxRetries           *int `access:"environment"`
+	// request phải chờ lâu hơn thời gian này sẽ trả về lỗi vượt giới hạn ngay thay vì giữ kết nối
+	GraphMaxBackoffSeconds *int `access:"environment"`
+	// số tin nhắn tối đa mỗi phút mà chiến dịch gửi tin nhắn được gửi cho một page
+	AutoMessagePerMinute *int `access:"environment"`
+}
+
+func (s *FacebookAPISettings) SetDefaults() {
+	if s.WebhookToken == nil {
+		s.WebhookToken = NewString("")
+	}
+
+	if s.WebhookWorkers == nil {
+		s.WebhookWorkers = NewInt(4)
+	}
+
+	if s.WebhookMaxAttempts == nil {
+		s.WebhookMaxAttempts = NewInt(8)
+	}
+
+	if s.OutboxWorkers == nil {
+		s.OutboxWorkers = NewInt(2)
+	}
+
+	if s.OutboxMaxAttempts == nil {
+		s.OutboxMaxAttempts = NewInt(6)
+	}
+
+	if s.EnableAttachmentMirroring == nil {
+		s.EnableAttachmentMirroring = NewBool(true)
+	}
+
+	if s.EnableNightlyFanpageSync == nil {
+		s.EnableNightlyFanpageSync = NewBool(true)
+	}
+
+	if s.FanpageSyncStartTime == nil {
+		s.FanpageSyncStartTime = NewString("03:00")
+	}
+
+	if s.FanpageSyncPostLookbackDays == nil {
+		s.FanpageSyncPostLookbackDays = NewInt(30)
+	}
+
+	if s.GraphUsageThrottlePercent == nil {
+		s.GraphUsageThrottlePercent = NewInt(80)
+	}
+
+	if s.GraphMaxRetries == nil {
+		s.GraphMaxRetries = NewInt(3)
+	}
+
+	if s.GraphMaxBackoffSeconds == nil {
+		s.GraphMaxBackoffSeconds = NewInt(60)
+	}
+
+	if s.AutoMessagePerMinute == nil {
+		s.AutoMessagePerMinute = NewInt(30)
+	}
+}
+
+func (s *FacebookAPISettings) isValid() *AppError {
+	if *s.AutoMessagePerMinute <= 0 {
+		return NewAppError("Config.IsValid", "model.config.is_valid.facebook_auto_message_per_minute.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
 type CloudSettings struct {
 	CWSUrl *string `access:"environment,write_restrictable"`
 }
@@ -2894,6 +3040,8 @@ type Config struct {
 	GuestAccountsSettings     GuestAccountsSettings
 	ImageProxySettings        ImageProxySettings
 	CloudSettings             CloudSettings
+	FacebookSettings          FacebookSettings
+	FacebookAPISettings       FacebookAPISettings
 	FeatureFlags              *FeatureFlags `json:",omitempty"`
 }
 
@@ -2994,6 +3142,8 @@ func (o *Config) SetDefaults() {
 	o.GuestAccountsSettings.SetDefaults()
 	o.ImageProxySettings.SetDefaults(o.ServiceSettings)
 	o.CloudSettings.SetDefaults()
+	o.FacebookSettings.SetDefaults()
+	o.FacebookAPISettings.SetDefaults()
 	if o.FeatureFlags == nil {
 		o.FeatureFlags = &FeatureFlags{}
 		o.FeatureFlags.SetDefaults()
@@ -3021,6 +3171,14 @@ func (o *Config) IsValid() *AppError {
 		return err
 	}
 
+	if err := o.FacebookSettings.isValid(); err != nil {
+		return err
+	}
+
+	if err := o.FacebookAPISettings.isValid(); err != nil {
+		return err
+	}
+
 	if err := o.FileSettings.isValid(); err != nil {
 		return err
 	}
@@ -3608,6 +3766,7 @@ func (o *Config) Sanitize() {
 
 	*o.SqlSettings.DataSource = FAKE_SETTING
 	*o.SqlSettings.AtRestEncryptKey = FAKE_SETTING
+	*o.FacebookSettings.PageTokenEncryptKey = FAKE_SETTING
 
 	*o.ElasticsearchSettings.Password = FAKE_SETTING
 
@@ -0,0 +1,131 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	// cách giao hội thoại mới chưa có người phụ trách cho nhân viên của page
+	ROUTING_METHOD_MANUAL      = "manual"      // không tự động giao, chỉ áp dụng luật theo thẻ nếu có
+	ROUTING_METHOD_ROUND_ROBIN = "round_robin" // lần lượt từng nhân viên
+	ROUTING_METHOD_LEAST_BUSY  = "least_busy"  // nhân viên đang online/away có ít hội thoại chưa trả lời nhất
+
+	ROUTING_TAG_RULES_MAX = 50
+	ROUTING_USER_IDS_MAX  = 200
+)
+
+// RoutingTagRule giao hội thoại có thẻ TagId cho một trong các nhân viên UserIds
+type RoutingTagRule struct {
+	TagId   string   `json:"tag_id"`
+	UserIds []string `json:"user_ids"`
+}
+
+type RoutingTagRules []*RoutingTagRule
+
+// ConversationRouting là cấu hình định tuyến hội thoại của một page. Luật theo thẻ được xét trước theo thứ tự,
+// nếu không luật nào khớp thì dùng Method với nhóm nhân viên UserIds
+type ConversationRouting struct {
+	PageId         string          `json:"page_id"`
+	Method         string          `json:"method"`
+	UserIds        StringArray     `json:"user_ids,omitempty"` // rỗng là mọi thành viên có quyền trả lời hội thoại
+	OnlyAvailable  bool            `json:"only_available"`     // chỉ giao cho nhân viên đang online hoặc away
+	TagRules       RoutingTagRules `json:"tag_rules,omitempty"`
+	LastAssignedTo string          `json:"last_assigned_to,omitempty"` // nhân viên được giao gần nhất, dùng cho round-robin
+	UpdateAt       int64           `json:"update_at"`
+}
+
+func (o *ConversationRouting) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func ConversationRoutingFromJson(data io.Reader) *ConversationRouting {
+	var o *ConversationRouting
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *ConversationRouting) PreSave() {
+	if len(o.Method) == 0 {
+		o.Method = ROUTING_METHOD_MANUAL
+	}
+	o.UpdateAt = GetMillis()
+}
+
+func (o *ConversationRouting) IsValid() *AppError {
+	if len(o.PageId) == 0 {
+		return NewAppError("ConversationRouting.IsValid", "model.conversation_routing.is_valid.page_id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	switch o.Method {
+	case ROUTING_METHOD_MANUAL, ROUTING_METHOD_ROUND_ROBIN, ROUTING_METHOD_LEAST_BUSY:
+	default:
+		return NewAppError("ConversationRouting.IsValid", "model.conversation_routing.is_valid.method.app_error", nil, "page_id="+o.PageId+", method="+o.Method, http.StatusBadRequest)
+	}
+
+	if !isValidRoutingUserIds(o.UserIds) {
+		return NewAppError("ConversationRouting.IsValid", "model.conversation_routing.is_valid.user_ids.app_error", map[string]interface{}{"Max": ROUTING_USER_IDS_MAX}, "page_id="+o.PageId, http.StatusBadRequest)
+	}
+
+	if len(o.TagRules) > ROUTING_TAG_RULES_MAX {
+		return NewAppError("ConversationRouting.IsValid", "model.conversation_routing.is_valid.tag_rules.app_error", map[string]interface{}{"Max": ROUTING_TAG_RULES_MAX}, "page_id="+o.PageId, http.StatusBadRequest)
+	}
+
+	for _, rule := range o.TagRules {
+		if rule == nil || len(rule.TagId) != 26 || len(rule.UserIds) == 0 || !isValidRoutingUserIds(rule.UserIds) {
+			return NewAppError("ConversationRouting.IsValid", "model.conversation_routing.is_valid.tag_rule.app_error", nil, "page_id="+o.PageId, http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+func isValidRoutingUserIds(userIds []string) bool {
+	if len(userIds) > ROUTING_USER_IDS_MAX {
+		return false
+	}
+	for _, userId := range userIds {
+		if len(userId) != 26 {
+			return false
+		}
+	}
+	return true
+}
+
+// TagRuleFor trả về luật đầu tiên có thẻ nằm trong tagIds
+func (o *ConversationRouting) TagRuleFor(tagIds []string) *RoutingTagRule {
+	for _, rule := range o.TagRules {
+		for _, tagId := range tagIds {
+			if rule.TagId == tagId {
+				return rule
+			}
+		}
+	}
+	return nil
+}
+
+// NextRoundRobin trả về nhân viên đứng sau lastUserId trong candidates đã sắp xếp theo id, quay lại đầu danh sách khi hết
+func NextRoundRobin(candidates []string, lastUserId string) string {
+	if len(candidates) == 0 {
+		return ""
+	}
+
+	for i, userId := range candidates {
+		if userId == lastUserId {
+			return candidates[(i+1)%len(candidates)]
+		}
+	}
+
+	// nhân viên được giao gần nhất không còn trong danh sách, chọn người đứng sau vị trí cũ của họ
+	for _, userId := range candidates {
+		if userId > lastUserId {
+			return userId
+		}
+	}
+	return candidates[0]
+}
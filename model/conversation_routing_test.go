@@ -0,0 +1,52 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversationRoutingIsValid(t *testing.T) {
+	routing := &ConversationRouting{PageId: "1234"}
+	routing.PreSave()
+	assert.Equal(t, ROUTING_METHOD_MANUAL, routing.Method)
+	assert.Nil(t, routing.IsValid())
+
+	routing.Method = "random"
+	assert.NotNil(t, routing.IsValid())
+
+	routing.Method = ROUTING_METHOD_ROUND_ROBIN
+	routing.UserIds = StringArray{"abc"}
+	assert.NotNil(t, routing.IsValid())
+
+	routing.UserIds = StringArray{NewId()}
+	routing.TagRules = RoutingTagRules{{TagId: NewId()}}
+	assert.NotNil(t, routing.IsValid())
+
+	routing.TagRules[0].UserIds = []string{NewId()}
+	assert.Nil(t, routing.IsValid())
+}
+
+func TestNextRoundRobin(t *testing.T) {
+	candidates := []string{"a", "c", "e"}
+
+	assert.Equal(t, "", NextRoundRobin(nil, "a"))
+	assert.Equal(t, "a", NextRoundRobin(candidates, ""))
+	assert.Equal(t, "c", NextRoundRobin(candidates, "a"))
+	assert.Equal(t, "a", NextRoundRobin(candidates, "e"))
+	assert.Equal(t, "e", NextRoundRobin(candidates, "d"))
+	assert.Equal(t, "a", NextRoundRobin(candidates, "f"))
+}
+
+func TestConversationRoutingTagRuleFor(t *testing.T) {
+	first := &RoutingTagRule{TagId: "t1", UserIds: []string{"u1"}}
+	second := &RoutingTagRule{TagId: "t2", UserIds: []string{"u2"}}
+	routing := &ConversationRouting{TagRules: RoutingTagRules{first, second}}
+
+	assert.Nil(t, routing.TagRuleFor(nil))
+	assert.Equal(t, second, routing.TagRuleFor([]string{"t2"}))
+	assert.Equal(t, first, routing.TagRuleFor([]string{"t2", "t1"}))
+}
@@ -0,0 +1,148 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+const (
+	CONVERSATION_SEARCH_DEFAULT_LIMIT = 30
+	CONVERSATION_SEARCH_MAX_LIMIT     = 200
+
+	CONVERSATION_SEARCH_TAG_MATCH_ANY = "any"
+	CONVERSATION_SEARCH_TAG_MATCH_ALL = "all"
+)
+
+// số điện thoại Việt Nam: 0xxxxxxxxx hoặc +84xxxxxxxxx, cho phép dấu cách, chấm, gạch giữa các nhóm số
+var phoneNumberRegexp = regexp.MustCompile(`(?:^|\D)(?:\+?84|0)(?:[\s.\-]?\d){9,10}\b`)
+
+// ContainsPhoneNumber cho biết nội dung tin nhắn có chứa số điện thoại hay không
+func ContainsPhoneNumber(text string) bool {
+	return phoneNumberRegexp.MatchString(text)
+}
+
+// ConversationSearchOptions là các điều kiện lọc hội thoại, mọi trường đều không bắt buộc
+// và được kết hợp với nhau bằng AND
+type ConversationSearchOptions struct {
+	Term       string   `json:"term,omitempty"`     // tìm trong nội dung tin nhắn và tên khách hàng
+	PageIds    []string `json:"page_ids,omitempty"` // facebook page id
+	Type       string   `json:"type,omitempty"`     // message hoặc comment
+	Seen       *bool    `json:"seen,omitempty"`
+	Replied    *bool    `json:"replied,omitempty"`
+	TagIds     []string `json:"tag_ids,omitempty"`
+	TagMatch   string   `json:"tag_match,omitempty"` // any (mặc định) hoặc all
+	HasPhone   *bool    `json:"has_phone,omitempty"`
+	Since      int64    `json:"since,omitempty"` // milliseconds, lọc theo thời gian cập nhật cuối của hội thoại
+	Until      int64    `json:"until,omitempty"`
+	AssignedTo string   `json:"assigned_to,omitempty"` // user id của nhân viên được giao hội thoại
+	Statuses   []string `json:"statuses,omitempty"`    // open, pending, snoozed, closed
+	Cursor     string   `json:"cursor,omitempty"`      // next_cursor của trang trước
+	Offset     int      `json:"offset,omitempty"`      // chỉ giữ cho client cũ, bị bỏ qua khi có Cursor
+	Limit      int      `json:"limit,omitempty"`
+}
+
+// ConversationSearchCursor đánh dấu vị trí hội thoại cuối cùng của trang trước, hội thoại chưa đọc đứng trước
+// rồi mới sắp xếp theo LastActivityAt, Id giảm dần
+type ConversationSearchCursor struct {
+	Seen           bool   `json:"s"`
+	LastActivityAt int64  `json:"t"`
+	Id             string `json:"i"`
+}
+
+type ConversationSearchResult struct {
+	Conversations []*FacebookConversation `json:"conversations"`
+	NextCursor    string                  `json:"next_cursor,omitempty"`
+}
+
+func (o *ConversationSearchOptions) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func ConversationSearchOptionsFromJson(data io.Reader) *ConversationSearchOptions {
+	var o *ConversationSearchOptions
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *ConversationSearchOptions) SetDefaults() {
+	if o.Limit <= 0 {
+		o.Limit = CONVERSATION_SEARCH_DEFAULT_LIMIT
+	}
+
+	if len(o.TagMatch) == 0 {
+		o.TagMatch = CONVERSATION_SEARCH_TAG_MATCH_ANY
+	}
+}
+
+func (o *ConversationSearchOptions) IsValid() *AppError {
+	if o.Limit < 0 || o.Limit > CONVERSATION_SEARCH_MAX_LIMIT {
+		return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.limit.app_error", map[string]interface{}{"Max": CONVERSATION_SEARCH_MAX_LIMIT}, "", http.StatusBadRequest)
+	}
+
+	if o.Offset < 0 {
+		return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.offset.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.Type) > 0 && o.Type != "message" && o.Type != "comment" {
+		return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.type.app_error", nil, "type="+o.Type, http.StatusBadRequest)
+	}
+
+	if len(o.TagMatch) > 0 && o.TagMatch != CONVERSATION_SEARCH_TAG_MATCH_ANY && o.TagMatch != CONVERSATION_SEARCH_TAG_MATCH_ALL {
+		return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.tag_match.app_error", nil, "tag_match="+o.TagMatch, http.StatusBadRequest)
+	}
+
+	for _, status := range o.Statuses {
+		if !IsValidConversationStatus(status) {
+			return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.status.app_error", nil, "status="+status, http.StatusBadRequest)
+		}
+	}
+
+	if o.Since > 0 && o.Until > 0 && o.Since > o.Until {
+		return NewAppError("ConversationSearchOptions.IsValid", "model.conversation_search.is_valid.date_range.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.Cursor) > 0 {
+		if _, err := DecodeConversationSearchCursor(o.Cursor); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *ConversationSearchCursor) Encode() string {
+	b, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+func DecodeConversationSearchCursor(value string) (*ConversationSearchCursor, *AppError) {
+	b, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, NewAppError("DecodeConversationSearchCursor", "model.conversation_search.is_valid.cursor.app_error", nil, err.Error(), http.StatusBadRequest)
+	}
+
+	var cursor *ConversationSearchCursor
+	if err := json.Unmarshal(b, &cursor); err != nil || cursor == nil || len(cursor.Id) == 0 {
+		return nil, NewAppError("DecodeConversationSearchCursor", "model.conversation_search.is_valid.cursor.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return cursor, nil
+}
+
+func (r *ConversationSearchResult) ToJson() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+func ConversationSearchResultFromJson(data io.Reader) *ConversationSearchResult {
+	var o *ConversationSearchResult
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
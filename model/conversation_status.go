@@ -0,0 +1,84 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	// trạng thái xử lý của hội thoại
+	CONVERSATION_STATUS_OPEN    = "open"    // đang chờ nhân viên xử lý
+	CONVERSATION_STATUS_PENDING = "pending" // đang chờ khách hàng phản hồi
+	CONVERSATION_STATUS_SNOOZED = "snoozed" // tạm ẩn tới SnoozedUntil rồi tự mở lại
+	CONVERSATION_STATUS_CLOSED  = "closed"  // đã xử lý xong
+
+	// thời gian tạm ẩn tối đa
+	CONVERSATION_SNOOZE_MAX_DURATION = 90 * 24 * 60 * 60 * 1000
+)
+
+// ConversationStatusUpdate là nội dung yêu cầu đổi trạng thái hội thoại, SnoozedUntil (millis) chỉ dùng khi Status là snoozed
+type ConversationStatusUpdate struct {
+	Status       string `json:"status"`
+	SnoozedUntil int64  `json:"snoozed_until,omitempty"`
+}
+
+func (o *ConversationStatusUpdate) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func ConversationStatusUpdateFromJson(data io.Reader) *ConversationStatusUpdate {
+	var o *ConversationStatusUpdate
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *ConversationStatusUpdate) IsValid(now int64) *AppError {
+	if !IsValidConversationStatus(o.Status) {
+		return NewAppError("ConversationStatusUpdate.IsValid", "model.conversation_status.is_valid.status.app_error", nil, "status="+o.Status, http.StatusBadRequest)
+	}
+
+	if o.Status == CONVERSATION_STATUS_SNOOZED {
+		if o.SnoozedUntil <= now || o.SnoozedUntil-now > CONVERSATION_SNOOZE_MAX_DURATION {
+			return NewAppError("ConversationStatusUpdate.IsValid", "model.conversation_status.is_valid.snoozed_until.app_error", nil, "", http.StatusBadRequest)
+		}
+	} else if o.SnoozedUntil != 0 {
+		return NewAppError("ConversationStatusUpdate.IsValid", "model.conversation_status.is_valid.snoozed_until.app_error", nil, "status="+o.Status, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func IsValidConversationStatus(status string) bool {
+	switch status {
+	case CONVERSATION_STATUS_OPEN, CONVERSATION_STATUS_PENDING, CONVERSATION_STATUS_SNOOZED, CONVERSATION_STATUS_CLOSED:
+		return true
+	}
+	return false
+}
+
+// GetStatus trả về trạng thái của hội thoại, hội thoại tạo trước khi có trạng thái được coi là open
+func (c *FacebookConversation) GetStatus() string {
+	if len(c.Status) == 0 {
+		return CONVERSATION_STATUS_OPEN
+	}
+	return c.Status
+}
+
+// CanTransitionTo cho biết hội thoại có thể chuyển sang status hay không. Hội thoại đang snoozed được phép
+// snooze lại với thời điểm mới, các trường hợp giữ nguyên trạng thái khác bị từ chối
+func (c *FacebookConversation) CanTransitionTo(status string) bool {
+	if !IsValidConversationStatus(status) {
+		return false
+	}
+	return c.GetStatus() != status || status == CONVERSATION_STATUS_SNOOZED
+}
+
+// ShouldReopenOnInbound cho biết tin nhắn mới của khách hàng có mở lại hội thoại hay không
+func (c *FacebookConversation) ShouldReopenOnInbound() bool {
+	return c.GetStatus() != CONVERSATION_STATUS_OPEN
+}
@@ -0,0 +1,49 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConversationStatusUpdateIsValid(t *testing.T) {
+	now := GetMillis()
+
+	update := &ConversationStatusUpdate{Status: "done"}
+	assert.NotNil(t, update.IsValid(now))
+
+	update.Status = CONVERSATION_STATUS_CLOSED
+	assert.Nil(t, update.IsValid(now))
+
+	update.SnoozedUntil = now + 1000
+	assert.NotNil(t, update.IsValid(now))
+
+	update.Status = CONVERSATION_STATUS_SNOOZED
+	assert.Nil(t, update.IsValid(now))
+
+	update.SnoozedUntil = now - 1000
+	assert.NotNil(t, update.IsValid(now))
+
+	update.SnoozedUntil = now + CONVERSATION_SNOOZE_MAX_DURATION + 1
+	assert.NotNil(t, update.IsValid(now))
+}
+
+func TestFacebookConversationStatusTransitions(t *testing.T) {
+	conversation := &FacebookConversation{}
+	assert.Equal(t, CONVERSATION_STATUS_OPEN, conversation.GetStatus())
+	assert.False(t, conversation.CanTransitionTo(CONVERSATION_STATUS_OPEN))
+	assert.False(t, conversation.CanTransitionTo("done"))
+	assert.True(t, conversation.CanTransitionTo(CONVERSATION_STATUS_CLOSED))
+	assert.False(t, conversation.ShouldReopenOnInbound())
+
+	conversation.Status = CONVERSATION_STATUS_SNOOZED
+	assert.True(t, conversation.CanTransitionTo(CONVERSATION_STATUS_SNOOZED))
+	assert.True(t, conversation.ShouldReopenOnInbound())
+
+	conversation.Status = CONVERSATION_STATUS_CLOSED
+	assert.True(t, conversation.CanTransitionTo(CONVERSATION_STATUS_OPEN))
+	assert.True(t, conversation.ShouldReopenOnInbound())
+}
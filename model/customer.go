@@ -0,0 +1,222 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	CUSTOMER_NAME_MAX_LENGTH = 128
+	CUSTOMER_PHONES_MAX      = 20
+
+	// số hội thoại và đơn hàng gần nhất được gom vào hồ sơ khách hàng
+	CUSTOMER_PROFILE_CONVERSATIONS_MAX = 50
+	CUSTOMER_PROFILE_ORDERS_MAX        = 50
+)
+
+// CustomerRecord là hồ sơ khách hàng, gom các facebook uid của cùng một người trên nhiều page
+// cùng với số điện thoại trích ra từ tin nhắn
+type CustomerRecord struct {
+	Id       string      `json:"id"`
+	Name     string      `json:"name"`
+	Phones   StringArray `json:"phones"`
+	CreateAt int64       `json:"create_at"`
+	UpdateAt int64       `json:"update_at"`
+	DeleteAt int64       `json:"delete_at"`
+}
+
+// CustomerIdentity gắn một facebook uid (người gửi của hội thoại bình luận hoặc tin nhắn) vào hồ sơ khách hàng,
+// mỗi uid chỉ thuộc một hồ sơ. Phones là các số điện thoại khách gửi trên page của định danh này
+type CustomerIdentity struct {
+	FacebookUid string      `json:"facebook_uid"`
+	CustomerId  string      `json:"customer_id"`
+	PageId      string      `json:"page_id,omitempty"`
+	PageScopeId string      `json:"page_scope_id,omitempty"`
+	Name        string      `json:"name"`
+	Phones      StringArray `json:"phones"`
+	CreateAt    int64       `json:"create_at"`
+}
+
+// CustomerProfile là hồ sơ đã gộp trả về cho client: các định danh, hội thoại, đơn hàng, thẻ và ghi chú của khách hàng
+type CustomerProfile struct {
+	Customer      *CustomerRecord         `json:"customer"`
+	Identities    []*CustomerIdentity     `json:"identities"`
+	Conversations []*FacebookConversation `json:"conversations"`
+	Orders        []*Order                `json:"orders"`
+	Tags          []*PageTag              `json:"tags"`
+	Notes         []*ConversationNote     `json:"notes"`
+}
+
+func (o *CustomerRecord) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func CustomerRecordFromJson(data io.Reader) *CustomerRecord {
+	var o *CustomerRecord
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *CustomerProfile) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func CustomerProfileFromJson(data io.Reader) *CustomerProfile {
+	var o *CustomerProfile
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *CustomerRecord) PreSave() {
+	if len(o.Id) == 0 {
+		o.Id = NewId()
+	}
+
+	o.CreateAt = GetMillis()
+	o.UpdateAt = o.CreateAt
+	o.DeleteAt = 0
+	o.normalize()
+}
+
+func (o *CustomerRecord) PreUpdate() {
+	o.UpdateAt = GetMillis()
+	o.normalize()
+}
+
+func (o *CustomerRecord) normalize() {
+	o.Name = strings.TrimSpace(o.Name)
+
+	phones := o.Phones
+	o.Phones = StringArray{}
+	o.AddPhones(phones...)
+}
+
+// AddPhones thêm số điện thoại đã chuẩn hoá vào hồ sơ, bỏ qua số trùng, trả về true nếu hồ sơ thay đổi
+func (o *CustomerRecord) AddPhones(phones ...string) bool {
+	var changed bool
+	o.Phones, changed = addPhoneNumbers(o.Phones, phones)
+	return changed
+}
+
+func (o *CustomerRecord) HasPhone(phone string) bool {
+	return hasPhoneNumber(o.Phones, phone)
+}
+
+func (o *CustomerRecord) IsValid() *AppError {
+	if len(o.Id) != 26 {
+		return NewAppError("CustomerRecord.IsValid", "model.customer.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if utf8.RuneCountInString(o.Name) > CUSTOMER_NAME_MAX_LENGTH {
+		return NewAppError("CustomerRecord.IsValid", "model.customer.is_valid.name.app_error", map[string]interface{}{"Max": CUSTOMER_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Phones) > CUSTOMER_PHONES_MAX {
+		return NewAppError("CustomerRecord.IsValid", "model.customer.is_valid.phones.app_error", map[string]interface{}{"Max": CUSTOMER_PHONES_MAX}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (o *CustomerIdentity) PreSave() {
+	if o.CreateAt == 0 {
+		o.CreateAt = GetMillis()
+	}
+}
+
+// AddPhones thêm số điện thoại đã chuẩn hoá vào định danh, trả về true nếu định danh thay đổi
+func (o *CustomerIdentity) AddPhones(phones ...string) bool {
+	var changed bool
+	o.Phones, changed = addPhoneNumbers(o.Phones, phones)
+	return changed
+}
+
+func (o *CustomerIdentity) IsValid() *AppError {
+	if len(o.FacebookUid) == 0 || len(o.FacebookUid) > 64 {
+		return NewAppError("CustomerIdentity.IsValid", "model.customer_identity.is_valid.facebook_uid.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.CustomerId) != 26 {
+		return NewAppError("CustomerIdentity.IsValid", "model.customer_identity.is_valid.customer_id.app_error", nil, "facebook_uid="+o.FacebookUid, http.StatusBadRequest)
+	}
+
+	if len(o.Phones) > CUSTOMER_PHONES_MAX {
+		return NewAppError("CustomerIdentity.IsValid", "model.customer.is_valid.phones.app_error", map[string]interface{}{"Max": CUSTOMER_PHONES_MAX}, "facebook_uid="+o.FacebookUid, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// FacebookUids trả về các facebook uid của hồ sơ
+func (o *CustomerProfile) FacebookUids() []string {
+	uids := make([]string, 0, len(o.Identities))
+	for _, identity := range o.Identities {
+		uids = append(uids, identity.FacebookUid)
+	}
+	return uids
+}
+
+func addPhoneNumbers(list StringArray, phones []string) (StringArray, bool) {
+	if list == nil {
+		list = StringArray{}
+	}
+
+	changed := false
+	for _, phone := range phones {
+		phone = NormalizePhoneNumber(phone)
+		if len(phone) == 0 || hasPhoneNumber(list, phone) || len(list) >= CUSTOMER_PHONES_MAX {
+			continue
+		}
+		list = append(list, phone)
+		changed = true
+	}
+	return list, changed
+}
+
+func hasPhoneNumber(list StringArray, phone string) bool {
+	for _, p := range list {
+		if p == phone {
+			return true
+		}
+	}
+	return false
+}
+
+// ExtractPhoneNumbers trích các số điện thoại trong nội dung tin nhắn, kết quả đã được chuẩn hoá
+func ExtractPhoneNumbers(text string) []string {
+	var phones []string
+	for _, match := range phoneNumberRegexp.FindAllString(text, -1) {
+		if phone := NormalizePhoneNumber(match); len(phone) > 0 {
+			phones = append(phones, phone)
+		}
+	}
+	return phones
+}
+
+// NormalizePhoneNumber bỏ các ký tự phân cách và đưa đầu số +84/84 về 0, trả về rỗng nếu không phải số điện thoại
+func NormalizePhoneNumber(phone string) string {
+	var b strings.Builder
+	for _, c := range phone {
+		if c >= '0' && c <= '9' {
+			b.WriteRune(c)
+		}
+	}
+
+	digits := b.String()
+	if strings.HasPrefix(digits, "84") && len(digits) >= 11 {
+		digits = "0" + digits[2:]
+	}
+
+	if !strings.HasPrefix(digits, "0") || len(digits) < 10 || len(digits) > 11 {
+		return ""
+	}
+	return digits
+}
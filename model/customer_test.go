@@ -0,0 +1,48 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizePhoneNumber(t *testing.T) {
+	assert.Equal(t, "0912345678", NormalizePhoneNumber("0912 345 678"))
+	assert.Equal(t, "0912345678", NormalizePhoneNumber("+84 912.345.678"))
+	assert.Equal(t, "0912345678", NormalizePhoneNumber("84912345678"))
+	assert.Equal(t, "", NormalizePhoneNumber("12345"))
+	assert.Equal(t, "", NormalizePhoneNumber("912345678"))
+}
+
+func TestContainsPhoneNumber(t *testing.T) {
+	assert.True(t, ContainsPhoneNumber("0912345678"))
+	assert.True(t, ContainsPhoneNumber("sdt em la 0912 345 678 nhe"))
+	assert.True(t, ContainsPhoneNumber("goi +84912345678"))
+	assert.False(t, ContainsPhoneNumber("ma don 120912345678"))
+	assert.Equal(t, []string{"0912345678", "0987654321"}, ExtractPhoneNumbers("0912345678,0987654321"))
+}
+
+func TestCustomerAddPhones(t *testing.T) {
+	customer := &CustomerRecord{Name: " Lan "}
+	customer.PreSave()
+	assert.Nil(t, customer.IsValid())
+	assert.Equal(t, "Lan", customer.Name)
+
+	assert.True(t, customer.AddPhones("0912345678", "+84912345678", "abc"))
+	assert.Equal(t, StringArray{"0912345678"}, customer.Phones)
+	assert.False(t, customer.AddPhones("0912.345.678"))
+
+	assert.True(t, customer.AddPhones(ExtractPhoneNumbers("sdt em 0912345678 hoặc 0987654321 nhé")...))
+	assert.Equal(t, StringArray{"0912345678", "0987654321"}, customer.Phones)
+}
+
+func TestCustomerIdentityAddPhones(t *testing.T) {
+	identity := &CustomerIdentity{FacebookUid: "100001", CustomerId: NewId()}
+	assert.True(t, identity.AddPhones("0912 345 678"))
+	assert.False(t, identity.AddPhones("+84912345678"))
+	assert.Equal(t, StringArray{"0912345678"}, identity.Phones)
+	assert.Nil(t, identity.IsValid())
+}
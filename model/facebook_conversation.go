@@ -7,6 +7,39 @@ import (
 	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"encoding/json"
 	"io"
+	"net/http"
+	"strings"
+)
+
+const (
+	// tin nhắn hệ thống sinh ra từ các sự kiện messenger không phải tin nhắn văn bản
+	CONVERSATION_MESSAGE_TYPE_SYSTEM = "system"
+
+	SYSTEM_MESSAGE_POSTBACK           = "postback"
+	SYSTEM_MESSAGE_QUICK_REPLY        = "quick_reply"
+	SYSTEM_MESSAGE_REACTION           = "reaction"
+	SYSTEM_MESSAGE_REFERRAL           = "referral"
+	SYSTEM_MESSAGE_OPTIN              = "optin"
+	SYSTEM_MESSAGE_POLICY_ENFORCEMENT = "policy_enforcement"
+	SYSTEM_MESSAGE_AUTO_MODERATION    = "auto_moderation" // thao tác của luật kiểm duyệt bình luận tự động
+
+	// trạng thái của tin nhắn gửi đi từ papo qua outbox
+	MESSAGE_STATUS_PENDING   = "pending"
+	MESSAGE_STATUS_SENT      = "sent"
+	MESSAGE_STATUS_FAILED    = "failed"
+	MESSAGE_STATUS_DELIVERED = "delivered"
+	MESSAGE_STATUS_READ      = "read"
+
+	// nội dung trả lời gốc được lưu trong Props để worker gửi lại khi cần
+	OUTBOX_PROP_REPLY = "outbox_reply"
+
+	// các thao tác kiểm duyệt bình luận, gửi kèm websocket event để client biết bình luận thay đổi vì sao
+	COMMENT_ACTION_HIDE          = "hide"
+	COMMENT_ACTION_UNHIDE        = "unhide"
+	COMMENT_ACTION_LIKE          = "like"
+	COMMENT_ACTION_UNLIKE        = "unlike"
+	COMMENT_ACTION_DELETE        = "delete"
+	COMMENT_ACTION_PRIVATE_REPLY = "private_reply"
 )
 
 // Đơn vị của hội thoại, type này dùng chung cho cả conversations và comments
@@ -39,6 +72,8 @@ type FacebookConversationMessage struct {
 	//MessageTags 		 	[]StringMap 			`json:"message_tags,omitempty"` // với comment
 	IsHidden  				bool 					`json:"is_hidden,omitempty"`  // chỉ có ở comment
 	IsPrivate 				bool 					`json:"is_private,omitempty"` // chỉ có ở comment
+	IsLiked 				bool 					`json:"is_liked,omitempty"` // chỉ có ở comment, page đã thích bình luận
+	PrivateRepliedAt 		int64 					`json:"private_replied_at,omitempty"` // chỉ có ở comment, thời điểm gửi private reply
 	FileIds       			StringArray     		`json:"file_ids,omitempty"`
 	AttachmentTargetIds     StringArray  			`json:"attachment_target_ids,omitempty"` // chỉ có ở comment
 	AttachmentIds     		StringArray  			`json:"attachment_ids,omitempty"` // chỉ có ở message
@@ -46,6 +81,14 @@ type FacebookConversationMessage struct {
 	UserId     				string 					`json:"user_id,omitempty"`
 	Sent 					bool 					`json:"sent,omitempty"`
 	Delivered 				bool 					`json:"delivered,omitempty"`
+	SystemType 				string 					`json:"system_type,omitempty"` // chỉ có ở tin nhắn hệ thống
+	Props 					StringInterface 		`json:"props,omitempty"`
+	Status 					string 					`json:"status,omitempty"` // chỉ có ở tin nhắn gửi từ papo: pending, sent, failed, delivered, read
+	SendAttempts 			int 					`json:"send_attempts,omitempty"`
+	NextAttemptAt 			int64 					`json:"next_attempt_at,omitempty"`
+	SentAt 					int64 					`json:"sent_at,omitempty"`
+	LastError 				string 					`json:"last_error,omitempty"`
+	ErrorCode 				int 					`json:"error_code,omitempty"` // mã lỗi Graph API của lần gửi cuối
 }
 
 type PostImage struct {
@@ -88,6 +131,7 @@ type FacebookConversation struct {
 	From                     string                 `json:"from"`
 	Snippet                  string                 `json:"snippet"` // cần set max length = 120 ký tự
 	UpdatedTime              string                 `json:"updated_time"`
+	LastActivityAt           int64                  `json:"last_activity_at"` // UpdatedTime quy về milliseconds, dùng để sắp xếp và phân trang
 	PostId                   string                 `json:"post_id,omitempty"`           // chỉ có ở comment
 	CommentId                string                 `json:"comment_id,omitempty"`        // chỉ có ở comment 1351651651_165654
 	PageScopeId              string                 `json:"page_scope_id,omitempty"`        // chỉ có ở message 3328924677161946
@@ -115,6 +159,16 @@ type FacebookConversation struct {
 	TagIds       			StringArray     		`json:"tag_ids,omitempty"`
 	NoteIds       			StringArray     		`json:"note_ids,omitempty"`
 	ReadWatermark 			int64 					`json:"read_watermark,omitempty"` // chỉ có ở message, cho biết người dùng đã đọc tất cả tin nhắn từ thời điểm này về trước
+	HasPhone 				bool 					`json:"has_phone,omitempty"` // khách hàng đã gửi số điện thoại trong hội thoại
+	AssignedTo 				string 					`json:"assigned_to,omitempty"` // user id của nhân viên được giao xử lý hội thoại
+	AssignedBy 				string 					`json:"assigned_by,omitempty"` // người giao, rỗng khi hội thoại được định tuyến tự động
+	AssignedAt 				int64 					`json:"assigned_at,omitempty"`
+	Status 					string 					`json:"status"` // open, pending, snoozed hoặc closed, rỗng là open
+	SnoozedUntil 			int64 					`json:"snoozed_until,omitempty"` // chỉ có khi Status là snoozed
+	StatusUpdateAt 			int64 					`json:"status_update_at,omitempty"`
+	StatusUpdatedBy 		string 					`json:"status_updated_by,omitempty"` // rỗng khi hệ thống tự đổi trạng thái
+	MessagingWindowExpiresAt int64 					`json:"messaging_window_expires_at,omitempty" db:"-"` // chỉ có ở message, thời điểm đóng cửa sổ 24h
+	MessagingWindowRemaining int64 					`json:"messaging_window_remaining,omitempty" db:"-"` // chỉ có ở message, số milliseconds còn lại của cửa sổ 24h
 }
 
 type UpsertConversationResult struct {
@@ -204,44 +258,91 @@ type ConversationReply struct {
 	PendingMessageId 	string 		`json:"pending_message_id"`
 	To 					string 		`json:"to"`
 	PageScopeId 		string 		`json:"page_scope_id"`
-	PageToken 			string 		`json:"page_token"`
-	UserToken 			string 		`json:"user_token"`
-}
-
-type MessageGraphReply struct {
-	MessagingType 		string 		`json:"messaging_type,omitempty"` //https://developers.facebook.com/docs/messenger-platform/send-messages/#messaging_types
-	Recipient 			*Recipient 	`json:"recipient"`
-	Message 			*Message 	`json:"message"`
-}
-
-type Recipient struct {
-	Id 					string 		`json:"id"`
+	Metadata 			string 		`json:"-"` // do outbox gán khi gửi, không nhận từ client
+	AttachmentType 		string 		`json:"attachment_type,omitempty"` // image, video, audio, file; mặc định là image
+	FileId 				string 		`json:"file_id,omitempty"` // file đã upload lên papo, sẽ được upload lên page trước khi gửi
+	AttachmentId 		string 		`json:"attachment_id,omitempty"` // attachment đã upload lên page qua /me/message_attachments
+	QuickReplies 		[]*facebookgraph.QuickReply 			`json:"quick_replies,omitempty"`
+	Template 			*facebookgraph.SendAttachmentPayload 	`json:"template,omitempty"` // generic hoặc button template
+	MessagingType 		string 		`json:"messaging_type,omitempty"` // RESPONSE, UPDATE hoặc MESSAGE_TAG
+	Tag 				string 		`json:"tag,omitempty"` // bắt buộc khi trả lời ngoài cửa sổ 24h
 }
 
-func (p *Recipient) ToJson() string {
+func (p *ConversationReply) ToJson() string {
 	b, _ := json.Marshal(p)
 	return string(b)
 }
 
-type Message struct {
-	Text 				string 		`json:"text,omitempty"`
-	Attachment   		*Attachment 	`json:"attachment,omitempty"`
+func (p *ConversationReply) HasAttachment() bool {
+	return len(p.AttachmentUrl) > 0 || len(p.FileId) > 0 || len(p.AttachmentId) > 0
 }
 
-func (p *Message) ToJson() string {
-	b, _ := json.Marshal(p)
-	return string(b)
+func (p *ConversationReply) GetAttachmentType() string {
+	if len(p.AttachmentType) == 0 {
+		return facebookgraph.SEND_ATTACHMENT_TYPE_IMAGE
+	}
+	return p.AttachmentType
 }
 
-type Attachment struct {
-	Type 			string 			`json:"type,omitempty"` //audio, video, image, file
-	Payload 		*Payload 		`json:"payload,omitempty"`
+// SendRequest dựng request Send API cho tin nhắn trả lời, file trong FileId phải được upload
+// và gán vào AttachmentId trước khi gửi
+func (p *ConversationReply) SendRequest(psId string) *facebookgraph.SendRequest {
+	request := facebookgraph.NewSendRequest(psId).
+		WithText(p.Message).
+		WithMetadata(p.Metadata).
+		WithQuickReplies(p.QuickReplies...)
+
+	if len(p.MessagingType) > 0 {
+		request.MessagingType = p.MessagingType
+	}
+	request.WithTag(p.Tag)
+
+	if p.Template != nil {
+		request.Message.Attachment = &facebookgraph.SendAttachment{Type: facebookgraph.SEND_ATTACHMENT_TYPE_TEMPLATE, Payload: p.Template}
+	} else if len(p.AttachmentId) > 0 {
+		request.WithAttachmentId(p.GetAttachmentType(), p.AttachmentId)
+	} else if len(p.AttachmentUrl) > 0 {
+		request.WithAttachmentUrl(p.GetAttachmentType(), p.AttachmentUrl)
+	}
+
+	return request
 }
 
-type Payload struct {
-	Url 			string 			`json:"url,omitempty"`
-	IsReusable      bool 			`json:"is_reusable,omitempty"`
-	AttachmentId	string 			`json:"attachment_id,omitempty"`
+func (p *ConversationReply) IsValid() *AppError {
+	sources := 0
+	for _, source := range []string{p.AttachmentUrl, p.FileId, p.AttachmentId} {
+		if len(source) > 0 {
+			sources++
+		}
+	}
+
+	if sources > 1 || (sources > 0 && p.Template != nil) {
+		return NewAppError("ConversationReply.IsValid", "model.conversation_reply.is_valid.multiple_attachments.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if p.Type == "comment" {
+		// comment chỉ nhận text và attachment_url
+		if len(p.FileId) > 0 || len(p.AttachmentId) > 0 || len(p.QuickReplies) > 0 || p.Template != nil {
+			return NewAppError("ConversationReply.IsValid", "model.conversation_reply.is_valid.comment_payload.app_error", nil, "", http.StatusBadRequest)
+		}
+
+		if len(p.Message) == 0 && len(p.AttachmentUrl) == 0 {
+			return NewAppError("ConversationReply.IsValid", "model.conversation_reply.is_valid.empty.app_error", nil, "", http.StatusBadRequest)
+		}
+
+		return nil
+	}
+
+	request := p.SendRequest("validate")
+	if len(p.FileId) > 0 {
+		request.WithAttachmentId(p.GetAttachmentType(), p.FileId)
+	}
+
+	if err := request.IsValid(); err != nil {
+		return NewAppError("ConversationReply.IsValid", "model.conversation_reply.is_valid.send_api.app_error", map[string]interface{}{"Reason": err.Error()}, err.Error(), http.StatusBadRequest)
+	}
+
+	return nil
 }
 
 func ConversationReplyFromJson(data io.Reader) *ConversationReply {
@@ -250,6 +351,25 @@ func ConversationReplyFromJson(data io.Reader) *ConversationReply {
 	return u
 }
 
+// OutboxReply trả về nội dung trả lời đã lưu khi tin nhắn được đưa vào outbox
+func (p *FacebookConversationMessage) OutboxReply() *ConversationReply {
+	if p.Props == nil {
+		return nil
+	}
+
+	raw, ok := p.Props[OUTBOX_PROP_REPLY].(string)
+	if !ok || len(raw) == 0 {
+		return nil
+	}
+
+	return ConversationReplyFromJson(strings.NewReader(raw))
+}
+
+// IsOutboxMessage cho biết tin nhắn được gửi từ papo qua outbox
+func (p *FacebookConversationMessage) IsOutboxMessage() bool {
+	return len(p.Status) > 0
+}
+
 func FacebookUserFromJson(data io.Reader) *FacebookUser {
 	var u *FacebookUser
 	json.NewDecoder(data).Decode(&u)
@@ -264,6 +384,11 @@ func (p *FacebookConversationMessage) PreSave() {
 	}
 }
 
+func (p *FacebookConversationMessage) ToJson() string {
+	b, _ := json.Marshal(p)
+	return string(b)
+}
+
 func FacebookConversationMessageToJson(p []*FacebookConversationMessage) string {
 	b, _ := json.Marshal(p)
 	return string(b)
@@ -276,8 +401,12 @@ func (p *FacebookConversation) PreSave() {
 		p.Id = NewId()
 	}
 	p.Seen = false
+	if len(p.Status) == 0 {
+		p.Status = CONVERSATION_STATUS_OPEN
+	}
 	p.CreateAt = GetMillis()
 	p.UpdateAt = p.CreateAt
+	p.LastActivityAt = ParseFacebookTime(p.UpdatedTime)
 }
 
 func (p *FacebookAttachmentImage) PreSave() {
@@ -313,6 +442,12 @@ func ConversationMessageResponseListToJson(p *ConversationMessageResponse) strin
 	return string(b)
 }
 
+func FacebookConversationFromJson(data io.Reader) *FacebookConversation {
+	var p *FacebookConversation
+	json.NewDecoder(data).Decode(&p)
+	return p
+}
+
 // decode input và trả về danh sách các đối tượng FacebookConversations
 func FacebookConversationListFromJson(data io.Reader) []*FacebookConversation {
 	var cvs []*FacebookConversation
@@ -0,0 +1,48 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"strconv"
+)
+
+const (
+	PROCESSED_EVENT_KEY_MESSAGE = "message:"
+	PROCESSED_EVENT_KEY_COMMENT = "comment:"
+	PROCESSED_EVENT_KEY_SYSTEM  = "system:"
+
+	// sự kiện đã nhận xử lý nhưng chưa hoàn tất sau khoảng này (server dừng giữa chừng) được nhận xử lý lại
+	PROCESSED_EVENT_CLAIM_TIMEOUT_MILLIS = 5 * 60 * 1000
+)
+
+// FacebookProcessedEvent là sổ ghi các sự kiện webhook đã xử lý.
+// Facebook gửi webhook theo kiểu at-least-once nên một tin nhắn (mid) hoặc comment (comment_id)
+// có thể được gửi lại nhiều lần, (PageId, EventKey) là khóa chính để chỉ xử lý một lần.
+// ProcessedAt bằng 0 nghĩa là sự kiện đang được xử lý, chỉ được đặt sau khi đã lưu xong dữ liệu.
+type FacebookProcessedEvent struct {
+	PageId      string `json:"page_id"`
+	EventKey    string `json:"event_key"`
+	CreateAt    int64  `json:"create_at"`
+	ProcessedAt int64  `json:"processed_at"`
+}
+
+func (e *FacebookProcessedEvent) PreSave() {
+	if e.CreateAt == 0 {
+		e.CreateAt = GetMillis()
+	}
+}
+
+func MessageProcessedEventKey(mid string) string {
+	return PROCESSED_EVENT_KEY_MESSAGE + mid
+}
+
+func CommentProcessedEventKey(commentId string) string {
+	return PROCESSED_EVENT_KEY_COMMENT + commentId
+}
+
+// SystemProcessedEventKey dùng cho postback, reaction, referral... vốn không có mid riêng,
+// Facebook gửi lại với cùng sender và timestamp
+func SystemProcessedEventKey(systemType, senderId string, timestamp int64) string {
+	return PROCESSED_EVENT_KEY_SYSTEM + systemType + ":" + senderId + ":" + strconv.FormatInt(timestamp, 10)
+}
@@ -0,0 +1,91 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+)
+
+const (
+	WEBHOOK_EVENT_STATUS_PENDING    = "pending"
+	WEBHOOK_EVENT_STATUS_PROCESSING = "processing"
+	WEBHOOK_EVENT_STATUS_RETRYING   = "retrying"
+	WEBHOOK_EVENT_STATUS_PROCESSED  = "processed"
+	WEBHOOK_EVENT_STATUS_FAILED     = "failed"
+
+	WEBHOOK_EVENT_LAST_ERROR_MAX_LENGTH = 1024
+)
+
+// FacebookWebhookEvent lưu nguyên payload webhook Facebook gửi về để xử lý bất đồng bộ và chạy lại khi cần
+type FacebookWebhookEvent struct {
+	Id            string `json:"id"`
+	Object        string `json:"object"`
+	Payload       string `json:"payload"`
+	Status        string `json:"status"`
+	Attempts      int    `json:"attempts"`
+	LastError     string `json:"last_error"`
+	NextAttemptAt int64  `json:"next_attempt_at"`
+	CreateAt      int64  `json:"create_at"`
+	UpdateAt      int64  `json:"update_at"`
+	ProcessedAt   int64  `json:"processed_at"`
+}
+
+func (e *FacebookWebhookEvent) PreSave() {
+	if e.Id == "" {
+		e.Id = NewId()
+	}
+
+	if e.Status == "" {
+		e.Status = WEBHOOK_EVENT_STATUS_PENDING
+	}
+
+	e.CreateAt = GetMillis()
+	e.UpdateAt = e.CreateAt
+	e.NextAttemptAt = e.CreateAt
+}
+
+func (e *FacebookWebhookEvent) PreUpdate() {
+	e.UpdateAt = GetMillis()
+
+	if len(e.LastError) > WEBHOOK_EVENT_LAST_ERROR_MAX_LENGTH {
+		e.LastError = e.LastError[:WEBHOOK_EVENT_LAST_ERROR_MAX_LENGTH]
+	}
+}
+
+func (e *FacebookWebhookEvent) IsValid() *AppError {
+	if len(e.Id) != 26 {
+		return NewAppError("FacebookWebhookEvent.IsValid", "model.facebook_webhook_event.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(e.Payload) == 0 {
+		return NewAppError("FacebookWebhookEvent.IsValid", "model.facebook_webhook_event.is_valid.payload.app_error", nil, "id="+e.Id, http.StatusBadRequest)
+	}
+
+	switch e.Status {
+	case WEBHOOK_EVENT_STATUS_PENDING, WEBHOOK_EVENT_STATUS_PROCESSING, WEBHOOK_EVENT_STATUS_RETRYING,
+		WEBHOOK_EVENT_STATUS_PROCESSED, WEBHOOK_EVENT_STATUS_FAILED:
+	default:
+		return NewAppError("FacebookWebhookEvent.IsValid", "model.facebook_webhook_event.is_valid.status.app_error", nil, "id="+e.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (e *FacebookWebhookEvent) ToJson() string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
+
+func FacebookWebhookEventFromJson(data io.Reader) *FacebookWebhookEvent {
+	var e *FacebookWebhookEvent
+	json.NewDecoder(data).Decode(&e)
+	return e
+}
+
+func FacebookWebhookEventsToJson(e []*FacebookWebhookEvent) string {
+	b, _ := json.Marshal(e)
+	return string(b)
+}
@@ -18,6 +18,7 @@ const(
 	PAGE_STATUS_INITIALIZED 			= "initialized"
 	PAGE_STATUS_ERROR 					= "error"
 	PAGE_STATUS_BLOCKED 				= "blocked"
+	PAGE_STATUS_NEEDS_REAUTH 			= "needs_reauth" // page token bị Facebook vô hiệu (lỗi 190), cần đăng nhập lại
 )
 // Model Fanpage sẽ chỉ gồm các trường sau đây, một số trường trong Server cũ không phù hợp đã được loại bỏ
 // Trường users trong server cũ là không cần thiết, vì hiếm có trường hợp nào cần query tất cả users của một
@@ -163,4 +164,9 @@ func PagesStatusFromJson(data io.Reader) *PagesStatus {
 	var p *PagesStatus
 	json.NewDecoder(data).Decode(&p)
 	return p
-}
\ No newline at end of file
+}
+
+// Sanitize bỏ các thông tin chỉ admin của page được xem
+func (o *Fanpage) Sanitize() {
+	o.BlockAt = 0
+}
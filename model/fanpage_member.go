@@ -10,12 +10,15 @@ import (
 	"strings"
 )
 
+// roles của người kết nối page, member tạo trước khi có role trên page cũng được xem là admin
+const FANPAGE_MEMBER_ADMIN_ROLES = FANPAGE_AGENT_ROLE_ID + " " + FANPAGE_ADMIN_ROLE_ID
+
 type FanpageMember struct {
 	FanpageId   string `json:"fanpage_id"`
 	PageId 		string `json:"page_id"`
 	UserId      string `json:"user_id"`
 	Roles       string `json:"roles,omitempty"`
-	AccessToken string `json:"access_token"`
+	AccessToken string `json:"-"` // luôn được mã hoá trước khi lưu, không bao giờ trả về client
 	LastViewedAt  int64     `json:"last_viewed_at"`
 	MsgCount      int64     `json:"msg_count"`
 	NotifyProps   StringMap `json:"notify_props"`
@@ -64,5 +67,50 @@ func (o *FanpageMember) IsValid() *AppError {
 }
 
 func (o *FanpageMember) GetRoles() []string {
+	if len(strings.TrimSpace(o.Roles)) == 0 {
+		return strings.Fields(FANPAGE_MEMBER_ADMIN_ROLES)
+	}
 	return strings.Fields(o.Roles)
 }
+
+// IsValidFanpageMemberRoles kiểm tra roles chỉ gồm các role trên page và không rỗng
+func IsValidFanpageMemberRoles(roles string) bool {
+	fields := strings.Fields(roles)
+	if len(fields) == 0 {
+		return false
+	}
+
+	for _, role := range fields {
+		if role != FANPAGE_VIEWER_ROLE_ID && role != FANPAGE_AGENT_ROLE_ID && role != FANPAGE_ADMIN_ROLE_ID {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAdmin cho biết member có quyền quản lý thành viên của page hay không
+func (o *FanpageMember) IsAdmin() bool {
+	for _, role := range o.GetRoles() {
+		if role == FANPAGE_ADMIN_ROLE_ID {
+			return true
+		}
+	}
+	return false
+}
+
+// FanpageMemberInvite là yêu cầu mời thành viên vào page qua email
+type FanpageMemberInvite struct {
+	Emails []string `json:"emails"`
+	Roles  string   `json:"roles"`
+}
+
+func FanpageMemberInviteFromJson(data io.Reader) *FanpageMemberInvite {
+	var o *FanpageMemberInvite
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *FanpageMemberInvite) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
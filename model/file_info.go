@@ -43,6 +43,9 @@ type FileInfo struct {
 	Id              string  `json:"id"`
 	CreatorId       string  `json:"user_id"`
 	PostId          string  `json:"post_id,omitempty"`
+	PageId          string  `json:"page_id,omitempty"`
+	MessageId       string  `json:"message_id,omitempty"` // tin nhắn hoặc bình luận chứa file
+	SourceUrl       string  `json:"-"`                    // url gốc trên facebook của attachment được sao lưu về papo
 	CreateAt        int64   `json:"create_at"`
 	UpdateAt        int64   `json:"update_at"`
 	DeleteAt        int64   `json:"delete_at"`
@@ -18,6 +18,9 @@ const (
 	JOB_TYPE_LDAP_SYNC                      = "ldap_sync"
 	JOB_TYPE_MIGRATIONS                     = "migrations"
 	JOB_TYPE_PLUGINS                        = "plugins"
+	JOB_TYPE_FANPAGE_INIT                   = "fanpage_init"
+	JOB_TYPE_FANPAGE_SYNC                   = "fanpage_sync"
+	JOB_TYPE_AUTO_MESSAGE_TASK              = "auto_message_task"
 
 	JOB_STATUS_PENDING          = "pending"
 	JOB_STATUS_IN_PROGRESS      = "in_progress"
@@ -25,6 +28,36 @@ const (
 	JOB_STATUS_ERROR            = "error"
 	JOB_STATUS_CANCEL_REQUESTED = "cancel_requested"
 	JOB_STATUS_CANCELED         = "canceled"
+
+	// dữ liệu của job khởi tạo page, cursor là link paging của Graph API đã graph tới
+	JOB_DATA_FANPAGE_INIT_PAGE_ID = "page_id"
+	JOB_DATA_FANPAGE_INIT_USER_ID = "user_id"
+	JOB_DATA_FANPAGE_INIT_PHASE   = "phase"
+	JOB_DATA_FANPAGE_INIT_CURSOR  = "cursor"
+
+	FANPAGE_INIT_PHASE_MESSAGES = "messages"
+	FANPAGE_INIT_PHASE_POSTS    = "posts"
+	FANPAGE_INIT_PHASE_DONE     = "done"
+
+	// dữ liệu của job đồng bộ lại page, since là mốc thời gian (milliseconds) bắt đầu đồng bộ.
+	// Job định kỳ không có page_id sẽ tạo job đồng bộ cho từng page.
+	JOB_DATA_FANPAGE_SYNC_PAGE_ID        = "page_id"
+	JOB_DATA_FANPAGE_SYNC_USER_ID        = "user_id"
+	JOB_DATA_FANPAGE_SYNC_PHASE          = "phase"
+	JOB_DATA_FANPAGE_SYNC_CURSOR         = "cursor"
+	JOB_DATA_FANPAGE_SYNC_SINCE          = "since"
+	JOB_DATA_FANPAGE_SYNC_MESSAGES_ADDED = "messages_added"
+	JOB_DATA_FANPAGE_SYNC_COMMENTS_ADDED = "comments_added"
+
+	FANPAGE_SYNC_PHASE_MESSAGES = "messages"
+	FANPAGE_SYNC_PHASE_POSTS    = "posts"
+	FANPAGE_SYNC_PHASE_DONE     = "done"
+
+	// job gửi tin nhắn của chiến dịch, cursor là next_cursor của trang hội thoại đã gửi tới
+	JOB_DATA_AUTO_MESSAGE_TASK_ID      = "task_id"
+	JOB_DATA_AUTO_MESSAGE_TASK_USER_ID = "user_id"
+	JOB_DATA_AUTO_MESSAGE_TASK_CURSOR  = "cursor"
+	JOB_DATA_AUTO_MESSAGE_TASK_DONE    = "done"
 )
 
 type Job struct {
@@ -56,6 +89,9 @@ func (j *Job) IsValid() *AppError {
 	case JOB_TYPE_MESSAGE_EXPORT:
 	case JOB_TYPE_MIGRATIONS:
 	case JOB_TYPE_PLUGINS:
+	case JOB_TYPE_FANPAGE_INIT:
+	case JOB_TYPE_FANPAGE_SYNC:
+	case JOB_TYPE_AUTO_MESSAGE_TASK:
 	default:
 		return NewAppError("Job.IsValid", "model.job.is_valid.type.app_error", nil, "id="+j.Id, http.StatusBadRequest)
 	}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// https://developers.facebook.com/docs/messenger-platform/policy/policy-overview#24hours_window
+const (
+	MESSAGING_WINDOW             = 24 * 60 * 60 * 1000     // 24 giờ kể từ tin nhắn cuối của khách hàng
+	MESSAGING_WINDOW_HUMAN_AGENT = 7 * 24 * 60 * 60 * 1000 // tag HUMAN_AGENT chỉ dùng được trong 7 ngày
+
+	MESSAGE_TAG_HUMAN_AGENT            = "HUMAN_AGENT"
+	MESSAGE_TAG_POST_PURCHASE_UPDATE   = "POST_PURCHASE_UPDATE"
+	MESSAGE_TAG_CONFIRMED_EVENT_UPDATE = "CONFIRMED_EVENT_UPDATE"
+	MESSAGE_TAG_ACCOUNT_UPDATE         = "ACCOUNT_UPDATE"
+)
+
+// các tag được phép dùng khi gửi tin nhắn ngoài cửa sổ 24h
+var ALLOWED_MESSAGE_TAGS = []string{
+	MESSAGE_TAG_HUMAN_AGENT,
+	MESSAGE_TAG_POST_PURCHASE_UPDATE,
+	MESSAGE_TAG_CONFIRMED_EVENT_UPDATE,
+	MESSAGE_TAG_ACCOUNT_UPDATE,
+}
+
+// thời gian trong hội thoại được lưu theo nhiều định dạng: webhook dùng RFC3339, graph dùng dạng 2006-01-02T15:04:05-0700
+var facebookTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05-0700",
+}
+
+type MessagingWindow struct {
+	ConversationId      string   `json:"conversation_id"`
+	Open                bool     `json:"open"`
+	LastUserMessageAt   int64    `json:"last_user_message_at"`
+	ExpiresAt           int64    `json:"expires_at"`
+	Remaining           int64    `json:"remaining"`
+	HumanAgentExpiresAt int64    `json:"human_agent_expires_at"`
+	AllowedTags         []string `json:"allowed_tags"`
+}
+
+func (w *MessagingWindow) ToJson() string {
+	b, _ := json.Marshal(w)
+	return string(b)
+}
+
+func IsAllowedMessageTag(tag string) bool {
+	for _, allowed := range ALLOWED_MESSAGE_TAGS {
+		if tag == allowed {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseFacebookTime đổi thời gian dạng chuỗi trong hội thoại sang milliseconds, trả về 0 nếu không đọc được
+func ParseFacebookTime(value string) int64 {
+	if len(value) == 0 {
+		return 0
+	}
+
+	for _, layout := range facebookTimeLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t.UnixNano() / int64(time.Millisecond)
+		}
+	}
+
+	if millis, err := strconv.ParseInt(value, 10, 64); err == nil {
+		return millis
+	}
+
+	return 0
+}
+
+// GetMessagingWindow tính cửa sổ nhắn tin của hội thoại tại thời điểm now (milliseconds).
+// Hội thoại chưa ghi nhận tin nhắn nào của khách hàng được coi là đã đóng cửa sổ.
+func (c *FacebookConversation) GetMessagingWindow(now int64) *MessagingWindow {
+	window := &MessagingWindow{
+		ConversationId:    c.Id,
+		LastUserMessageAt: ParseFacebookTime(c.LastUserMessageAt),
+	}
+
+	if window.LastUserMessageAt > 0 {
+		window.ExpiresAt = window.LastUserMessageAt + MESSAGING_WINDOW
+		window.HumanAgentExpiresAt = window.LastUserMessageAt + MESSAGING_WINDOW_HUMAN_AGENT
+	}
+
+	if window.ExpiresAt > now {
+		window.Open = true
+		window.Remaining = window.ExpiresAt - now
+	}
+
+	for _, tag := range ALLOWED_MESSAGE_TAGS {
+		if tag == MESSAGE_TAG_HUMAN_AGENT && window.HumanAgentExpiresAt <= now {
+			continue
+		}
+		window.AllowedTags = append(window.AllowedTags, tag)
+	}
+
+	return window
+}
+
+// PopulateMessagingWindow gán thời gian còn lại của cửa sổ 24h cho hội thoại tin nhắn để client hiển thị đếm ngược
+func (c *FacebookConversation) PopulateMessagingWindow(now int64) {
+	if c.Type != "message" {
+		return
+	}
+
+	window := c.GetMessagingWindow(now)
+	c.MessagingWindowExpiresAt = window.ExpiresAt
+	c.MessagingWindowRemaining = window.Remaining
+}
@@ -0,0 +1,51 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseFacebookTime(t *testing.T) {
+	expected := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC).UnixNano() / int64(time.Millisecond)
+
+	assert.Equal(t, expected, ParseFacebookTime("2019-03-01T10:00:00Z"))
+	assert.Equal(t, expected, ParseFacebookTime("2019-03-01T10:00:00+0000"))
+	assert.Equal(t, expected, ParseFacebookTime("2019-03-01T17:00:00+07:00"))
+	assert.Equal(t, int64(0), ParseFacebookTime(""))
+	assert.Equal(t, int64(0), ParseFacebookTime("yesterday"))
+}
+
+func TestGetMessagingWindow(t *testing.T) {
+	lastUserMessageAt := time.Date(2019, 3, 1, 10, 0, 0, 0, time.UTC)
+	conversation := &FacebookConversation{Id: "c1", Type: "message", LastUserMessageAt: lastUserMessageAt.Format(time.RFC3339)}
+	millis := func(d time.Duration) int64 {
+		return lastUserMessageAt.Add(d).UnixNano() / int64(time.Millisecond)
+	}
+
+	window := conversation.GetMessagingWindow(millis(time.Hour))
+	assert.True(t, window.Open)
+	assert.Equal(t, int64(23*time.Hour/time.Millisecond), window.Remaining)
+	assert.Equal(t, ALLOWED_MESSAGE_TAGS, window.AllowedTags)
+
+	window = conversation.GetMessagingWindow(millis(48 * time.Hour))
+	assert.False(t, window.Open)
+	assert.Equal(t, int64(0), window.Remaining)
+	assert.Contains(t, window.AllowedTags, MESSAGE_TAG_HUMAN_AGENT)
+
+	window = conversation.GetMessagingWindow(millis(8 * 24 * time.Hour))
+	assert.False(t, window.Open)
+	assert.NotContains(t, window.AllowedTags, MESSAGE_TAG_HUMAN_AGENT)
+	assert.Contains(t, window.AllowedTags, MESSAGE_TAG_POST_PURCHASE_UPDATE)
+
+	conversation.PopulateMessagingWindow(millis(time.Hour))
+	assert.Equal(t, millis(24*time.Hour), conversation.MessagingWindowExpiresAt)
+	assert.Equal(t, int64(23*time.Hour/time.Millisecond), conversation.MessagingWindowRemaining)
+
+	unknown := &FacebookConversation{Id: "c2", Type: "message"}
+	assert.False(t, unknown.GetMessagingWindow(millis(0)).Open)
+}
@@ -0,0 +1,200 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	// điều kiện để một bình luận khớp luật kiểm duyệt
+	MODERATION_MATCH_KEYWORD      = "keyword"      // chứa một trong các từ khoá, không phân biệt hoa thường
+	MODERATION_MATCH_REGEX        = "regex"        // khớp biểu thức chính quy
+	MODERATION_MATCH_PHONE        = "phone"        // chứa số điện thoại
+	MODERATION_MATCH_URL          = "url"          // chứa đường dẫn
+	MODERATION_MATCH_BLOCKED_USER = "blocked_user" // người viết nằm trong danh sách FacebookUid bị chặn
+
+	// thao tác tự động khi bình luận khớp luật
+	MODERATION_ACTION_HIDE          = "hide"
+	MODERATION_ACTION_DELETE        = "delete"
+	MODERATION_ACTION_TAG           = "tag"
+	MODERATION_ACTION_PRIVATE_REPLY = "private_reply"
+
+	MODERATION_RULE_NAME_MAX_LENGTH    = 64
+	MODERATION_RULE_PATTERN_MAX_LENGTH = 512
+	MODERATION_RULE_KEYWORDS_MAX       = 200
+)
+
+var urlRegexp = regexp.MustCompile(`(?i)(?:https?://|www\.)\S+|\b[a-z0-9-]+\.(?:com|net|org|vn|info|biz|shop|store|online|site|xyz|me|io|co)\b`)
+
+// ContainsUrl cho biết nội dung có chứa đường dẫn hay không
+func ContainsUrl(text string) bool {
+	return urlRegexp.MatchString(text)
+}
+
+// ModerationRule là luật kiểm duyệt bình luận tự động của một page, được áp dụng cho mỗi bình luận mới của khách hàng
+type ModerationRule struct {
+	Id           string         `json:"id"`
+	PageId       string         `json:"page_id"`
+	Name         string         `json:"name"`
+	Enabled      bool           `json:"enabled"`
+	MatchType    string         `json:"match_type"`
+	Keywords     StringArray    `json:"keywords,omitempty"`      // chỉ dùng với match_type keyword
+	Pattern      string         `json:"pattern,omitempty"`       // chỉ dùng với match_type regex
+	FacebookUids StringArray    `json:"facebook_uids,omitempty"` // chỉ dùng với match_type blocked_user
+	Action       string         `json:"action"`
+	TagId        string         `json:"tag_id,omitempty"`        // PageTag gắn vào hội thoại khi action là tag
+	ReplyMessage string         `json:"reply_message,omitempty"` // nội dung trả lời riêng khi action là private_reply
+	CreatorId    string         `json:"creator_id"`
+	CreateAt     int64          `json:"create_at"`
+	UpdateAt     int64          `json:"update_at"`
+	DeleteAt     int64          `json:"delete_at"`
+	regexp       *regexp.Regexp `db:"-"`
+}
+
+func (o *ModerationRule) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func ModerationRuleFromJson(data io.Reader) *ModerationRule {
+	var o *ModerationRule
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func ModerationRuleListToJson(l []*ModerationRule) string {
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+func (o *ModerationRule) PreSave() {
+	if len(o.Id) == 0 {
+		o.Id = NewId()
+	}
+
+	o.CreateAt = GetMillis()
+	o.UpdateAt = o.CreateAt
+	o.DeleteAt = 0
+	o.normalize()
+}
+
+func (o *ModerationRule) PreUpdate() {
+	o.UpdateAt = GetMillis()
+	o.normalize()
+}
+
+func (o *ModerationRule) normalize() {
+	o.Name = strings.TrimSpace(o.Name)
+
+	var keywords StringArray
+	for _, keyword := range o.Keywords {
+		if keyword = strings.ToLower(strings.TrimSpace(keyword)); len(keyword) > 0 {
+			keywords = append(keywords, keyword)
+		}
+	}
+	o.Keywords = keywords
+}
+
+func (o *ModerationRule) IsValid() *AppError {
+	if len(o.Id) != 26 {
+		return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.PageId) == 0 {
+		return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.page_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Name) == 0 || utf8.RuneCountInString(o.Name) > MODERATION_RULE_NAME_MAX_LENGTH {
+		return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.name.app_error", map[string]interface{}{"Max": MODERATION_RULE_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	switch o.MatchType {
+	case MODERATION_MATCH_KEYWORD:
+		if len(o.Keywords) == 0 || len(o.Keywords) > MODERATION_RULE_KEYWORDS_MAX {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.keywords.app_error", map[string]interface{}{"Max": MODERATION_RULE_KEYWORDS_MAX}, "id="+o.Id, http.StatusBadRequest)
+		}
+	case MODERATION_MATCH_REGEX:
+		if len(o.Pattern) == 0 || len(o.Pattern) > MODERATION_RULE_PATTERN_MAX_LENGTH {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.pattern.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+		}
+		if _, err := regexp.Compile(o.Pattern); err != nil {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.pattern.app_error", nil, "id="+o.Id+", "+err.Error(), http.StatusBadRequest)
+		}
+	case MODERATION_MATCH_BLOCKED_USER:
+		if len(o.FacebookUids) == 0 {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.facebook_uids.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+		}
+	case MODERATION_MATCH_PHONE, MODERATION_MATCH_URL:
+	default:
+		return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.match_type.app_error", nil, "id="+o.Id+", match_type="+o.MatchType, http.StatusBadRequest)
+	}
+
+	switch o.Action {
+	case MODERATION_ACTION_TAG:
+		if len(o.TagId) == 0 {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.tag_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+		}
+	case MODERATION_ACTION_PRIVATE_REPLY:
+		if len(strings.TrimSpace(o.ReplyMessage)) == 0 {
+			return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.reply_message.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+		}
+	case MODERATION_ACTION_HIDE, MODERATION_ACTION_DELETE:
+	default:
+		return NewAppError("ModerationRule.IsValid", "model.moderation_rule.is_valid.action.app_error", nil, "id="+o.Id+", action="+o.Action, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+// CompilePattern biên dịch sẵn biểu thức chính quy của luật, cần gọi trước khi luật được dùng chung giữa các goroutine
+// vì Match không ghi lại kết quả biên dịch lên luật
+func (o *ModerationRule) CompilePattern() {
+	if o.MatchType != MODERATION_MATCH_REGEX {
+		return
+	}
+
+	if compiled, err := regexp.Compile(o.Pattern); err == nil {
+		o.regexp = compiled
+	}
+}
+
+// Match cho biết bình luận có nội dung text của người viết from có khớp luật hay không
+func (o *ModerationRule) Match(text string, from string) bool {
+	switch o.MatchType {
+	case MODERATION_MATCH_KEYWORD:
+		lower := strings.ToLower(text)
+		for _, keyword := range o.Keywords {
+			if len(keyword) > 0 && strings.Contains(lower, keyword) {
+				return true
+			}
+		}
+	case MODERATION_MATCH_REGEX:
+		compiled := o.regexp
+		if compiled == nil {
+			var err error
+			if compiled, err = regexp.Compile(o.Pattern); err != nil {
+				return false
+			}
+		}
+		return compiled.MatchString(text)
+	case MODERATION_MATCH_PHONE:
+		return ContainsPhoneNumber(text)
+	case MODERATION_MATCH_URL:
+		return ContainsUrl(text)
+	case MODERATION_MATCH_BLOCKED_USER:
+		for _, uid := range o.FacebookUids {
+			if uid == from {
+				return true
+			}
+		}
+	}
+
+	return false
+}
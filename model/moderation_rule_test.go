@@ -0,0 +1,66 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestModerationRuleIsValid(t *testing.T) {
+	rule := &ModerationRule{
+		PageId:    "1234",
+		Name:      "Chặn link",
+		MatchType: MODERATION_MATCH_URL,
+		Action:    MODERATION_ACTION_HIDE,
+	}
+	rule.PreSave()
+	assert.Nil(t, rule.IsValid())
+
+	rule.MatchType = MODERATION_MATCH_REGEX
+	rule.Pattern = "(abc"
+	assert.NotNil(t, rule.IsValid())
+
+	rule.MatchType = MODERATION_MATCH_KEYWORD
+	assert.NotNil(t, rule.IsValid())
+
+	rule.Keywords = StringArray{"shopee"}
+	rule.Action = MODERATION_ACTION_TAG
+	assert.NotNil(t, rule.IsValid())
+
+	rule.TagId = NewId()
+	assert.Nil(t, rule.IsValid())
+
+	rule.Action = "ban"
+	assert.NotNil(t, rule.IsValid())
+}
+
+func TestModerationRuleMatch(t *testing.T) {
+	keyword := &ModerationRule{MatchType: MODERATION_MATCH_KEYWORD, Keywords: StringArray{" Shopee ", ""}}
+	keyword.normalize()
+	assert.True(t, keyword.Match("mua bên SHOPEE rẻ hơn", "1"))
+	assert.False(t, keyword.Match("còn hàng không shop", "1"))
+
+	regex := &ModerationRule{MatchType: MODERATION_MATCH_REGEX, Pattern: `(?i)inbox\s+giá`}
+	assert.True(t, regex.Match("Inbox  giá nhé", "1"))
+	assert.False(t, regex.Match("giá bao nhiêu", "1"))
+	assert.Nil(t, regex.regexp)
+	regex.CompilePattern()
+	assert.NotNil(t, regex.regexp)
+	assert.True(t, regex.Match("Inbox  giá nhé", "1"))
+
+	phone := &ModerationRule{MatchType: MODERATION_MATCH_PHONE}
+	assert.True(t, phone.Match("sđt 0912 345 678", "1"))
+	assert.False(t, phone.Match("size 40", "1"))
+
+	url := &ModerationRule{MatchType: MODERATION_MATCH_URL}
+	assert.True(t, url.Match("xem tại https://example.com/a", "1"))
+	assert.True(t, url.Match("vào shopabc.vn mà mua", "1"))
+	assert.False(t, url.Match("đẹp quá. mua thế nào", "1"))
+
+	blocked := &ModerationRule{MatchType: MODERATION_MATCH_BLOCKED_USER, FacebookUids: StringArray{"100"}}
+	assert.True(t, blocked.Match("hello", "100"))
+	assert.False(t, blocked.Match("hello", "200"))
+}
@@ -1,14 +1,87 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
 package model
 
 import (
 	"encoding/json"
 	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
 )
 
+const (
+	ORDER_ITEMS_MAX                   = 100
+	ORDER_ITEM_NAME_MAX_LENGTH        = 256
+	ORDER_ITEM_SKU_MAX_LENGTH         = 64
+	ORDER_CUSTOMER_NAME_MAX_LENGTH    = 128
+	ORDER_CUSTOMER_PHONE_MAX_LENGTH   = 32
+	ORDER_CUSTOMER_ADDRESS_MAX_LENGTH = 512
+	ORDER_NOTES_MAX_LENGTH            = 2000
+
+	ORDER_SEARCH_DEFAULT_LIMIT = 30
+	ORDER_SEARCH_MAX_LIMIT     = 200
+)
+
+// OrderItem là một dòng sản phẩm trong đơn hàng, giá tính theo đơn vị nhỏ nhất của tiền tệ (đồng)
+type OrderItem struct {
+	ProductId string `json:"product_id,omitempty"` // sản phẩm trong danh mục của page, để trống nếu nhập tay
+	VariantId string `json:"variant_id,omitempty"`
+	Sku       string `json:"sku,omitempty"`
+	Name      string `json:"name"`
+	Quantity  int    `json:"quantity"`
+	Price     int64  `json:"price"`
+}
+
+func (o *OrderItem) Amount() int64 {
+	return int64(o.Quantity) * o.Price
+}
+
+// OrderItems được lưu thành một cột json trong bảng Orders
+type OrderItems []*OrderItem
+
+// Order là đơn hàng tạo từ hội thoại của khách hàng trên một page
 type Order struct {
-	Id 					string 			`json:"id"`
-	CustomerName 		string 			`json:"customer_name"`
-	CreateAt 			int64 			`json:"create_at"`
+	Id              string     `json:"id"`
+	PageId          string     `json:"page_id"`
+	ConversationId  string     `json:"conversation_id,omitempty"`
+	FacebookUid     string     `json:"facebook_uid,omitempty"` // khách hàng, mặc định là người gửi của hội thoại
+	CustomerName    string     `json:"customer_name"`
+	CustomerPhone   string     `json:"customer_phone"`
+	CustomerAddress string     `json:"customer_address"`
+	Items           OrderItems `json:"items"`
+	Subtotal        int64      `json:"subtotal"` // tổng tiền các dòng sản phẩm
+	Discount        int64      `json:"discount"`
+	ShippingFee     int64      `json:"shipping_fee"`
+	Total           int64      `json:"total"` // Subtotal - Discount + ShippingFee
+	StatusId        string     `json:"status_id"`
+	AssignedTo      string     `json:"assigned_to,omitempty"` // user id của nhân viên phụ trách đơn
+	CreatorId       string     `json:"creator_id"`
+	Notes           string     `json:"notes,omitempty"`
+	CreateAt        int64      `json:"create_at"`
+	UpdateAt        int64      `json:"update_at"`
+	DeleteAt        int64      `json:"delete_at"`
+}
+
+// OrderSearchOptions là các điều kiện lọc đơn hàng, được kết hợp với nhau bằng AND
+type OrderSearchOptions struct {
+	PageIds        []string `json:"page_ids"` // bắt buộc
+	StatusIds      []string `json:"status_ids,omitempty"`
+	AssignedTo     string   `json:"assigned_to,omitempty"`
+	CreatorId      string   `json:"creator_id,omitempty"`
+	ConversationId string   `json:"conversation_id,omitempty"`
+	FacebookUid    string   `json:"facebook_uid,omitempty"`
+	Term           string   `json:"term,omitempty"`  // tìm theo tên hoặc số điện thoại khách hàng
+	Since          int64    `json:"since,omitempty"` // milliseconds, lọc theo thời điểm tạo đơn
+	Until          int64    `json:"until,omitempty"`
+	Offset         int      `json:"offset,omitempty"`
+	Limit          int      `json:"limit,omitempty"`
+}
+
+type OrderSearchResult struct {
+	Orders     []*Order `json:"orders"`
+	TotalCount int64    `json:"total_count"`
 }
 
 func OrderFromJson(data io.Reader) *Order {
@@ -28,6 +101,110 @@ func (o *Order) PreSave() {
 	}
 
 	o.CreateAt = GetMillis()
+	o.UpdateAt = o.CreateAt
+	o.DeleteAt = 0
+	o.normalize()
+}
+
+func (o *Order) PreUpdate() {
+	o.UpdateAt = GetMillis()
+	o.normalize()
+}
+
+func (o *Order) normalize() {
+	o.CustomerName = strings.TrimSpace(o.CustomerName)
+	o.CustomerPhone = strings.TrimSpace(o.CustomerPhone)
+	o.CustomerAddress = strings.TrimSpace(o.CustomerAddress)
+
+	if o.Items == nil {
+		o.Items = OrderItems{}
+	}
+	for _, item := range o.Items {
+		if item != nil {
+			item.Name = strings.TrimSpace(item.Name)
+			item.Sku = strings.TrimSpace(item.Sku)
+		}
+	}
+
+	o.CalculateTotals()
+}
+
+// CalculateTotals tính lại Subtotal và Total từ các dòng sản phẩm, tổng tiền gửi lên từ client bị bỏ qua
+func (o *Order) CalculateTotals() {
+	o.Subtotal = 0
+	for _, item := range o.Items {
+		if item != nil {
+			o.Subtotal += item.Amount()
+		}
+	}
+	o.Total = o.Subtotal - o.Discount + o.ShippingFee
+}
+
+func (o *Order) IsValid() *AppError {
+	if len(o.Id) != 26 {
+		return NewAppError("Order.IsValid", "model.order.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.PageId) == 0 {
+		return NewAppError("Order.IsValid", "model.order.is_valid.page_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.StatusId) != 26 {
+		return NewAppError("Order.IsValid", "model.order.is_valid.status_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.CustomerName) == 0 || utf8.RuneCountInString(o.CustomerName) > ORDER_CUSTOMER_NAME_MAX_LENGTH {
+		return NewAppError("Order.IsValid", "model.order.is_valid.customer_name.app_error", map[string]interface{}{"Max": ORDER_CUSTOMER_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.CustomerPhone) > ORDER_CUSTOMER_PHONE_MAX_LENGTH || (len(o.CustomerPhone) > 0 && !ContainsPhoneNumber(o.CustomerPhone)) {
+		return NewAppError("Order.IsValid", "model.order.is_valid.customer_phone.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if utf8.RuneCountInString(o.CustomerAddress) > ORDER_CUSTOMER_ADDRESS_MAX_LENGTH {
+		return NewAppError("Order.IsValid", "model.order.is_valid.customer_address.app_error", map[string]interface{}{"Max": ORDER_CUSTOMER_ADDRESS_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if utf8.RuneCountInString(o.Notes) > ORDER_NOTES_MAX_LENGTH {
+		return NewAppError("Order.IsValid", "model.order.is_valid.notes.app_error", map[string]interface{}{"Max": ORDER_NOTES_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Items) == 0 || len(o.Items) > ORDER_ITEMS_MAX {
+		return NewAppError("Order.IsValid", "model.order.is_valid.items.app_error", map[string]interface{}{"Max": ORDER_ITEMS_MAX}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	for _, item := range o.Items {
+		if err := item.IsValid(); err != nil {
+			err.DetailedError = "id=" + o.Id
+			return err
+		}
+	}
+
+	if o.Discount < 0 || o.ShippingFee < 0 || o.Total < 0 {
+		return NewAppError("Order.IsValid", "model.order.is_valid.total.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (o *OrderItem) IsValid() *AppError {
+	if o == nil {
+		return NewAppError("OrderItem.IsValid", "model.order.is_valid.items.app_error", map[string]interface{}{"Max": ORDER_ITEMS_MAX}, "", http.StatusBadRequest)
+	}
+
+	if len(o.Name) == 0 || utf8.RuneCountInString(o.Name) > ORDER_ITEM_NAME_MAX_LENGTH {
+		return NewAppError("OrderItem.IsValid", "model.order.is_valid.item_name.app_error", map[string]interface{}{"Max": ORDER_ITEM_NAME_MAX_LENGTH}, "", http.StatusBadRequest)
+	}
+
+	if len(o.Sku) > ORDER_ITEM_SKU_MAX_LENGTH {
+		return NewAppError("OrderItem.IsValid", "model.order.is_valid.item_sku.app_error", map[string]interface{}{"Max": ORDER_ITEM_SKU_MAX_LENGTH}, "", http.StatusBadRequest)
+	}
+
+	if o.Quantity <= 0 || o.Price < 0 {
+		return NewAppError("OrderItem.IsValid", "model.order.is_valid.item_quantity.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
 }
 
 func (p *Order) ToJson() string {
@@ -35,28 +212,50 @@ func (p *Order) ToJson() string {
 	return string(b)
 }
 
-//type Order struct {
-//	Id 					string 			`json:"id"`
-//	PageId 				string 			`json:"page_id"`
-//	ExternalSource 		string 			`json:"external_source"`
-//	ExternalLink 		string			`json:"external_link"`
-//	TeamId 				string 			`json:"team_id"`
-//	CreateAt 			int64         	`json:"create_at"`
-//	UpdateAt 			int64         	`json:"update_at"`
-//	DeleteAt 			int64         	`json:"delete_at"`
-//	BlockAt  			int64         	`json:"block_at"`
-//	AssignTo			string 			`json:"assign_to"`
-//	AssignBy 			string 			`json:"assign_by"`
-//	LastAssignAt 		int64 			`json:"last_assign_at"`
-//	ProductId 			string 			`json:"product_id"`
-//	CustomerName		string 			`json:"customer_name"`
-//	CustomerMobile 		string 			`json:"customer_mobile"`
-//	CustomerMobileExt  	string 			`json:"customer_mobile_ext"`
-//	CustomerAddress 	string 			`json:"customer_address"`
-//	CustomerAddressExt 	string 			`json:"customer_address_ext"`
-//	CustomerBirthday 	int64 			`json:"customer_birthday"`
-//	CustomerLunarBirthday 	int64 		`json:"customer_lunar_birthday"`
-//	CustomerNotes 		string 			`json:"customer_notes"`
-//	CreatorNotes 		string 			`json:"creator_notes"`
-//	StatusId 			string 			`json:"status_id"`
-//}
+func (o *OrderSearchOptions) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func OrderSearchOptionsFromJson(data io.Reader) *OrderSearchOptions {
+	var o *OrderSearchOptions
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func (o *OrderSearchOptions) SetDefaults() {
+	if o.Limit <= 0 {
+		o.Limit = ORDER_SEARCH_DEFAULT_LIMIT
+	}
+}
+
+func (o *OrderSearchOptions) IsValid() *AppError {
+	if len(o.PageIds) == 0 {
+		return NewAppError("OrderSearchOptions.IsValid", "model.order_search.is_valid.page_ids.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if o.Limit < 0 || o.Limit > ORDER_SEARCH_MAX_LIMIT {
+		return NewAppError("OrderSearchOptions.IsValid", "model.order_search.is_valid.limit.app_error", map[string]interface{}{"Max": ORDER_SEARCH_MAX_LIMIT}, "", http.StatusBadRequest)
+	}
+
+	if o.Offset < 0 {
+		return NewAppError("OrderSearchOptions.IsValid", "model.order_search.is_valid.offset.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if o.Since > 0 && o.Until > 0 && o.Since > o.Until {
+		return NewAppError("OrderSearchOptions.IsValid", "model.order_search.is_valid.date_range.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
+
+func (r *OrderSearchResult) ToJson() string {
+	b, _ := json.Marshal(r)
+	return string(b)
+}
+
+func OrderSearchResultFromJson(data io.Reader) *OrderSearchResult {
+	var o *OrderSearchResult
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
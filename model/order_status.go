@@ -0,0 +1,102 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	ORDER_STATUS_NAME_MAX_LENGTH = 64
+)
+
+var orderStatusColorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// OrderStatus là trạng thái đơn hàng do mỗi page tự cấu hình, đơn mới tạo nhận trạng thái có IsDefault
+type OrderStatus struct {
+	Id        string `json:"id"`
+	PageId    string `json:"page_id"`
+	Name      string `json:"name"`
+	Color     string `json:"color"`
+	Position  int    `json:"position"`
+	IsDefault bool   `json:"is_default"`
+	CreatorId string `json:"creator_id"`
+	CreateAt  int64  `json:"create_at"`
+	UpdateAt  int64  `json:"update_at"`
+	DeleteAt  int64  `json:"delete_at"`
+}
+
+// DefaultOrderStatuses là các trạng thái được tạo cho page lần đầu dùng đơn hàng
+func DefaultOrderStatuses(pageId string) []*OrderStatus {
+	return []*OrderStatus{
+		{PageId: pageId, Name: "Mới", Color: "#2389d7", Position: 0, IsDefault: true},
+		{PageId: pageId, Name: "Đã xác nhận", Color: "#8e44ad", Position: 1},
+		{PageId: pageId, Name: "Đang giao", Color: "#f39c12", Position: 2},
+		{PageId: pageId, Name: "Hoàn thành", Color: "#27ae60", Position: 3},
+		{PageId: pageId, Name: "Đã huỷ", Color: "#7f8c8d", Position: 4},
+	}
+}
+
+func (o *OrderStatus) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func OrderStatusFromJson(data io.Reader) *OrderStatus {
+	var o *OrderStatus
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func OrderStatusListToJson(l []*OrderStatus) string {
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+func OrderStatusListFromJson(data io.Reader) []*OrderStatus {
+	var l []*OrderStatus
+	json.NewDecoder(data).Decode(&l)
+	return l
+}
+
+func (o *OrderStatus) PreSave() {
+	if len(o.Id) == 0 {
+		o.Id = NewId()
+	}
+
+	o.CreateAt = GetMillis()
+	o.UpdateAt = o.CreateAt
+	o.DeleteAt = 0
+	o.Name = strings.TrimSpace(o.Name)
+}
+
+func (o *OrderStatus) PreUpdate() {
+	o.UpdateAt = GetMillis()
+	o.Name = strings.TrimSpace(o.Name)
+}
+
+func (o *OrderStatus) IsValid() *AppError {
+	if len(o.Id) != 26 {
+		return NewAppError("OrderStatus.IsValid", "model.order_status.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.PageId) == 0 {
+		return NewAppError("OrderStatus.IsValid", "model.order_status.is_valid.page_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Name) == 0 || utf8.RuneCountInString(o.Name) > ORDER_STATUS_NAME_MAX_LENGTH {
+		return NewAppError("OrderStatus.IsValid", "model.order_status.is_valid.name.app_error", map[string]interface{}{"Max": ORDER_STATUS_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Color) > 0 && !orderStatusColorRegexp.MatchString(o.Color) {
+		return NewAppError("OrderStatus.IsValid", "model.order_status.is_valid.color.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	return nil
+}
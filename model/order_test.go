@@ -0,0 +1,75 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOrderCalculateTotals(t *testing.T) {
+	order := &Order{
+		Items: OrderItems{
+			{Name: "Áo thun", Quantity: 2, Price: 150000},
+			{Name: "Quần jean", Quantity: 1, Price: 350000},
+		},
+		Discount:    50000,
+		ShippingFee: 30000,
+		Subtotal:    1,
+		Total:       1,
+	}
+	order.CalculateTotals()
+
+	assert.Equal(t, int64(650000), order.Subtotal)
+	assert.Equal(t, int64(630000), order.Total)
+}
+
+func TestOrderIsValid(t *testing.T) {
+	order := &Order{
+		PageId:       "1234",
+		StatusId:     NewId(),
+		CustomerName: " Nguyễn Văn A ",
+		Items:        OrderItems{{Name: "Áo thun", Quantity: 1, Price: 150000}},
+	}
+	order.PreSave()
+	assert.Nil(t, order.IsValid())
+	assert.Equal(t, "Nguyễn Văn A", order.CustomerName)
+
+	order.CustomerPhone = "abc"
+	assert.NotNil(t, order.IsValid())
+
+	order.CustomerPhone = "0912345678"
+	assert.Nil(t, order.IsValid())
+
+	order.Items[0].Quantity = 0
+	assert.NotNil(t, order.IsValid())
+
+	order.Items[0].Quantity = 1
+	order.Discount = 200000
+	order.CalculateTotals()
+	assert.NotNil(t, order.IsValid())
+
+	order.Discount = 0
+	order.Items = OrderItems{}
+	assert.NotNil(t, order.IsValid())
+
+	order.Items = OrderItems{{Name: strings.Repeat("a", ORDER_ITEM_NAME_MAX_LENGTH+1), Quantity: 1}}
+	assert.NotNil(t, order.IsValid())
+}
+
+func TestOrderStatusIsValid(t *testing.T) {
+	status := &OrderStatus{PageId: "1234", Name: "Mới", Color: "#2389d7"}
+	status.PreSave()
+	assert.Nil(t, status.IsValid())
+
+	status.Color = "red"
+	assert.NotNil(t, status.IsValid())
+
+	for _, status := range DefaultOrderStatuses("1234") {
+		status.PreSave()
+		assert.Nil(t, status.IsValid())
+	}
+}
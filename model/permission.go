@@ -7,6 +7,7 @@ const (
 	PermissionScopeSystem  = "system_scope"
 	PermissionScopeTeam    = "team_scope"
 	PermissionScopeChannel = "channel_scope"
+	PermissionScopeFanpage = "fanpage_scope"
 )
 
 type Permission struct {
@@ -100,6 +101,15 @@ var PERMISSION_USE_GROUP_MENTIONS *Permission
 var PERMISSION_READ_OTHER_USERS_TEAMS *Permission
 var PERMISSION_EDIT_BRAND *Permission
 
+// các quyền trên một fanpage, được cấp qua role của FanpageMember
+var PERMISSION_READ_CONVERSATIONS *Permission
+var PERMISSION_REPLY_CONVERSATIONS *Permission
+var PERMISSION_MANAGE_TAGS *Permission
+var PERMISSION_MANAGE_SNIPPETS *Permission
+var PERMISSION_MANAGE_ORDERS *Permission
+var PERMISSION_MANAGE_FANPAGE *Permission
+var PERMISSION_MANAGE_FANPAGE_MEMBERS *Permission
+
 var PERMISSION_SYSCONSOLE_READ_ABOUT *Permission
 var PERMISSION_SYSCONSOLE_WRITE_ABOUT *Permission
 
@@ -666,6 +676,48 @@ func initializePermissions() {
 		"authentication.permissions.edit_brand.description",
 		PermissionScopeSystem,
 	}
+	PERMISSION_READ_CONVERSATIONS = &Permission{
+		"read_conversations",
+		"authentication.permissions.read_conversations.name",
+		"authentication.permissions.read_conversations.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_REPLY_CONVERSATIONS = &Permission{
+		"reply_conversations",
+		"authentication.permissions.reply_conversations.name",
+		"authentication.permissions.reply_conversations.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_MANAGE_TAGS = &Permission{
+		"manage_tags",
+		"authentication.permissions.manage_tags.name",
+		"authentication.permissions.manage_tags.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_MANAGE_SNIPPETS = &Permission{
+		"manage_snippets",
+		"authentication.permissions.manage_snippets.name",
+		"authentication.permissions.manage_snippets.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_MANAGE_ORDERS = &Permission{
+		"manage_orders",
+		"authentication.permissions.manage_orders.name",
+		"authentication.permissions.manage_orders.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_MANAGE_FANPAGE = &Permission{
+		"manage_fanpage",
+		"authentication.permissions.manage_fanpage.name",
+		"authentication.permissions.manage_fanpage.description",
+		PermissionScopeFanpage,
+	}
+	PERMISSION_MANAGE_FANPAGE_MEMBERS = &Permission{
+		"manage_fanpage_members",
+		"authentication.permissions.manage_fanpage_members.name",
+		"authentication.permissions.manage_fanpage_members.description",
+		PermissionScopeFanpage,
+	}
 	PERMISSION_SYSCONSOLE_READ_ABOUT = &Permission{
 		"sysconsole_read_about",
 		"authentication.permissions.use_group_mentions.name",
@@ -969,6 +1021,16 @@ func initializePermissions() {
 		PERMISSION_USE_GROUP_MENTIONS,
 	}
 
+	FanpageScopedPermissions := []*Permission{
+		PERMISSION_READ_CONVERSATIONS,
+		PERMISSION_REPLY_CONVERSATIONS,
+		PERMISSION_MANAGE_TAGS,
+		PERMISSION_MANAGE_SNIPPETS,
+		PERMISSION_MANAGE_ORDERS,
+		PERMISSION_MANAGE_FANPAGE,
+		PERMISSION_MANAGE_FANPAGE_MEMBERS,
+	}
+
 	DeprecatedPermissions = []*Permission{
 		PERMISSION_PERMANENT_DELETE_USER,
 		PERMISSION_MANAGE_WEBHOOKS,
@@ -981,6 +1043,7 @@ func initializePermissions() {
 	AllPermissions = append(AllPermissions, SystemScopedPermissionsMinusSysconsole...)
 	AllPermissions = append(AllPermissions, TeamScopedPermissions...)
 	AllPermissions = append(AllPermissions, ChannelScopedPermissions...)
+	AllPermissions = append(AllPermissions, FanpageScopedPermissions...)
 	AllPermissions = append(AllPermissions, SysconsoleReadPermissions...)
 	AllPermissions = append(AllPermissions, SysconsoleWritePermissions...)
 
@@ -0,0 +1,284 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"bitbucket.org/enesyteam/papo-server/facebook_graph"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	PRODUCT_NAME_MAX_LENGTH        = 256
+	PRODUCT_SKU_MAX_LENGTH         = 64
+	PRODUCT_DESCRIPTION_MAX_LENGTH = 4000
+	PRODUCT_URL_MAX_LENGTH         = 1024
+	PRODUCT_VARIANTS_MAX           = 100
+	PRODUCT_IMAGES_MAX             = 10
+
+	PRODUCT_SEARCH_DEFAULT_LIMIT = 30
+	PRODUCT_SEARCH_MAX_LIMIT     = 200
+)
+
+// ProductVariant là một phân loại của sản phẩm (màu, size...), có SKU, giá và tồn kho riêng
+type ProductVariant struct {
+	Id    string `json:"id"`
+	Sku   string `json:"sku,omitempty"`
+	Name  string `json:"name"`
+	Price int64  `json:"price"`
+	Stock int    `json:"stock"`
+}
+
+// ProductVariants được lưu thành một cột json trong bảng Products
+type ProductVariants []*ProductVariant
+
+// Product là sản phẩm trong danh mục của một page, giá tính theo đơn vị nhỏ nhất của tiền tệ (đồng)
+type Product struct {
+	Id          string          `json:"id"`
+	PageId      string          `json:"page_id"`
+	Sku         string          `json:"sku"`
+	Name        string          `json:"name"`
+	Description string          `json:"description,omitempty"`
+	Price       int64           `json:"price"`
+	Stock       int             `json:"stock"`
+	Variants    ProductVariants `json:"variants"`
+	FileIds     StringArray     `json:"file_ids"` // ảnh sản phẩm, ảnh đầu tiên được dùng làm ảnh đại diện
+	Url         string          `json:"url,omitempty"`
+	CreatorId   string          `json:"creator_id"`
+	CreateAt    int64           `json:"create_at"`
+	UpdateAt    int64           `json:"update_at"`
+	DeleteAt    int64           `json:"delete_at"`
+}
+
+type ProductSearchOptions struct {
+	PageId string `json:"page_id"`
+	Term   string `json:"term,omitempty"` // tìm theo tên hoặc SKU
+	Offset int    `json:"offset,omitempty"`
+	Limit  int    `json:"limit,omitempty"`
+}
+
+func (o *Product) ToJson() string {
+	b, _ := json.Marshal(o)
+	return string(b)
+}
+
+func ProductFromJson(data io.Reader) *Product {
+	var o *Product
+	json.NewDecoder(data).Decode(&o)
+	return o
+}
+
+func ProductListToJson(l []*Product) string {
+	b, _ := json.Marshal(l)
+	return string(b)
+}
+
+func ProductListFromJson(data io.Reader) []*Product {
+	var l []*Product
+	json.NewDecoder(data).Decode(&l)
+	return l
+}
+
+func (o *Product) PreSave() {
+	if len(o.Id) == 0 {
+		o.Id = NewId()
+	}
+
+	o.CreateAt = GetMillis()
+	o.UpdateAt = o.CreateAt
+	o.DeleteAt = 0
+	o.normalize()
+}
+
+func (o *Product) PreUpdate() {
+	o.UpdateAt = GetMillis()
+	o.normalize()
+}
+
+func (o *Product) normalize() {
+	o.Name = strings.TrimSpace(o.Name)
+	o.Sku = strings.TrimSpace(o.Sku)
+
+	if o.Variants == nil {
+		o.Variants = ProductVariants{}
+	}
+	for _, variant := range o.Variants {
+		if variant == nil {
+			continue
+		}
+		if len(variant.Id) == 0 {
+			variant.Id = NewId()
+		}
+		variant.Name = strings.TrimSpace(variant.Name)
+		variant.Sku = strings.TrimSpace(variant.Sku)
+	}
+
+	if o.FileIds == nil {
+		o.FileIds = StringArray{}
+	}
+}
+
+// GetVariant trả về phân loại theo id, nil nếu sản phẩm không có phân loại đó
+func (o *Product) GetVariant(variantId string) *ProductVariant {
+	for _, variant := range o.Variants {
+		if variant != nil && variant.Id == variantId {
+			return variant
+		}
+	}
+	return nil
+}
+
+// ApplyToOrderItem điền tên, SKU và giá từ danh mục vào dòng sản phẩm của đơn hàng,
+// đơn giá do nhân viên nhập (khác 0) được giữ lại
+func (o *Product) ApplyToOrderItem(item *OrderItem) *AppError {
+	name, sku, price := o.Name, o.Sku, o.Price
+
+	if len(item.VariantId) > 0 {
+		variant := o.GetVariant(item.VariantId)
+		if variant == nil {
+			return NewAppError("Product.ApplyToOrderItem", "model.order.is_valid.item_variant.app_error", nil, "product_id="+o.Id+", variant_id="+item.VariantId, http.StatusBadRequest)
+		}
+
+		name = o.Name + " - " + variant.Name
+		if len(variant.Sku) > 0 {
+			sku = variant.Sku
+		}
+		price = variant.Price
+	}
+
+	item.Name = name
+	item.Sku = sku
+	if item.Price == 0 {
+		item.Price = price
+	}
+	return nil
+}
+
+// TemplateElement dựng phần tử generic template để gửi sản phẩm cho khách hàng qua Messenger,
+// imageUrl phải là url công khai facebook tải được
+func (o *Product) TemplateElement(variant *ProductVariant, imageUrl string) *facebookgraph.TemplateElement {
+	title, price := o.Name, o.Price
+	if variant != nil {
+		title = o.Name + " - " + variant.Name
+		price = variant.Price
+	}
+
+	element := &facebookgraph.TemplateElement{
+		Title:    truncateRunes(title, facebookgraph.SEND_GENERIC_TITLE_MAX_LENGTH),
+		Subtitle: truncateRunes(PriceText(price), facebookgraph.SEND_GENERIC_SUBTITLE_MAX_LENGTH),
+		ImageUrl: imageUrl,
+	}
+
+	if len(o.Url) > 0 {
+		element.DefaultAction = &facebookgraph.TemplateDefaultAction{Type: facebookgraph.BUTTON_TYPE_WEB_URL, Url: o.Url}
+	}
+
+	return element
+}
+
+func truncateRunes(s string, max int) string {
+	if utf8.RuneCountInString(s) <= max {
+		return s
+	}
+	runes := []rune(s)
+	return string(runes[:max-1]) + "…"
+}
+
+// PriceText hiển thị giá theo kiểu Việt Nam, ví dụ 150.000 đ
+func PriceText(price int64) string {
+	sign := ""
+	if price < 0 {
+		sign = "-"
+		price = -price
+	}
+
+	digits := fmt.Sprintf("%d", price)
+	var b strings.Builder
+	for i, c := range digits {
+		if i > 0 && (len(digits)-i)%3 == 0 {
+			b.WriteByte('.')
+		}
+		b.WriteRune(c)
+	}
+
+	return sign + b.String() + " đ"
+}
+
+func (o *Product) IsValid() *AppError {
+	if len(o.Id) != 26 {
+		return NewAppError("Product.IsValid", "model.product.is_valid.id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if len(o.PageId) == 0 {
+		return NewAppError("Product.IsValid", "model.product.is_valid.page_id.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Name) == 0 || utf8.RuneCountInString(o.Name) > PRODUCT_NAME_MAX_LENGTH {
+		return NewAppError("Product.IsValid", "model.product.is_valid.name.app_error", map[string]interface{}{"Max": PRODUCT_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Sku) > PRODUCT_SKU_MAX_LENGTH {
+		return NewAppError("Product.IsValid", "model.product.is_valid.sku.app_error", map[string]interface{}{"Max": PRODUCT_SKU_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if utf8.RuneCountInString(o.Description) > PRODUCT_DESCRIPTION_MAX_LENGTH {
+		return NewAppError("Product.IsValid", "model.product.is_valid.description.app_error", map[string]interface{}{"Max": PRODUCT_DESCRIPTION_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Url) > 0 && (len(o.Url) > PRODUCT_URL_MAX_LENGTH || !IsValidHttpUrl(o.Url)) {
+		return NewAppError("Product.IsValid", "model.product.is_valid.url.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if o.Price < 0 || o.Stock < 0 {
+		return NewAppError("Product.IsValid", "model.product.is_valid.price.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.FileIds) > PRODUCT_IMAGES_MAX {
+		return NewAppError("Product.IsValid", "model.product.is_valid.file_ids.app_error", map[string]interface{}{"Max": PRODUCT_IMAGES_MAX}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	if len(o.Variants) > PRODUCT_VARIANTS_MAX {
+		return NewAppError("Product.IsValid", "model.product.is_valid.variants.app_error", map[string]interface{}{"Max": PRODUCT_VARIANTS_MAX}, "id="+o.Id, http.StatusBadRequest)
+	}
+
+	ids := map[string]bool{}
+	for _, variant := range o.Variants {
+		if variant == nil || len(variant.Id) != 26 || ids[variant.Id] {
+			return NewAppError("Product.IsValid", "model.product.is_valid.variants.app_error", map[string]interface{}{"Max": PRODUCT_VARIANTS_MAX}, "id="+o.Id, http.StatusBadRequest)
+		}
+		ids[variant.Id] = true
+
+		if len(variant.Name) == 0 || utf8.RuneCountInString(variant.Name) > PRODUCT_NAME_MAX_LENGTH || len(variant.Sku) > PRODUCT_SKU_MAX_LENGTH {
+			return NewAppError("Product.IsValid", "model.product.is_valid.variant_name.app_error", map[string]interface{}{"Max": PRODUCT_NAME_MAX_LENGTH}, "id="+o.Id, http.StatusBadRequest)
+		}
+
+		if variant.Price < 0 || variant.Stock < 0 {
+			return NewAppError("Product.IsValid", "model.product.is_valid.price.app_error", nil, "id="+o.Id, http.StatusBadRequest)
+		}
+	}
+
+	return nil
+}
+
+func (o *ProductSearchOptions) SetDefaults() {
+	if o.Limit <= 0 {
+		o.Limit = PRODUCT_SEARCH_DEFAULT_LIMIT
+	}
+}
+
+func (o *ProductSearchOptions) IsValid() *AppError {
+	if len(o.PageId) == 0 {
+		return NewAppError("ProductSearchOptions.IsValid", "model.product_search.is_valid.page_id.app_error", nil, "", http.StatusBadRequest)
+	}
+
+	if o.Limit < 0 || o.Limit > PRODUCT_SEARCH_MAX_LIMIT || o.Offset < 0 {
+		return NewAppError("ProductSearchOptions.IsValid", "model.product_search.is_valid.limit.app_error", map[string]interface{}{"Max": PRODUCT_SEARCH_MAX_LIMIT}, "", http.StatusBadRequest)
+	}
+
+	return nil
+}
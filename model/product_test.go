@@ -0,0 +1,73 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package model
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProductIsValid(t *testing.T) {
+	product := &Product{
+		PageId:   "1234",
+		Name:     " Áo thun ",
+		Sku:      "AT01",
+		Price:    150000,
+		Variants: ProductVariants{{Name: "Đỏ / L", Price: 160000, Stock: 3}},
+	}
+	product.PreSave()
+	assert.Nil(t, product.IsValid())
+	assert.Equal(t, "Áo thun", product.Name)
+	assert.Len(t, product.Variants[0].Id, 26)
+
+	product.Url = "not a url"
+	assert.NotNil(t, product.IsValid())
+
+	product.Url = "https://example.com/ao-thun"
+	product.Variants = append(product.Variants, &ProductVariant{Id: product.Variants[0].Id, Name: "Xanh / M"})
+	assert.NotNil(t, product.IsValid())
+
+	product.Variants = product.Variants[:1]
+	product.Price = -1
+	assert.NotNil(t, product.IsValid())
+}
+
+func TestProductApplyToOrderItem(t *testing.T) {
+	product := &Product{Name: "Áo thun", Sku: "AT01", Price: 150000}
+	product.PreSave()
+	variant := &ProductVariant{Id: NewId(), Name: "Đỏ", Sku: "AT01-D", Price: 160000}
+	product.Variants = ProductVariants{variant}
+
+	item := &OrderItem{ProductId: product.Id, Name: "ao", Quantity: 1}
+	assert.Nil(t, product.ApplyToOrderItem(item))
+	assert.Equal(t, "Áo thun", item.Name)
+	assert.Equal(t, int64(150000), item.Price)
+
+	item = &OrderItem{ProductId: product.Id, VariantId: variant.Id, Quantity: 1, Price: 140000}
+	assert.Nil(t, product.ApplyToOrderItem(item))
+	assert.Equal(t, "Áo thun - Đỏ", item.Name)
+	assert.Equal(t, "AT01-D", item.Sku)
+	assert.Equal(t, int64(140000), item.Price)
+
+	item.VariantId = NewId()
+	assert.NotNil(t, product.ApplyToOrderItem(item))
+}
+
+func TestProductTemplateElement(t *testing.T) {
+	assert.Equal(t, "1.250.000 đ", PriceText(1250000))
+	assert.Equal(t, "0 đ", PriceText(0))
+
+	product := &Product{Name: "Áo thun", Price: 150000, Url: "https://example.com/ao-thun"}
+	element := product.TemplateElement(nil, "https://papo.vn/files/abc/public?h=x")
+	assert.Nil(t, element.IsValid())
+	assert.Equal(t, "150.000 đ", element.Subtitle)
+	assert.Equal(t, "https://example.com/ao-thun", element.DefaultAction.Url)
+
+	product.Name = strings.Repeat("á", 100)
+	element = product.TemplateElement(&ProductVariant{Name: "Đỏ", Price: 160000}, "")
+	assert.Nil(t, element.IsValid())
+	assert.Equal(t, "160.000 đ", element.Subtitle)
+}
@@ -43,6 +43,10 @@ func init() {
 		CHANNEL_GUEST_ROLE_ID,
 		CHANNEL_USER_ROLE_ID,
 		CHANNEL_ADMIN_ROLE_ID,
+
+		FANPAGE_VIEWER_ROLE_ID,
+		FANPAGE_AGENT_ROLE_ID,
+		FANPAGE_ADMIN_ROLE_ID,
 	}, NewSystemRoleIDs...)
 
 	SysconsoleAncillaryPermissions = map[string][]*Permission{
@@ -179,6 +183,10 @@ const (
 	CHANNEL_USER_ROLE_ID  = "channel_user"
 	CHANNEL_ADMIN_ROLE_ID = "channel_admin"
 
+	FANPAGE_VIEWER_ROLE_ID = "fanpage_viewer"
+	FANPAGE_AGENT_ROLE_ID  = "fanpage_agent"
+	FANPAGE_ADMIN_ROLE_ID  = "fanpage_admin"
+
 	ROLE_NAME_MAX_LENGTH         = 64
 	ROLE_DISPLAY_NAME_MAX_LENGTH = 128
 	ROLE_DESCRIPTION_MAX_LENGTH  = 1024
@@ -186,6 +194,7 @@ const (
 	RoleScopeSystem  RoleScope = "System"
 	RoleScopeTeam    RoleScope = "Team"
 	RoleScopeChannel RoleScope = "Channel"
+	RoleScopeFanpage RoleScope = "Fanpage"
 
 	RoleTypeGuest RoleType = "Guest"
 	RoleTypeUser  RoleType = "User"
@@ -571,6 +580,45 @@ func MakeDefaultRoles() map[string]*Role {
 		BuiltIn:       true,
 	}
 
+	roles[FANPAGE_VIEWER_ROLE_ID] = &Role{
+		Name:        "fanpage_viewer",
+		DisplayName: "authentication.roles.fanpage_viewer.name",
+		Description: "authentication.roles.fanpage_viewer.description",
+		Permissions: []string{
+			PERMISSION_READ_CONVERSATIONS.Id,
+		},
+		SchemeManaged: true,
+		BuiltIn:       true,
+	}
+
+	roles[FANPAGE_AGENT_ROLE_ID] = &Role{
+		Name:        "fanpage_agent",
+		DisplayName: "authentication.roles.fanpage_agent.name",
+		Description: "authentication.roles.fanpage_agent.description",
+		Permissions: []string{
+			PERMISSION_READ_CONVERSATIONS.Id,
+			PERMISSION_REPLY_CONVERSATIONS.Id,
+			PERMISSION_MANAGE_TAGS.Id,
+			PERMISSION_MANAGE_ORDERS.Id,
+		},
+		SchemeManaged: true,
+		BuiltIn:       true,
+	}
+
+	// fanpage_admin có mọi quyền trên page, member được cấp cả agent và admin khi thêm vào page
+	roles[FANPAGE_ADMIN_ROLE_ID] = &Role{
+		Name:        "fanpage_admin",
+		DisplayName: "authentication.roles.fanpage_admin.name",
+		Description: "authentication.roles.fanpage_admin.description",
+		Permissions: []string{
+			PERMISSION_MANAGE_SNIPPETS.Id,
+			PERMISSION_MANAGE_FANPAGE.Id,
+			PERMISSION_MANAGE_FANPAGE_MEMBERS.Id,
+		},
+		SchemeManaged: true,
+		BuiltIn:       true,
+	}
+
 	roles[TEAM_GUEST_ROLE_ID] = &Role{
 		Name:        "team_guest",
 		DisplayName: "authentication.roles.team_guest.name",
@@ -16,6 +16,7 @@ const (
 	SCHEME_DESCRIPTION_MAX_LENGTH  = 1024
 	SCHEME_SCOPE_TEAM              = "team"
 	SCHEME_SCOPE_CHANNEL           = "channel"
+	SCHEME_SCOPE_FANPAGE           = "fanpage"
 )
 
 type Scheme struct {
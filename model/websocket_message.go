@@ -65,11 +65,32 @@ const (
 	WEBSOCKET_EVENT_PAGES_STATUS_UPDATED    = "pages_status_updated"
 	WEBSOCKET_EVENT_PAGES_INI_VALIDATION_RESULT    = "pages_init_validation_result"
 	WEBSOCKET_EVENT_PAGE_INIT_UPDATE_VALUE    = "pages_init_update_value"
+	WEBSOCKET_EVENT_PAGE_SYNC_UPDATED    = "page_sync_updated"
 
 	WEBSOCKET_EVENT_ADD_MESSAGE    			= "conversation_add_message"
 	MESSAGE_SENT 							= "message_sent"
+	MESSAGE_FAILED 							= "message_failed"
+	MESSAGE_CANCELLED 						= "message_cancelled"
+	MESSAGE_STATUS_UPDATED 					= "message_status_updated"
+	MESSAGE_ATTACHMENTS_MIRRORED 			= "message_attachments_mirrored"
 	RECEIVE_CONVERSATION_READ 				= "read_watermark"
 	ADDED_ORDER 							= "added_order"
+	ORDER_UPDATED 							= "order_updated"
+	ORDER_DELETED 							= "order_deleted"
+	ORDER_STATUSES_UPDATED 					= "order_statuses_updated"
+	RECEIVE_POSTBACK 						= "receive_postback"
+	RECEIVE_QUICK_REPLY 					= "receive_quick_reply"
+	RECEIVE_REACTION 						= "receive_reaction"
+	RECEIVE_REFERRAL 						= "receive_referral"
+	RECEIVE_OPTIN 							= "receive_optin"
+	RECEIVE_POLICY_ENFORCEMENT 				= "receive_policy_enforcement"
+	PAGE_NEEDS_REAUTH 						= "page_needs_reauth"
+	PAGE_MEMBER_ADDED 						= "page_member_added"
+	PAGE_MEMBER_REMOVED 					= "page_member_removed"
+	PAGE_MEMBER_ROLES_UPDATED 				= "page_member_roles_updated"
+	CONVERSATION_ASSIGNEE_UPDATED 			= "conversation_assignee_updated"
+	CONVERSATION_STATUS_UPDATED 			= "conversation_status_updated"
+	AUTO_MESSAGE_TASK_UPDATED 				= "auto_message_task_updated"
 	WEBSOCKET_WARN_METRIC_STATUS_RECEIVED                    = "warn_metric_status_received"
 	WEBSOCKET_WARN_METRIC_STATUS_REMOVED                     = "warn_metric_status_removed"
 	WEBSOCKET_EVENT_GUESTS_DEACTIVATED                       = "guests_deactivated"
@@ -436,7 +436,7 @@ func (s *OpenTracingLayerAuditStore) Save(audit *model.Audit) error {
 	return err
 }
 
-func (s *OpenTracingLayerAutoMessageTaskStore) Delete(taskId string) StoreChannel {
+func (s *OpenTracingLayerAutoMessageTaskStore) Delete(taskId string, deleteAt int64) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "AutoMessageTaskStore.Delete")
 	s.Root.Store.SetContext(newCtx)
@@ -445,7 +445,7 @@ func (s *OpenTracingLayerAutoMessageTaskStore) Delete(taskId string) StoreChanne
 	}()
 
 	defer span.Finish()
-	result := s.AutoMessageTaskStore.Delete(taskId)
+	result := s.AutoMessageTaskStore.Delete(taskId, deleteAt)
 	return result
 }
 
@@ -462,19 +462,6 @@ func (s *OpenTracingLayerAutoMessageTaskStore) GetByPageId(pageId string) StoreC
 	return result
 }
 
-func (s *OpenTracingLayerAutoMessageTaskStore) Pause(taskId string) StoreChannel {
-	origCtx := s.Root.Store.Context()
-	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "AutoMessageTaskStore.Pause")
-	s.Root.Store.SetContext(newCtx)
-	defer func() {
-		s.Root.Store.SetContext(origCtx)
-	}()
-
-	defer span.Finish()
-	result := s.AutoMessageTaskStore.Pause(taskId)
-	return result
-}
-
 func (s *OpenTracingLayerAutoMessageTaskStore) Save(messageTask *model.AutoMessageTask) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "AutoMessageTaskStore.Save")
@@ -488,19 +475,6 @@ func (s *OpenTracingLayerAutoMessageTaskStore) Save(messageTask *model.AutoMessa
 	return result
 }
 
-func (s *OpenTracingLayerAutoMessageTaskStore) Start(taskId string) StoreChannel {
-	origCtx := s.Root.Store.Context()
-	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "AutoMessageTaskStore.Start")
-	s.Root.Store.SetContext(newCtx)
-	defer func() {
-		s.Root.Store.SetContext(origCtx)
-	}()
-
-	defer span.Finish()
-	result := s.AutoMessageTaskStore.Start(taskId)
-	return result
-}
-
 func (s *OpenTracingLayerAutoMessageTaskStore) Update(messageTask *model.AutoMessageTask) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "AutoMessageTaskStore.Update")
@@ -1025,7 +999,7 @@ func (s *OpenTracingLayerFacebookConversationStore) AnalyticsConversationCountsB
 	return result
 }
 
-func (s *OpenTracingLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, appScopedUserId string) StoreChannel {
+func (s *OpenTracingLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, deletedBy string) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FacebookConversationStore.DeleteCommentByCommentId")
 	s.Root.Store.SetContext(newCtx)
@@ -1034,7 +1008,7 @@ func (s *OpenTracingLayerFacebookConversationStore) DeleteCommentByCommentId(com
 	}()
 
 	defer span.Finish()
-	result := s.FacebookConversationStore.DeleteCommentByCommentId(commentId, appScopedUserId)
+	result := s.FacebookConversationStore.DeleteCommentByCommentId(commentId, deletedBy)
 	return result
 }
 
@@ -1064,19 +1038,6 @@ func (s *OpenTracingLayerFacebookConversationStore) GetConversationById(id strin
 	return result
 }
 
-func (s *OpenTracingLayerFacebookConversationStore) GetConversations(pageIds string, offset int, limit int) StoreChannel {
-	origCtx := s.Root.Store.Context()
-	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FacebookConversationStore.GetConversations")
-	s.Root.Store.SetContext(newCtx)
-	defer func() {
-		s.Root.Store.SetContext(origCtx)
-	}()
-
-	defer span.Finish()
-	result := s.FacebookConversationStore.GetConversations(pageIds, offset, limit)
-	return result
-}
-
 func (s *OpenTracingLayerFacebookConversationStore) GetFacebookAttachmentByIds(userIds []string, allowFromCache bool) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FacebookConversationStore.GetFacebookAttachmentByIds")
@@ -1181,7 +1142,7 @@ func (s *OpenTracingLayerFacebookConversationStore) Save(conversation *model.Fac
 	return result
 }
 
-func (s *OpenTracingLayerFacebookConversationStore) Search(term string, pageIds string, offset int, limit int) StoreChannel {
+func (s *OpenTracingLayerFacebookConversationStore) Search(options *model.ConversationSearchOptions) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FacebookConversationStore.Search")
 	s.Root.Store.SetContext(newCtx)
@@ -1190,7 +1151,7 @@ func (s *OpenTracingLayerFacebookConversationStore) Search(term string, pageIds
 	}()
 
 	defer span.Finish()
-	result := s.FacebookConversationStore.Search(term, pageIds, offset, limit)
+	result := s.FacebookConversationStore.Search(options)
 	return result
 }
 
@@ -1519,16 +1480,16 @@ func (s *OpenTracingLayerFanpageStore) GetMemberByPageId(pageId string, userId s
 	return result
 }
 
-func (s *OpenTracingLayerFanpageStore) GetOneFanPageMember(fanpageId string) StoreChannel {
+func (s *OpenTracingLayerFanpageStore) GetOwnerMember(pageId string) StoreChannel {
 	origCtx := s.Root.Store.Context()
-	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FanpageStore.GetOneFanPageMember")
+	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "FanpageStore.GetOwnerMember")
 	s.Root.Store.SetContext(newCtx)
 	defer func() {
 		s.Root.Store.SetContext(origCtx)
 	}()
 
 	defer span.Finish()
-	result := s.FanpageStore.GetOneFanPageMember(fanpageId)
+	result := s.FanpageStore.GetOwnerMember(pageId)
 	return result
 }
 
@@ -2609,19 +2570,6 @@ func (s *OpenTracingLayerOAuthStore) UpdateApp(app *model.OAuthApp) (*model.OAut
 	return result, err
 }
 
-func (s *OpenTracingLayerOrderStore) GetOrders(limit int, offset int) StoreChannel {
-	origCtx := s.Root.Store.Context()
-	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "OrderStore.GetOrders")
-	s.Root.Store.SetContext(newCtx)
-	defer func() {
-		s.Root.Store.SetContext(origCtx)
-	}()
-
-	defer span.Finish()
-	result := s.OrderStore.GetOrders(limit, offset)
-	return result
-}
-
 func (s *OpenTracingLayerOrderStore) Save(order *model.Order) StoreChannel {
 	origCtx := s.Root.Store.Context()
 	span, newCtx := tracing.StartSpanWithParentByContext(s.Root.Store.Context(), "OrderStore.Save")
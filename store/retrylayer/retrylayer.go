@@ -458,9 +458,9 @@ func (s *RetryLayerAuditStore) Save(audit *model.Audit) error {
 
 }
 
-func (s *RetryLayerAutoMessageTaskStore) Delete(taskId string) StoreChannel {
+func (s *RetryLayerAutoMessageTaskStore) Delete(taskId string, deleteAt int64) StoreChannel {
 
-	return s.AutoMessageTaskStore.Delete(taskId)
+	return s.AutoMessageTaskStore.Delete(taskId, deleteAt)
 
 }
 
@@ -470,24 +470,12 @@ func (s *RetryLayerAutoMessageTaskStore) GetByPageId(pageId string) StoreChannel
 
 }
 
-func (s *RetryLayerAutoMessageTaskStore) Pause(taskId string) StoreChannel {
-
-	return s.AutoMessageTaskStore.Pause(taskId)
-
-}
-
 func (s *RetryLayerAutoMessageTaskStore) Save(messageTask *model.AutoMessageTask) StoreChannel {
 
 	return s.AutoMessageTaskStore.Save(messageTask)
 
 }
 
-func (s *RetryLayerAutoMessageTaskStore) Start(taskId string) StoreChannel {
-
-	return s.AutoMessageTaskStore.Start(taskId)
-
-}
-
 func (s *RetryLayerAutoMessageTaskStore) Update(messageTask *model.AutoMessageTask) StoreChannel {
 
 	return s.AutoMessageTaskStore.Update(messageTask)
@@ -952,9 +940,9 @@ func (s *RetryLayerFacebookConversationStore) AnalyticsConversationCountsByDay(p
 
 }
 
-func (s *RetryLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, appScopedUserId string) StoreChannel {
+func (s *RetryLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, deletedBy string) StoreChannel {
 
-	return s.FacebookConversationStore.DeleteCommentByCommentId(commentId, appScopedUserId)
+	return s.FacebookConversationStore.DeleteCommentByCommentId(commentId, deletedBy)
 
 }
 
@@ -970,12 +958,6 @@ func (s *RetryLayerFacebookConversationStore) GetConversationById(id string) Sto
 
 }
 
-func (s *RetryLayerFacebookConversationStore) GetConversations(pageIds string, offset int, limit int) StoreChannel {
-
-	return s.FacebookConversationStore.GetConversations(pageIds, offset, limit)
-
-}
-
 func (s *RetryLayerFacebookConversationStore) GetFacebookAttachmentByIds(userIds []string, allowFromCache bool) StoreChannel {
 
 	return s.FacebookConversationStore.GetFacebookAttachmentByIds(userIds, allowFromCache)
@@ -1024,9 +1006,9 @@ func (s *RetryLayerFacebookConversationStore) Save(conversation *model.FacebookC
 
 }
 
-func (s *RetryLayerFacebookConversationStore) Search(term string, pageIds string, offset int, limit int) StoreChannel {
+func (s *RetryLayerFacebookConversationStore) Search(options *model.ConversationSearchOptions) StoreChannel {
 
-	return s.FacebookConversationStore.Search(term, pageIds, offset, limit)
+	return s.FacebookConversationStore.Search(options)
 
 }
 
@@ -1180,9 +1162,9 @@ func (s *RetryLayerFanpageStore) GetMemberByPageId(pageId string, userId string)
 
 }
 
-func (s *RetryLayerFanpageStore) GetOneFanPageMember(fanpageId string) StoreChannel {
+func (s *RetryLayerFanpageStore) GetOwnerMember(pageId string) StoreChannel {
 
-	return s.FanpageStore.GetOneFanPageMember(fanpageId)
+	return s.FanpageStore.GetOwnerMember(pageId)
 
 }
 
@@ -2256,12 +2238,6 @@ func (s *RetryLayerOAuthStore) UpdateApp(app *model.OAuthApp) (*model.OAuthApp,
 
 }
 
-func (s *RetryLayerOrderStore) GetOrders(limit int, offset int) StoreChannel {
-
-	return s.OrderStore.GetOrders(limit, offset)
-
-}
-
 func (s *RetryLayerOrderStore) Save(order *model.Order) StoreChannel {
 
 	return s.OrderStore.Save(order)
@@ -6,7 +6,10 @@ package sqlstore
 import (
 	"bitbucket.org/enesyteam/papo-server/model"
 	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
 	"net/http"
+
+	sq "github.com/Masterminds/squirrel"
 )
 
 type sqlAutoMessageTaskStore struct {
@@ -22,6 +25,17 @@ func NewSqlAutoMessageTaskStore(sqlStore SqlStore) store.AutoMessageTaskStore {
 	for _, db := range sqlStore.GetAllConns() {
 		table := db.AddTableWithName(model.AutoMessageTask{}, "AutoMessageTasks").SetKeys(false, "Id")
 		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("Tag").SetMaxSize(32)
+		table.ColMap("Status").SetMaxSize(16)
+		table.ColMap("JobId").SetMaxSize(26)
+
+		tabler := db.AddTableWithName(model.AutoMessageTaskRecipient{}, "AutoMessageTaskRecipients").SetKeys(false, "Id")
+		tabler.ColMap("Id").SetMaxSize(26)
+		tabler.ColMap("TaskId").SetMaxSize(26)
+		tabler.ColMap("ConversationId").SetMaxSize(26)
+		tabler.ColMap("Status").SetMaxSize(16)
+		tabler.ColMap("MessageId").SetMaxSize(26)
+		tabler.ColMap("Error").SetMaxSize(1024)
 	}
 
 	return fs
@@ -35,12 +49,30 @@ func (fs sqlAutoMessageTaskStore) CreateIndexesIfNotExists() {
 	fs.CreateIndexIfNotExists("idx_auto_message_tasks_update_at", "AutoMessageTasks", "UpdateAt")
 	fs.CreateIndexIfNotExists("idx_auto_message_tasks_create_at", "AutoMessageTasks", "CreateAt")
 	fs.CreateIndexIfNotExists("idx_auto_message_tasks_delete_at", "AutoMessageTasks", "DeleteAt")
+
+	fs.CreateCompositeIndexIfNotExists("idx_auto_message_task_recipients_task_id_conversation_id", "AutoMessageTaskRecipients", []string{"TaskId", "ConversationId"})
+	fs.CreateCompositeIndexIfNotExists("idx_auto_message_task_recipients_task_id_status", "AutoMessageTaskRecipients", []string{"TaskId", "Status"})
+}
+
+func (fs sqlAutoMessageTaskStore) Get(taskId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var task *model.AutoMessageTask
+		if err := fs.GetReplica().SelectOne(&task, "SELECT * FROM AutoMessageTasks WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": taskId}); err != nil {
+			if err == sql.ErrNoRows {
+				result.Err = model.NewAppError("sqlAutoMessageTaskStore.Get", "store.sql_auto_message_task.get.app_error", nil, "id="+taskId, http.StatusNotFound)
+				return
+			}
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Get", "store.sql_auto_message_task.get.app_error", nil, "id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = task
+	})
 }
 
 func (fs sqlAutoMessageTaskStore) GetByPageId(pageId string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		var data []*model.AutoMessageTask
-		if _, err := fs.GetReplica().Select(&data, "SELECT AutoMessageTasks.* FROM AutoMessageTasks WHERE AutoMessageTasks.PageId = :PageId", map[string]interface{}{"PageId": pageId}); err != nil {
+		if _, err := fs.GetReplica().Select(&data, "SELECT AutoMessageTasks.* FROM AutoMessageTasks WHERE AutoMessageTasks.PageId = :PageId AND AutoMessageTasks.DeleteAt = 0", map[string]interface{}{"PageId": pageId}); err != nil {
 			result.Err = model.NewAppError("sqlAutoMessageTaskStore.GetByPageId", "store.sql_team.get_all.app_error", nil, err.Error(), http.StatusInternalServerError)
 			return
 		}
@@ -61,52 +93,213 @@ func (fs sqlAutoMessageTaskStore) Save(task *model.AutoMessageTask) store.StoreC
 	})
 }
 
+// Update lưu nội dung và bộ lọc của chiến dịch, trạng thái và bộ đếm chỉ được thay đổi bởi job gửi tin nhắn.
+// Chỉ sửa được chiến dịch chưa chạy hoặc đang tạm dừng.
 func (s sqlAutoMessageTaskStore) Update(task *model.AutoMessageTask) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		task.PreUpdate()
+		task.UpdateAt = model.GetMillis()
 
-		oldResult, err := s.GetMaster().Get(model.AutoMessageTask{}, task.Id)
+		editableStatuses := []string{model.AUTO_MESSAGE_TASK_STATUS_CREATED, model.AUTO_MESSAGE_TASK_STATUS_PAUSED}
+		queryString, args, err := s.getQueryBuilder().
+			Update("AutoMessageTasks").
+			Set("Name", task.Name).
+			Set("Description", task.Description).
+			Set("Message", task.Message).
+			Set("Attachments", task.Attachments).
+			Set("Tag", task.Tag).
+			Set("FilterFromDate", task.FilterFromDate).
+			Set("FilterToDate", task.FilterToDate).
+			Set("FilterTags", task.FilterTags).
+			Set("FilterHasPhone", task.FilterHasPhone).
+			Set("FilterNotHasPhone", task.FilterNotHasPhone).
+			Set("FilterHasOrder", task.FilterHasOrder).
+			Set("FilterNotHasOrder", task.FilterNotHasOrder).
+			Set("FilterReceptions", task.FilterReceptions).
+			Set("UpdateAt", task.UpdateAt).
+			Where(sq.Eq{"Id": task.Id, "Status": editableStatuses, "DeleteAt": 0}).
+			ToSql()
 		if err != nil {
-			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.finding.app_error", nil, "id="+task.Id+", "+err.Error(), http.StatusInternalServerError)
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.updating.app_error", nil, err.Error(), http.StatusInternalServerError)
 			return
 		}
 
-		if oldResult == nil {
-			result.Err = model.NewAppError("sqlPageReplySnippetStore.Update", "store.sql_team.update.find.app_error", nil, "id="+task.Id, http.StatusBadRequest)
+		sqlResult, err := s.GetMaster().Exec(queryString, args...)
+		if err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.updating.app_error", nil, "id="+task.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := sqlResult.RowsAffected(); rows == 0 {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_auto_message_task.update.not_editable.app_error", nil, "id="+task.Id, http.StatusBadRequest)
 			return
 		}
 
-		oldTask := oldResult.(*model.AutoMessageTask)
-		task.CreateAt = oldTask.CreateAt
-		task.UpdateAt = model.GetMillis()
+		updated, err := s.GetMaster().Get(model.AutoMessageTask{}, task.Id)
+		if err != nil || updated == nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.finding.app_error", nil, "id="+task.Id, http.StatusInternalServerError)
+			return
+		}
+		result.Data = updated.(*model.AutoMessageTask)
+	})
+}
+
+// UpdateStatus đổi trạng thái chiến dịch nếu trạng thái hiện tại nằm trong currentStatuses, jobId rỗng thì giữ nguyên job cũ.
+// Trả về false nếu chiến dịch đã bị đổi trạng thái trước đó.
+func (s sqlAutoMessageTaskStore) UpdateStatus(taskId string, currentStatuses []string, status string, jobId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		now := model.GetMillis()
+		query := s.getQueryBuilder().
+			Update("AutoMessageTasks").
+			Set("Status", status).
+			Set("UpdateAt", now).
+			Where(sq.Eq{"Id": taskId, "Status": currentStatuses, "DeleteAt": 0})
+
+		if len(jobId) > 0 {
+			query = query.Set("JobId", jobId)
+		}
 
-		count, err := s.GetMaster().Update(task)
+		if status == model.AUTO_MESSAGE_TASK_STATUS_ACTIVE {
+			query = query.Set("StartAt", sq.Expr("CASE WHEN StartAt = 0 THEN ? ELSE StartAt END", now))
+		}
+
+		queryString, args, err := query.ToSql()
 		if err != nil {
-			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.updating.app_error", nil, "id="+task.Id+", "+err.Error(), http.StatusInternalServerError)
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.UpdateStatus", "store.sql_auto_message_task.update_status.app_error", nil, err.Error(), http.StatusInternalServerError)
 			return
 		}
-		if count != 1 {
-			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Update", "store.sql_team.update.app_error", nil, "id="+task.Id, http.StatusInternalServerError)
+
+		sqlResult, err := s.GetMaster().Exec(queryString, args...)
+		if err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.UpdateStatus", "store.sql_auto_message_task.update_status.app_error", nil, "id="+taskId+", "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		result.Data = task
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows > 0
+	})
+}
+
+// IncrementCounts cộng dồn số người nhận theo từng kết quả gửi
+func (s sqlAutoMessageTaskStore) IncrementCounts(taskId string, sent, failed, skipped, queued int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := `UPDATE AutoMessageTasks SET SentCount = SentCount + :Sent, FailedCount = FailedCount + :Failed,
+			SkippedCount = SkippedCount + :Skipped, QueuedCount = QueuedCount + :Queued, UpdateAt = :UpdateAt WHERE Id = :Id`
+		if _, err := s.GetMaster().Exec(query, map[string]interface{}{"Id": taskId, "Sent": sent, "Failed": failed, "Skipped": skipped, "Queued": queued, "UpdateAt": model.GetMillis()}); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.IncrementCounts", "store.sql_auto_message_task.increment_counts.app_error", nil, "id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Finish kết thúc chiến dịch đang chạy với trạng thái success hoặc error
+func (s sqlAutoMessageTaskStore) Finish(taskId string, status string, endAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := "UPDATE AutoMessageTasks SET Status = :Status, EndAt = :EndAt, UpdateAt = :EndAt WHERE Id = :Id AND Status = :Active"
+		sqlResult, err := s.GetMaster().Exec(query, map[string]interface{}{"Id": taskId, "Status": status, "EndAt": endAt, "Active": model.AUTO_MESSAGE_TASK_STATUS_ACTIVE})
+		if err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Finish", "store.sql_auto_message_task.update_status.app_error", nil, "id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows > 0
+	})
+}
+
+func (s sqlAutoMessageTaskStore) Delete(taskId string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := "UPDATE AutoMessageTasks SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id"
+		if _, err := s.GetMaster().Exec(query, map[string]interface{}{"Id": taskId, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.Delete", "store.sql_auto_message_task.delete.app_error", nil, "id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (s sqlAutoMessageTaskStore) SaveRecipient(recipient *model.AutoMessageTaskRecipient) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		recipient.PreSave()
+		if len(recipient.Error) > 1024 {
+			recipient.Error = recipient.Error[:1024]
+		}
+
+		if err := s.GetMaster().Insert(recipient); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.SaveRecipient", "store.sql_auto_message_task.save_recipient.app_error", nil, "task_id="+recipient.TaskId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = recipient
 	})
 }
 
-func (s sqlAutoMessageTaskStore) Delete(taskId string) store.StoreChannel {
+// UpdateRecipient cập nhật kết quả gửi của người nhận đã được ghi nhận trước khi gửi
+func (s sqlAutoMessageTaskStore) UpdateRecipient(recipient *model.AutoMessageTaskRecipient) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
+		if len(recipient.Error) > 1024 {
+			recipient.Error = recipient.Error[:1024]
+		}
 
+		if _, err := s.GetMaster().Exec(`UPDATE AutoMessageTaskRecipients SET Status = :Status, MessageId = :MessageId, Error = :Error WHERE Id = :Id`,
+			map[string]interface{}{"Id": recipient.Id, "Status": recipient.Status, "MessageId": recipient.MessageId, "Error": recipient.Error}); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.UpdateRecipient", "store.sql_auto_message_task.update_recipient.app_error", nil, "id="+recipient.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = recipient
 	})
 }
 
-func (s sqlAutoMessageTaskStore) Start(taskId string) store.StoreChannel {
+// GetRecipientConversationIds trả về các hội thoại trong conversationIds đã được chiến dịch xử lý,
+// dùng để không gửi lặp khi job chạy lại từ cursor cũ
+func (s sqlAutoMessageTaskStore) GetRecipientConversationIds(taskId string, conversationIds []string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
+		ids := []string{}
+		if len(conversationIds) == 0 {
+			result.Data = ids
+			return
+		}
 
+		queryString, args, err := s.getQueryBuilder().
+			Select("ConversationId").
+			From("AutoMessageTaskRecipients").
+			Where(sq.Eq{"TaskId": taskId, "ConversationId": conversationIds}).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.GetRecipientConversationIds", "store.sql_auto_message_task.get_recipients.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := s.GetReplica().Select(&ids, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.GetRecipientConversationIds", "store.sql_auto_message_task.get_recipients.app_error", nil, "task_id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = ids
 	})
 }
 
-func (s sqlAutoMessageTaskStore) Pause(taskId string) store.StoreChannel {
+// GetRecipients trả về kết quả gửi của chiến dịch, mới nhất trước, status rỗng là mọi kết quả
+func (s sqlAutoMessageTaskStore) GetRecipients(taskId string, status string, offset, limit int) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
+		query := s.getQueryBuilder().
+			Select("*").
+			From("AutoMessageTaskRecipients").
+			Where(sq.Eq{"TaskId": taskId})
+
+		if len(status) > 0 {
+			query = query.Where(sq.Eq{"Status": status})
+		}
 
+		queryString, args, err := query.
+			OrderBy("CreateAt DESC", "Id DESC").
+			Offset(uint64(offset)).
+			Limit(uint64(limit)).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.GetRecipients", "store.sql_auto_message_task.get_recipients.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		recipients := []*model.AutoMessageTaskRecipient{}
+		if _, err := s.GetReplica().Select(&recipients, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlAutoMessageTaskStore.GetRecipients", "store.sql_auto_message_task.get_recipients.app_error", nil, "task_id="+taskId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = recipients
 	})
 }
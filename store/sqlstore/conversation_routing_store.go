@@ -0,0 +1,83 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
+	"net/http"
+)
+
+type sqlConversationRoutingStore struct {
+	SqlStore
+}
+
+func NewSqlConversationRoutingStore(sqlStore SqlStore) store.ConversationRoutingStore {
+	s := &sqlConversationRoutingStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.ConversationRouting{}, "ConversationRoutings").SetKeys(false, "PageId")
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("Method").SetMaxSize(32)
+		table.ColMap("UserIds").SetMaxSize(8000)
+		table.ColMap("TagRules").SetMaxSize(65535)
+		table.ColMap("LastAssignedTo").SetMaxSize(26)
+	}
+
+	return s
+}
+
+func (s sqlConversationRoutingStore) CreateIndexesIfNotExists() {
+}
+
+// Save lưu cấu hình định tuyến của page, tạo mới nếu page chưa có cấu hình
+func (s sqlConversationRoutingStore) Save(routing *model.ConversationRouting) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		routing.PreSave()
+		if result.Err = routing.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := s.GetMaster().Update(routing)
+		if err != nil {
+			result.Err = model.NewAppError("sqlConversationRoutingStore.Save", "store.sql_conversation_routing.save.app_error", nil, "page_id="+routing.PageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if count == 0 {
+			if err := s.GetMaster().Insert(routing); err != nil {
+				result.Err = model.NewAppError("sqlConversationRoutingStore.Save", "store.sql_conversation_routing.save.app_error", nil, "page_id="+routing.PageId+", "+err.Error(), http.StatusInternalServerError)
+				return
+			}
+		}
+
+		result.Data = routing
+	})
+}
+
+func (s sqlConversationRoutingStore) Get(pageId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var routing model.ConversationRouting
+		if err := s.GetReplica().SelectOne(&routing, "SELECT * FROM ConversationRoutings WHERE PageId = :PageId", map[string]interface{}{"PageId": pageId}); err != nil {
+			result.Err = model.NewAppError("sqlConversationRoutingStore.Get", "store.sql_conversation_routing.get.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &routing
+	})
+}
+
+func (s sqlConversationRoutingStore) UpdateLastAssignedTo(pageId string, userId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE ConversationRoutings SET LastAssignedTo = :LastAssignedTo WHERE PageId = :PageId", map[string]interface{}{"PageId": pageId, "LastAssignedTo": userId}); err != nil {
+			result.Err = model.NewAppError("sqlConversationRoutingStore.UpdateLastAssignedTo", "store.sql_conversation_routing.save.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,204 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
+	"net/http"
+
+	sq "github.com/Masterminds/squirrel"
+)
+
+type sqlCustomerStore struct {
+	SqlStore
+}
+
+func NewSqlCustomerStore(sqlStore SqlStore) store.CustomerStore {
+	s := &sqlCustomerStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.CustomerRecord{}, "Customers").SetKeys(false, "Id")
+		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("Name").SetMaxSize(model.CUSTOMER_NAME_MAX_LENGTH * 4)
+		table.ColMap("Phones").SetMaxSize(model.CUSTOMER_PHONES_MAX * 16)
+
+		identities := db.AddTableWithName(model.CustomerIdentity{}, "CustomerIdentities").SetKeys(false, "FacebookUid")
+		identities.ColMap("FacebookUid").SetMaxSize(64)
+		identities.ColMap("CustomerId").SetMaxSize(26)
+		identities.ColMap("PageId").SetMaxSize(64)
+		identities.ColMap("PageScopeId").SetMaxSize(64)
+		identities.ColMap("Name").SetMaxSize(256)
+		identities.ColMap("Phones").SetMaxSize(model.CUSTOMER_PHONES_MAX * 16)
+	}
+
+	return s
+}
+
+func (s sqlCustomerStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_customer_identities_customer_id", "CustomerIdentities", "CustomerId")
+	s.CreateIndexIfNotExists("idx_customer_identities_page_scope_id", "CustomerIdentities", "PageScopeId")
+}
+
+func (s sqlCustomerStore) Save(customer *model.CustomerRecord) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		customer.PreSave()
+		if result.Err = customer.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(customer); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.Save", "store.sql_customer.save.app_error", nil, "id="+customer.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = customer
+	})
+}
+
+func (s sqlCustomerStore) Update(customer *model.CustomerRecord) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		customer.PreUpdate()
+		if result.Err = customer.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := s.GetMaster().Update(customer)
+		if err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.Update", "store.sql_customer.update.app_error", nil, "id="+customer.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != 1 {
+			result.Err = model.NewAppError("sqlCustomerStore.Update", "store.sql_customer.get.app_error", nil, "id="+customer.Id, http.StatusNotFound)
+			return
+		}
+
+		result.Data = customer
+	})
+}
+
+func (s sqlCustomerStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var customer model.CustomerRecord
+		if err := s.GetReplica().SelectOne(&customer, "SELECT * FROM Customers WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.Get", "store.sql_customer.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &customer
+	})
+}
+
+func (s sqlCustomerStore) Delete(id string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE Customers SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id", map[string]interface{}{"Id": id, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.Delete", "store.sql_customer.delete.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (s sqlCustomerStore) SaveIdentity(identity *model.CustomerIdentity) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		identity.PreSave()
+		if result.Err = identity.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(identity); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.SaveIdentity", "store.sql_customer.save_identity.app_error", nil, "facebook_uid="+identity.FacebookUid+", "+err.Error(), http.StatusInternalServerError)
+			if IsUniqueConstraintError(err, []string{"PRIMARY", "customeridentities_pkey"}) {
+				result.Err.StatusCode = http.StatusConflict
+			}
+			return
+		}
+
+		result.Data = identity
+	})
+}
+
+func (s sqlCustomerStore) GetIdentity(facebookUid string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var identity model.CustomerIdentity
+		if err := s.GetReplica().SelectOne(&identity, "SELECT * FROM CustomerIdentities WHERE FacebookUid = :FacebookUid", map[string]interface{}{"FacebookUid": facebookUid}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.GetIdentity", "store.sql_customer.get_identity.app_error", nil, "facebook_uid="+facebookUid+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &identity
+	})
+}
+
+// GetIdentityByPageScopeId tìm định danh theo page scoped id của messenger trên page, dùng để nhận ra khách hàng
+// nhắn tin qua webhook trước khi biết facebook uid. Page scoped id chỉ có nghĩa trong một page nên luôn lọc theo page.
+func (s sqlCustomerStore) GetIdentityByPageScopeId(pageId string, pageScopeId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var identity model.CustomerIdentity
+		if err := s.GetReplica().SelectOne(&identity, "SELECT * FROM CustomerIdentities WHERE PageId = :PageId AND PageScopeId = :PageScopeId LIMIT 1", map[string]interface{}{"PageId": pageId, "PageScopeId": pageScopeId}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.GetIdentityByPageScopeId", "store.sql_customer.get_identity.app_error", nil, "page_id="+pageId+", page_scope_id="+pageScopeId+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &identity
+	})
+}
+
+func (s sqlCustomerStore) GetIdentities(customerId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		identities := []*model.CustomerIdentity{}
+		if _, err := s.GetReplica().Select(&identities, "SELECT * FROM CustomerIdentities WHERE CustomerId = :CustomerId ORDER BY CreateAt ASC", map[string]interface{}{"CustomerId": customerId}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.GetIdentities", "store.sql_customer.get_identity.app_error", nil, "customer_id="+customerId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = identities
+	})
+}
+
+func (s sqlCustomerStore) UpdateIdentityPhones(facebookUid string, phones model.StringArray) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE CustomerIdentities SET Phones = :Phones WHERE FacebookUid = :FacebookUid", map[string]interface{}{"FacebookUid": facebookUid, "Phones": model.ArrayToJson(phones)}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.UpdateIdentityPhones", "store.sql_customer.update_identity.app_error", nil, "facebook_uid="+facebookUid+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MoveIdentities chuyển các facebook uid sang hồ sơ customerId, dùng khi tách hồ sơ
+func (s sqlCustomerStore) MoveIdentities(facebookUids []string, customerId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		queryString, args, err := s.getQueryBuilder().
+			Update("CustomerIdentities").
+			Set("CustomerId", customerId).
+			Where(sq.Eq{"FacebookUid": facebookUids}).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.MoveIdentities", "store.sql_customer.move_identities.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := s.GetMaster().Exec(queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.MoveIdentities", "store.sql_customer.move_identities.app_error", nil, "customer_id="+customerId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// MoveAllIdentities chuyển mọi facebook uid của hồ sơ này sang hồ sơ khác, dùng khi gộp hồ sơ
+func (s sqlCustomerStore) MoveAllIdentities(fromCustomerId string, toCustomerId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE CustomerIdentities SET CustomerId = :ToCustomerId WHERE CustomerId = :FromCustomerId", map[string]interface{}{"FromCustomerId": fromCustomerId, "ToCustomerId": toCustomerId}); err != nil {
+			result.Err = model.NewAppError("sqlCustomerStore.MoveAllIdentities", "store.sql_customer.move_identities.app_error", nil, "customer_id="+fromCustomerId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
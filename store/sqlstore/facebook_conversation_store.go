@@ -5,6 +5,7 @@ package sqlstore
 
 import (
 	"bitbucket.org/enesyteam/papo-server/einterfaces"
+	"bitbucket.org/enesyteam/papo-server/mlog"
 	"bitbucket.org/enesyteam/papo-server/model"
 	"bitbucket.org/enesyteam/papo-server/store"
 	"bitbucket.org/enesyteam/papo-server/utils"
@@ -17,6 +18,10 @@ import (
 )
 
 const (
+	// mỗi mid hoặc comment_id chỉ được lưu một lần trên một page, webhook gửi lại sẽ không tạo thêm dòng
+	MESSAGE_PAGE_MESSAGE_ID_INDEX = "idx_facebook_conversations_messages_page_message_id"
+	MESSAGE_PAGE_COMMENT_ID_INDEX = "idx_facebook_conversations_messages_page_comment_id"
+
 	ATTACHMENT_CACHE_SIZE = model.CHANNEL_CACHE_SIZE
 	ATTACHMENT_CACHE_SEC  = 900 // 15 mins
 	ATTACHMENT_BY_IDS_CACHE_SIZE      = model.SESSION_CACHE_SIZE
@@ -64,6 +69,10 @@ func NewSqlFacebookConversationStore(sqlStore SqlStore, metrics einterfaces.Metr
 		table.ColMap("Id").SetMaxSize(26)
 		// Thiết lập cho các columns
 		table.ColMap("Type").SetMaxSize(12)
+		table.ColMap("AssignedTo").SetMaxSize(26)
+		table.ColMap("AssignedBy").SetMaxSize(26)
+		table.ColMap("Status").SetMaxSize(16)
+		table.ColMap("StatusUpdatedBy").SetMaxSize(26)
 		//table.ColMap("Snippet").SetMaxSize(120) // chỉ lấy 120 ký tự
 
 		// Khởi tạo các table con
@@ -71,6 +80,10 @@ func NewSqlFacebookConversationStore(sqlStore SqlStore, metrics einterfaces.Metr
 
 		tablem.ColMap("ConversationId").SetMaxSize(26)
 		tablem.ColMap("Id").SetMaxSize(26)
+		tablem.ColMap("SystemType").SetMaxSize(32)
+		tablem.ColMap("Props").SetMaxSize(8000)
+		tablem.ColMap("Status").SetMaxSize(16)
+		tablem.ColMap("LastError").SetMaxSize(1024)
 
 		// image table
 		tablei := db.AddTableWithName(model.FacebookAttachmentImage{}, "FacebookAttachmentImages").SetKeys(false, "Id", "MessageId", "PostId" )
@@ -87,15 +100,56 @@ func (fs sqlFacebookConversationStore) CreateIndexesIfNotExists() {
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_seen", "FacebookConversations", "Seen")
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_update_at", "FacebookConversations", "UpdateAt")
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_updated_time", "FacebookConversations", "UpdatedTime")
+	fs.CreateCompositeIndexIfNotExists("idx_facebook_conversations_seen_last_activity_at", "FacebookConversations", []string{"Seen", "LastActivityAt"})
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_create_at", "FacebookConversations", "CreateAt")
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_delete_at", "FacebookConversations", "DeleteAt")
+	fs.CreateIndexIfNotExists("idx_facebook_conversations_assigned_to", "FacebookConversations", "AssignedTo")
+	fs.CreateIndexIfNotExists("idx_facebook_conversations_status", "FacebookConversations", "Status")
+	fs.CreateIndexIfNotExists("idx_facebook_conversations_snoozed_until", "FacebookConversations", "SnoozedUntil")
 
 	fs.CreateIndexIfNotExists("idx_facebook_conversations_messages_created_time", "FacebookConversationMessages", "CreatedTime")
+	fs.CreateCompositeIndexIfNotExists("idx_facebook_conversations_messages_status_next_attempt_at", "FacebookConversationMessages", []string{"Status", "NextAttemptAt"})
+	fs.createMessageKeyIndexIfNotExists(MESSAGE_PAGE_MESSAGE_ID_INDEX, "MessageId")
+	fs.createMessageKeyIndexIfNotExists(MESSAGE_PAGE_COMMENT_ID_INDEX, "CommentId")
 	//fs.CreateIndexIfNotExists("idx_facebook_conversations_messages_conversation_id", "FacebookConversationMessages", "ConversationId")
 
 	// còn nhiều thứ khác cần index
 }
 
+// createMessageKeyIndexIfNotExists tạo unique index trên (PageId, column) cho các dòng có column khác rỗng,
+// tin nhắn outbox chưa gửi và tin nhắn hệ thống không có mid nên không thể dùng unique index thông thường.
+// Các dòng bị lưu trùng trước khi có index được xóa đi, chỉ giữ lại bản lưu đầu tiên.
+func (fs sqlFacebookConversationStore) createMessageKeyIndexIfNotExists(indexName, column string) {
+	var query string
+	if fs.DriverName() == model.DATABASE_DRIVER_POSTGRES {
+		if _, err := fs.GetMaster().SelectStr("SELECT $1::regclass", indexName); err == nil {
+			return
+		}
+
+		fs.GetMaster().Exec(`DELETE FROM FacebookConversationMessages a USING FacebookConversationMessages b
+			WHERE a.PageId = b.PageId AND a.` + column + ` = b.` + column + ` AND a.` + column + ` <> ''
+				AND (a.CreateAt > b.CreateAt OR (a.CreateAt = b.CreateAt AND a.Id > b.Id))`)
+		query = "CREATE UNIQUE INDEX " + indexName + " ON FacebookConversationMessages (PageId, " + column + ") WHERE " + column + " <> ''"
+	} else if fs.DriverName() == model.DATABASE_DRIVER_MYSQL {
+		count, err := fs.GetMaster().SelectInt("SELECT COUNT(0) FROM information_schema.statistics WHERE TABLE_SCHEMA = DATABASE() AND table_name = 'FacebookConversationMessages' AND index_name = ?", indexName)
+		if err != nil || count > 0 {
+			return
+		}
+
+		fs.GetMaster().Exec(`DELETE a FROM FacebookConversationMessages a INNER JOIN FacebookConversationMessages b
+			ON a.PageId = b.PageId AND a.` + column + ` = b.` + column + `
+			WHERE a.` + column + ` <> '' AND (a.CreateAt > b.CreateAt OR (a.CreateAt = b.CreateAt AND a.Id > b.Id))`)
+		// MySQL không có partial index, dùng functional key part (MySQL 8.0.13 trở lên) để bỏ qua giá trị rỗng
+		query = "CREATE UNIQUE INDEX " + indexName + " ON FacebookConversationMessages (PageId, (NULLIF(" + column + ", '')))"
+	} else {
+		return
+	}
+
+	if _, err := fs.GetMaster().ExecNoTimeout(query); err != nil {
+		mlog.Error("Failed to create unique message index, redelivered webhooks may be saved twice", mlog.String("index", indexName), mlog.Err(err))
+	}
+}
+
 func (fs sqlFacebookConversationStore) Get(id string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		if obj, err := fs.GetReplica().Get(model.FacebookConversation{}, id); err != nil {
@@ -175,8 +229,8 @@ func (fs sqlFacebookConversationStore) UpdatePageScopeId(conversationId, pageSco
 
 func (fs sqlFacebookConversationStore) UpdateLatestTime(conversationId string, time string, commentId string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-		query := "UPDATE facebookconversations SET updatedtime = :updated_time WHERE id = :conversation_id"
-		_, err := fs.GetMaster().Exec(query, map[string]interface{}{"updated_time": time, "comment_id": commentId, "conversation_id": conversationId})
+		query := "UPDATE facebookconversations SET updatedtime = :updated_time, LastActivityAt = :last_activity_at WHERE id = :conversation_id"
+		_, err := fs.GetMaster().Exec(query, map[string]interface{}{"updated_time": time, "last_activity_at": model.ParseFacebookTime(time), "comment_id": commentId, "conversation_id": conversationId})
 		if err != nil {
 			result.Err = model.NewAppError("sqlFanpageStore.Save", "store.sql_fanpage.save.app_error", nil, "page_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
 		} else {
@@ -216,12 +270,12 @@ func (fs sqlFacebookConversationStore) UpdateConversation(conversationId string,
 	return store.Do(func(result *store.StoreResult) {
 		var query string
 		if isFromPage {
-			query = "UPDATE facebookconversations SET UnreadCount = 0, Snippet = :Snippet, UpdatedTime = :UpdatedTime, Seen = true, Replied = true  WHERE id = :ConversationId"
+			query = "UPDATE facebookconversations SET UnreadCount = 0, Snippet = :Snippet, UpdatedTime = :UpdatedTime, LastActivityAt = :LastActivityAt, Seen = true, Replied = true  WHERE id = :ConversationId"
 		} else {
-			query = "UPDATE facebookconversations SET UnreadCount = UnreadCount + :UnreadCount, Snippet = :Snippet, UpdatedTime = :UpdatedTime, Seen = false, Replied = false, LastUserMessageAt = :LastUserMessageAt WHERE id = :ConversationId"
+			query = "UPDATE facebookconversations SET UnreadCount = UnreadCount + :UnreadCount, Snippet = :Snippet, UpdatedTime = :UpdatedTime, LastActivityAt = :LastActivityAt, Seen = false, Replied = false, LastUserMessageAt = :LastUserMessageAt WHERE id = :ConversationId"
 		}
 
-		_, err := fs.GetMaster().Exec(query, map[string]interface{}{"ConversationId": conversationId, "Snippet": snippet, "UpdatedTime": updatedTime, "UnreadCount": unreadCount, "LastUserMessageAt": lastUserMessageAt})
+		_, err := fs.GetMaster().Exec(query, map[string]interface{}{"ConversationId": conversationId, "Snippet": snippet, "UpdatedTime": updatedTime, "LastActivityAt": model.ParseFacebookTime(updatedTime), "UnreadCount": unreadCount, "LastUserMessageAt": lastUserMessageAt})
 		if err != nil {
 			fmt.Println(err)
 			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateConversation", "store.UpdateConversation.update_snippet.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
@@ -385,47 +439,282 @@ func (s sqlFacebookConversationStore) GetMessagesByConversationId(conversationId
 //	})
 //}
 
-func (fs sqlFacebookConversationStore) getConversations(pageIds string, conversationId string, offset, limit int) store.StoreChannel {
+// Search tìm hội thoại theo các điều kiện trong options, mọi giá trị đều được truyền vào câu query dưới dạng tham số.
+// Hội thoại chưa đọc đứng trước, sau đó sắp xếp theo LastActivityAt, Id giảm dần; NextCursor rỗng khi đã hết kết quả.
+func (fs sqlFacebookConversationStore) Search(options *model.ConversationSearchOptions) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-
-		if limit > 250 {
-			result.Err = model.NewAppError("sqlFacebookConversationStore.GetMessagesByConversationId", "store.sql_conversation.get_messages.app_error", nil, "conversationId="+conversationId, http.StatusBadRequest)
+		options.SetDefaults()
+		if err := options.IsValid(); err != nil {
+			result.Err = err
 			return
 		}
 
-		query := `SELECT * FROM facebookconversations `
+		query := fs.conversationQuery.Where(sq.Eq{"FacebookConversations.DeleteAt": 0})
+
+		if len(options.PageIds) > 0 {
+			query = query.Where(sq.Eq{"FacebookConversations.PageId": options.PageIds})
+		}
 
-		if len(pageIds) > 0 {
-			s := strings.Split(pageIds, ",")
-			inPage := ` WHERE facebookconversations.pageid IN (`
-			i := 0
-			for _, id := range s  {
-				inPage += "'" + id + "'"
-				if i < cap(s) - 1 {
-					inPage += ","
+		if len(options.Type) > 0 {
+			query = query.Where(sq.Eq{"FacebookConversations.Type": options.Type})
+		}
+
+		if options.Seen != nil {
+			query = query.Where(sq.Eq{"FacebookConversations.Seen": *options.Seen})
+		}
+
+		if options.Replied != nil {
+			query = query.Where(sq.Eq{"FacebookConversations.Replied": *options.Replied})
+		}
+
+		if options.HasPhone != nil {
+			query = query.Where(sq.Eq{"FacebookConversations.HasPhone": *options.HasPhone})
+		}
+
+		if len(options.AssignedTo) > 0 {
+			query = query.Where(sq.Eq{"FacebookConversations.AssignedTo": options.AssignedTo})
+		}
+
+		if len(options.Statuses) > 0 {
+			statuses := options.Statuses
+			for _, status := range options.Statuses {
+				// hội thoại tạo trước khi có trạng thái được coi là open
+				if status == model.CONVERSATION_STATUS_OPEN {
+					statuses = append(statuses, "")
+					break
 				}
-				i++
 			}
-			inPage += ")"
+			query = query.Where(sq.Eq{"FacebookConversations.Status": statuses})
+		}
+
+		if len(options.TagIds) > 0 {
+			tagQuery := fs.getQueryBuilder().
+				Select("ConversationTags.ConversationId").
+				From("ConversationTags").
+				Where(sq.Eq{"ConversationTags.TagId": options.TagIds}).
+				GroupBy("ConversationTags.ConversationId")
+
+			if options.TagMatch == model.CONVERSATION_SEARCH_TAG_MATCH_ALL {
+				tagQuery = tagQuery.Having("COUNT(DISTINCT ConversationTags.TagId) = ?", len(options.TagIds))
+			}
+
+			tagSql, tagArgs, err := tagQuery.PlaceholderFormat(sq.Question).ToSql()
+			if err != nil {
+				result.Err = model.NewAppError("sqlFacebookConversationStore.Search", "store.sql_conversation.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+				return
+			}
+			query = query.Where("FacebookConversations.Id IN ("+tagSql+")", tagArgs...)
+		}
+
+		if options.Since > 0 {
+			query = query.Where(sq.GtOrEq{"FacebookConversations.LastActivityAt": options.Since})
+		}
+
+		if options.Until > 0 {
+			query = query.Where(sq.LtOrEq{"FacebookConversations.LastActivityAt": options.Until})
+		}
+
+		if len(options.Term) > 0 {
+			term := "%" + strings.ToLower(sanitizeSearchTerm(options.Term, "\\")) + "%"
+			query = query.Where(`EXISTS (
+				SELECT 1 FROM FacebookConversationMessages
+					LEFT JOIN FacebookUids ON FacebookUids.Id = FacebookConversationMessages.From
+				WHERE FacebookConversationMessages.ConversationId = FacebookConversations.Id
+					AND (LOWER(FacebookConversationMessages.Message) LIKE ? OR LOWER(FacebookUids.Name) LIKE ?)
+			)`, term, term)
+		}
+
+		if len(options.Cursor) > 0 {
+			cursor, err := model.DecodeConversationSearchCursor(options.Cursor)
+			if err != nil {
+				result.Err = err
+				return
+			}
+			after := sq.Or{
+				sq.Lt{"FacebookConversations.LastActivityAt": cursor.LastActivityAt},
+				sq.And{
+					sq.Eq{"FacebookConversations.LastActivityAt": cursor.LastActivityAt},
+					sq.Lt{"FacebookConversations.Id": cursor.Id},
+				},
+			}
+			if cursor.Seen {
+				query = query.Where(sq.And{sq.Eq{"FacebookConversations.Seen": true}, after})
+			} else {
+				// trang trước còn hội thoại chưa đọc, các hội thoại đã đọc đều nằm phía sau
+				query = query.Where(sq.Or{sq.Eq{"FacebookConversations.Seen": true}, sq.And{sq.Eq{"FacebookConversations.Seen": false}, after}})
+			}
+		} else if options.Offset > 0 {
+			query = query.Offset(uint64(options.Offset))
+		}
+
+		// lấy thêm một hội thoại để biết còn trang sau hay không
+		query = query.
+			OrderBy("FacebookConversations.Seen", "FacebookConversations.LastActivityAt DESC", "FacebookConversations.Id DESC").
+			Limit(uint64(options.Limit + 1))
+
+		queryString, args, err := query.ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.Search", "store.sql_conversation.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		conversations := []*model.FacebookConversation{}
+		if _, err := fs.GetReplica().Select(&conversations, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.Search", "store.sql_conversation.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		searchResult := &model.ConversationSearchResult{Conversations: conversations}
+		if len(conversations) > options.Limit {
+			searchResult.Conversations = conversations[:options.Limit]
+			last := searchResult.Conversations[options.Limit-1]
+			searchResult.NextCursor = (&model.ConversationSearchCursor{Seen: last.Seen, LastActivityAt: last.LastActivityAt, Id: last.Id}).Encode()
+		}
+
+		result.Data = searchResult
+	})
+}
 
-			query += inPage
-		} else if len(conversationId) > 0 {
-			query += ` WHERE facebookconversations.id = :ConversationId `
+func (fs sqlFacebookConversationStore) SetHasPhone(conversationId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := fs.GetMaster().Exec("UPDATE FacebookConversations SET HasPhone = true WHERE Id = :Id AND HasPhone = false", map[string]interface{}{"Id": conversationId}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.SetHasPhone", "store.sql_conversation.set_has_phone.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
 		}
+	})
+}
 
-		query += `	ORDER BY
-						facebookconversations.Seen,
-						facebookconversations.UpdatedTime DESC
-					LIMIT :Limit OFFSET :Offset
-				`
-		var data []*model.FacebookConversation
+// UpdateAssignee giao hội thoại cho assignedTo, assignedTo rỗng là bỏ giao
+func (fs sqlFacebookConversationStore) UpdateAssignee(conversationId, assignedTo, assignedBy string, assignedAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := "UPDATE FacebookConversations SET AssignedTo = :AssignedTo, AssignedBy = :AssignedBy, AssignedAt = :AssignedAt WHERE Id = :Id"
+		if _, err := fs.GetMaster().Exec(query, map[string]interface{}{"Id": conversationId, "AssignedTo": assignedTo, "AssignedBy": assignedBy, "AssignedAt": assignedAt}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateAssignee", "store.sql_conversation.update_assignee.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
 
-		if _, err := fs.GetReplica().Select(&data, query, map[string]interface{}{"Limit": limit, "Offset": offset, "ConversationId": conversationId}); err != nil {
-			//fmt.Println(err.Error())
-			result.Err = model.NewAppError("SqlTeamStore.getConversations", "store.sqlFacebookConversationStore.getConversations.app_error", nil, err.Error(), http.StatusInternalServerError)
+// AssignIfUnassigned giao hội thoại cho assignedTo khi hội thoại chưa có người phụ trách,
+// trả về false nếu hội thoại đã được giao trước đó
+func (fs sqlFacebookConversationStore) AssignIfUnassigned(conversationId, assignedTo string, assignedAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := "UPDATE FacebookConversations SET AssignedTo = :AssignedTo, AssignedBy = '', AssignedAt = :AssignedAt WHERE Id = :Id AND AssignedTo = ''"
+		sqlResult, err := fs.GetMaster().Exec(query, map[string]interface{}{"Id": conversationId, "AssignedTo": assignedTo, "AssignedAt": assignedAt})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.AssignIfUnassigned", "store.sql_conversation.update_assignee.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
 			return
 		}
-		result.Data = data
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows > 0
+	})
+}
+
+// UpdateStatus đổi trạng thái hội thoại khi trạng thái hiện tại vẫn là oldStatus, trả về false nếu hội thoại đã bị đổi trạng thái trước đó
+func (fs sqlFacebookConversationStore) UpdateStatus(conversationId, oldStatus, status string, snoozedUntil int64, updatedBy string, updateAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		oldStatuses := []string{oldStatus}
+		if oldStatus == model.CONVERSATION_STATUS_OPEN {
+			oldStatuses = append(oldStatuses, "")
+		}
+
+		queryString, args, err := fs.getQueryBuilder().
+			Update("FacebookConversations").
+			Set("Status", status).
+			Set("SnoozedUntil", snoozedUntil).
+			Set("StatusUpdateAt", updateAt).
+			Set("StatusUpdatedBy", updatedBy).
+			Where(sq.Eq{"Id": conversationId, "Status": oldStatuses}).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateStatus", "store.sql_conversation.update_status.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sqlResult, err := fs.GetMaster().Exec(queryString, args...)
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateStatus", "store.sql_conversation.update_status.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows > 0
+	})
+}
+
+// GetSnoozedDue trả về tối đa limit hội thoại đang snoozed đã tới thời điểm mở lại
+func (fs sqlFacebookConversationStore) GetSnoozedDue(now int64, limit int) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var conversations []*model.FacebookConversation
+		query := "SELECT * FROM FacebookConversations WHERE Status = :Status AND SnoozedUntil <= :Now AND DeleteAt = 0 ORDER BY SnoozedUntil LIMIT :Limit"
+		if _, err := fs.GetReplica().Select(&conversations, query, map[string]interface{}{"Status": model.CONVERSATION_STATUS_SNOOZED, "Now": now, "Limit": limit}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetSnoozedDue", "store.sql_conversation.get_snoozed_due.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = conversations
+	})
+}
+
+// CountUnrepliedByAssignee đếm số hội thoại chưa trả lời đang giao cho từng nhân viên trong userIds của page
+func (fs sqlFacebookConversationStore) CountUnrepliedByAssignee(pageId string, userIds []string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		counts := map[string]int64{}
+		if len(userIds) == 0 {
+			result.Data = counts
+			return
+		}
+
+		queryString, args, err := fs.getQueryBuilder().
+			Select("AssignedTo, COUNT(*) AS Count").
+			From("FacebookConversations").
+			Where(sq.Eq{"PageId": pageId, "AssignedTo": userIds, "Replied": false, "DeleteAt": 0}).
+			GroupBy("AssignedTo").
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.CountUnrepliedByAssignee", "store.sql_conversation.count_by_assignee.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var rows []struct {
+			AssignedTo string
+			Count      int64
+		}
+		if _, err := fs.GetReplica().Select(&rows, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.CountUnrepliedByAssignee", "store.sql_conversation.count_by_assignee.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, row := range rows {
+			counts[row.AssignedTo] = row.Count
+		}
+		result.Data = counts
+	})
+}
+
+// GetBySenderIds trả về các hội thoại bình luận và tin nhắn mới nhất của những người gửi trong senderIds
+func (fs sqlFacebookConversationStore) GetBySenderIds(senderIds []string, limit int) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		conversations := []*model.FacebookConversation{}
+		if len(senderIds) == 0 {
+			result.Data = conversations
+			return
+		}
+
+		queryString, args, err := fs.conversationQuery.
+			Where(sq.Eq{"FacebookConversations.From": senderIds, "FacebookConversations.DeleteAt": 0}).
+			OrderBy("FacebookConversations.UpdateAt DESC").
+			Limit(uint64(limit)).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetBySenderIds", "store.sql_conversation.get_by_sender_ids.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := fs.GetReplica().Select(&conversations, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetBySenderIds", "store.sql_conversation.get_by_sender_ids.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = conversations
 	})
 }
 
@@ -608,12 +897,21 @@ func (s *sqlFacebookConversationStore) AnalyticsConversationCountsByDay(pageId s
 	//})
 }
 
-func (fs sqlFacebookConversationStore) GetConversations(pageIds string, offset, limit int) store.StoreChannel {
-	return fs.getConversations(pageIds, "", offset, limit)
-}
-
 func (fs sqlFacebookConversationStore) GetConversationById(id string) store.StoreChannel {
-	return fs.getConversations("", id, 0, 1)
+	return store.Do(func(result *store.StoreResult) {
+		queryString, args, err := fs.conversationQuery.Where(sq.Eq{"FacebookConversations.Id": id}).ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetConversationById", "store.sql_conversation.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		conversations := []*model.FacebookConversation{}
+		if _, err := fs.GetReplica().Select(&conversations, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetConversationById", "store.sql_conversation.search.app_error", nil, "conversation_id="+id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = conversations
+	})
 }
 
 func (fs sqlFacebookConversationStore) UpdateSeen(id string, pageId string, userId string) store.StoreChannel {
@@ -650,11 +948,27 @@ func (fs sqlFacebookConversationStore) UpdateUnSeen(id string, pageId string, us
 	})
 }
 
+// AddMessage lưu tin nhắn hoặc bình luận. Nếu mid (hoặc comment_id) đã được lưu trên page thì không thêm dòng mới,
+// không cập nhật hội thoại mà trả về bản đã lưu, nhờ vậy xử lý lại một webhook không làm trùng tin nhắn và số chưa đọc
 func (fs sqlFacebookConversationStore) AddMessage(message *model.FacebookConversationMessage, shouldUpdateConversation bool, isFromPage bool) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 
 		message.PreSave()
 		if err := fs.GetMaster().Insert(message); err != nil {
+			if IsUniqueConstraintError(err, []string{MESSAGE_PAGE_MESSAGE_ID_INDEX, MESSAGE_PAGE_COMMENT_ID_INDEX}) {
+				column, value := "MessageId", message.MessageId
+				if len(value) == 0 {
+					column, value = "CommentId", message.CommentId
+				}
+
+				var saved model.FacebookConversationMessage
+				if err := fs.GetMaster().SelectOne(&saved, "SELECT * FROM FacebookConversationMessages WHERE PageId = :PageId AND "+column+" = :Value", map[string]interface{}{"PageId": message.PageId, "Value": value}); err != nil {
+					result.Err = model.NewAppError("sqlFacebookConversationStore.AddMessage", "store.sql_message.save.app_error", nil, "page_id="+message.PageId+", "+err.Error(), http.StatusInternalServerError)
+					return
+				}
+				result.Data = &saved
+				return
+			}
 			// cần sửa
 			result.Err = model.NewAppError("sqlFanpageStore.Save", "store.sql_fanpage.save.app_error", nil, "page_id="+message.Id+", "+err.Error(), http.StatusInternalServerError)
 		} else {
@@ -999,67 +1313,6 @@ func (fs sqlFacebookConversationStore) InsertConversationFromCommentIfNeed(paren
 	})
 }
 
-func (fs *sqlFacebookConversationStore) Search(term string, pageIds string, limit, offset int) store.StoreChannel {
-
-	var inPages string
-	if len(pageIds) > 0 {
-		inPages += " WHERE a.pageid IN " + pageIds + " "
-	}
-	query := `
-			SELECT 
-					json_build_object(
-						'id', a.id, 
-						'page_id', a.PageId,
-						'post_id', a.PostId,
-						'type', a.Type,
-						'message', a.Message,
-						'snippet', a.Snippet, 
-						'updated_time', a.UpdatedTime, 
-						'comment_id', a.CommentId, 
-						'scoped_thread_key', a.ScopedthreadKey, 
-						'seen', a.Seen, 
-						'last_seen_by', a.LastseenBy, 
-						'unread_count', a.UnreadCount, 
-						'can_comment', a.CanComment, 
-						'can_like', a.CanLike, 
-						'can_hide', a.CanHide, 
-						'can_reply', a.CanReply, 
-						'can_reply_privately', a.CanReplyPrivately, 
-						'is_hidden', a.IsHidden, 
-						'is_private', a.IsPrivate, 
-						'private_reply_conversation', a.PrivatereplyConversation
-					) as data, json_build_object('id', b.id, 'name', b.Name) as from,
-					COALESCE(NULLIF(d.tags::TEXT, '[null]'), '[]')::JSON as tags
-				FROM 
-					FacebookConversations a INNER JOIN FacebookUids b
-				ON 
-					a.From = b.Id
-				LEFT OUTER JOIN ( -- 1 và 2 và 3 chỉ trong 1 câu query nhé
-					SELECT b.ConversationId, json_agg(c.id) as tags
-					FROM ConversationTags b INNER JOIN PageTags c ON b.TagId = c.id
-					GROUP BY b.ConversationId
-					) d ON  d.ConversationId = a.id
-				INNER JOIN (
-					SELECT DISTINCT g.conversationid FROM facebookconversationmessages g INNER JOIN facebookuids h
-					ON h.id = g.from
-					WHERE g.message ILIKE '%` + term + `%' OR h.name ILIKE '%` + term + `%'
-				) f ON f.conversationid = a.id ` + inPages + `
-			
-			ORDER BY
-					a.UpdatedTime DESC
-				LIMIT :Limit OFFSET :Offset
-		`
-
-	return store.Do(func(result *store.StoreResult) {
-		var data []*model.ConversationResponse
-		if _, err := fs.GetReplica().Select(&data, query, map[string]interface{}{"Limit": limit, "Offset": offset}); err != nil {
-			result.Err = model.NewAppError("sqlFacebookConversationStore.Search", "store.sql_team.get_all.app_error", nil, err.Error(), http.StatusInternalServerError)
-			return
-		}
-		result.Data = data
-	})
-}
-
 func (s *sqlFacebookConversationStore) OverwriteMessage(message *model.FacebookConversationMessage) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		//message.UpdateAt = model.GetMillis()
@@ -1146,6 +1399,59 @@ func (us sqlFacebookConversationStore) GetFacebookAttachmentByIds(ids []string,
 	})
 }
 
+// GetAttachmentPageIds trả về page chứa từng attachment, key là Id hoặc TargetId của attachment.
+// Attachment của tin nhắn/bình luận lấy page qua hội thoại, attachment của bài viết lấy từ tiền tố của post id.
+func (s sqlFacebookConversationStore) GetAttachmentPageIds(ids []string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		pageIds := map[string]string{}
+		if len(ids) == 0 {
+			result.Data = pageIds
+			return
+		}
+
+		query := s.getQueryBuilder().
+			Select("a.Id, a.TargetId, a.PostId, COALESCE(c.PageId, '') AS PageId").
+			From("FacebookAttachmentImages a").
+			LeftJoin("FacebookConversationMessages m ON m.Id = a.MessageId").
+			LeftJoin("FacebookConversations c ON c.Id = m.ConversationId").
+			Where(sq.Or{sq.Eq{"a.Id": ids}, sq.Eq{"a.TargetId": ids}})
+
+		queryString, args, err := query.ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetAttachmentPageIds", "store.sql_conversation.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var rows []struct {
+			Id       string
+			TargetId string
+			PostId   string
+			PageId   string
+		}
+		if _, err := s.GetReplica().Select(&rows, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetAttachmentPageIds", "store.sql_conversation.get_attachments.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		for _, row := range rows {
+			pageId := row.PageId
+			if len(pageId) == 0 && strings.Contains(row.PostId, "_") {
+				pageId = strings.SplitN(row.PostId, "_", 2)[0]
+			}
+			if len(pageId) == 0 {
+				continue
+			}
+
+			pageIds[row.Id] = pageId
+			if len(row.TargetId) > 0 {
+				pageIds[row.TargetId] = pageId
+			}
+		}
+
+		result.Data = pageIds
+	})
+}
+
 func (s sqlFacebookConversationStore) GetPageMessageByMid(pageId, mid string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		var message model.FacebookConversationMessage
@@ -1172,8 +1478,17 @@ func (s sqlFacebookConversationStore) GetPageMessageByMid(pageId, mid string) st
 
 func (fs sqlFacebookConversationStore) UpdateMessageSent(messageId string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-		query := "UPDATE FacebookConversationMessages SET Delivered = true WHERE MessageId = :MessageId"
-		_, err := fs.GetMaster().Exec(query, map[string]interface{}{"MessageId": messageId})
+		// tin nhắn trong outbox chỉ chuyển sang delivered nếu chưa được đánh dấu đã đọc
+		query := `UPDATE FacebookConversationMessages
+			SET Delivered = true,
+				Status = CASE WHEN Status IN (:Pending, :Sent) THEN :Delivered ELSE Status END
+			WHERE MessageId = :MessageId`
+		_, err := fs.GetMaster().Exec(query, map[string]interface{}{
+			"MessageId": messageId,
+			"Pending":   model.MESSAGE_STATUS_PENDING,
+			"Sent":      model.MESSAGE_STATUS_SENT,
+			"Delivered": model.MESSAGE_STATUS_DELIVERED,
+		})
 		if err != nil {
 			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateMessageSent", "store.sql_conversations.save.app_error", nil, "message_id="+messageId+", "+err.Error(), http.StatusInternalServerError)
 		} else {
@@ -1214,16 +1529,18 @@ func (fs sqlFacebookConversationStore) UpdateCommentByCommentId(commentId, newTe
 	})
 }
 
-func (fs sqlFacebookConversationStore) DeleteCommentByCommentId(commentId, appScopedUserId string) store.StoreChannel {
+// DeleteCommentByCommentId đánh dấu bình luận đã xoá, deletedBy là user papo đã xoá bình luận,
+// để trống khi bình luận bị xoá trên facebook (nhận qua webhook)
+func (fs sqlFacebookConversationStore) DeleteCommentByCommentId(commentId, deletedBy string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		updatedTime := time.Now().UnixNano() / int64(time.Millisecond)
 		var deleteQuery string
-		if len(appScopedUserId) > 0 {
+		if len(deletedBy) > 0 {
 			deleteQuery = "UPDATE FacebookConversationMessages SET DeleteAt = :DeleteTime, DeleteBy = :DeleteBy WHERE Type = 'comment' AND CommentId = :CommentId"
 		} else {
 			deleteQuery = "UPDATE FacebookConversationMessages SET DeleteAt = :DeleteTime WHERE Type = 'comment' AND CommentId = :CommentId"
 		}
-		_, err := fs.GetMaster().Exec(deleteQuery, map[string]interface{}{"CommentId": commentId, "DeleteBy": appScopedUserId, "DeleteTime": updatedTime})
+		_, err := fs.GetMaster().Exec(deleteQuery, map[string]interface{}{"CommentId": commentId, "DeleteBy": deletedBy, "DeleteTime": updatedTime})
 		if err == nil {
 			messageQuery := "SELECT a.* FROM FacebookConversationMessages a WHERE a.Type = 'comment' AND a.CommentId = :CommentId"
 			conversationMessage := model.FacebookConversationMessage{}
@@ -1238,3 +1555,194 @@ func (fs sqlFacebookConversationStore) DeleteCommentByCommentId(commentId, appSc
 		}
 	})
 }
+
+func (fs sqlFacebookConversationStore) GetCommentByCommentId(commentId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var message model.FacebookConversationMessage
+		if err := fs.GetReplica().SelectOne(&message, "SELECT * FROM FacebookConversationMessages WHERE Type = 'comment' AND CommentId = :CommentId", map[string]interface{}{"CommentId": commentId}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetCommentByCommentId", "store.sql_conversation.get_comment.app_error", nil, "comment_id="+commentId+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &message
+	})
+}
+
+// UpdateCommentHidden lưu trạng thái ẩn của bình luận và người đã ẩn/hiện bình luận
+func (fs sqlFacebookConversationStore) UpdateCommentHidden(commentId string, isHidden bool, userId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		result.Data, result.Err = fs.updateComment("sqlFacebookConversationStore.UpdateCommentHidden", commentId,
+			"IsHidden = :IsHidden", map[string]interface{}{"IsHidden": isHidden, "EditBy": userId})
+	})
+}
+
+// UpdateCommentLiked lưu trạng thái page đã thích bình luận hay chưa
+func (fs sqlFacebookConversationStore) UpdateCommentLiked(commentId string, isLiked bool, userId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		result.Data, result.Err = fs.updateComment("sqlFacebookConversationStore.UpdateCommentLiked", commentId,
+			"IsLiked = :IsLiked", map[string]interface{}{"IsLiked": isLiked, "EditBy": userId})
+	})
+}
+
+// UpdateCommentPrivateReplied đánh dấu bình luận đã được trả lời riêng, facebook chỉ cho phép trả lời riêng một lần
+func (fs sqlFacebookConversationStore) UpdateCommentPrivateReplied(commentId string, userId string, repliedAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		result.Data, result.Err = fs.updateComment("sqlFacebookConversationStore.UpdateCommentPrivateReplied", commentId,
+			"CanReplyPrivately = false, PrivateRepliedAt = :PrivateRepliedAt", map[string]interface{}{"PrivateRepliedAt": repliedAt, "EditBy": userId})
+	})
+}
+
+// updateComment cập nhật các cột trong setClause của bình luận cùng EditAt, EditBy và trả về bình luận sau khi cập nhật
+func (fs sqlFacebookConversationStore) updateComment(where string, commentId string, setClause string, params map[string]interface{}) (*model.FacebookConversationMessage, *model.AppError) {
+	params["CommentId"] = commentId
+	params["EditAt"] = model.GetMillis()
+
+	query := "UPDATE FacebookConversationMessages SET " + setClause + ", EditAt = :EditAt, EditBy = :EditBy WHERE Type = 'comment' AND CommentId = :CommentId"
+	if _, err := fs.GetMaster().Exec(query, params); err != nil {
+		return nil, model.NewAppError(where, "store.sql_conversation.update_comment.app_error", nil, "comment_id="+commentId+", "+err.Error(), http.StatusInternalServerError)
+	}
+
+	var message model.FacebookConversationMessage
+	if err := fs.GetMaster().SelectOne(&message, "SELECT * FROM FacebookConversationMessages WHERE Type = 'comment' AND CommentId = :CommentId", map[string]interface{}{"CommentId": commentId}); err != nil {
+		appErr := model.NewAppError(where, "store.sql_conversation.update_comment.app_error", nil, "comment_id="+commentId+", "+err.Error(), http.StatusInternalServerError)
+		if err == sql.ErrNoRows {
+			appErr.StatusCode = http.StatusNotFound
+		}
+		return nil, appErr
+	}
+
+	return &message, nil
+}
+
+func (fs sqlFacebookConversationStore) GetOutboxMessage(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var message model.FacebookConversationMessage
+		if err := fs.GetReplica().SelectOne(&message, "SELECT * FROM FacebookConversationMessages WHERE Id = :Id", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetOutboxMessage", "store.sql_message.get_outbox.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &message
+	})
+}
+
+// GetDueOutboxMessages trả về các tin nhắn đang chờ gửi đã đến lượt gửi, cũ nhất trước
+func (fs sqlFacebookConversationStore) GetDueOutboxMessages(now int64, limit int) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var messages []*model.FacebookConversationMessage
+		query := `SELECT * FROM FacebookConversationMessages
+			WHERE Status = :Status AND NextAttemptAt <= :Now AND DeleteAt = 0
+			ORDER BY NextAttemptAt ASC
+			LIMIT :Limit`
+		if _, err := fs.GetReplica().Select(&messages, query, map[string]interface{}{"Status": model.MESSAGE_STATUS_PENDING, "Now": now, "Limit": limit}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.GetDueOutboxMessages", "store.sql_message.get_due_outbox.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = messages
+	})
+}
+
+// ClaimOutboxMessage đẩy NextAttemptAt của tin nhắn tới leaseUntil, chỉ một worker nhận được tin nhắn nếu nhiều worker cùng claim.
+// Nếu worker dừng giữa chừng, tin nhắn sẽ được gửi lại sau khi hết hạn lease.
+func (fs sqlFacebookConversationStore) ClaimOutboxMessage(id string, nextAttemptAt int64, leaseUntil int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := `UPDATE FacebookConversationMessages
+			SET NextAttemptAt = :LeaseUntil
+			WHERE Id = :Id AND Status = :Status AND NextAttemptAt = :NextAttemptAt AND DeleteAt = 0`
+		sqlResult, err := fs.GetMaster().Exec(query, map[string]interface{}{
+			"Id":            id,
+			"Status":        model.MESSAGE_STATUS_PENDING,
+			"NextAttemptAt": nextAttemptAt,
+			"LeaseUntil":    leaseUntil,
+		})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.ClaimOutboxMessage", "store.sql_message.claim_outbox.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows == 1
+	})
+}
+
+// UpdateOutboxMessage cập nhật các trường trạng thái gửi của tin nhắn nếu trạng thái hiện tại vẫn là expectedStatus,
+// tránh ghi đè trạng thái delivered/read đã được webhook cập nhật trước đó
+func (fs sqlFacebookConversationStore) UpdateOutboxMessage(message *model.FacebookConversationMessage, expectedStatus string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := `UPDATE FacebookConversationMessages
+			SET Status = :Status,
+				SendAttempts = :SendAttempts,
+				NextAttemptAt = :NextAttemptAt,
+				SentAt = :SentAt,
+				LastError = :LastError,
+				ErrorCode = :ErrorCode,
+				Sent = :Sent,
+				MessageId = :MessageId,
+				CommentId = :CommentId,
+				DeleteAt = :DeleteAt,
+				DeleteBy = :DeleteBy
+			WHERE Id = :Id AND Status = :ExpectedStatus`
+		sqlResult, err := fs.GetMaster().Exec(query, map[string]interface{}{
+			"Id":             message.Id,
+			"ExpectedStatus": expectedStatus,
+			"Status":         message.Status,
+			"SendAttempts":   message.SendAttempts,
+			"NextAttemptAt":  message.NextAttemptAt,
+			"SentAt":         message.SentAt,
+			"LastError":      message.LastError,
+			"ErrorCode":      message.ErrorCode,
+			"Sent":           message.Sent,
+			"MessageId":      message.MessageId,
+			"CommentId":      message.CommentId,
+			"DeleteAt":       message.DeleteAt,
+			"DeleteBy":       message.DeleteBy,
+		})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateOutboxMessage", "store.sql_message.update_outbox.app_error", nil, "id="+message.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows == 1
+	})
+}
+
+// UpdateMessagesRead đánh dấu đã đọc các tin nhắn papo gửi đi trước thời điểm watermark của hội thoại
+func (fs sqlFacebookConversationStore) UpdateMessagesRead(conversationId string, watermark int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := `UPDATE FacebookConversationMessages
+			SET Status = :Read
+			WHERE ConversationId = :ConversationId AND Status IN (:Sent, :Delivered) AND SentAt <= :Watermark`
+		sqlResult, err := fs.GetMaster().Exec(query, map[string]interface{}{
+			"ConversationId": conversationId,
+			"Read":           model.MESSAGE_STATUS_READ,
+			"Sent":           model.MESSAGE_STATUS_SENT,
+			"Delivered":      model.MESSAGE_STATUS_DELIVERED,
+			"Watermark":      watermark,
+		})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateMessagesRead", "store.sql_message.update_read.app_error", nil, "conversation_id="+conversationId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows
+	})
+}
+
+// UpdateMessageFileIds chỉ ghi danh sách file đã sao lưu của tin nhắn, không động tới trạng thái gửi
+func (fs sqlFacebookConversationStore) UpdateMessageFileIds(id string, fileIds []string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := "UPDATE FacebookConversationMessages SET FileIds = :FileIds WHERE Id = :Id"
+		if _, err := fs.GetMaster().Exec(query, map[string]interface{}{"Id": id, "FileIds": model.ArrayToJson(fileIds)}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookConversationStore.UpdateMessageFileIds", "store.sql_message.update_file_ids.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,130 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"net/http"
+)
+
+type sqlFacebookProcessedEventStore struct {
+	SqlStore
+}
+
+func NewSqlFacebookProcessedEventStore(sqlStore SqlStore) store.FacebookProcessedEventStore {
+	s := &sqlFacebookProcessedEventStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.FacebookProcessedEvent{}, "FacebookProcessedEvents").SetKeys(false, "PageId", "EventKey")
+		table.ColMap("PageId").SetMaxSize(64)
+		table.ColMap("EventKey").SetMaxSize(255)
+	}
+
+	return s
+}
+
+func (s sqlFacebookProcessedEventStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_facebook_processed_events_create_at", "FacebookProcessedEvents", "CreateAt")
+}
+
+// Claim nhận xử lý sự kiện, result.Data là false nếu sự kiện đã được xử lý xong trước đó.
+// Sự kiện đang được xử lý ở nơi khác trả về lỗi StatusConflict để webhook được thử lại sau,
+// trừ khi lần nhận trước đã quá PROCESSED_EVENT_CLAIM_TIMEOUT_MILLIS mà chưa hoàn tất.
+func (s sqlFacebookProcessedEventStore) Claim(pageId, eventKey string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		event := &model.FacebookProcessedEvent{
+			PageId:   pageId,
+			EventKey: eventKey,
+		}
+		event.PreSave()
+
+		err := s.GetMaster().Insert(event)
+		if err == nil {
+			result.Data = true
+			return
+		}
+
+		if !IsUniqueConstraintError(err, []string{"PRIMARY", "facebookprocessedevents_pkey"}) {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Claim", "store.sql_facebook_processed_event.save.app_error", nil, "page_id="+pageId+", event_key="+eventKey+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		sqlResult, err := s.GetMaster().Exec(`UPDATE FacebookProcessedEvents SET CreateAt = :CreateAt
+			WHERE PageId = :PageId AND EventKey = :EventKey AND ProcessedAt = 0 AND CreateAt < :StaleBefore`,
+			map[string]interface{}{"PageId": pageId, "EventKey": eventKey, "CreateAt": event.CreateAt, "StaleBefore": event.CreateAt - model.PROCESSED_EVENT_CLAIM_TIMEOUT_MILLIS})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Claim", "store.sql_facebook_processed_event.save.app_error", nil, "page_id="+pageId+", event_key="+eventKey+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := sqlResult.RowsAffected(); rows == 1 {
+			result.Data = true
+			return
+		}
+
+		processedAt, err := s.GetMaster().SelectInt("SELECT ProcessedAt FROM FacebookProcessedEvents WHERE PageId = :PageId AND EventKey = :EventKey",
+			map[string]interface{}{"PageId": pageId, "EventKey": eventKey})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Claim", "store.sql_facebook_processed_event.save.app_error", nil, "page_id="+pageId+", event_key="+eventKey+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if processedAt == 0 {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Claim", "store.sql_facebook_processed_event.in_progress.app_error", nil, "page_id="+pageId+", event_key="+eventKey, http.StatusConflict)
+			return
+		}
+		result.Data = false
+	})
+}
+
+// Complete đánh dấu sự kiện đã xử lý xong, chỉ gọi sau khi dữ liệu của sự kiện đã được lưu
+func (s sqlFacebookProcessedEventStore) Complete(pageId, eventKey string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE FacebookProcessedEvents SET ProcessedAt = :ProcessedAt WHERE PageId = :PageId AND EventKey = :EventKey",
+			map[string]interface{}{"PageId": pageId, "EventKey": eventKey, "ProcessedAt": model.GetMillis()}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Complete", "store.sql_facebook_processed_event.save.app_error", nil, "page_id="+pageId+", event_key="+eventKey+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Release xóa sự kiện khỏi sổ khi xử lý thất bại để lần gửi lại có thể xử lý tiếp
+func (s sqlFacebookProcessedEventStore) Release(pageId, eventKey string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("DELETE FROM FacebookProcessedEvents WHERE PageId = :PageId AND EventKey = :EventKey",
+			map[string]interface{}{"PageId": pageId, "EventKey": eventKey}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.Release", "store.sql_facebook_processed_event.delete.app_error", nil, "page_id="+pageId+", event_key="+eventKey+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// ReleaseProcessedBetween xóa các sự kiện đã xử lý xong được nhận trong khoảng [start, end] để webhook phát lại
+// được xử lý lại, sự kiện đang xử lý dở vẫn được giữ
+func (s sqlFacebookProcessedEventStore) ReleaseProcessedBetween(start, end int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		sqlResult, err := s.GetMaster().Exec("DELETE FROM FacebookProcessedEvents WHERE ProcessedAt > 0 AND CreateAt >= :Start AND CreateAt <= :End",
+			map[string]interface{}{"Start": start, "End": end})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.ReleaseProcessedBetween", "store.sql_facebook_processed_event.delete.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows
+	})
+}
+
+func (s sqlFacebookProcessedEventStore) PermanentDeleteBefore(createAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		sqlResult, err := s.GetMaster().Exec("DELETE FROM FacebookProcessedEvents WHERE CreateAt < :CreateAt",
+			map[string]interface{}{"CreateAt": createAt})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookProcessedEventStore.PermanentDeleteBefore", "store.sql_facebook_processed_event.delete.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows
+	})
+}
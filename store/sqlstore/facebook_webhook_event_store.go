@@ -0,0 +1,188 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"net/http"
+)
+
+type sqlFacebookWebhookEventStore struct {
+	SqlStore
+}
+
+func NewSqlFacebookWebhookEventStore(sqlStore SqlStore) store.FacebookWebhookEventStore {
+	s := &sqlFacebookWebhookEventStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.FacebookWebhookEvent{}, "FacebookWebhookEvents").SetKeys(false, "Id")
+		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("Object").SetMaxSize(32)
+		table.ColMap("Status").SetMaxSize(16)
+		table.ColMap("LastError").SetMaxSize(model.WEBHOOK_EVENT_LAST_ERROR_MAX_LENGTH)
+	}
+
+	return s
+}
+
+func (s sqlFacebookWebhookEventStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_facebook_webhook_events_status", "FacebookWebhookEvents", "Status")
+	s.CreateIndexIfNotExists("idx_facebook_webhook_events_next_attempt_at", "FacebookWebhookEvents", "NextAttemptAt")
+	s.CreateIndexIfNotExists("idx_facebook_webhook_events_create_at", "FacebookWebhookEvents", "CreateAt")
+}
+
+func (s sqlFacebookWebhookEventStore) Save(event *model.FacebookWebhookEvent) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		event.PreSave()
+		if result.Err = event.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(event); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.Save", "store.sql_facebook_webhook_event.save.app_error", nil, "id="+event.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = event
+	})
+}
+
+func (s sqlFacebookWebhookEventStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var event model.FacebookWebhookEvent
+		if err := s.GetReplica().SelectOne(&event, "SELECT * FROM FacebookWebhookEvents WHERE Id = :Id", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.Get", "store.sql_facebook_webhook_event.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusNotFound)
+			return
+		}
+		result.Data = &event
+	})
+}
+
+func (s sqlFacebookWebhookEventStore) Update(event *model.FacebookWebhookEvent) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		event.PreUpdate()
+		if result.Err = event.IsValid(); result.Err != nil {
+			return
+		}
+
+		if _, err := s.GetMaster().Update(event); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.Update", "store.sql_facebook_webhook_event.update.app_error", nil, "id="+event.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = event
+	})
+}
+
+// Claim đánh dấu event đang được xử lý, chỉ một worker (trên một server bất kỳ) nhận được event.
+// result.Data là true nếu claim thành công.
+func (s sqlFacebookWebhookEventStore) Claim(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		sqlResult, err := s.GetMaster().Exec(
+			`UPDATE FacebookWebhookEvents SET Status = :Processing, UpdateAt = :UpdateAt
+			WHERE Id = :Id AND Status IN (:Pending, :Retrying)`,
+			map[string]interface{}{
+				"Processing": model.WEBHOOK_EVENT_STATUS_PROCESSING,
+				"Pending":    model.WEBHOOK_EVENT_STATUS_PENDING,
+				"Retrying":   model.WEBHOOK_EVENT_STATUS_RETRYING,
+				"UpdateAt":   model.GetMillis(),
+				"Id":         id,
+			})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.Claim", "store.sql_facebook_webhook_event.update.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows == 1
+	})
+}
+
+// GetDue trả về các event đến hạn xử lý, bao gồm cả event bị treo ở trạng thái processing quá lâu (server chết giữa chừng)
+func (s sqlFacebookWebhookEventStore) GetDue(now int64, staleBefore int64, limit int) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec(
+			`UPDATE FacebookWebhookEvents SET Status = :Retrying
+			WHERE Status = :Processing AND UpdateAt < :StaleBefore`,
+			map[string]interface{}{
+				"Retrying":    model.WEBHOOK_EVENT_STATUS_RETRYING,
+				"Processing":  model.WEBHOOK_EVENT_STATUS_PROCESSING,
+				"StaleBefore": staleBefore,
+			}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.GetDue", "store.sql_facebook_webhook_event.update.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var events []*model.FacebookWebhookEvent
+		if _, err := s.GetReplica().Select(&events,
+			`SELECT * FROM FacebookWebhookEvents
+			WHERE Status IN (:Pending, :Retrying) AND NextAttemptAt <= :Now
+			ORDER BY CreateAt ASC
+			LIMIT :Limit`,
+			map[string]interface{}{
+				"Pending":  model.WEBHOOK_EVENT_STATUS_PENDING,
+				"Retrying": model.WEBHOOK_EVENT_STATUS_RETRYING,
+				"Now":      now,
+				"Limit":    limit,
+			}); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.GetDue", "store.sql_facebook_webhook_event.get.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = events
+	})
+}
+
+func (s sqlFacebookWebhookEventStore) GetSince(since int64, status string, offset, limit int) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		query := s.getQueryBuilder().
+			Select("*").
+			From("FacebookWebhookEvents").
+			Where("CreateAt >= ?", since).
+			OrderBy("CreateAt DESC").
+			Limit(uint64(limit)).
+			Offset(uint64(offset))
+
+		if len(status) > 0 {
+			query = query.Where("Status = ?", status)
+		}
+
+		queryString, args, err := query.ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.GetSince", "store.sql_facebook_webhook_event.get.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		var events []*model.FacebookWebhookEvent
+		if _, err := s.GetReplica().Select(&events, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.GetSince", "store.sql_facebook_webhook_event.get.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = events
+	})
+}
+
+// ResetForReplay đưa toàn bộ event nhận từ thời điểm since về trạng thái chờ xử lý, trả về số event được reset
+func (s sqlFacebookWebhookEventStore) ResetForReplay(since int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		now := model.GetMillis()
+		sqlResult, err := s.GetMaster().Exec(
+			`UPDATE FacebookWebhookEvents
+			SET Status = :Pending, Attempts = 0, LastError = '', NextAttemptAt = :Now, UpdateAt = :Now
+			WHERE CreateAt >= :Since AND Status <> :Processing`,
+			map[string]interface{}{
+				"Pending":    model.WEBHOOK_EVENT_STATUS_PENDING,
+				"Processing": model.WEBHOOK_EVENT_STATUS_PROCESSING,
+				"Now":        now,
+				"Since":      since,
+			})
+		if err != nil {
+			result.Err = model.NewAppError("sqlFacebookWebhookEventStore.ResetForReplay", "store.sql_facebook_webhook_event.update.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		rows, _ := sqlResult.RowsAffected()
+		result.Data = rows
+	})
+}
@@ -91,7 +91,7 @@ func NewSqlFanpageStore(sqlStore SqlStore, metrics einterfaces.MetricsInterface)
 		tablem.ColMap("FanpageId").SetMaxSize(26)
 		tablem.ColMap("UserId").SetMaxSize(26)
 		tablem.ColMap("Roles").SetMaxSize(64)
-		tablem.ColMap("AccessToken").SetMaxSize(500)
+		tablem.ColMap("AccessToken").SetMaxSize(1024) // token đã mã hoá dài hơn token gốc khoảng 1/3
 		table.ColMap("Filenames").SetMaxSize(model.FANPAGE_FILENAMES_MAX_RUNES)
 		table.ColMap("FileIds").SetMaxSize(150)
 	}
@@ -306,6 +306,18 @@ func (fs sqlFanpageStore) GetFanpageByPageID(pageId string) store.StoreChannel {
 	})
 }
 
+// GetFanpagesByStatus trả về các page đang ở trạng thái status, dùng cho các job chạy định kỳ trên toàn bộ page
+func (fs sqlFanpageStore) GetFanpagesByStatus(status string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var pages []*model.Fanpage
+		if _, err := fs.GetReplica().Select(&pages, "SELECT fanpages.* FROM fanpages WHERE status = :Status", map[string]interface{}{"Status": status}); err != nil {
+			result.Err = model.NewAppError("SqlFanpageStore.GetFanpagesByStatus", "store.sql_fanpage.get_by_status.app_error", nil, "status="+status+" "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = pages
+	})
+}
+
 func (fs sqlFanpageStore) SaveFanPageMember(member *model.FanpageMember) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		if result.Err = member.IsValid(); result.Err != nil {
@@ -314,6 +326,7 @@ func (fs sqlFanpageStore) SaveFanPageMember(member *model.FanpageMember) store.S
 
 		// Kiểm tra nếu FanpageMember chưa có => thêm vào db
 		// nếu đã tồn tại => cập nhật token
+		member.LastUpdateAt = model.GetMillis()
 		var dbMember *model.FanpageMember
 		err := fs.GetReplica().SelectOne(&dbMember, "SELECT * FROM fanpagemembers WHERE fanpageid = :FanpageId AND userid = :UserId", map[string]interface{}{"FanpageId": member.FanpageId, "UserId": member.UserId})
 
@@ -332,10 +345,10 @@ func (fs sqlFanpageStore) SaveFanPageMember(member *model.FanpageMember) store.S
 		}
 
 		// FanpageMember đã tồn tại => cập nhật page token
-		query := "UPDATE fanpagemembers SET accesstoken = :AccessToken WHERE userid = :UserId AND pageid = :PageId"
-		_, updateError := fs.GetMaster().Exec(query, map[string]interface{}{"AccessToken": member.AccessToken, "UserId": member.UserId, "PageId": member.PageId})
+		query := "UPDATE fanpagemembers SET accesstoken = :AccessToken, LastUpdateAt = :LastUpdateAt WHERE userid = :UserId AND pageid = :PageId"
+		_, updateError := fs.GetMaster().Exec(query, map[string]interface{}{"AccessToken": member.AccessToken, "LastUpdateAt": member.LastUpdateAt, "UserId": member.UserId, "PageId": member.PageId})
 		if updateError != nil {
-			result.Err = model.NewAppError("sqlFanpageStore.SaveFanPageMember", "store.sqlFanpageStore.update_fanpage_member_token.app_error", nil, "page_id="+member.PageId+ "&user_id="+ member.UserId +", "+err.Error(), http.StatusInternalServerError)
+			result.Err = model.NewAppError("sqlFanpageStore.SaveFanPageMember", "store.sqlFanpageStore.update_fanpage_member_token.app_error", nil, "page_id="+member.PageId+ "&user_id="+ member.UserId +", "+updateError.Error(), http.StatusInternalServerError)
 			return
 		} else {
 			fmt.Println("Đã cập nhật page token của user: ", member.UserId, " & page: ", member.PageId)
@@ -378,18 +391,57 @@ func (fs sqlFanpageStore) GetMemberByPageId(pageId string, userId string) store.
 	})
 }
 
-func (fs sqlFanpageStore) GetOneFanPageMember(pageiId string) store.StoreChannel {
+func (fs sqlFanpageStore) GetMembers(pageId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var members []*model.FanpageMember
+		if _, err := fs.GetReplica().Select(&members, "SELECT * FROM FanpageMembers WHERE PageId = :PageId ORDER BY UserId", map[string]interface{}{"PageId": pageId}); err != nil {
+			result.Err = model.NewAppError("SqlFanpageStore.GetMembers", "store.sql_fanpage.get_members.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = members
+	})
+}
+
+func (fs sqlFanpageStore) UpdateMemberRoles(pageId string, userId string, roles string) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-		var fanpageMember *model.FanpageMember
-		err := fs.GetReplica().SelectOne(&fanpageMember, "SELECT a.* FROM fanpagemembers a INNER JOIN fanpages b on a.fanpageid = b.id  WHERE b.pageid = :pageId LIMIT 1", map[string]interface{}{"pageId": pageiId})
+		query := "UPDATE FanpageMembers SET Roles = :Roles, LastUpdateAt = :LastUpdateAt WHERE PageId = :PageId AND UserId = :UserId"
+		sqlResult, err := fs.GetMaster().Exec(query, map[string]interface{}{"Roles": roles, "LastUpdateAt": model.GetMillis(), "PageId": pageId, "UserId": userId})
 		if err != nil {
+			result.Err = model.NewAppError("SqlFanpageStore.UpdateMemberRoles", "store.sql_fanpage.update_member_roles.app_error", nil, "page_id="+pageId+", user_id="+userId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if rows, _ := sqlResult.RowsAffected(); rows == 0 {
+			result.Err = model.NewAppError("SqlFanpageStore.UpdateMemberRoles", "store.sql_fanpage.get_member.missing.app_error", nil, "page_id="+pageId+", user_id="+userId, http.StatusNotFound)
+			return
+		}
+	})
+}
+
+func (fs sqlFanpageStore) RemoveMember(pageId string, userId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := fs.GetMaster().Exec("DELETE FROM FanpageMembers WHERE PageId = :PageId AND UserId = :UserId", map[string]interface{}{"PageId": pageId, "UserId": userId}); err != nil {
+			result.Err = model.NewAppError("SqlFanpageStore.RemoveMember", "store.sql_fanpage.remove_member.app_error", nil, "page_id="+pageId+", user_id="+userId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// GetOwnerMember trả về member quản trị page đang giữ page token, ưu tiên người xác thực gần nhất.
+// Member có Roles rỗng được tạo trước khi có role trên page và được xem là admin giống FanpageMember.GetRoles
+func (fs sqlFanpageStore) GetOwnerMember(pageId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var member *model.FanpageMember
+		query := `SELECT * FROM FanpageMembers
+			WHERE PageId = :PageId AND AccessToken != '' AND (Roles LIKE :Roles OR Roles = '')
+			ORDER BY LastUpdateAt DESC, UserId LIMIT 1`
+		if err := fs.GetReplica().SelectOne(&member, query, map[string]interface{}{"PageId": pageId, "Roles": "%" + model.FANPAGE_ADMIN_ROLE_ID + "%"}); err != nil {
 			if err == sql.ErrNoRows {
-				result.Err = model.NewAppError("SqlFanpageStore.GetMember", "store.sql_fanpage.get_member.missing.app_error", nil, "fanpageId="+pageiId+" "+err.Error(), http.StatusNotFound)
+				result.Err = model.NewAppError("SqlFanpageStore.GetOwnerMember", "store.sql_fanpage.get_member.missing.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusNotFound)
 				return
 			}
-			result.Err = model.NewAppError("SqlFanpageStore.GetMember", "store.sql_fanpage.get_member.app_error", nil, "fanpageId="+pageiId+" "+err.Error(), http.StatusInternalServerError)
+			result.Err = model.NewAppError("SqlFanpageStore.GetOwnerMember", "store.sql_fanpage.get_member.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
 		}
-		result.Data = fanpageMember
+		result.Data = member
 	})
 }
 
@@ -438,6 +490,9 @@ type allPageMembers []allPageMember
 
 func (db allPageMember) Process() (string, string) {
 	roles := strings.Fields(db.Roles)
+	if len(roles) == 0 {
+		roles = strings.Fields(model.FANPAGE_MEMBER_ADMIN_ROLES)
+	}
 
 	// Add any scheme derived roles that are not in the Roles field due to being Implicit from the Scheme, and add
 	// them to the Roles field for backwards compatibility reasons.
@@ -486,6 +541,14 @@ func (db allPageMembers) ToMapStringString() map[string]string {
 	return result
 }
 
+func (s sqlFanpageStore) InvalidateAllPageMembersForUser(userId string) {
+	allPageMembersForUserCache.Remove(userId)
+	allPageMembersForUserCache.Remove(userId + "_deleted")
+	if s.metrics != nil {
+		s.metrics.IncrementMemCacheInvalidationCounter("All Page Members for User - Remove by UserId")
+	}
+}
+
 func (s sqlFanpageStore) GetAllPageMembersForUser(userId string, allowFromCache bool, includeDeleted bool) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		cache_key := userId
@@ -508,7 +571,7 @@ func (s sqlFanpageStore) GetAllPageMembersForUser(userId string, allowFromCache
 
 		var deletedClause string
 		if !includeDeleted {
-			deletedClause = "Fanpages.DeleteAt = 0"
+			deletedClause = "AND Fanpages.DeleteAt = 0"
 		}
 
 		var data allPageMembers
@@ -520,6 +583,7 @@ func (s sqlFanpageStore) GetAllPageMembersForUser(userId string, allowFromCache
 			INNER JOIN
 				Fanpages ON FanpageMembers.PageId = Fanpages.PageId
 			WHERE
+				FanpageMembers.UserId = :UserId
 				`+deletedClause+`
 			`, map[string]interface{}{"UserId": userId})
 
@@ -529,9 +593,7 @@ func (s sqlFanpageStore) GetAllPageMembersForUser(userId string, allowFromCache
 			return
 		}
 
-		fmt.Println("data", data)
 		ids := data.ToMapStringString()
-		fmt.Println("ids", ids)
 		result.Data = ids
 
 		if allowFromCache {
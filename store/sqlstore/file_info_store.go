@@ -43,6 +43,9 @@ func newSqlFileInfoStore(sqlStore SqlStore, metrics einterfaces.MetricsInterface
 		table.ColMap("Id").SetMaxSize(26)
 		table.ColMap("CreatorId").SetMaxSize(26)
 		table.ColMap("PostId").SetMaxSize(26)
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("MessageId").SetMaxSize(26)
+		table.ColMap("SourceUrl").SetMaxSize(2048)
 		table.ColMap("Path").SetMaxSize(512)
 		table.ColMap("ThumbnailPath").SetMaxSize(512)
 		table.ColMap("PreviewPath").SetMaxSize(512)
@@ -59,6 +62,7 @@ func (fs SqlFileInfoStore) createIndexesIfNotExists() {
 	fs.CreateIndexIfNotExists("idx_fileinfo_create_at", "FileInfo", "CreateAt")
 	fs.CreateIndexIfNotExists("idx_fileinfo_delete_at", "FileInfo", "DeleteAt")
 	fs.CreateIndexIfNotExists("idx_fileinfo_postid_at", "FileInfo", "PostId")
+	fs.CreateIndexIfNotExists("idx_fileinfo_message_id", "FileInfo", "MessageId")
 }
 
 func (fs SqlFileInfoStore) InvalidateFileInfosForPageCache(pageId string) {
@@ -317,6 +321,27 @@ func (fs SqlFileInfoStore) GetForPost(postId string, readFromMaster, includeDele
 	return infos, nil
 }
 
+// GetForMessage trả về các file đính kèm của tin nhắn hoặc bình luận, theo thứ tự của attachment
+func (fs SqlFileInfoStore) GetForMessage(messageId string) ([]*model.FileInfo, error) {
+	var infos []*model.FileInfo
+
+	query := fs.getQueryBuilder().
+		Select("*").
+		From("FileInfo").
+		Where(sq.Eq{"MessageId": messageId, "DeleteAt": 0}).
+		OrderBy("CreateAt")
+
+	queryString, args, err := query.ToSql()
+	if err != nil {
+		return nil, errors.Wrap(err, "file_info_tosql")
+	}
+
+	if _, err := fs.GetReplica().Select(&infos, queryString, args...); err != nil {
+		return nil, errors.Wrapf(err, "failed to find FileInfos with messageId=%s", messageId)
+	}
+	return infos, nil
+}
+
 func (fs SqlFileInfoStore) GetForUser(userId string) ([]*model.FileInfo, error) {
 	var infos []*model.FileInfo
 
@@ -0,0 +1,115 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
+	"net/http"
+)
+
+type sqlModerationRuleStore struct {
+	SqlStore
+}
+
+func NewSqlModerationRuleStore(sqlStore SqlStore) store.ModerationRuleStore {
+	s := &sqlModerationRuleStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.ModerationRule{}, "ModerationRules").SetKeys(false, "Id")
+		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("Name").SetMaxSize(model.MODERATION_RULE_NAME_MAX_LENGTH * 4)
+		table.ColMap("MatchType").SetMaxSize(32)
+		table.ColMap("Keywords").SetMaxSize(8000)
+		table.ColMap("Pattern").SetMaxSize(model.MODERATION_RULE_PATTERN_MAX_LENGTH)
+		table.ColMap("FacebookUids").SetMaxSize(8000)
+		table.ColMap("Action").SetMaxSize(32)
+		table.ColMap("TagId").SetMaxSize(26)
+		table.ColMap("ReplyMessage").SetMaxSize(2000)
+		table.ColMap("CreatorId").SetMaxSize(26)
+	}
+
+	return s
+}
+
+func (s sqlModerationRuleStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_moderation_rules_page_id", "ModerationRules", "PageId")
+	s.CreateIndexIfNotExists("idx_moderation_rules_delete_at", "ModerationRules", "DeleteAt")
+}
+
+func (s sqlModerationRuleStore) Save(rule *model.ModerationRule) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		rule.PreSave()
+		if result.Err = rule.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(rule); err != nil {
+			result.Err = model.NewAppError("sqlModerationRuleStore.Save", "store.sql_moderation_rule.save.app_error", nil, "id="+rule.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = rule
+	})
+}
+
+func (s sqlModerationRuleStore) Update(rule *model.ModerationRule) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		rule.PreUpdate()
+		if result.Err = rule.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := s.GetMaster().Update(rule)
+		if err != nil {
+			result.Err = model.NewAppError("sqlModerationRuleStore.Update", "store.sql_moderation_rule.update.app_error", nil, "id="+rule.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != 1 {
+			result.Err = model.NewAppError("sqlModerationRuleStore.Update", "store.sql_moderation_rule.get.app_error", nil, "id="+rule.Id, http.StatusNotFound)
+			return
+		}
+
+		result.Data = rule
+	})
+}
+
+func (s sqlModerationRuleStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var rule model.ModerationRule
+		if err := s.GetReplica().SelectOne(&rule, "SELECT * FROM ModerationRules WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlModerationRuleStore.Get", "store.sql_moderation_rule.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &rule
+	})
+}
+
+func (s sqlModerationRuleStore) GetByPageId(pageId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var rules []*model.ModerationRule
+		if _, err := s.GetReplica().Select(&rules, "SELECT * FROM ModerationRules WHERE PageId = :PageId AND DeleteAt = 0 ORDER BY CreateAt ASC", map[string]interface{}{"PageId": pageId}); err != nil {
+			result.Err = model.NewAppError("sqlModerationRuleStore.GetByPageId", "store.sql_moderation_rule.get_by_page.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = rules
+	})
+}
+
+func (s sqlModerationRuleStore) Delete(id string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE ModerationRules SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id", map[string]interface{}{"Id": id, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlModerationRuleStore.Delete", "store.sql_moderation_rule.delete.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
@@ -0,0 +1,117 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
+	"net/http"
+)
+
+type sqlOrderStatusStore struct {
+	SqlStore
+}
+
+func NewSqlOrderStatusStore(sqlStore SqlStore) store.OrderStatusStore {
+	s := &sqlOrderStatusStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.OrderStatus{}, "OrderStatuses").SetKeys(false, "Id")
+		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("Name").SetMaxSize(model.ORDER_STATUS_NAME_MAX_LENGTH * 4)
+		table.ColMap("Color").SetMaxSize(7)
+		table.ColMap("CreatorId").SetMaxSize(26)
+	}
+
+	return s
+}
+
+func (s sqlOrderStatusStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_order_statuses_page_id", "OrderStatuses", "PageId")
+}
+
+func (s sqlOrderStatusStore) Save(status *model.OrderStatus) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		status.PreSave()
+		if result.Err = status.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(status); err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.Save", "store.sql_order_status.save.app_error", nil, "id="+status.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = status
+	})
+}
+
+func (s sqlOrderStatusStore) Update(status *model.OrderStatus) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		status.PreUpdate()
+		if result.Err = status.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := s.GetMaster().Update(status)
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.Update", "store.sql_order_status.update.app_error", nil, "id="+status.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != 1 {
+			result.Err = model.NewAppError("sqlOrderStatusStore.Update", "store.sql_order_status.get.app_error", nil, "id="+status.Id, http.StatusNotFound)
+			return
+		}
+
+		result.Data = status
+	})
+}
+
+func (s sqlOrderStatusStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var status model.OrderStatus
+		if err := s.GetReplica().SelectOne(&status, "SELECT * FROM OrderStatuses WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.Get", "store.sql_order_status.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &status
+	})
+}
+
+func (s sqlOrderStatusStore) GetByPageId(pageId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var statuses []*model.OrderStatus
+		if _, err := s.GetReplica().Select(&statuses, "SELECT * FROM OrderStatuses WHERE PageId = :PageId AND DeleteAt = 0 ORDER BY Position ASC, CreateAt ASC", map[string]interface{}{"PageId": pageId}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.GetByPageId", "store.sql_order_status.get_by_page.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = statuses
+	})
+}
+
+// ClearDefault bỏ IsDefault của mọi trạng thái khác exceptId trong page, mỗi page chỉ có một trạng thái mặc định
+func (s sqlOrderStatusStore) ClearDefault(pageId string, exceptId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE OrderStatuses SET IsDefault = :IsDefault, UpdateAt = :UpdateAt WHERE PageId = :PageId AND Id != :Id AND IsDefault = :Default", map[string]interface{}{"IsDefault": false, "Default": true, "UpdateAt": model.GetMillis(), "PageId": pageId, "Id": exceptId}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.ClearDefault", "store.sql_order_status.update.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+func (s sqlOrderStatusStore) Delete(id string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE OrderStatuses SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id", map[string]interface{}{"Id": id, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStatusStore.Delete", "store.sql_order_status.delete.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
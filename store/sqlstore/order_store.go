@@ -1,10 +1,16 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
 package sqlstore
 
 import (
 	"bitbucket.org/enesyteam/papo-server/model"
 	"bitbucket.org/enesyteam/papo-server/store"
-	"fmt"
+	"database/sql"
 	"net/http"
+	"strings"
+
+	sq "github.com/Masterminds/squirrel"
 )
 
 type sqlOrderStore struct {
@@ -12,7 +18,6 @@ type sqlOrderStore struct {
 }
 
 func NewSqlOrderStore(sqlStore SqlStore) store.OrderStore {
-
 	fs := &sqlOrderStore{
 		SqlStore: sqlStore,
 	}
@@ -20,36 +25,239 @@ func NewSqlOrderStore(sqlStore SqlStore) store.OrderStore {
 	for _, db := range sqlStore.GetAllConns() {
 		table := db.AddTableWithName(model.Order{}, "Orders").SetKeys(false, "Id")
 		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("ConversationId").SetMaxSize(26)
+		table.ColMap("FacebookUid").SetMaxSize(64)
+		table.ColMap("CustomerName").SetMaxSize(model.ORDER_CUSTOMER_NAME_MAX_LENGTH * 4)
+		table.ColMap("CustomerPhone").SetMaxSize(model.ORDER_CUSTOMER_PHONE_MAX_LENGTH)
+		table.ColMap("CustomerAddress").SetMaxSize(model.ORDER_CUSTOMER_ADDRESS_MAX_LENGTH * 4)
+		table.ColMap("Items").SetMaxSize(65535)
+		table.ColMap("StatusId").SetMaxSize(26)
+		table.ColMap("AssignedTo").SetMaxSize(26)
+		table.ColMap("CreatorId").SetMaxSize(26)
+		table.ColMap("Notes").SetMaxSize(model.ORDER_NOTES_MAX_LENGTH * 4)
 	}
 
 	return fs
 }
 
 func (fs sqlOrderStore) CreateIndexesIfNotExists() {
-	//fs.CreateIndexIfNotExists("idx_fanpages_page_id", "Fanpages", "PageId")
+	fs.CreateIndexIfNotExists("idx_orders_page_id", "Orders", "PageId")
+	fs.CreateIndexIfNotExists("idx_orders_conversation_id", "Orders", "ConversationId")
+	fs.CreateIndexIfNotExists("idx_orders_facebook_uid", "Orders", "FacebookUid")
+	fs.CreateIndexIfNotExists("idx_orders_status_id", "Orders", "StatusId")
+	fs.CreateIndexIfNotExists("idx_orders_assigned_to", "Orders", "AssignedTo")
+	fs.CreateIndexIfNotExists("idx_orders_create_at", "Orders", "CreateAt")
+	fs.CreateIndexIfNotExists("idx_orders_delete_at", "Orders", "DeleteAt")
 }
 
 func (fs sqlOrderStore) Save(order *model.Order) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
 		order.PreSave()
+		if result.Err = order.IsValid(); result.Err != nil {
+			return
+		}
 
 		if err := fs.GetMaster().Insert(order); err != nil {
-			fmt.Print(err)
-			result.Err = model.NewAppError("sqlOrderStore.Save", "store.sql_order.save.app_error", nil, "", http.StatusInternalServerError)
-		} else {
-			result.Data = order
+			result.Err = model.NewAppError("sqlOrderStore.Save", "store.sql_order.save.app_error", nil, "id="+order.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = order
+	})
+}
+
+func (fs sqlOrderStore) Update(order *model.Order) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		order.PreUpdate()
+		if result.Err = order.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := fs.GetMaster().Update(order)
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Update", "store.sql_order.update.app_error", nil, "id="+order.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != 1 {
+			result.Err = model.NewAppError("sqlOrderStore.Update", "store.sql_order.get.app_error", nil, "id="+order.Id, http.StatusNotFound)
+			return
+		}
+
+		result.Data = order
+	})
+}
+
+func (fs sqlOrderStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var order model.Order
+		if err := fs.GetReplica().SelectOne(&order, "SELECT * FROM Orders WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Get", "store.sql_order.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &order
+	})
+}
+
+func (fs sqlOrderStore) Delete(id string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := fs.GetMaster().Exec("UPDATE Orders SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id", map[string]interface{}{"Id": id, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Delete", "store.sql_order.delete.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
+
+// Search trả về một trang đơn hàng mới nhất khớp điều kiện lọc kèm tổng số đơn khớp
+func (fs sqlOrderStore) Search(options *model.OrderSearchOptions) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		options.SetDefaults()
+		if err := options.IsValid(); err != nil {
+			result.Err = err
+			return
+		}
+
+		query := fs.searchQuery(fs.getQueryBuilder().Select("*").From("Orders"), options)
+		countSql, countArgs, err := fs.searchQuery(fs.getQueryBuilder().Select("COUNT(*)").From("Orders"), options).ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Search", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		totalCount, err := fs.GetReplica().SelectInt(countSql, countArgs...)
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Search", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		queryString, args, err := query.
+			OrderBy("CreateAt DESC", "Id DESC").
+			Limit(uint64(options.Limit)).
+			Offset(uint64(options.Offset)).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Search", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		orders := []*model.Order{}
+		if _, err := fs.GetReplica().Select(&orders, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.Search", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = &model.OrderSearchResult{Orders: orders, TotalCount: totalCount}
+	})
+}
+
+func (fs sqlOrderStore) searchQuery(query sq.SelectBuilder, options *model.OrderSearchOptions) sq.SelectBuilder {
+	query = query.Where(sq.Eq{"DeleteAt": 0, "PageId": options.PageIds})
+
+	if len(options.StatusIds) > 0 {
+		query = query.Where(sq.Eq{"StatusId": options.StatusIds})
+	}
+
+	if len(options.AssignedTo) > 0 {
+		query = query.Where(sq.Eq{"AssignedTo": options.AssignedTo})
+	}
+
+	if len(options.CreatorId) > 0 {
+		query = query.Where(sq.Eq{"CreatorId": options.CreatorId})
+	}
+
+	if len(options.ConversationId) > 0 {
+		query = query.Where(sq.Eq{"ConversationId": options.ConversationId})
+	}
+
+	if len(options.FacebookUid) > 0 {
+		query = query.Where(sq.Eq{"FacebookUid": options.FacebookUid})
+	}
+
+	if options.Since > 0 {
+		query = query.Where(sq.GtOrEq{"CreateAt": options.Since})
+	}
+
+	if options.Until > 0 {
+		query = query.Where(sq.LtOrEq{"CreateAt": options.Until})
+	}
+
+	if len(options.Term) > 0 {
+		term := "%" + strings.ToLower(sanitizeSearchTerm(options.Term, "\\")) + "%"
+		query = query.Where("(LOWER(CustomerName) LIKE ? OR CustomerPhone LIKE ?)", term, term)
+	}
+
+	return query
+}
+
+// CountByStatusId đếm số đơn hàng chưa xoá đang ở trạng thái statusId
+func (fs sqlOrderStore) CountByStatusId(statusId string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		count, err := fs.GetReplica().SelectInt("SELECT COUNT(*) FROM Orders WHERE StatusId = :StatusId AND DeleteAt = 0", map[string]interface{}{"StatusId": statusId})
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.CountByStatusId", "store.sql_order.count_by_status.app_error", nil, "status_id="+statusId+", "+err.Error(), http.StatusInternalServerError)
+			return
 		}
+
+		result.Data = count
 	})
 }
 
-func (fs sqlOrderStore) GetOrders(limit, offset int) store.StoreChannel {
+// GetByFacebookUids trả về các đơn hàng mới nhất của khách hàng trên mọi page
+func (fs sqlOrderStore) GetByFacebookUids(facebookUids []string, limit int) store.StoreChannel {
 	return store.Do(func(result *store.StoreResult) {
-		var orders []*model.Order
-		query := `SELECT Orders.* FROM Orders LIMIT :Limit OFFSET :Offset`
-		if _, err := fs.GetReplica().Select(&orders, query, map[string]interface{}{"Limit": limit, "Offset": offset}); err != nil {
-			result.Err = model.NewAppError("sqlOrderStore.GetOrders", "store.sqlOrderStore.GetOrders.app_error", nil, err.Error(), http.StatusInternalServerError)
+		orders := []*model.Order{}
+		if len(facebookUids) == 0 {
+			result.Data = orders
+			return
+		}
+
+		queryString, args, err := fs.getQueryBuilder().
+			Select("*").
+			From("Orders").
+			Where(sq.Eq{"FacebookUid": facebookUids, "DeleteAt": 0}).
+			OrderBy("CreateAt DESC").
+			Limit(uint64(limit)).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.GetByFacebookUids", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := fs.GetReplica().Select(&orders, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.GetByFacebookUids", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
 			return
 		}
+
 		result.Data = orders
 	})
-}
\ No newline at end of file
+}
+
+// GetFacebookUidsWithOrders trả về các khách hàng trong facebookUids đã có đơn hàng trên page
+func (fs sqlOrderStore) GetFacebookUidsWithOrders(pageId string, facebookUids []string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		uids := []string{}
+		if len(facebookUids) == 0 {
+			result.Data = uids
+			return
+		}
+
+		queryString, args, err := fs.getQueryBuilder().
+			Select("DISTINCT FacebookUid").
+			From("Orders").
+			Where(sq.Eq{"PageId": pageId, "FacebookUid": facebookUids, "DeleteAt": 0}).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.GetFacebookUidsWithOrders", "store.sql_order.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		if _, err := fs.GetReplica().Select(&uids, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlOrderStore.GetFacebookUidsWithOrders", "store.sql_order.search.app_error", nil, "page_id="+pageId+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		result.Data = uids
+	})
+}
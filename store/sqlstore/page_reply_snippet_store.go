@@ -87,6 +87,12 @@ func (s sqlPageReplySnippetStore) Update(snippet *model.ReplySnippet) store.Stor
 		}
 
 		oldSnippet := oldResult.(*model.ReplySnippet)
+		// không cho phép sửa hoặc chuyển snippet của page khác
+		if oldSnippet.PageId != snippet.PageId {
+			result.Err = model.NewAppError("sqlPageReplySnippetStore.Update", "store.sql_team.update.find.app_error", nil, "id="+snippet.Id+", page_id="+snippet.PageId, http.StatusNotFound)
+			return
+		}
+
 		snippet.CreateAt = oldSnippet.CreateAt
 		snippet.UpdateAt = model.GetMillis()
 
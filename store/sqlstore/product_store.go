@@ -0,0 +1,157 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package sqlstore
+
+import (
+	"bitbucket.org/enesyteam/papo-server/model"
+	"bitbucket.org/enesyteam/papo-server/store"
+	"database/sql"
+	"net/http"
+	"strings"
+)
+
+type sqlProductStore struct {
+	SqlStore
+}
+
+func NewSqlProductStore(sqlStore SqlStore) store.ProductStore {
+	s := &sqlProductStore{
+		SqlStore: sqlStore,
+	}
+
+	for _, db := range sqlStore.GetAllConns() {
+		table := db.AddTableWithName(model.Product{}, "Products").SetKeys(false, "Id")
+		table.ColMap("Id").SetMaxSize(26)
+		table.ColMap("PageId").SetMaxSize(26)
+		table.ColMap("Sku").SetMaxSize(model.PRODUCT_SKU_MAX_LENGTH)
+		table.ColMap("Name").SetMaxSize(model.PRODUCT_NAME_MAX_LENGTH * 4)
+		table.ColMap("Description").SetMaxSize(model.PRODUCT_DESCRIPTION_MAX_LENGTH * 4)
+		table.ColMap("Variants").SetMaxSize(65535)
+		table.ColMap("FileIds").SetMaxSize(300)
+		table.ColMap("Url").SetMaxSize(model.PRODUCT_URL_MAX_LENGTH)
+		table.ColMap("CreatorId").SetMaxSize(26)
+	}
+
+	return s
+}
+
+func (s sqlProductStore) CreateIndexesIfNotExists() {
+	s.CreateIndexIfNotExists("idx_products_page_id", "Products", "PageId")
+	s.CreateIndexIfNotExists("idx_products_sku", "Products", "Sku")
+	s.CreateIndexIfNotExists("idx_products_delete_at", "Products", "DeleteAt")
+}
+
+func (s sqlProductStore) Save(product *model.Product) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		product.PreSave()
+		if result.Err = product.IsValid(); result.Err != nil {
+			return
+		}
+
+		if err := s.GetMaster().Insert(product); err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Save", "store.sql_product.save.app_error", nil, "id="+product.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = product
+	})
+}
+
+func (s sqlProductStore) Update(product *model.Product) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		product.PreUpdate()
+		if result.Err = product.IsValid(); result.Err != nil {
+			return
+		}
+
+		count, err := s.GetMaster().Update(product)
+		if err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Update", "store.sql_product.update.app_error", nil, "id="+product.Id+", "+err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if count != 1 {
+			result.Err = model.NewAppError("sqlProductStore.Update", "store.sql_product.get.app_error", nil, "id="+product.Id, http.StatusNotFound)
+			return
+		}
+
+		result.Data = product
+	})
+}
+
+func (s sqlProductStore) Get(id string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var product model.Product
+		if err := s.GetReplica().SelectOne(&product, "SELECT * FROM Products WHERE Id = :Id AND DeleteAt = 0", map[string]interface{}{"Id": id}); err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Get", "store.sql_product.get.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &product
+	})
+}
+
+// GetBySku tìm sản phẩm chưa xoá của page theo SKU, dùng để tránh trùng SKU trong cùng một page
+func (s sqlProductStore) GetBySku(pageId string, sku string) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		var product model.Product
+		if err := s.GetReplica().SelectOne(&product, "SELECT * FROM Products WHERE PageId = :PageId AND Sku = :Sku AND DeleteAt = 0", map[string]interface{}{"PageId": pageId, "Sku": sku}); err != nil {
+			result.Err = model.NewAppError("sqlProductStore.GetBySku", "store.sql_product.get.app_error", nil, "page_id="+pageId+", sku="+sku+", "+err.Error(), http.StatusInternalServerError)
+			if err == sql.ErrNoRows {
+				result.Err.StatusCode = http.StatusNotFound
+			}
+			return
+		}
+
+		result.Data = &product
+	})
+}
+
+func (s sqlProductStore) Search(options *model.ProductSearchOptions) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		options.SetDefaults()
+		if err := options.IsValid(); err != nil {
+			result.Err = err
+			return
+		}
+
+		query := s.getQueryBuilder().
+			Select("*").
+			From("Products").
+			Where("PageId = ? AND DeleteAt = 0", options.PageId)
+
+		if len(options.Term) > 0 {
+			term := "%" + strings.ToLower(sanitizeSearchTerm(options.Term, "\\")) + "%"
+			query = query.Where("(LOWER(Name) LIKE ? OR LOWER(Sku) LIKE ?)", term, term)
+		}
+
+		queryString, args, err := query.
+			OrderBy("Name ASC", "Id ASC").
+			Limit(uint64(options.Limit)).
+			Offset(uint64(options.Offset)).
+			ToSql()
+		if err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Search", "store.sql_product.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		products := []*model.Product{}
+		if _, err := s.GetReplica().Select(&products, queryString, args...); err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Search", "store.sql_product.search.app_error", nil, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		result.Data = products
+	})
+}
+
+func (s sqlProductStore) Delete(id string, deleteAt int64) store.StoreChannel {
+	return store.Do(func(result *store.StoreResult) {
+		if _, err := s.GetMaster().Exec("UPDATE Products SET DeleteAt = :DeleteAt, UpdateAt = :DeleteAt WHERE Id = :Id", map[string]interface{}{"Id": id, "DeleteAt": deleteAt}); err != nil {
+			result.Err = model.NewAppError("sqlProductStore.Delete", "store.sql_product.delete.app_error", nil, "id="+id+", "+err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
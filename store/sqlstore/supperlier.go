@@ -101,6 +101,9 @@ type SqlSupplierStores struct {
 	UserTermsOfService   store.UserTermsOfServiceStore
 
 	order 				store.OrderStore
+	orderStatus 		store.OrderStatusStore
+	product 			store.ProductStore
+	customer 			store.CustomerStore
 
 	fanpage              store.FanpageStore
 	fanpageInitResult    store.FanpageInitResultStore
@@ -113,6 +116,10 @@ type SqlSupplierStores struct {
 	conversationNote 	store.ConversationNoteStore
 	facebookUid          store.FacebookUidStore
 	linkMetadata         store.LinkMetadataStore
+	facebookWebhookEvent store.FacebookWebhookEventStore
+	facebookProcessedEvent store.FacebookProcessedEventStore
+	moderationRule       store.ModerationRuleStore
+	conversationRouting  store.ConversationRoutingStore
 }
 
 type SqlSupplier struct {
@@ -199,6 +206,13 @@ func NewSqlSupplier(settings model.SqlSettings, metrics einterfaces.MetricsInter
 	supplier.stores.conversationTag = NewSqlConversationTagStore(supplier)
 	supplier.stores.conversationNote = NewSqlConversationNoteStore(supplier)
 	supplier.stores.linkMetadata = newSqlLinkMetadataStore(supplier)
+	supplier.stores.conversationRouting = NewSqlConversationRoutingStore(supplier)
+	supplier.stores.customer = NewSqlCustomerStore(supplier)
+	supplier.stores.product = NewSqlProductStore(supplier)
+	supplier.stores.orderStatus = NewSqlOrderStatusStore(supplier)
+	supplier.stores.moderationRule = NewSqlModerationRuleStore(supplier)
+	supplier.stores.facebookProcessedEvent = NewSqlFacebookProcessedEventStore(supplier)
+	supplier.stores.facebookWebhookEvent = NewSqlFacebookWebhookEventStore(supplier)
 
 	err := supplier.GetMaster().CreateTablesIfNotExists()
 	if err != nil {
@@ -237,6 +251,13 @@ func NewSqlSupplier(settings model.SqlSettings, metrics einterfaces.MetricsInter
 	supplier.stores.conversationTag.(*sqlConversationTagStore).CreateIndexesIfNotExists()
 	supplier.stores.conversationNote.(*sqlConversationNoteStore).CreateIndexesIfNotExists()
 	supplier.stores.linkMetadata.(*SqlLinkMetadataStore).createIndexesIfNotExists()
+	supplier.stores.conversationRouting.(*sqlConversationRoutingStore).CreateIndexesIfNotExists()
+	supplier.stores.customer.(*sqlCustomerStore).CreateIndexesIfNotExists()
+	supplier.stores.product.(*sqlProductStore).CreateIndexesIfNotExists()
+	supplier.stores.orderStatus.(*sqlOrderStatusStore).CreateIndexesIfNotExists()
+	supplier.stores.moderationRule.(*sqlModerationRuleStore).CreateIndexesIfNotExists()
+	supplier.stores.facebookProcessedEvent.(*sqlFacebookProcessedEventStore).CreateIndexesIfNotExists()
+	supplier.stores.facebookWebhookEvent.(*sqlFacebookWebhookEventStore).CreateIndexesIfNotExists()
 	//supplier.stores.facebookUid.(*sqlFacebookUidStore).CreateIndexesIfNotExists()
 	supplier.stores.preference.(*SqlPreferenceStore).deleteUnusedFeatures()
 	supplier.stores.TermsOfService.(SqlTermsOfServiceStore).createIndexesIfNotExists()
@@ -1125,6 +1146,34 @@ func (ss *SqlSupplier) LinkMetadata() store.LinkMetadataStore {
 	return ss.stores.linkMetadata
 }
 
+func (ss *SqlSupplier) FacebookWebhookEvent() store.FacebookWebhookEventStore {
+	return ss.stores.facebookWebhookEvent
+}
+
+func (ss *SqlSupplier) FacebookProcessedEvent() store.FacebookProcessedEventStore {
+	return ss.stores.facebookProcessedEvent
+}
+
+func (ss *SqlSupplier) ModerationRule() store.ModerationRuleStore {
+	return ss.stores.moderationRule
+}
+
+func (ss *SqlSupplier) OrderStatus() store.OrderStatusStore {
+	return ss.stores.orderStatus
+}
+
+func (ss *SqlSupplier) Product() store.ProductStore {
+	return ss.stores.product
+}
+
+func (ss *SqlSupplier) Customer() store.CustomerStore {
+	return ss.stores.customer
+}
+
+func (ss *SqlSupplier) ConversationRouting() store.ConversationRoutingStore {
+	return ss.stores.conversationRouting
+}
+
 func (ss *SqlSupplier) DropAllTables() {
 	ss.master.TruncateTables()
 }
@@ -1144,6 +1193,15 @@ func (me mattermConverter) ToDb(val interface{}) (interface{}, error) {
 		return model.StringInterfaceToJson(t), nil
 	case map[string]interface{}:
 		return model.StringInterfaceToJson(model.StringInterface(t)), nil
+	case model.OrderItems:
+		b, err := json.Marshal(t)
+		return string(b), err
+	case model.ProductVariants:
+		b, err := json.Marshal(t)
+		return string(b), err
+	case model.RoutingTagRules:
+		b, err := json.Marshal(t)
+		return string(b), err
 	}
 
 	return val, nil
@@ -1201,6 +1259,36 @@ func (me mattermConverter) FromDb(target interface{}) (gorp.CustomScanner, bool)
 			return json.Unmarshal(b, target)
 		}
 		return gorp.CustomScanner{Holder: new(string), Target: target, Binder: binder}, true
+	case *model.OrderItems:
+		binder := func(holder, target interface{}) error {
+			s, ok := holder.(*string)
+			if !ok {
+				return errors.New(utils.T("store.sql.convert_order_items"))
+			}
+			b := []byte(*s)
+			return json.Unmarshal(b, target)
+		}
+		return gorp.CustomScanner{Holder: new(string), Target: target, Binder: binder}, true
+	case *model.ProductVariants:
+		binder := func(holder, target interface{}) error {
+			s, ok := holder.(*string)
+			if !ok {
+				return errors.New(utils.T("store.sql.convert_product_variants"))
+			}
+			b := []byte(*s)
+			return json.Unmarshal(b, target)
+		}
+		return gorp.CustomScanner{Holder: new(string), Target: target, Binder: binder}, true
+	case *model.RoutingTagRules:
+		binder := func(holder, target interface{}) error {
+			s, ok := holder.(*string)
+			if !ok {
+				return errors.New(utils.T("store.sql.convert_routing_tag_rules"))
+			}
+			b := []byte(*s)
+			return json.Unmarshal(b, target)
+		}
+		return gorp.CustomScanner{Holder: new(string), Target: target, Binder: binder}, true
 	}
 
 	return gorp.CustomScanner{}, false
@@ -190,6 +190,7 @@ func upgradeDatabase(sqlStore SqlStore, currentModelVersionString string) error
 	upgradeDatabaseToVersion527(sqlStore)
 	upgradeDatabaseToVersion528(sqlStore)
 	upgradeDatabaseToVersion5281(sqlStore)
+	upgradeDatabaseToPapoSchema(sqlStore)
 
 	return nil
 }
@@ -863,6 +864,124 @@ func upgradeDatabaseToVersion5281(sqlStore SqlStore) {
 	}
 }
 
+// upgradeDatabaseToPapoSchema thêm các cột mới vào những bảng đã có từ trước. Các bước đều bỏ qua cột đã tồn tại
+// nên được chạy ở mọi lần khởi động, không phụ thuộc schema version.
+// MySQL không cho đặt giá trị mặc định cho cột text nên các cột này được điền giá trị cho dòng cũ sau khi thêm.
+func upgradeDatabaseToPapoSchema(sqlStore SqlStore) {
+	// tin nhắn hệ thống từ postback, quick reply, reaction, referral...
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "SystemType", "varchar(32)", "varchar(32)", "")
+	if sqlStore.CreateColumnIfNotExistsNoDefault("FacebookConversationMessages", "Props", "text", "varchar(8000)") {
+		sqlStore.GetMaster().Exec("UPDATE FacebookConversationMessages SET Props = '{}' WHERE Props IS NULL")
+	}
+
+	// page token được mã hoá trước khi lưu nên cần cột dài hơn
+	if sqlStore.GetMaxLengthOfColumnIfExists("FanpageMembers", "AccessToken") == "500" {
+		sqlStore.AlterColumnTypeIfExists("FanpageMembers", "AccessToken", "varchar(1024)", "varchar(1024)")
+	}
+
+	// member kết nối page trước khi có role trên page là admin của page
+	sqlStore.GetMaster().Exec("UPDATE FanpageMembers SET Roles = :Roles WHERE Roles = ''", map[string]interface{}{"Roles": model.FANPAGE_MEMBER_ADMIN_ROLES})
+
+	// outbox: trạng thái gửi và lịch thử lại của tin nhắn gửi từ papo
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "Status", "varchar(16)", "varchar(16)", "")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "SendAttempts", "int", "integer", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "NextAttemptAt", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "SentAt", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "LastError", "varchar(1024)", "varchar(1024)", "")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "ErrorCode", "int", "integer", "0")
+
+	// lọc hội thoại theo số điện thoại và nhân viên phụ trách
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "AssignedTo", "varchar(26)", "varchar(26)", "")
+
+	// trạng thái thích và trả lời riêng của bình luận
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "IsLiked", "tinyint(1)", "boolean", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversationMessages", "PrivateRepliedAt", "bigint", "bigint", "0")
+
+	// file sao lưu từ attachment của tin nhắn và bình luận
+	sqlStore.CreateColumnIfNotExists("FileInfo", "PageId", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("FileInfo", "MessageId", "varchar(26)", "varchar(26)", "")
+	if sqlStore.CreateColumnIfNotExistsNoDefault("FileInfo", "SourceUrl", "text", "varchar(2048)") {
+		sqlStore.GetMaster().Exec("UPDATE FileInfo SET SourceUrl = '' WHERE SourceUrl IS NULL")
+	}
+
+	// đơn hàng gắn với page, hội thoại và danh mục sản phẩm
+	if sqlStore.GetMaxLengthOfColumnIfExists("Orders", "CustomerName") == "255" {
+		sqlStore.AlterColumnTypeIfExists("Orders", "CustomerName", "text", "varchar(512)")
+	}
+	sqlStore.CreateColumnIfNotExists("Orders", "PageId", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("Orders", "ConversationId", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("Orders", "FacebookUid", "varchar(64)", "varchar(64)", "")
+	sqlStore.CreateColumnIfNotExists("Orders", "CustomerPhone", "varchar(32)", "varchar(32)", "")
+	if sqlStore.CreateColumnIfNotExistsNoDefault("Orders", "CustomerAddress", "text", "varchar(2048)") {
+		sqlStore.GetMaster().Exec("UPDATE Orders SET CustomerAddress = '' WHERE CustomerAddress IS NULL")
+	}
+	if sqlStore.CreateColumnIfNotExistsNoDefault("Orders", "Items", "text", "varchar(65535)") {
+		sqlStore.GetMaster().Exec("UPDATE Orders SET Items = '[]' WHERE Items IS NULL")
+	}
+	sqlStore.CreateColumnIfNotExists("Orders", "Subtotal", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("Orders", "Discount", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("Orders", "ShippingFee", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("Orders", "Total", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("Orders", "StatusId", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("Orders", "AssignedTo", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("Orders", "CreatorId", "varchar(26)", "varchar(26)", "")
+	if sqlStore.CreateColumnIfNotExistsNoDefault("Orders", "Notes", "text", "varchar(8000)") {
+		sqlStore.GetMaster().Exec("UPDATE Orders SET Notes = '' WHERE Notes IS NULL")
+	}
+	sqlStore.CreateColumnIfNotExists("Orders", "UpdateAt", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("Orders", "DeleteAt", "bigint", "bigint", "0")
+
+	// người giao và thời điểm giao hội thoại
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "AssignedBy", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "AssignedAt", "bigint", "bigint", "0")
+
+	// trạng thái xử lý và hẹn giờ mở lại hội thoại
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "Status", "varchar(16)", "varchar(16)", "")
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "SnoozedUntil", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "StatusUpdateAt", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("FacebookConversations", "StatusUpdatedBy", "varchar(26)", "varchar(26)", "")
+
+	// chiến dịch gửi tin nhắn tự động chạy bằng job
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "Tag", "varchar(32)", "varchar(32)", "")
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "JobId", "varchar(26)", "varchar(26)", "")
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "SentCount", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "FailedCount", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "SkippedCount", "bigint", "bigint", "0")
+	sqlStore.CreateColumnIfNotExists("AutoMessageTasks", "QueuedCount", "bigint", "bigint", "0")
+
+	// UpdatedTime của hội thoại có nhiều định dạng chuỗi, lưu thêm dạng milliseconds để sắp xếp và phân trang
+	if sqlStore.CreateColumnIfNotExists("FacebookConversations", "LastActivityAt", "bigint", "bigint", "0") {
+		var conversations []*model.FacebookConversation
+		if _, err := sqlStore.GetMaster().Select(&conversations, "SELECT Id, UpdatedTime FROM FacebookConversations WHERE UpdatedTime <> ''"); err != nil {
+			mlog.Error("Failed to backfill conversation LastActivityAt", mlog.Err(err))
+		}
+		for _, conversation := range conversations {
+			sqlStore.GetMaster().Exec("UPDATE FacebookConversations SET LastActivityAt = :LastActivityAt WHERE Id = :Id", map[string]interface{}{"Id": conversation.Id, "LastActivityAt": model.ParseFacebookTime(conversation.UpdatedTime)})
+		}
+	}
+
+	// HasPhone chỉ được đánh dấu khi có tin nhắn mới, quét lại tin nhắn cũ của khách hàng để lọc được cả các hội thoại trước đó
+	if sqlStore.CreateColumnIfNotExists("FacebookConversations", "HasPhone", "tinyint(1)", "boolean", "0") {
+		fromColumn := "`From`"
+		if sqlStore.DriverName() == model.DATABASE_DRIVER_POSTGRES {
+			fromColumn = "\"from\""
+		}
+
+		var messages []*model.FacebookConversationMessage
+		if _, err := sqlStore.GetMaster().Select(&messages, "SELECT ConversationId, Message FROM FacebookConversationMessages WHERE Message <> '' AND "+fromColumn+" <> PageId"); err != nil {
+			mlog.Error("Failed to backfill conversation HasPhone", mlog.Err(err))
+		}
+		marked := make(map[string]bool)
+		for _, message := range messages {
+			if marked[message.ConversationId] || !model.ContainsPhoneNumber(message.Message) {
+				continue
+			}
+			marked[message.ConversationId] = true
+			sqlStore.GetMaster().Exec("UPDATE FacebookConversations SET HasPhone = true WHERE Id = :Id", map[string]interface{}{"Id": message.ConversationId})
+		}
+	}
+}
+
 func precheckMigrationToVersion528(sqlStore SqlStore) error {
 	teamsQuery, _, err := sqlStore.getQueryBuilder().Select(`COALESCE(SUM(CASE
 				WHEN CHAR_LENGTH(SchemeId) > 26 THEN 1
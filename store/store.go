@@ -54,6 +54,13 @@ type Store interface {
 	Session() SessionStore
 	Status() StatusStore
 	Webhook() WebhookStore
+	ConversationRouting() ConversationRoutingStore
+	Customer() CustomerStore
+	Product() ProductStore
+	OrderStatus() OrderStatusStore
+	ModerationRule() ModerationRuleStore
+	FacebookProcessedEvent() FacebookProcessedEventStore
+	FacebookWebhookEvent() FacebookWebhookEventStore
 	Close()
 	DropAllTables()
 	RecycleDBConnections(d time.Duration)
@@ -99,8 +106,46 @@ type PluginStore interface {
 }
 
 type OrderStore interface {
-	Save(order *model.Order) (*model.Order, error)
-	GetOrders(limit, offset int) ([]*model.Order, error)
+	Save(order *model.Order) StoreChannel
+	Update(order *model.Order) StoreChannel
+	Get(id string) StoreChannel
+	Delete(id string, deleteAt int64) StoreChannel
+	Search(options *model.OrderSearchOptions) StoreChannel
+	CountByStatusId(statusId string) StoreChannel
+	GetByFacebookUids(facebookUids []string, limit int) StoreChannel
+	GetFacebookUidsWithOrders(pageId string, facebookUids []string) StoreChannel
+}
+
+type OrderStatusStore interface {
+	Save(status *model.OrderStatus) StoreChannel
+	Update(status *model.OrderStatus) StoreChannel
+	Get(id string) StoreChannel
+	GetByPageId(pageId string) StoreChannel
+	ClearDefault(pageId string, exceptId string) StoreChannel
+	Delete(id string, deleteAt int64) StoreChannel
+}
+
+type CustomerStore interface {
+	Save(customer *model.CustomerRecord) StoreChannel
+	Update(customer *model.CustomerRecord) StoreChannel
+	Get(id string) StoreChannel
+	Delete(id string, deleteAt int64) StoreChannel
+	SaveIdentity(identity *model.CustomerIdentity) StoreChannel
+	GetIdentity(facebookUid string) StoreChannel
+	GetIdentityByPageScopeId(pageId string, pageScopeId string) StoreChannel
+	GetIdentities(customerId string) StoreChannel
+	UpdateIdentityPhones(facebookUid string, phones model.StringArray) StoreChannel
+	MoveIdentities(facebookUids []string, customerId string) StoreChannel
+	MoveAllIdentities(fromCustomerId string, toCustomerId string) StoreChannel
+}
+
+type ProductStore interface {
+	Save(product *model.Product) StoreChannel
+	Update(product *model.Product) StoreChannel
+	Get(id string) StoreChannel
+	GetBySku(pageId string, sku string) StoreChannel
+	Search(options *model.ProductSearchOptions) StoreChannel
+	Delete(id string, deleteAt int64) StoreChannel
 }
 
 type LicenseStore interface {
@@ -175,6 +220,7 @@ type FileInfoStore interface {
 	GetWithOptions(page, perPage int, opt *model.GetFileInfosOptions) ([]*model.FileInfo, error)
 	GetForPage(pageId string, readFromMaster bool, allowFromCache bool, offset, limit int) ([]*model.FileInfo, error)
 	AttachToMessage(fileId string, messageId string, creatorId string) (*model.FileInfo, error)
+	GetForMessage(messageId string) ([]*model.FileInfo, error)
 	InvalidateFileInfosForPageCache(pageId string)
 	AttachToPost(fileId string, postId string, creatorId string) error
 	DeleteForPost(postId string) (string, error)
@@ -185,12 +231,18 @@ type FileInfoStore interface {
 }
 
 type AutoMessageTaskStore interface {
-	Save(messageTask *model.AutoMessageTask) (*model.AutoMessageTask, error)
-	Update(messageTask *model.AutoMessageTask) (*model.AutoMessageTask, error)
-	GetByPageId(pageId string) ([]*model.AutoMessageTask, error)
-	Start(taskId string) (*model.AutoMessageTask, error)
-	Pause(taskId string) (*model.AutoMessageTask, error)
-	Delete(taskId string) (*model.AutoMessageTask, error)
+	Save(messageTask *model.AutoMessageTask) StoreChannel
+	Update(messageTask *model.AutoMessageTask) StoreChannel
+	Get(taskId string) StoreChannel
+	GetByPageId(pageId string) StoreChannel
+	UpdateStatus(taskId string, currentStatuses []string, status string, jobId string) StoreChannel
+	IncrementCounts(taskId string, sent, failed, skipped, queued int64) StoreChannel
+	Finish(taskId string, status string, endAt int64) StoreChannel
+	Delete(taskId string, deleteAt int64) StoreChannel
+	SaveRecipient(recipient *model.AutoMessageTaskRecipient) StoreChannel
+	UpdateRecipient(recipient *model.AutoMessageTaskRecipient) StoreChannel
+	GetRecipientConversationIds(taskId string, conversationIds []string) StoreChannel
+	GetRecipients(taskId string, status string, offset, limit int) StoreChannel
 }
 
 type PageReplySnippetStore interface {
@@ -213,9 +265,15 @@ type FacebookConversationStore interface {
 	AddMessage(message *model.FacebookConversationMessage, shouldUpdateConversation bool, isFromPage bool) StoreChannel
 	AddImage(image *model.FacebookAttachmentImage) StoreChannel
 	GetMessagesByConversationId(conversationId string, offset, limit int) StoreChannel
-	GetConversations(pageIds string, offset, limit int) StoreChannel
 	GetConversationById(id string) StoreChannel
-	Search(term string, pageIds string, offset, limit int) StoreChannel
+	Search(options *model.ConversationSearchOptions) StoreChannel
+	SetHasPhone(conversationId string) StoreChannel
+	GetBySenderIds(senderIds []string, limit int) StoreChannel
+	UpdateAssignee(conversationId, assignedTo, assignedBy string, assignedAt int64) StoreChannel
+	AssignIfUnassigned(conversationId, assignedTo string, assignedAt int64) StoreChannel
+	CountUnrepliedByAssignee(pageId string, userIds []string) StoreChannel
+	UpdateStatus(conversationId, oldStatus, status string, snoozedUntil int64, updatedBy string, updateAt int64) StoreChannel
+	GetSnoozedDue(now int64, limit int) StoreChannel
 	UpsertCommentConversation(conversation *model.FacebookConversation) StoreChannel
 	UpdatePageScopeId(conversationId, pageScopeId string) StoreChannel
 	UpdateLatestTime(conversationId string, time string, commentId string) StoreChannel
@@ -230,10 +288,21 @@ type FacebookConversationStore interface {
 	UpdateConversation(conversationId string, snippet string, isFromPage bool, updatedTime string, unreadCount int, lastUserMessageAt string) StoreChannel
 	UpdateConversationUnread(conversationId string, isFromPage bool, unreadCount int, lastUserMessageAt string) StoreChannel
 	GetFacebookAttachmentByIds(userIds []string, allowFromCache bool) StoreChannel
+	GetAttachmentPageIds(ids []string) StoreChannel
 	UpdateMessageSent(messageId string) StoreChannel
 	UpdateReadWatermark(conversationId, pageId string, timestamp int64) StoreChannel
 	UpdateCommentByCommentId(commentId, newText string) StoreChannel
-	DeleteCommentByCommentId(commentId, appScopedUserId string) StoreChannel
+	DeleteCommentByCommentId(commentId, deletedBy string) StoreChannel
+	GetCommentByCommentId(commentId string) StoreChannel
+	UpdateCommentHidden(commentId string, isHidden bool, userId string) StoreChannel
+	UpdateCommentLiked(commentId string, isLiked bool, userId string) StoreChannel
+	UpdateCommentPrivateReplied(commentId string, userId string, repliedAt int64) StoreChannel
+	GetOutboxMessage(id string) StoreChannel
+	GetDueOutboxMessages(now int64, limit int) StoreChannel
+	ClaimOutboxMessage(id string, nextAttemptAt int64, leaseUntil int64) StoreChannel
+	UpdateOutboxMessage(message *model.FacebookConversationMessage, expectedStatus string) StoreChannel
+	UpdateMessagesRead(conversationId string, watermark int64) StoreChannel
+	UpdateMessageFileIds(id string, fileIds []string) StoreChannel
 }
 
 type FanpageStore interface {
@@ -246,10 +315,15 @@ type FanpageStore interface {
 	GetMember(teamId string, userId string) StoreChannel
 	GetMemberByPageId(pageId string, userId string) StoreChannel
 	SaveFanPageMember(member *model.FanpageMember) StoreChannel
+	GetMembers(pageId string) StoreChannel
+	UpdateMemberRoles(pageId string, userId string, roles string) StoreChannel
+	RemoveMember(pageId string, userId string) StoreChannel
 	GetFanpagesByUserId(userId string) StoreChannel
 	GetFanpageByPageID(pageId string) StoreChannel
-	GetOneFanPageMember(fanpageId string) StoreChannel
+	GetFanpagesByStatus(status string) StoreChannel
+	GetOwnerMember(pageId string) StoreChannel
 	GetAllPageMembersForUser(userId string, allowFromCache bool, includeDeleted bool) StoreChannel
+	InvalidateAllPageMembersForUser(userId string)
 	//GetAllFanpages(offset int, limit int) StoreChannel
 	//Delete(fanpageId string) StoreChannel
 	//UpdateStatus(newStatus string) StoreChannel
@@ -265,6 +339,38 @@ type FacebookUidStore interface {
 	UpdatePageScopeId(id, pageScopeId string) StoreChannel
 }
 
+type FacebookWebhookEventStore interface {
+	Save(event *model.FacebookWebhookEvent) StoreChannel
+	Get(id string) StoreChannel
+	Update(event *model.FacebookWebhookEvent) StoreChannel
+	Claim(id string) StoreChannel
+	GetDue(now int64, staleBefore int64, limit int) StoreChannel
+	GetSince(since int64, status string, offset, limit int) StoreChannel
+	ResetForReplay(since int64) StoreChannel
+}
+
+type FacebookProcessedEventStore interface {
+	Claim(pageId, eventKey string) StoreChannel
+	Complete(pageId, eventKey string) StoreChannel
+	Release(pageId, eventKey string) StoreChannel
+	ReleaseProcessedBetween(start, end int64) StoreChannel
+	PermanentDeleteBefore(createAt int64) StoreChannel
+}
+
+type ModerationRuleStore interface {
+	Save(rule *model.ModerationRule) StoreChannel
+	Update(rule *model.ModerationRule) StoreChannel
+	Get(id string) StoreChannel
+	GetByPageId(pageId string) StoreChannel
+	Delete(id string, deleteAt int64) StoreChannel
+}
+
+type ConversationRoutingStore interface {
+	Save(routing *model.ConversationRouting) StoreChannel
+	Get(pageId string) StoreChannel
+	UpdateLastAssignedTo(pageId string, userId string) StoreChannel
+}
+
 type PreferenceStore interface {
 	//Save(preferences *model.Preferences) StoreChannel
 	//Get(userId string, category string, name string) StoreChannel
@@ -428,10 +428,10 @@ func (s *TimerLayerAuditStore) Save(audit *model.Audit) error {
 	return err
 }
 
-func (s *TimerLayerAutoMessageTaskStore) Delete(taskId string) StoreChannel {
+func (s *TimerLayerAutoMessageTaskStore) Delete(taskId string, deleteAt int64) StoreChannel {
 	start := timemodule.Now()
 
-	result := s.AutoMessageTaskStore.Delete(taskId)
+	result := s.AutoMessageTaskStore.Delete(taskId, deleteAt)
 
 	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
 	if s.Root.Metrics != nil {
@@ -460,22 +460,6 @@ func (s *TimerLayerAutoMessageTaskStore) GetByPageId(pageId string) StoreChannel
 	return result
 }
 
-func (s *TimerLayerAutoMessageTaskStore) Pause(taskId string) StoreChannel {
-	start := timemodule.Now()
-
-	result := s.AutoMessageTaskStore.Pause(taskId)
-
-	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
-	if s.Root.Metrics != nil {
-		success := "false"
-		if true {
-			success = "true"
-		}
-		s.Root.Metrics.ObserveStoreMethodDuration("AutoMessageTaskStore.Pause", success, elapsed)
-	}
-	return result
-}
-
 func (s *TimerLayerAutoMessageTaskStore) Save(messageTask *model.AutoMessageTask) StoreChannel {
 	start := timemodule.Now()
 
@@ -492,22 +476,6 @@ func (s *TimerLayerAutoMessageTaskStore) Save(messageTask *model.AutoMessageTask
 	return result
 }
 
-func (s *TimerLayerAutoMessageTaskStore) Start(taskId string) StoreChannel {
-	start := timemodule.Now()
-
-	result := s.AutoMessageTaskStore.Start(taskId)
-
-	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
-	if s.Root.Metrics != nil {
-		success := "false"
-		if true {
-			success = "true"
-		}
-		s.Root.Metrics.ObserveStoreMethodDuration("AutoMessageTaskStore.Start", success, elapsed)
-	}
-	return result
-}
-
 func (s *TimerLayerAutoMessageTaskStore) Update(messageTask *model.AutoMessageTask) StoreChannel {
 	start := timemodule.Now()
 
@@ -1036,10 +1004,10 @@ func (s *TimerLayerFacebookConversationStore) AnalyticsConversationCountsByDay(p
 	return result
 }
 
-func (s *TimerLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, appScopedUserId string) StoreChannel {
+func (s *TimerLayerFacebookConversationStore) DeleteCommentByCommentId(commentId string, deletedBy string) StoreChannel {
 	start := timemodule.Now()
 
-	result := s.FacebookConversationStore.DeleteCommentByCommentId(commentId, appScopedUserId)
+	result := s.FacebookConversationStore.DeleteCommentByCommentId(commentId, deletedBy)
 
 	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
 	if s.Root.Metrics != nil {
@@ -1084,22 +1052,6 @@ func (s *TimerLayerFacebookConversationStore) GetConversationById(id string) Sto
 	return result
 }
 
-func (s *TimerLayerFacebookConversationStore) GetConversations(pageIds string, offset int, limit int) StoreChannel {
-	start := timemodule.Now()
-
-	result := s.FacebookConversationStore.GetConversations(pageIds, offset, limit)
-
-	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
-	if s.Root.Metrics != nil {
-		success := "false"
-		if true {
-			success = "true"
-		}
-		s.Root.Metrics.ObserveStoreMethodDuration("FacebookConversationStore.GetConversations", success, elapsed)
-	}
-	return result
-}
-
 func (s *TimerLayerFacebookConversationStore) GetFacebookAttachmentByIds(userIds []string, allowFromCache bool) StoreChannel {
 	start := timemodule.Now()
 
@@ -1228,10 +1180,10 @@ func (s *TimerLayerFacebookConversationStore) Save(conversation *model.FacebookC
 	return result
 }
 
-func (s *TimerLayerFacebookConversationStore) Search(term string, pageIds string, offset int, limit int) StoreChannel {
+func (s *TimerLayerFacebookConversationStore) Search(options *model.ConversationSearchOptions) StoreChannel {
 	start := timemodule.Now()
 
-	result := s.FacebookConversationStore.Search(term, pageIds, offset, limit)
+	result := s.FacebookConversationStore.Search(options)
 
 	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
 	if s.Root.Metrics != nil {
@@ -1644,10 +1596,10 @@ func (s *TimerLayerFanpageStore) GetMemberByPageId(pageId string, userId string)
 	return result
 }
 
-func (s *TimerLayerFanpageStore) GetOneFanPageMember(fanpageId string) StoreChannel {
+func (s *TimerLayerFanpageStore) GetOwnerMember(pageId string) StoreChannel {
 	start := timemodule.Now()
 
-	result := s.FanpageStore.GetOneFanPageMember(fanpageId)
+	result := s.FanpageStore.GetOwnerMember(pageId)
 
 	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
 	if s.Root.Metrics != nil {
@@ -1655,7 +1607,7 @@ func (s *TimerLayerFanpageStore) GetOneFanPageMember(fanpageId string) StoreChan
 		if true {
 			success = "true"
 		}
-		s.Root.Metrics.ObserveStoreMethodDuration("FanpageStore.GetOneFanPageMember", success, elapsed)
+		s.Root.Metrics.ObserveStoreMethodDuration("FanpageStore.GetOwnerMember", success, elapsed)
 	}
 	return result
 }
@@ -2682,22 +2634,6 @@ func (s *TimerLayerOAuthStore) UpdateApp(app *model.OAuthApp) (*model.OAuthApp,
 	return result, err
 }
 
-func (s *TimerLayerOrderStore) GetOrders(limit int, offset int) StoreChannel {
-	start := timemodule.Now()
-
-	result := s.OrderStore.GetOrders(limit, offset)
-
-	elapsed := float64(timemodule.Since(start)) / float64(timemodule.Second)
-	if s.Root.Metrics != nil {
-		success := "false"
-		if true {
-			success = "true"
-		}
-		s.Root.Metrics.ObserveStoreMethodDuration("OrderStore.GetOrders", success, elapsed)
-	}
-	return result
-}
-
 func (s *TimerLayerOrderStore) Save(order *model.Order) StoreChannel {
 	start := timemodule.Now()
 
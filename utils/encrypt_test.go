@@ -0,0 +1,63 @@
+// Copyright (c) 2018-present Papo. All Rights Reserved.
+// See LICENSE.txt for license information.
+
+package utils
+
+import (
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncryptStringRoundTrip(t *testing.T) {
+	encrypted, err := EncryptString("key", "page-token")
+	require.Nil(t, err)
+	assert.True(t, IsEncrypted(encrypted))
+	assert.NotContains(t, encrypted, "page-token")
+
+	decrypted, err := DecryptString("key", encrypted)
+	require.Nil(t, err)
+	assert.Equal(t, "page-token", decrypted)
+
+	// mỗi lần mã hoá dùng nonce khác nhau
+	other, err := EncryptString("key", "page-token")
+	require.Nil(t, err)
+	assert.NotEqual(t, encrypted, other)
+
+	// dữ liệu cũ chưa mã hoá được trả về nguyên vẹn
+	plain, err := DecryptString("key", "legacy-token")
+	require.Nil(t, err)
+	assert.Equal(t, "legacy-token", plain)
+
+	_, err = EncryptString("", "page-token")
+	assert.NotNil(t, err)
+}
+
+func TestDecryptStringWrongKey(t *testing.T) {
+	encrypted, err := EncryptString("key", "page-token")
+	require.Nil(t, err)
+
+	_, err = DecryptString("other-key", encrypted)
+	assert.NotNil(t, err)
+}
+
+func TestDecryptStringTampered(t *testing.T) {
+	encrypted, err := EncryptString("key", "page-token")
+	require.Nil(t, err)
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(encrypted, ENCRYPTED_PREFIX))
+	require.Nil(t, err)
+	sealed[len(sealed)-1] ^= 0xff
+
+	_, err = DecryptString("key", ENCRYPTED_PREFIX+base64.StdEncoding.EncodeToString(sealed))
+	assert.NotNil(t, err)
+
+	_, err = DecryptString("key", ENCRYPTED_PREFIX+base64.StdEncoding.EncodeToString(sealed[:4]))
+	assert.NotNil(t, err)
+
+	_, err = DecryptString("key", ENCRYPTED_PREFIX+"not base64")
+	assert.NotNil(t, err)
+}
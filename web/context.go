@@ -349,17 +349,83 @@ func (c *Context) RequireCommentId() *Context {
 	return c
 }
 
+func (c *Context) RequireRuleId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.RuleId) != 26 {
+		c.SetInvalidUrlParam("rule_id")
+	}
+	return c
+}
+
+func (c *Context) RequireTaskId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.TaskId) != 26 {
+		c.SetInvalidUrlParam("task_id")
+	}
+	return c
+}
+
 func (c *Context) RequireOrderId() *Context {
 	if c.Err != nil {
 		return c
 	}
 
-	if len(c.Params.OrderId) == 0 {
+	if len(c.Params.OrderId) != 26 {
 		c.SetInvalidUrlParam("order_id")
 	}
 	return c
 }
 
+func (c *Context) RequireOrderStatusId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.OrderStatusId) != 26 {
+		c.SetInvalidUrlParam("order_status_id")
+	}
+	return c
+}
+
+func (c *Context) RequireProductId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.ProductId) != 26 {
+		c.SetInvalidUrlParam("product_id")
+	}
+	return c
+}
+
+func (c *Context) RequireCustomerId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.CustomerId) != 26 {
+		c.SetInvalidUrlParam("customer_id")
+	}
+	return c
+}
+
+func (c *Context) RequireEventId() *Context {
+	if c.Err != nil {
+		return c
+	}
+
+	if len(c.Params.EventId) != 26 {
+		c.SetInvalidUrlParam("event_id")
+	}
+	return c
+}
+
 func (c *Context) RequireNoteId() *Context {
 	if c.Err != nil {
 		return c
@@ -63,6 +63,12 @@ type Params struct {
 	MessageId 	   string
 	CommentId 	   string
 	OrderId 	   string
+	OrderStatusId  string
+	ProductId 	   string
+	CustomerId 	   string
+	EventId 	   string
+	RuleId 		   string
+	TaskId 		   string
 }
 
 func ParamsFromRequest(r *http.Request) *Params {
@@ -227,10 +233,30 @@ func ParamsFromRequest(r *http.Request) *Params {
 		params.CommentId = val
 	}
 
+	if val, ok := props["rule_id"]; ok {
+		params.RuleId = val
+	}
+
+	if val, ok := props["task_id"]; ok {
+		params.TaskId = val
+	}
+
 	if val, ok := props["order_id"]; ok {
 		params.OrderId = val
 	}
 
+	if val, ok := props["order_status_id"]; ok {
+		params.OrderStatusId = val
+	}
+
+	if val, ok := props["product_id"]; ok {
+		params.ProductId = val
+	}
+
+	if val, ok := props["customer_id"]; ok {
+		params.CustomerId = val
+	}
+
 	if val, ok := props["conversation_id"]; ok {
 		params.ConversationId = val
 	}
@@ -251,5 +277,9 @@ func ParamsFromRequest(r *http.Request) *Params {
 		params.Status = val
 	}
 
+	if val, ok := props["event_id"]; ok {
+		params.EventId = val
+	}
+
 	return params
 }
@@ -7,7 +7,6 @@ import (
 	"bitbucket.org/enesyteam/papo-server/facebook_graph"
 	"bitbucket.org/enesyteam/papo-server/mlog"
 	"bitbucket.org/enesyteam/papo-server/model"
-	"encoding/json"
 	"fmt"
 	"github.com/gorilla/mux"
 	"github.com/gorilla/schema"
@@ -39,25 +38,48 @@ func verifyWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
 }
 
 func handleFacebookWebhook(c *Context, w http.ResponseWriter, r *http.Request) {
-	var z *facebookgraph.HubEntries
-	x, _ := ioutil.ReadAll(r.Body)
-	json.Unmarshal(x, &z)
-
-	if &z.Entry[0] != nil {
-		err := c.App.HandleFacebookWebhook(z)
-		if err == nil {
-			w.WriteHeader(http.StatusOK)
-			return
-		} else {
-			fmt.Println("================error when receive wehbook" + err.Message + err.DetailedError)
-			w.WriteHeader(http.StatusBadRequest)
-			return
-		}
-	} else {
-		// return status 200 for other webhook that we don't handle
-		w.WriteHeader(http.StatusOK)
+	body, err := ioutil.ReadAll(r.Body)
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	if appErr := verifyFacebookWebhookSignature(c, r, body); appErr != nil {
+		c.LogAudit("fail - " + appErr.Id)
+		c.Err = appErr
+		return
+	}
+
+	// chỉ lưu payload rồi trả về ngay, việc xử lý do FacebookWebhookQueue đảm nhận để Facebook không bị timeout
+	if _, appErr := c.App.EnqueueFacebookWebhook(body); appErr != nil {
+		c.Err = appErr
 		return
 	}
+
+	w.WriteHeader(http.StatusOK)
+}
+
+// verifyFacebookWebhookSignature kiểm tra request thực sự đến từ Facebook bằng header X-Hub-Signature-256
+func verifyFacebookWebhookSignature(c *Context, r *http.Request, body []byte) *model.AppError {
+	settings := c.App.Config().FacebookSettings
+	if !*settings.EnableWebhookSignatureVerification {
+		return nil
+	}
+
+	if len(*settings.Secret) == 0 {
+		return model.NewAppError("handleFacebookWebhook", "web.facebook_webhook.missing_app_secret.app_error", nil, "", http.StatusInternalServerError)
+	}
+
+	signature := r.Header.Get(facebookgraph.WEBHOOK_SIGNATURE_HEADER)
+	if len(signature) == 0 {
+		return model.NewAppError("handleFacebookWebhook", "web.facebook_webhook.missing_signature.app_error", nil, "", http.StatusUnauthorized)
+	}
+
+	if !facebookgraph.VerifyWebhookSignature(*settings.Secret, body, signature) {
+		return model.NewAppError("handleFacebookWebhook", "web.facebook_webhook.invalid_signature.app_error", nil, "", http.StatusForbidden)
+	}
+
+	return nil
 }
 
 func incomingWebhook(c *Context, w http.ResponseWriter, r *http.Request) {